@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/acme/autocert"
+)
+
+var (
+	flagACMEDomain = flag.String("acme-domain", "", "serve HTTPS with certificates from Let's Encrypt for these comma-separated domains (needs port 80 for the HTTP-01 challenge)")
+	flagACMEEmail  = flag.String("acme-email", "", "contact address for the ACME account, told about expiring certificates")
+	flagACMECache  = flag.String("acme-cache", "acme-certs", "directory keeping the ACME account key and certificates")
+)
+
+var (
+	acmeOnce    sync.Once
+	acmeManager *autocert.Manager
+)
+
+// certManager sets up the certificate manager on first use and starts the
+// port 80 listener that answers HTTP-01 challenges and sends everything
+// else to HTTPS.
+func certManager() *autocert.Manager {
+	acmeOnce.Do(func() {
+		var domains []string
+		for _, d := range strings.Split(*flagACMEDomain, ",") {
+			if d = strings.TrimSpace(d); d != "" {
+				domains = append(domains, d)
+			}
+		}
+		acmeManager = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(domains...),
+			Cache:      autocert.DirCache(*flagACMECache),
+			Email:      *flagACMEEmail,
+		}
+		go serveACMEChallenges(domains)
+	})
+	return acmeManager
+}
+
+// serveACMEChallenges answers HTTP-01 challenges on port 80 of -bind, or of
+// every interface when -bind is a Unix socket since the CA comes over TCP.
+// Like the game's listeners it goes to the new process on an upgrade.
+func serveACMEChallenges(domains []string) {
+	ln, err := listenShared("acme", func() (net.Listener, error) {
+		host := *flagBind
+		if unixSocket() != "" {
+			host = ""
+		}
+		return net.Listen("tcp", net.JoinHostPort(host, "80"))
+	})
+	if err != nil {
+		log.Printf("ACME challenge listener failed, certificates cannot be renewed: %v", err)
+		return
+	}
+	srv := &http.Server{Handler: acmeManager.HTTPHandler(nil)}
+	onHandoff(func() { shutdownServer(srv.Shutdown, srv.Close) })
+	log.Printf("Answering ACME challenges on port 80 for %s", strings.Join(domains, ", "))
+	if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("ACME challenge listener failed, certificates cannot be renewed: %v", err)
+	}
+}
+
+// listenAndServe serves h on port (on -bind), over HTTPS with automatic
+// certificates when -acme-domain is set, with client addresses from
+// -trusted-proxies, -allow and -deny, and the -cors-origins policy.
+func listenAndServe(port string, h http.Handler) error {
+	ln, err := listenOn(port)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: behindProxies(filterClients(withCORS(h)))}
+	onHandoff(func() { shutdownServer(srv.Shutdown, srv.Close) })
+	if *flagACMEDomain == "" {
+		err = srv.Serve(ln)
+	} else {
+		srv.TLSConfig = certManager().TLSConfig()
+		err = srv.ServeTLS(ln, "", "")
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
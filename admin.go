@@ -0,0 +1,89 @@
+package main
+
+import (
+	"crypto/subtle"
+	"flag"
+	"net/http"
+	"strings"
+)
+
+var flagAdminToken = flag.String("admin-token", "", "token with full admin rights on admin endpoints (empty disables it)")
+
+// credential extracts the token or API key a request presents, from
+// "Authorization: Bearer ...", X-API-Key / X-Admin-Token or the key query
+// parameter (browsers cannot set headers on WebSocket requests).
+func credential(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if k := r.Header.Get("X-API-Key"); k != "" {
+		return k
+	}
+	if k := r.Header.Get("X-Admin-Token"); k != "" {
+		return k
+	}
+	return r.URL.Query().Get("key")
+}
+
+// authConfigured reports whether any credential could possibly be valid.
+func authConfigured() bool {
+	return *flagAdminToken != "" || apiKeyCount() > 0
+}
+
+// authorize returns who is behind the request's credential and whether it
+// grants scope.
+func authorize(r *http.Request, scope string) (string, bool) {
+	return authorizeCredential(credential(r), scope)
+}
+
+// authorizeCredential is authorize for a credential from elsewhere, like
+// gRPC metadata.
+func authorizeCredential(cred, scope string) (string, bool) {
+	if cred == "" {
+		return "", false
+	}
+	if *flagAdminToken != "" && subtle.ConstantTimeCompare([]byte(cred), []byte(*flagAdminToken)) == 1 {
+		return "admin", true
+	}
+	k := lookupAPIKey(cred)
+	if k == nil {
+		return "", false
+	}
+	return k.Name, k.hasScope(scope)
+}
+
+// requireScope only lets requests through whose credential grants scope.
+func requireScope(scope string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authConfigured() {
+			http.Error(w, "this endpoint needs an admin token or API key, none are configured (see -admin-token)", http.StatusForbidden)
+			return
+		}
+		if credential(r) == "" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if _, ok := authorize(r, scope); !ok {
+			http.Error(w, "forbidden: missing scope "+scope, http.StatusForbidden)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// requireScopeIfConfigured protects endpoints that used to be open: without
+// any credentials configured they stay public.
+func requireScopeIfConfigured(scope string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authConfigured() {
+			h(w, r)
+			return
+		}
+		requireScope(scope, h)(w, r)
+	}
+}
+
+// requireAdmin only lets requests through that carry admin rights.
+func requireAdmin(h http.HandlerFunc) http.HandlerFunc {
+	return requireScope(scopeAdmin, h)
+}
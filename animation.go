@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// Bounds of /replays/{id}/animation, which runs on public requests: the
+// side of the image in pixels, the number of frames, the pixels of all
+// frames together and how many animations are rendered at once. A longer
+// round is cut off.
+const (
+	maxAnimationPixels = 1024
+	maxAnimationFrames = 3000
+	maxAnimationTotal  = 64 << 20
+	animationRenders   = 2
+)
+
+var animationSlots = make(chan struct{}, animationRenders)
+
+// handleReplayAnimation serves GET /replays/{id}/animation: the replay as
+// an animated GIF, to post in chat. Query parameters: speed (default 4),
+// fps (default 10), cell (pixels per cell) and a crop in cells as x, y, w
+// and h.
+func handleReplayAnimation(w http.ResponseWriter, r *http.Request) {
+	select {
+	case animationSlots <- struct{}{}:
+		defer func() { <-animationSlots }()
+	default:
+		w.Header().Set("Retry-After", "5")
+		http.Error(w, "busy rendering other animations, try again shortly", http.StatusServiceUnavailable)
+		return
+	}
+	events := replayFor(w, r)
+	if events == nil {
+		return
+	}
+	grid := events[0].Maze
+	if len(grid) == 0 || len(grid[0]) == 0 {
+		http.Error(w, "the replay has no maze", http.StatusUnprocessableEntity)
+		return
+	}
+	mh, mw := len(grid), len(grid[0])
+	x, y := queryInt(r, "x", 0, 0, mw-1), queryInt(r, "y", 0, 0, mh-1)
+	crop := image.Rect(x, y, x+queryInt(r, "w", mw, 1, mw), y+queryInt(r, "h", mh, 1, mh)).Intersect(image.Rect(0, 0, mw, mh))
+	if max(crop.Dx(), crop.Dy()) > maxAnimationPixels {
+		http.Error(w, fmt.Sprintf("the maze is too large to animate whole, crop it to %d cells a side with x, y, w and h", maxAnimationPixels), http.StatusBadRequest)
+		return
+	}
+	cell := queryInt(r, "cell", 4, 1, 16)
+	cell = max(1, min(cell, maxAnimationPixels/max(crop.Dx(), crop.Dy())))
+	speed, err := strconv.ParseFloat(r.URL.Query().Get("speed"), 64)
+	if err != nil {
+		speed = 4
+	}
+	fps := queryInt(r, "fps", 10, 1, 25)
+
+	anim := renderReplay(events, crop, cell, clampSpeed(speed), fps)
+	w.Header().Set("Content-Type", "image/gif")
+	w.Header().Set("Cache-Control", "public, max-age=86400") // replays do not change
+	if err := gif.EncodeAll(w, anim); err != nil {
+		log.Printf("Could not send replay animation: %v", err)
+	}
+}
+
+// renderReplay draws the replayed round seen through crop. The first frame
+// is the whole crop; the others only cover the cells that changed, drawn
+// over the previous frame.
+func renderReplay(events []replayEvent, crop image.Rectangle, cell int, speed float64, fps int) *gif.GIF {
+	start := events[0]
+	grid := make([][]int, len(start.Maze))
+	for y := range start.Maze {
+		grid[y] = append([]int(nil), start.Maze[y]...)
+	}
+	goal := point{-1, -1}
+	if start.Goal != nil {
+		goal = *start.Goal
+	}
+	pal := newPaletteBuilder(exportBackground, exportGoal)
+
+	players := map[int]replayEvent{} // by player number, 0 the minotaur
+	at := map[point]int{}            // players standing on a cell
+	dirty := map[point]bool{}
+	place := func(id int, on bool) {
+		p, ok := players[id]
+		if !ok {
+			return
+		}
+		c := point{p.X, p.Y}
+		if on {
+			at[c]++
+		} else if at[c]--; at[c] <= 0 {
+			delete(at, c)
+		}
+		dirty[c] = true
+	}
+	// look is the color of a cell: the last player to step on it, or the
+	// cell itself.
+	look := func(c point) uint8 {
+		if at[c] > 0 {
+			for id, p := range players {
+				if p.X == c.X && p.Y == c.Y {
+					col := parseHexColor(p.Color, color.RGBA{0xe7, 0x4c, 0x3c, 255})
+					if id == 0 {
+						col = parseHexColor(minotaurPlayer().Color, col)
+					}
+					return pal.of(col)
+				}
+			}
+		}
+		if c == goal {
+			return 1
+		}
+		return pal.of(cellColor(grid[c.Y][c.X]))
+	}
+	paint := func(img *image.Paletted, c point) {
+		i := look(c)
+		x, y := (c.X-crop.Min.X)*cell, (c.Y-crop.Min.Y)*cell
+		for py := y; py < y+cell; py++ {
+			for px := x; px < x+cell; px++ {
+				img.SetColorIndex(px, py, i)
+			}
+		}
+	}
+
+	anim := &gif.GIF{Config: image.Config{Width: crop.Dx() * cell, Height: crop.Dy() * cell}}
+	step := max(1, int64(speed*1000/float64(fps))) // replay milliseconds per frame
+	delay := 100 / fps                             // hundredths of a second
+	total := 0
+	for t, i := int64(0), 0; i < len(events) && len(anim.Image) < maxAnimationFrames && total < maxAnimationTotal; t += step {
+		for ; i < len(events) && events[i].T <= t; i++ {
+			e := events[i]
+			switch e.E {
+			case "join":
+				place(e.P, false)
+				players[e.P] = e
+				place(e.P, true)
+			case "move":
+				place(e.P, false)
+				p := players[e.P]
+				p.X, p.Y = e.X, e.Y
+				players[e.P] = p
+				place(e.P, true)
+			case "finish", "leave":
+				place(e.P, false)
+				delete(players, e.P)
+			case "cells":
+				for _, c := range e.Changes {
+					grid[c.Y][c.X] = c.Cell
+					dirty[point{c.X, c.Y}] = true
+				}
+			}
+		}
+		if len(anim.Image) == 0 {
+			img := image.NewPaletted(image.Rect(0, 0, anim.Config.Width, anim.Config.Height), nil)
+			for y := crop.Min.Y; y < crop.Max.Y; y++ {
+				for x := crop.Min.X; x < crop.Max.X; x++ {
+					paint(img, point{x, y})
+				}
+			}
+			anim.Image, anim.Delay = append(anim.Image, img), append(anim.Delay, delay)
+			total += len(img.Pix)
+			clear(dirty)
+			continue
+		}
+		var changed image.Rectangle
+		for c := range dirty {
+			if (image.Point{c.X, c.Y}).In(crop) {
+				changed = changed.Union(image.Rect(c.X, c.Y, c.X+1, c.Y+1))
+			}
+		}
+		if changed.Empty() {
+			anim.Delay[len(anim.Delay)-1] += delay // nothing moved, hold the last frame longer
+			clear(dirty)
+			continue
+		}
+		// The frame covers every cell between the changed ones, so those
+		// are drawn again too.
+		bounds := changed.Sub(crop.Min)
+		img := image.NewPaletted(image.Rect(bounds.Min.X*cell, bounds.Min.Y*cell, bounds.Max.X*cell, bounds.Max.Y*cell), nil)
+		total += len(img.Pix)
+		for y := changed.Min.Y; y < changed.Max.Y; y++ {
+			for x := changed.Min.X; x < changed.Max.X; x++ {
+				paint(img, point{x, y})
+			}
+		}
+		anim.Image, anim.Delay = append(anim.Image, img), append(anim.Delay, delay)
+		clear(dirty)
+	}
+	anim.Delay[len(anim.Delay)-1] += 200 // rest on the final positions before looping
+	for _, img := range anim.Image {
+		img.Palette = pal.pal
+	}
+	return anim
+}
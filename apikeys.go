@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+var flagAPIKeys = flag.String("api-keys", "api-keys.json", "file holding the issued API keys (hashed)")
+
+// API key scopes. Broader scopes include the narrower ones.
+const (
+	scopeReadState   = "read-state"
+	scopeManageRooms = "manage-rooms"
+	scopeAdmin       = "admin"
+)
+
+var scopeRank = map[string]int{scopeReadState: 1, scopeManageRooms: 2, scopeAdmin: 3}
+
+// APIKey is an issued key. Only the SHA-256 of the secret is kept.
+type APIKey struct {
+	Name    string    `json:"name"`
+	Hash    string    `json:"hash"`
+	Scopes  []string  `json:"scopes"`
+	Created time.Time `json:"created"`
+}
+
+// hasScope reports whether k grants scope. A scope nobody knows is never
+// granted.
+func (k *APIKey) hasScope(scope string) bool {
+	need, ok := scopeRank[scope]
+	if !ok {
+		return false
+	}
+	for _, s := range k.Scopes {
+		if scopeRank[s] >= need {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	apiKeysMu sync.Mutex
+	apiKeys   []APIKey
+)
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func loadAPIKeys(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+	if err := json.Unmarshal(data, &apiKeys); err != nil {
+		return err
+	}
+	log.Printf("Loaded %d API key(s) from %s", len(apiKeys), path)
+	return nil
+}
+
+// saveAPIKeys writes the key list; the caller holds apiKeysMu.
+func saveAPIKeys() error {
+	data, err := json.MarshalIndent(apiKeys, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*flagAPIKeys, data, 0600)
+}
+
+func apiKeyCount() int {
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+	return len(apiKeys)
+}
+
+func lookupAPIKey(key string) *APIKey {
+	h := hashAPIKey(key)
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+	for i := range apiKeys {
+		if apiKeys[i].Hash == h {
+			k := apiKeys[i]
+			return &k
+		}
+	}
+	return nil
+}
+
+// handleAPIKeys lists (GET), issues (POST {"name","scopes"}) and revokes
+// (DELETE ?name=) API keys. A new key's secret is only shown once.
+func handleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	actor := actorOf(r)
+	apiKeysMu.Lock()
+	defer apiKeysMu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		type listed struct {
+			Name    string    `json:"name"`
+			Scopes  []string  `json:"scopes"`
+			Created time.Time `json:"created"`
+		}
+		list := []listed{}
+		for _, k := range apiKeys {
+			list = append(list, listed{k.Name, k.Scopes, k.Created})
+		}
+		json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		var req struct {
+			Name   string   `json:"name"`
+			Scopes []string `json:"scopes"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" || len(req.Scopes) == 0 {
+			http.Error(w, `want {"name": "...", "scopes": ["read-state"|"manage-rooms"|"admin"]}`, http.StatusBadRequest)
+			return
+		}
+		for _, s := range req.Scopes {
+			if scopeRank[s] == 0 {
+				http.Error(w, "unknown scope "+s, http.StatusBadRequest)
+				return
+			}
+		}
+		if slices.ContainsFunc(apiKeys, func(k APIKey) bool { return k.Name == req.Name }) {
+			http.Error(w, "a key named "+req.Name+" already exists", http.StatusConflict)
+			return
+		}
+		buf := make([]byte, 24)
+		rand.Read(buf)
+		secret := "mr_" + hex.EncodeToString(buf)
+		apiKeys = append(apiKeys, APIKey{Name: req.Name, Hash: hashAPIKey(secret), Scopes: req.Scopes, Created: time.Now()})
+		if err := saveAPIKeys(); err != nil {
+			log.Printf("Saving API keys failed: %v", err)
+		}
+		log.Printf("API key %q issued with scopes %v", req.Name, req.Scopes)
+		auditBy(actor, r, "api-key-issue", map[string]any{"name": req.Name, "scopes": req.Scopes})
+		json.NewEncoder(w).Encode(map[string]any{"name": req.Name, "key": secret, "scopes": req.Scopes})
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		n := len(apiKeys)
+		apiKeys = slices.DeleteFunc(apiKeys, func(k APIKey) bool { return k.Name == name })
+		if len(apiKeys) == n {
+			http.Error(w, "no such key", http.StatusNotFound)
+			return
+		}
+		if err := saveAPIKeys(); err != nil {
+			log.Printf("Saving API keys failed: %v", err)
+		}
+		log.Printf("API key %q revoked", name)
+		auditBy(actor, r, "api-key-revoke", map[string]any{"name": name})
+		json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import "testing"
+
+func TestAPIKeyScopes(t *testing.T) {
+	for _, tc := range []struct {
+		scopes []string
+		want   map[string]bool
+	}{
+		{[]string{scopeReadState}, map[string]bool{scopeReadState: true, scopeManageRooms: false, scopeAdmin: false}},
+		{[]string{scopeManageRooms}, map[string]bool{scopeReadState: true, scopeManageRooms: true, scopeAdmin: false}},
+		{[]string{scopeAdmin}, map[string]bool{scopeReadState: true, scopeManageRooms: true, scopeAdmin: true}},
+		{[]string{scopeReadState, scopeAdmin}, map[string]bool{scopeReadState: true, scopeManageRooms: true, scopeAdmin: true}},
+		{nil, map[string]bool{scopeReadState: false, scopeManageRooms: false, scopeAdmin: false}},
+		// A scope from a hand-edited key file grants nothing.
+		{[]string{"superuser"}, map[string]bool{scopeReadState: false, scopeManageRooms: false, scopeAdmin: false}},
+		// Nor is a scope nobody knows granted to anyone.
+		{[]string{scopeAdmin}, map[string]bool{"": false, "write-state": false}},
+	} {
+		k := APIKey{Name: "test", Scopes: tc.scopes}
+		for scope, want := range tc.want {
+			if got := k.hasScope(scope); got != want {
+				t.Errorf("key with %v: hasScope(%q) = %v, want %v", tc.scopes, scope, got, want)
+			}
+		}
+	}
+}
+
+func TestAuthorizeCredential(t *testing.T) {
+	defer func(token string, keys []APIKey) { *flagAdminToken, apiKeys = token, keys }(*flagAdminToken, apiKeys)
+	*flagAdminToken = "admin-secret"
+	apiKeys = []APIKey{
+		{Name: "overlay", Hash: hashAPIKey("mr_overlay"), Scopes: []string{scopeReadState}},
+		{Name: "rooms", Hash: hashAPIKey("mr_rooms"), Scopes: []string{scopeManageRooms}},
+	}
+	for _, tc := range []struct {
+		cred, scope string
+		name        string
+		ok          bool
+	}{
+		{"admin-secret", scopeAdmin, "admin", true},
+		{"mr_overlay", scopeReadState, "overlay", true},
+		{"mr_overlay", scopeManageRooms, "overlay", false},
+		{"mr_rooms", scopeManageRooms, "rooms", true},
+		{"mr_rooms", scopeAdmin, "rooms", false},
+		{"mr_unknown", scopeReadState, "", false},
+		{"", scopeReadState, "", false},
+		{"admin-secre", scopeReadState, "", false},
+	} {
+		name, ok := authorizeCredential(tc.cred, tc.scope)
+		if name != tc.name || ok != tc.ok {
+			t.Errorf("authorizeCredential(%q, %q) = %q, %v, want %q, %v", tc.cred, tc.scope, name, ok, tc.name, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"time"
+)
+
+var (
+	flagAssist          = flag.Bool("assist", false, "help players trailing the field in family games: shorter mud and freeze penalties and a free hint now and then; assisted results are flagged and kept off the leaderboard")
+	flagAssistHintEvery = flag.Duration("assist-hint-every", 45*time.Second, "how often a trailing player gets a free hint with -assist (0 for none)")
+)
+
+// assistPenalty scales the mud and freeze penalties of a trailing player.
+const assistPenalty = 0.5
+
+// assistMessage tells a player whether it is being helped, so the client
+// holds it in mud and freezes for as long as the server does.
+type assistMessage struct {
+	Type    string  `json:"type"` // "assist"
+	On      bool    `json:"on"`
+	Penalty float64 `json:"penalty"` // factor for mud and freeze times
+}
+
+// trailing reports whether p is in the last third of the field by distance
+// to the goal, finishers counting as ahead. Caller holds mu.
+func trailing(p *Player) bool {
+	if !*flagAssist || p.Finished || p.Eliminated || p.Distance < 0 {
+		return false
+	}
+	field, rank := 0, 1
+	for _, o := range clients {
+		if o.Eliminated {
+			continue
+		}
+		field++
+		if o != p && (o.Finished || o.Distance >= 0 && o.Distance < p.Distance) {
+			rank++
+		}
+	}
+	return field >= 2 && rank > field-max(1, field/3)
+}
+
+// penaltyFor shortens a mud or freeze penalty for a trailing player and
+// flags its result as assisted. Caller holds mu.
+func penaltyFor(p *Player, d time.Duration) time.Duration {
+	if !p.assisting {
+		return d
+	}
+	p.Assisted = true
+	p.dirty = true
+	return time.Duration(float64(d) * assistPenalty)
+}
+
+// runAssist checks every few seconds who is trailing, tells players when
+// that changes and hands out the free hints.
+func runAssist() {
+	for range time.Tick(2 * time.Second) {
+		mu.Lock()
+		now := clock()
+		for _, p := range clients {
+			if on := trailing(p); on != p.assisting {
+				p.assisting = on
+				if p.caps.has(capPrivate) {
+					data, _ := json.Marshal(assistMessage{Type: "assist", On: on, Penalty: assistPenalty})
+					p.send(data)
+				}
+				if on {
+					// The first free hint comes a full interval later.
+					p.lastAssist = now
+				}
+			}
+			if !p.assisting || *flagAssistHintEvery <= 0 || now.Sub(p.lastAssist) < *flagAssistHintEvery {
+				continue
+			}
+			path := shortestPath(maze, point{p.X, p.Y}, nextTarget(p))
+			if len(path) < 2 {
+				continue
+			}
+			path = path[1:min(len(path), *flagHintCells+1)]
+			p.lastAssist = now
+			p.Assisted = true
+			p.dirty = true
+			log.Printf("ASSIST: free hint for %s", p.Name)
+			data, _ := json.Marshal(hintReply{Type: "hint", Path: path, Remaining: max(0, *flagHints-p.HintsUsed), Assist: true})
+			p.send(data)
+		}
+		mu.Unlock()
+	}
+}
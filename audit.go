@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var flagAuditLog = flag.String("audit-log", "audit.log", "file recording every admin action as JSON lines, apart from the server log (empty disables it)")
+
+// auditKeep is how many recent entries /admin/audit can show.
+const auditKeep = 500
+
+// AuditEntry is one administrative action.
+type AuditEntry struct {
+	Time   time.Time      `json:"time"`
+	Actor  string         `json:"actor"` // API key name, "admin", or where an unauthenticated call came from
+	Action string         `json:"action"`
+	Remote string         `json:"remote,omitempty"`
+	Params map[string]any `json:"params,omitempty"`
+}
+
+var (
+	auditMu     sync.Mutex
+	auditFile   *os.File
+	auditRecent []AuditEntry
+)
+
+func openAuditLog(path string) error {
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	auditFile = f
+	return nil
+}
+
+// actorOf names who is behind an HTTP request for the audit log.
+func actorOf(r *http.Request) string {
+	return actorFor(credential(r))
+}
+
+func actorFor(cred string) string {
+	if cred == "" {
+		return "anonymous"
+	}
+	if name, _ := authorizeCredential(cred, scopeReadState); name != "" {
+		return name
+	}
+	return "unknown credential"
+}
+
+// audit records an action taken through an HTTP request.
+func audit(r *http.Request, action string, params map[string]any) {
+	auditBy(actorOf(r), r, action, params)
+}
+
+// auditBy is audit for callers holding apiKeysMu, who have to look up the
+// actor beforehand.
+func auditBy(actor string, r *http.Request, action string, params map[string]any) {
+	recordAudit(AuditEntry{Actor: actor, Action: action, Remote: r.RemoteAddr, Params: params})
+}
+
+func recordAudit(e AuditEntry) {
+	e.Time = time.Now().UTC()
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	if len(auditRecent) == auditKeep {
+		auditRecent = auditRecent[1:]
+	}
+	auditRecent = append(auditRecent, e)
+	if auditFile == nil {
+		return
+	}
+	data, _ := json.Marshal(e)
+	if _, err := auditFile.Write(append(data, '\n')); err != nil {
+		log.Printf("Audit log write failed: %v", err)
+	}
+}
+
+// handleAudit serves GET /admin/audit?limit=N: the latest actions, newest
+// first.
+func handleAudit(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = min(n, auditKeep)
+	}
+	auditMu.Lock()
+	list := make([]AuditEntry, 0, min(limit, len(auditRecent)))
+	for i := len(auditRecent) - 1; i >= 0 && len(list) < limit; i-- {
+		list = append(list, auditRecent[i])
+	}
+	auditMu.Unlock()
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var flagBans = flag.String("bans", "bans.db", "SQLite database keeping banned addresses and players, managed through /admin/bans (empty disables)")
+
+// Ban keeps an address or a player out until it expires or is lifted. A
+// kick is a ban that expires soon.
+type Ban struct {
+	ID      int64      `json:"id"`
+	IP      string     `json:"ip,omitempty"`
+	Player  string     `json:"player,omitempty"` // public player id, as in /players/{id}/stats
+	Reason  string     `json:"reason,omitempty"`
+	By      string     `json:"by"`
+	Created time.Time  `json:"created"`
+	Expires *time.Time `json:"expires,omitempty"` // nil for a ban that lasts until lifted
+}
+
+// BanStore keeps bans. AddBan sets the ban's ID. ActiveBan returns a ban in
+// force at now for the address or the player, nil without an error if there
+// is none; an empty ip or player matches nothing. Bans lists the bans in
+// force, newest first. LiftBan reports whether the ban existed.
+type BanStore interface {
+	AddBan(b *Ban) error
+	ActiveBan(ip, player string, now time.Time) (*Ban, error)
+	Bans(now time.Time) ([]Ban, error)
+	LiftBan(id int64) (bool, error)
+	Close() error
+}
+
+var bans BanStore // nil when disabled
+
+func openBans(path string) error {
+	if path == "" {
+		return nil
+	}
+	s, err := openSQLiteBans(path)
+	if err != nil {
+		return err
+	}
+	bans = s
+	return nil
+}
+
+// clientIP is the address a request came from, behind trusted proxies the
+// player's own. It is empty if unknown, as on a Unix socket.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return ""
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return ""
+	}
+	return addr.Unmap().String()
+}
+
+// activeBan looks up the ban keeping ip or player out. A failing store
+// lets everyone in rather than locking the game.
+func activeBan(ip, player string) *Ban {
+	if bans == nil || (ip == "" && player == "") {
+		return nil
+	}
+	b, err := bans.ActiveBan(ip, player, time.Now())
+	if err != nil {
+		log.Printf("Ban lookup failed: %v", err)
+		return nil
+	}
+	return b
+}
+
+// refuseBanned answers a request from a banned address or, with a player
+// token or login, a banned player and reports whether it did.
+func refuseBanned(w http.ResponseWriter, r *http.Request) bool {
+	b := activeBan(clientIP(r), verifiedPlayer(r))
+	if b == nil {
+		return false
+	}
+	msg := "banned from this server"
+	if b.Reason != "" {
+		msg += ": " + b.Reason
+	}
+	http.Error(w, msg, http.StatusForbidden)
+	return true
+}
+
+// bannedMessage tells a player whose account is banned why the connection
+// closes.
+type bannedMessage struct {
+	Type    string     `json:"type"`
+	Reason  string     `json:"reason,omitempty"`
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// kickBanned closes the connections b keeps out; the usual disconnect
+// handling removes the players.
+func kickBanned(b *Ban) int {
+	kicked := map[*websocket.Conn]*Player{}
+	mu.Lock()
+	for conn, p := range clients {
+		if (b.IP != "" && clientIP(conn.Request()) == b.IP) || (b.Player != "" && p.profile == b.Player) {
+			kicked[conn] = p
+		}
+	}
+	mu.Unlock()
+	for conn, p := range kicked {
+		sendBanned(conn, p, b)
+	}
+	return len(kicked)
+}
+
+// sendBanned tells the player about b and closes the connection.
+func sendBanned(ws *websocket.Conn, p *Player, b *Ban) {
+	data, _ := json.Marshal(bannedMessage{Type: "banned", Reason: b.Reason, Expires: b.Expires})
+	p.send(data)
+	p.out.flush(time.Second)
+	ws.Close()
+}
+
+// handleBans lists (GET), adds (POST {"ip", "player", "reason", "duration"})
+// and lifts (DELETE ?id=) bans. A new ban closes the connections it covers
+// at once; without a duration it lasts until lifted.
+func handleBans(w http.ResponseWriter, r *http.Request) {
+	if bans == nil {
+		http.Error(w, "bans are disabled (see -bans)", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		list, err := bans.Bans(time.Now())
+		if err != nil {
+			log.Printf("Listing bans failed: %v", err)
+			http.Error(w, "could not load bans", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		var req struct {
+			IP       string `json:"ip"`
+			Player   string `json:"player"`
+			Reason   string `json:"reason"`
+			Duration string `json:"duration"` // as in "30m" or "72h"
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || (req.IP == "") == (req.Player == "") {
+			http.Error(w, `want {"ip": "..."} or {"player": "..."}, with optional "reason" and "duration"`, http.StatusBadRequest)
+			return
+		}
+		b := &Ban{Player: req.Player, Reason: req.Reason, By: actorOf(r), Created: time.Now().UTC()}
+		if req.IP != "" {
+			addr, err := netip.ParseAddr(req.IP)
+			if err != nil {
+				http.Error(w, "invalid ip", http.StatusBadRequest)
+				return
+			}
+			b.IP = addr.Unmap().String()
+		}
+		if req.Player != "" && profiles == nil && !jwtEnabled() && len(loginProviders()) == 0 {
+			http.Error(w, "player bans need player accounts (see -profiles, -jwt-secret or -github-client-id)", http.StatusBadRequest)
+			return
+		}
+		if req.Duration != "" {
+			d, err := time.ParseDuration(req.Duration)
+			if err != nil || d <= 0 {
+				http.Error(w, "invalid duration", http.StatusBadRequest)
+				return
+			}
+			at := b.Created.Add(d)
+			b.Expires = &at
+		}
+		if err := bans.AddBan(b); err != nil {
+			log.Printf("Saving ban failed: %v", err)
+			http.Error(w, "could not save ban", http.StatusInternalServerError)
+			return
+		}
+		kicked := kickBanned(b)
+		log.Printf("Ban %d on %s%s added, %d connection(s) closed", b.ID, b.IP, b.Player, kicked)
+		audit(r, "ban", map[string]any{"id": b.ID, "ip": b.IP, "player": b.Player, "reason": b.Reason, "duration": req.Duration, "kicked": kicked})
+		json.NewEncoder(w).Encode(b)
+	case http.MethodDelete:
+		id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invalid id", http.StatusBadRequest)
+			return
+		}
+		ok, err := bans.LiftBan(id)
+		if err != nil {
+			log.Printf("Lifting ban failed: %v", err)
+			http.Error(w, "could not lift ban", http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.Error(w, "no such ban", http.StatusNotFound)
+			return
+		}
+		log.Printf("Ban %d lifted", id)
+		audit(r, "ban-lift", map[string]any{"id": id})
+		json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
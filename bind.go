@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+)
+
+var flagBind = flag.String("bind", "", "interface address to listen on, e.g. 127.0.0.1, or unix:/path/to.sock for a Unix socket (default all interfaces)")
+
+// unixSocket is the socket path from -bind, empty when listening on TCP.
+func unixSocket() string {
+	path, ok := strings.CutPrefix(*flagBind, "unix:")
+	if !ok {
+		return ""
+	}
+	return path
+}
+
+// listenOn opens the listener for port according to -bind, or takes over
+// the one handed down by an upgrade. A Unix socket left behind by an
+// earlier run is replaced.
+func listenOn(port string) (net.Listener, error) {
+	return listenShared(port, func() (net.Listener, error) {
+		path := unixSocket()
+		if path == "" {
+			return net.Listen("tcp", net.JoinHostPort(*flagBind, port))
+		}
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	mrand "math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+var flagCardSecret = flag.String("card-secret", "", "HMAC secret for signing result cards (random per start if empty, which invalidates old card links)")
+
+var cardKey []byte
+
+// cardPayload is everything a result card shows. It travels inside the
+// signed token, so cards need no server-side storage.
+type cardPayload struct {
+	Name   string `json:"n"`
+	Color  string `json:"c"`
+	Rank   int    `json:"r"`
+	Time   int64  `json:"t"`
+	Seed   int64  `json:"s,omitempty"`
+	Width  int    `json:"w"`
+	Height int    `json:"h"`
+	Date   int64  `json:"d"`
+}
+
+func initCardKey() {
+	if *flagCardSecret != "" {
+		cardKey = []byte(*flagCardSecret)
+		return
+	}
+	cardKey = make([]byte, 32)
+	rand.Read(cardKey)
+	log.Println("No -card-secret set, result card links will stop verifying after a restart")
+}
+
+func signCard(payload string) string {
+	mac := hmac.New(sha256.New, cardKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// cardURL returns the shareable link for a finished player of the current round.
+func cardURL(p Player) string {
+	data, _ := json.Marshal(cardPayload{
+		Name: p.Name, Color: p.Color, Rank: p.FinishRank, Time: p.FinishTime,
+		Seed: mazeSeed, Width: mazeWidth, Height: mazeHeight, Date: time.Now().Unix(),
+	})
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return "/cards/" + payload + "." + signCard(payload) + ".png"
+}
+
+func verifyCard(token string) (*cardPayload, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signCard(payload))) {
+		return nil, errors.New("invalid signature")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, err
+	}
+	var c cardPayload
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// handleCard serves /cards/{token}.png as an image and /cards/{token}.json
+// as the verified card data.
+func handleCard(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/cards/")
+	i := strings.LastIndex(name, ".")
+	if i < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	token, ext := name[:i], name[i+1:]
+	card, err := verifyCard(token)
+	if err != nil {
+		http.Error(w, "result card could not be verified", http.StatusNotFound)
+		return
+	}
+	switch ext {
+	case "json":
+		json.NewEncoder(w).Encode(map[string]any{"verified": true, "card": card})
+	case "png":
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		png.Encode(w, renderCard(card))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func parseHexColor(s string, fallback color.RGBA) color.RGBA {
+	s = strings.TrimPrefix(s, "#")
+	if len(s) != 6 {
+		return fallback
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return fallback
+	}
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}
+}
+
+// drawText writes s with the 7x13 bitmap font, blown up by scale.
+func drawText(dst *image.RGBA, x, y int, s string, col color.Color, scale int) {
+	face := basicfont.Face7x13
+	width := font.MeasureString(face, s).Ceil()
+	tmp := image.NewAlpha(image.Rect(0, 0, width, 13))
+	d := font.Drawer{Dst: tmp, Src: image.Opaque, Face: face, Dot: fixed.P(0, 11)}
+	d.DrawString(s)
+	src := image.NewUniform(col)
+	for ty := 0; ty < 13; ty++ {
+		for tx := 0; tx < width; tx++ {
+			if tmp.AlphaAt(tx, ty).A > 127 {
+				draw.Draw(dst, image.Rect(x+tx*scale, y+ty*scale, x+(tx+1)*scale, y+(ty+1)*scale), src, image.Point{}, draw.Src)
+			}
+		}
+	}
+}
+
+func fillRect(dst *image.RGBA, r image.Rectangle, col color.Color) {
+	draw.Draw(dst, r, image.NewUniform(col), image.Point{}, draw.Src)
+}
+
+// truncate shortens s to at most n characters, never splitting one.
+func truncate(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+func renderCard(c *cardPayload) *image.RGBA {
+	const cw, ch = 600, 315
+	img := image.NewRGBA(image.Rect(0, 0, cw, ch))
+	fillRect(img, img.Bounds(), color.RGBA{0x1a, 0x1a, 0x1a, 255})
+	playerCol := parseHexColor(c.Color, color.RGBA{0x4a, 0x9e, 0xff, 255})
+	fillRect(img, image.Rect(0, 0, 12, ch), playerCol)
+
+	drawText(img, 36, 28, "MAZE RUNNER", color.RGBA{0x66, 0x66, 0x66, 255}, 2)
+	drawText(img, 36, 72, truncate(c.Name, 14), color.RGBA{0xe8, 0xe8, 0xe8, 255}, 4)
+	drawText(img, 36, 140, "RANK #"+strconv.Itoa(c.Rank), color.RGBA{0xd4, 0xaa, 0x00, 255}, 3)
+	ts := strconv.FormatInt(c.Time/60, 10) + ":" + strconv.FormatInt(c.Time%60/10, 10) + strconv.FormatInt(c.Time%10, 10)
+	drawText(img, 36, 190, "TIME  "+ts, color.RGBA{0xcc, 0xcc, 0xcc, 255}, 3)
+	footer := strconv.Itoa(c.Width) + "x" + strconv.Itoa(c.Height) + "  " + time.Unix(c.Date, 0).UTC().Format("2006-01-02")
+	drawText(img, 36, 270, footer, color.RGBA{0x55, 0x55, 0x55, 255}, 2)
+
+	// The thumbnail is rebuilt from the seed; uploaded mazes have none.
+	if c.Seed != 0 && validateMazeSize(c.Width, c.Height) == nil && c.Width*c.Height <= 401*401 {
+		grid, gx, gy := buildMaze(c.Width, c.Height, mrand.New(mrand.NewSource(c.Seed)))
+		area := image.Rect(330, 28, cw-28, ch-28)
+		cell := max(1, min(area.Dx()/c.Width, area.Dy()/c.Height))
+		ox := area.Min.X + (area.Dx()-cell*c.Width)/2
+		oy := area.Min.Y + (area.Dy()-cell*c.Height)/2
+		wall := color.RGBA{0x35, 0x35, 0x40, 255}
+		for y, row := range grid {
+			for x, v := range row {
+				if v == 1 {
+					fillRect(img, image.Rect(ox+x*cell, oy+y*cell, ox+(x+1)*cell, oy+(y+1)*cell), wall)
+				}
+			}
+		}
+		fillRect(img, image.Rect(ox+gx*cell, oy+gy*cell, ox+(gx+1)*cell, oy+(gy+1)*cell), color.RGBA{0xd4, 0xaa, 0x00, 255})
+	}
+	return img
+}
@@ -0,0 +1,48 @@
+package main
+
+import "flag"
+
+var flagCheckpoints = flag.Int("checkpoints", 0, "checkpoint race: number of ordered checkpoints on the solution path that must be passed before the goal counts (0 disables)")
+
+var checkpoints []point // in the order they have to be passed
+
+// placeCheckpoints spreads n checkpoints evenly over the shortest path from
+// start to goal, leaving out both ends. Short paths get fewer checkpoints.
+func placeCheckpoints(grid [][]int, start, goal point, n int) []point {
+	if n <= 0 {
+		return nil
+	}
+	path := shortestPath(grid, start, goal)
+	if len(path) < 3 {
+		return nil
+	}
+	inner := path[1 : len(path)-1]
+	if n > len(inner) {
+		n = len(inner)
+	}
+	cps := make([]point, n)
+	for i := range cps {
+		cps[i] = inner[(i+1)*len(inner)/(n+1)]
+	}
+	return cps
+}
+
+// passCheckpoint advances p's checkpoint progress if it stands on the next
+// one. Checkpoints passed out of order do not count. Caller holds mu.
+func passCheckpoint(p *Player) {
+	if p.Checkpoint < len(checkpoints) && checkpoints[p.Checkpoint] == (point{p.X, p.Y}) {
+		p.Checkpoint++
+	}
+}
+
+// nextTarget is where p has to go next: its next checkpoint, the closest
+// goal it has not collected yet, or the goal.
+func nextTarget(p *Player) point {
+	if p.Checkpoint < len(checkpoints) {
+		return checkpoints[p.Checkpoint]
+	}
+	if g, ok := nearestUncollected(p); ok {
+		return g
+	}
+	return point{goalX, goalY}
+}
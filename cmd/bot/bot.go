@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Cell values of /maze, as in the server's terrain.go.
+const (
+	cellWall  = 1
+	cellEast  = 2
+	cellSouth = 3
+	cellWest  = 4
+	cellNorth = 5
+)
+
+var oneWayDirs = map[int]point{
+	cellEast:  {1, 0},
+	cellSouth: {0, 1},
+	cellWest:  {-1, 0},
+	cellNorth: {0, -1},
+}
+
+type point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func (p point) add(d point) point { return point{p.X + d.X, p.Y + d.Y} }
+
+// serverMessage covers the fields of every server message a bot reads.
+type serverMessage struct {
+	Type    string `json:"type"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	Name    string `json:"name"`
+	Message string `json:"message"`
+	Changes []struct {
+		X    int `json:"x"`
+		Y    int `json:"y"`
+		Cell int `json:"cell"`
+	} `json:"changes"`
+}
+
+// mazeInfo is the part of /info a bot needs.
+type mazeInfo struct {
+	GoalX       int     `json:"goalX"`
+	GoalY       int     `json:"goalY"`
+	Checkpoints []point `json:"checkpoints"`
+	Goals       []point `json:"goals"`
+}
+
+type bot struct {
+	server   string
+	name     string
+	color    string
+	strategy Strategy
+	interval time.Duration
+
+	ws      *websocket.Conn
+	maze    [][]int
+	pos     point
+	targets []point // cells still to visit, in order; the last is the goal
+	playing bool
+}
+
+// canStep mirrors the server's rule: floor on both sides, and one-way cells
+// only left or entered in their direction.
+func (b *bot) canStep(from, to point) bool {
+	for _, c := range [2]point{from, to} {
+		if c.Y < 0 || c.Y >= len(b.maze) || c.X < 0 || c.X >= len(b.maze[c.Y]) || b.maze[c.Y][c.X] == cellWall {
+			return false
+		}
+		if d, ok := oneWayDirs[b.maze[c.Y][c.X]]; ok && d != (point{to.X - from.X, to.Y - from.Y}) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bot) getJSON(path string, v any) error {
+	resp, err := http.Get(b.server + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// loadRound fetches the maze and where the bot has to go.
+func (b *bot) loadRound() error {
+	var info mazeInfo
+	if err := b.getJSON("/info", &info); err != nil {
+		return err
+	}
+	if err := b.getJSON("/maze", &b.maze); err != nil {
+		return err
+	}
+	b.targets = append([]point{}, info.Checkpoints...)
+	if len(info.Goals) > 1 {
+		b.targets = append(b.targets, info.Goals[1:]...)
+	}
+	b.targets = append(b.targets, point{info.GoalX, info.GoalY})
+	b.strategy.Reset(b)
+	return nil
+}
+
+func (b *bot) dial() error {
+	u, err := url.Parse(b.server)
+	if err != nil {
+		return err
+	}
+	origin := u.String()
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	u.Path = "/ws"
+	if *flagPassword != "" {
+		u.RawQuery = url.Values{"password": {*flagPassword}}.Encode()
+	}
+	b.ws, err = websocket.Dial(u.String(), "", origin)
+	return err
+}
+
+func (b *bot) send(v any) error {
+	return websocket.JSON.Send(b.ws, v)
+}
+
+// run plays until the connection breaks.
+func (b *bot) run() error {
+	if err := b.dial(); err != nil {
+		return err
+	}
+	defer b.ws.Close()
+	if err := b.send(map[string]any{"type": "hello", "protocol": 1, "caps": []string{"events", "private"}}); err != nil {
+		return err
+	}
+
+	msgs := make(chan serverMessage)
+	errc := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			var m serverMessage
+			if err := websocket.JSON.Receive(b.ws, &m); err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case msgs <- m:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	tick := time.NewTicker(b.interval)
+	defer tick.Stop()
+	for {
+		select {
+		case err := <-errc:
+			return err
+		case m := <-msgs:
+			if err := b.handle(m); err != nil {
+				return err
+			}
+		case <-tick.C:
+			if b.playing {
+				if err := b.step(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+func (b *bot) handle(m serverMessage) error {
+	switch m.Type {
+	case "join":
+		// A new round, or the first one.
+		b.pos = point{m.X, m.Y}
+		if err := b.loadRound(); err != nil {
+			return err
+		}
+		b.playing = true
+		log.Printf("%s: running from %d,%d", b.name, m.X, m.Y)
+	case "lap":
+		b.pos = point{m.X, m.Y}
+		return b.loadRound()
+	case "position":
+		// Refused move, ice or a portal.
+		b.pos = point{m.X, m.Y}
+		b.strategy.Reset(b)
+	case "maze-diff":
+		for _, c := range m.Changes {
+			b.maze[c.Y][c.X] = c.Cell
+		}
+		b.strategy.Reset(b)
+	case "finish":
+		if m.Name == b.name {
+			b.playing = false
+			log.Printf("%s: finished", b.name)
+		}
+	case "warning":
+		log.Printf("%s: server warns: %s", b.name, m.Message)
+	}
+	return nil
+}
+
+func (b *bot) step() error {
+	if len(b.targets) > 0 && b.pos == b.targets[0] {
+		b.targets = b.targets[1:]
+	}
+	next, ok := b.strategy.Next(b)
+	if !ok {
+		return nil
+	}
+	b.pos = next
+	return b.send(map[string]any{"x": next.X, "y": next.Y, "name": b.name, "color": b.color})
+}
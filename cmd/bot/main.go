@@ -0,0 +1,80 @@
+// Command bot connects players that find their own way through a Maze
+// Runner game, for demos and to fill a lobby.
+//
+//	go run ./cmd/bot -server http://localhost:8080 -count 5 -strategy wall
+//
+// Bots stay connected and run every new round until stopped.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	flagServer   = flag.String("server", "http://localhost:8080", "address of the game server")
+	flagCount    = flag.Int("count", 1, "number of bots to connect")
+	flagStrategy = flag.String("strategy", "bfs", "how bots move: "+strings.Join(strategyNames(), ", "))
+	flagSpeed    = flag.Float64("speed", 5, "moves per second of each bot")
+	flagName     = flag.String("name", "Bot", "name prefix; bots are called <name> 1, <name> 2, ...")
+	flagPassword = flag.String("password", "", "password of a private game")
+)
+
+// palette gives each bot a different color.
+var palette = []string{"#e74c3c", "#e67e22", "#f1c40f", "#2ecc71", "#1abc9c", "#3498db", "#9b59b6", "#e84393"}
+
+func strategyNames() []string {
+	names := make([]string, 0, len(strategies))
+	for name := range strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func main() {
+	flag.Parse()
+	log.SetFlags(log.Ltime)
+	newStrategy, ok := strategies[*flagStrategy]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown strategy %q, want one of %s\n", *flagStrategy, strings.Join(strategyNames(), ", "))
+		os.Exit(2)
+	}
+	if *flagCount < 1 || *flagSpeed <= 0 {
+		fmt.Fprintln(os.Stderr, "-count and -speed must be positive")
+		os.Exit(2)
+	}
+	server := strings.TrimRight(*flagServer, "/")
+	if !strings.Contains(server, "://") {
+		server = "http://" + server
+	}
+
+	var wg sync.WaitGroup
+	for i := 1; i <= *flagCount; i++ {
+		b := &bot{
+			server:   server,
+			name:     fmt.Sprintf("%s %d", *flagName, i),
+			color:    palette[(i-1)%len(palette)],
+			strategy: newStrategy(),
+			interval: time.Duration(float64(time.Second) / *flagSpeed),
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				err := b.run()
+				log.Printf("%s: %v, reconnecting in 5s", b.name, err)
+				time.Sleep(5 * time.Second)
+			}
+		}()
+		// Don't all arrive in the same instant.
+		time.Sleep(200 * time.Millisecond)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,119 @@
+package main
+
+import "math/rand"
+
+// A Strategy picks the bot's moves.
+type Strategy interface {
+	// Next returns the cell to step to from b.pos, or false to stay.
+	Next(b *bot) (point, bool)
+	// Reset forgets plans made for an earlier maze or position.
+	Reset(b *bot)
+}
+
+var strategies = map[string]func() Strategy{
+	"bfs":    func() Strategy { return &shortestPath{} },
+	"wall":   func() Strategy { return &wallFollower{} },
+	"random": func() Strategy { return &randomWalk{} },
+}
+
+// dirs in clockwise order: east, south, west, north.
+var dirs = []point{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+
+// shortestPath walks the shortest way to each target in turn.
+type shortestPath struct {
+	path []point
+}
+
+func (s *shortestPath) Reset(b *bot) { s.path = nil }
+
+func (s *shortestPath) Next(b *bot) (point, bool) {
+	if len(b.targets) == 0 {
+		return point{}, false
+	}
+	if len(s.path) == 0 {
+		s.path = s.search(b, b.targets[0])
+		if len(s.path) == 0 {
+			return point{}, false
+		}
+	}
+	next := s.path[0]
+	s.path = s.path[1:]
+	return next, true
+}
+
+// search returns the cells from b.pos (exclusive) to goal, or nil if the
+// goal cannot be reached.
+func (s *shortestPath) search(b *bot, goal point) []point {
+	prev := map[point]point{b.pos: b.pos}
+	queue := []point{b.pos}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		if c == goal {
+			var path []point
+			for ; c != b.pos; c = prev[c] {
+				path = append([]point{c}, path...)
+			}
+			return path
+		}
+		for _, d := range dirs {
+			n := c.add(d)
+			if _, seen := prev[n]; !seen && b.canStep(c, n) {
+				prev[n] = c
+				queue = append(queue, n)
+			}
+		}
+	}
+	return nil
+}
+
+// wallFollower keeps its right hand on the wall. It gets out of any maze
+// without loops, though rarely by the shortest way.
+type wallFollower struct {
+	heading int // index into dirs
+}
+
+func (w *wallFollower) Reset(b *bot) {}
+
+func (w *wallFollower) Next(b *bot) (point, bool) {
+	// Right, straight on, left, back.
+	for _, turn := range []int{1, 0, 3, 2} {
+		h := (w.heading + turn) % 4
+		if n := b.pos.add(dirs[h]); b.canStep(b.pos, n) {
+			w.heading = h
+			return n, true
+		}
+	}
+	return point{}, false
+}
+
+// randomWalk wanders, turning back only at dead ends.
+type randomWalk struct {
+	last point
+}
+
+func (r *randomWalk) Reset(b *bot) { r.last = b.pos }
+
+func (r *randomWalk) Next(b *bot) (point, bool) {
+	var options []point
+	back := false
+	for _, d := range dirs {
+		n := b.pos.add(d)
+		if !b.canStep(b.pos, n) {
+			continue
+		}
+		if n == r.last {
+			back = true
+			continue
+		}
+		options = append(options, n)
+	}
+	if len(options) == 0 {
+		if !back {
+			return point{}, false
+		}
+		options = []point{r.last}
+	}
+	r.last = b.pos
+	return options[rand.Intn(len(options))], true
+}
@@ -0,0 +1,290 @@
+// Command loadtest connects many simulated players to a Maze Runner server
+// and measures how long moves take to come back in the broadcast state.
+//
+//	go run ./cmd/loadtest -server localhost:8080 -players 200 -rate 5 -duration 1m
+//
+// Each player steps back and forth between its spawn and a neighbouring
+// cell. A move counts as echoed once a state frame shows the player there,
+// and as dropped if no such frame arrives within -timeout.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var (
+	flagServer   = flag.String("server", "http://localhost:8080", "address of the game server")
+	flagPlayers  = flag.Int("players", 50, "number of simulated players")
+	flagRate     = flag.Float64("rate", 5, "moves per second of each player")
+	flagDuration = flag.Duration("duration", 30*time.Second, "how long to keep moving once everyone is connected")
+	flagRamp     = flag.Duration("ramp", 5*time.Second, "time over which the players connect")
+	flagTimeout  = flag.Duration("timeout", 2*time.Second, "a move not seen in the state within this long counts as dropped")
+	flagPassword = flag.String("password", "", "password of a private game")
+)
+
+type point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// frame is what the players read of server messages: typed events, or the
+// untyped state with everybody's position.
+type frame struct {
+	Type    string `json:"type"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	Players []struct {
+		Name string `json:"name"`
+		X    int    `json:"x"`
+		Y    int    `json:"y"`
+	} `json:"players"`
+}
+
+// stats is shared by all players.
+type stats struct {
+	connected, failed          atomic.Int64
+	sent, echoed, dropped      atomic.Int64
+	frames, bytes, disconnects atomic.Int64
+
+	mu        sync.Mutex
+	latencies []time.Duration
+}
+
+func (s *stats) record(d time.Duration) {
+	s.echoed.Add(1)
+	s.mu.Lock()
+	s.latencies = append(s.latencies, d)
+	s.mu.Unlock()
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[min(len(sorted)-1, int(p*float64(len(sorted))))]
+}
+
+func main() {
+	flag.Parse()
+	log.SetFlags(log.Ltime)
+	if *flagPlayers < 1 || *flagRate <= 0 {
+		fmt.Fprintln(os.Stderr, "-players and -rate must be positive")
+		os.Exit(2)
+	}
+	server := strings.TrimRight(*flagServer, "/")
+	if !strings.Contains(server, "://") {
+		server = "http://" + server
+	}
+	var maze [][]int
+	if err := getJSON(server+"/maze", &maze); err != nil {
+		log.Fatalf("Cannot load the maze: %v", err)
+	}
+
+	st := &stats{}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	gap := *flagRamp / time.Duration(*flagPlayers)
+	for i := range *flagPlayers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			play(server, fmt.Sprintf("load%d", i+1), maze, st, stop)
+		}()
+		time.Sleep(gap)
+	}
+	log.Printf("%d of %d players connected, moving for %v", st.connected.Load(), *flagPlayers, *flagDuration)
+
+	start := time.Now()
+	progress := time.NewTicker(5 * time.Second)
+	end := time.After(*flagDuration)
+loop:
+	for {
+		select {
+		case <-progress.C:
+			log.Printf("sent %d, echoed %d, dropped %d, %d frames", st.sent.Load(), st.echoed.Load(), st.dropped.Load(), st.frames.Load())
+		case <-end:
+			break loop
+		}
+	}
+	progress.Stop()
+	elapsed := time.Since(start)
+	close(stop)
+	wg.Wait()
+
+	sort.Slice(st.latencies, func(i, j int) bool { return st.latencies[i] < st.latencies[j] })
+	sent := st.sent.Load()
+	fmt.Printf("players     %d connected, %d failed to connect, %d disconnected early\n", st.connected.Load(), st.failed.Load(), st.disconnects.Load())
+	fmt.Printf("moves       %d sent, %d echoed, %d dropped", sent, st.echoed.Load(), st.dropped.Load())
+	if sent > 0 {
+		fmt.Printf(" (%.2f%%)", 100*float64(st.dropped.Load())/float64(sent))
+	}
+	fmt.Println()
+	fmt.Printf("latency     p50 %v, p90 %v, p99 %v, max %v\n",
+		percentile(st.latencies, .5), percentile(st.latencies, .9), percentile(st.latencies, .99), percentile(st.latencies, 1))
+	fmt.Printf("broadcasts  %.0f frames/s, %.1f MB/s received in total\n",
+		float64(st.frames.Load())/elapsed.Seconds(), float64(st.bytes.Load())/elapsed.Seconds()/1e6)
+}
+
+func getJSON(u string, v any) error {
+	resp, err := http.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func dial(server string) (*websocket.Conn, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, err
+	}
+	origin := u.String()
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	u.Path = "/ws"
+	if *flagPassword != "" {
+		u.RawQuery = url.Values{"password": {*flagPassword}}.Encode()
+	}
+	return websocket.Dial(u.String(), "", origin)
+}
+
+// neighbour is an open cell next to c, so a player can step back and forth.
+func neighbour(maze [][]int, c point) (point, bool) {
+	for _, d := range []point{{1, 0}, {0, 1}, {-1, 0}, {0, -1}} {
+		n := point{c.X + d.X, c.Y + d.Y}
+		if n.Y >= 0 && n.Y < len(maze) && n.X >= 0 && n.X < len(maze[n.Y]) && maze[n.Y][n.X] == 0 {
+			return n, true
+		}
+	}
+	return point{}, false
+}
+
+// play runs one simulated player until stop is closed.
+func play(server, name string, maze [][]int, st *stats, stop <-chan struct{}) {
+	ws, err := dial(server)
+	if err != nil {
+		st.failed.Add(1)
+		log.Printf("%s: %v", name, err)
+		return
+	}
+	defer ws.Close()
+	st.connected.Add(1)
+	if err := websocket.JSON.Send(ws, map[string]any{"type": "hello", "protocol": 1, "caps": []string{"private"}}); err != nil {
+		st.disconnects.Add(1)
+		return
+	}
+
+	// The reader reports each frame's time and where it shows this player.
+	type sighting struct {
+		at    time.Time
+		pos   point
+		spawn bool // a join or position message rather than the state
+	}
+	seen := make(chan sighting, 64)
+	done, quit := make(chan struct{}), make(chan struct{})
+	defer close(quit)
+	report := func(s sighting) bool {
+		select {
+		case seen <- s:
+			return true
+		case <-quit:
+			return false
+		}
+	}
+	go func() {
+		defer close(done)
+		for {
+			var data []byte
+			if err := websocket.Message.Receive(ws, &data); err != nil {
+				return
+			}
+			at := time.Now()
+			st.frames.Add(1)
+			st.bytes.Add(int64(len(data)))
+			var f frame
+			if json.Unmarshal(data, &f) != nil {
+				continue
+			}
+			switch f.Type {
+			case "join", "position":
+				if !report(sighting{at, point{f.X, f.Y}, true}) {
+					return
+				}
+			case "":
+				for _, p := range f.Players {
+					if p.Name == name {
+						if !report(sighting{at, point{p.X, p.Y}, false}) {
+							return
+						}
+						break
+					}
+				}
+			}
+		}
+	}()
+
+	var home, away, pos point
+	var pending *point
+	var sentAt time.Time
+	tick := time.NewTicker(time.Duration(float64(time.Second) / *flagRate))
+	defer tick.Stop()
+	ready := false
+	for {
+		select {
+		case <-stop:
+			// A move still in flight is neither echoed nor dropped.
+			return
+		case <-done:
+			st.disconnects.Add(1)
+			return
+		case s := <-seen:
+			if s.spawn {
+				home, pos = s.pos, s.pos
+				away, ready = neighbour(maze, home)
+				pending = nil
+				continue
+			}
+			if pending != nil && s.pos == *pending {
+				st.record(s.at.Sub(sentAt))
+				pending = nil
+			}
+		case <-tick.C:
+			if !ready {
+				continue
+			}
+			if pending != nil {
+				if time.Since(sentAt) < *flagTimeout {
+					continue
+				}
+				st.dropped.Add(1)
+				pending = nil
+			}
+			next := away
+			if pos == away {
+				next = home
+			}
+			if err := websocket.JSON.Send(ws, map[string]any{"x": next.X, "y": next.Y, "name": name, "color": "#888888"}); err != nil {
+				st.disconnects.Add(1)
+				return
+			}
+			st.sent.Add(1)
+			pos, pending, sentAt = next, &next, time.Now()
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+// Cell values of /maze, as in the server's terrain.go.
+const (
+	cellWall  = 1
+	cellEast  = 2
+	cellSouth = 3
+	cellWest  = 4
+	cellNorth = 5
+	cellIce   = 6
+	cellMud   = 7
+)
+
+var oneWayDirs = map[int]point{
+	cellEast:  {1, 0},
+	cellSouth: {0, 1},
+	cellWest:  {-1, 0},
+	cellNorth: {0, -1},
+}
+
+type point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func (p point) add(d point) point { return point{p.X + d.X, p.Y + d.Y} }
+
+type playerState struct {
+	X        int    `json:"x"`
+	Y        int    `json:"y"`
+	Name     string `json:"name"`
+	Color    string `json:"color"`
+	Finished bool   `json:"finished"`
+}
+
+// message covers the fields of every server message the client reads: typed
+// events, or the untyped state.
+type message struct {
+	Type      string  `json:"type"`
+	X         int     `json:"x"`
+	Y         int     `json:"y"`
+	Name      string  `json:"name"`
+	Rank      int     `json:"rank"`
+	Time      int64   `json:"time"`
+	Message   string  `json:"message"`
+	Error     string  `json:"error"`
+	Path      []point `json:"path"`
+	Remaining int     `json:"remaining"`
+	Changes   []struct {
+		X    int `json:"x"`
+		Y    int `json:"y"`
+		Cell int `json:"cell"`
+	} `json:"changes"`
+	Players  []playerState `json:"players"`
+	GameOver bool          `json:"gameOver"`
+}
+
+// mazeInfo is the part of /info the client draws.
+type mazeInfo struct {
+	GoalX       int     `json:"goalX"`
+	GoalY       int     `json:"goalY"`
+	Checkpoints []point `json:"checkpoints"`
+	Goals       []point `json:"goals"`
+}
+
+type game struct {
+	server string
+	name   string
+	color  string
+
+	ws      *websocket.Conn
+	maze    [][]int
+	info    mazeInfo
+	pos     point
+	joined  bool // the server placed us in the current round
+	players []playerState
+	hint    []point // cells still to walk of the last hint
+	status  string  // last event worth telling
+	over    bool
+}
+
+func (g *game) getJSON(path string, v any) error {
+	resp, err := http.Get(g.server + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// loadRound fetches the maze and its goals.
+func (g *game) loadRound() error {
+	if err := g.getJSON("/info", &g.info); err != nil {
+		return err
+	}
+	return g.getJSON("/maze", &g.maze)
+}
+
+func (g *game) connect() error {
+	u, err := url.Parse(g.server)
+	if err != nil {
+		return err
+	}
+	origin := u.String()
+	u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	u.Path = "/ws"
+	if *flagPassword != "" {
+		u.RawQuery = url.Values{"password": {*flagPassword}}.Encode()
+	}
+	if g.ws, err = websocket.Dial(u.String(), "", origin); err != nil {
+		return err
+	}
+	return g.send(map[string]any{"type": "hello", "protocol": 1, "caps": []string{"events", "private"}})
+}
+
+func (g *game) send(v any) error {
+	return websocket.JSON.Send(g.ws, v)
+}
+
+// canStep mirrors the server's rule: floor on both sides, and one-way cells
+// only left or entered in their direction.
+func (g *game) canStep(from, to point) bool {
+	for _, c := range [2]point{from, to} {
+		if c.Y < 0 || c.Y >= len(g.maze) || c.X < 0 || c.X >= len(g.maze[c.Y]) || g.maze[c.Y][c.X] == cellWall {
+			return false
+		}
+		if d, ok := oneWayDirs[g.maze[c.Y][c.X]]; ok && d != (point{to.X - from.X, to.Y - from.Y}) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *game) me() (playerState, bool) {
+	for _, p := range g.players {
+		if p.Name == g.name {
+			return p, true
+		}
+	}
+	return playerState{}, false
+}
+
+// move steps by d. Steps into walls are sent too, the server counts them.
+func (g *game) move(d point) error {
+	if me, _ := g.me(); !g.joined || g.over || me.Finished {
+		return nil
+	}
+	to := g.pos.add(d)
+	if g.canStep(g.pos, to) {
+		// Ice and portals move us further; the server tells us where to.
+		g.pos = to
+		for i, c := range g.hint {
+			if c == to {
+				g.hint = g.hint[i+1:]
+				break
+			}
+		}
+	}
+	return g.send(map[string]any{"x": to.X, "y": to.Y, "name": g.name, "color": g.color})
+}
+
+func (g *game) handle(m message) error {
+	switch m.Type {
+	case "":
+		g.players, g.over = m.Players, m.GameOver
+	case "join":
+		// A new round, or the first one.
+		g.pos, g.joined, g.hint = point{m.X, m.Y}, true, nil
+		g.status = "Go!"
+		return g.loadRound()
+	case "lap":
+		g.pos, g.hint = point{m.X, m.Y}, nil
+		g.status = "Lap done"
+		return g.loadRound()
+	case "position":
+		g.pos = point{m.X, m.Y}
+	case "maze-diff":
+		for _, c := range m.Changes {
+			g.maze[c.Y][c.X] = c.Cell
+		}
+	case "finish":
+		who := m.Name
+		if who == g.name {
+			who = "You"
+		}
+		g.status = fmt.Sprintf("%s finished #%d in %ds", who, m.Rank, m.Time)
+	case "hint":
+		if m.Error != "" {
+			g.status = "No hint: " + m.Error
+			break
+		}
+		g.hint = m.Path
+		g.status = fmt.Sprintf("Hint shown, %d left", m.Remaining)
+	case "warning":
+		g.status = "Server: " + m.Message
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+// Command tui plays Maze Runner in a terminal, for a game over SSH or a
+// quick look at what a server is doing.
+//
+//	go run ./cmd/tui -server localhost:8080 -name Ann
+//
+// Arrow keys or WASD move, h asks for a hint and q quits. The view follows
+// the player through mazes larger than the terminal.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var (
+	flagServer   = flag.String("server", "http://localhost:8080", "address of the game server")
+	flagName     = flag.String("name", "", "player name (default: your user name)")
+	flagColor    = flag.String("color", "#3498db", "player color as #rrggbb")
+	flagPassword = flag.String("password", "", "password of a private game")
+)
+
+type key int
+
+const (
+	keyUp key = iota
+	keyDown
+	keyLeft
+	keyRight
+	keyHint
+	keyQuit
+)
+
+var keyDirs = map[key]point{keyUp: {0, -1}, keyDown: {0, 1}, keyLeft: {-1, 0}, keyRight: {1, 0}}
+
+// parseKeys turns raw terminal input into keys. Arrow keys arrive as
+// ESC [ A..D, or ESC O A..D in application mode.
+func parseKeys(in []byte) []key {
+	var keys []key
+	for i := 0; i < len(in); i++ {
+		if in[i] == 0x1b && i+2 < len(in) && (in[i+1] == '[' || in[i+1] == 'O') {
+			switch in[i+2] {
+			case 'A':
+				keys = append(keys, keyUp)
+			case 'B':
+				keys = append(keys, keyDown)
+			case 'C':
+				keys = append(keys, keyRight)
+			case 'D':
+				keys = append(keys, keyLeft)
+			}
+			i += 2
+			continue
+		}
+		switch in[i] {
+		case 'w', 'W':
+			keys = append(keys, keyUp)
+		case 's', 'S':
+			keys = append(keys, keyDown)
+		case 'a', 'A':
+			keys = append(keys, keyLeft)
+		case 'd', 'D':
+			keys = append(keys, keyRight)
+		case 'h', 'H':
+			keys = append(keys, keyHint)
+		case 'q', 'Q', 3: // 3 is Ctrl-C, which raw mode delivers as input
+			keys = append(keys, keyQuit)
+		}
+	}
+	return keys
+}
+
+func readKeys(r io.Reader, keys chan<- key) {
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			keys <- keyQuit
+			return
+		}
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+	server := strings.TrimRight(*flagServer, "/")
+	if !strings.Contains(server, "://") {
+		server = "http://" + server
+	}
+	name := *flagName
+	if name == "" {
+		name = os.Getenv("USER")
+	}
+	if name == "" {
+		name = "Terminal"
+	}
+	g := &game{server: server, name: name, color: *flagColor}
+	if err := g.connect(); err != nil {
+		fmt.Fprintln(os.Stderr, "Cannot connect:", err)
+		os.Exit(1)
+	}
+	defer g.ws.Close()
+
+	restore, err := rawMode()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Not a terminal:", err)
+		os.Exit(1)
+	}
+	os.Stdout.WriteString(enterScreen)
+	err = g.play()
+	os.Stdout.WriteString(leaveScreen)
+	restore()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// play redraws after every message and key until the player quits or the
+// connection breaks.
+func (g *game) play() error {
+	msgs := make(chan message)
+	errc := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			var m message
+			if err := websocket.JSON.Receive(g.ws, &m); err != nil {
+				errc <- err
+				return
+			}
+			select {
+			case msgs <- m:
+			case <-done:
+				return
+			}
+		}
+	}()
+	keys := make(chan key, 16)
+	go readKeys(os.Stdin, keys)
+
+	// The ticker picks up a resized terminal.
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		g.draw(os.Stdout)
+		select {
+		case err := <-errc:
+			return fmt.Errorf("connection lost: %v", err)
+		case m := <-msgs:
+			if err := g.handle(m); err != nil {
+				return err
+			}
+		case k := <-keys:
+			switch k {
+			case keyQuit:
+				return nil
+			case keyHint:
+				if err := g.send(map[string]any{"type": "hint"}); err != nil {
+					return err
+				}
+			default:
+				if err := g.move(keyDirs[k]); err != nil {
+					return err
+				}
+			}
+		case <-tick.C:
+		}
+	}
+}
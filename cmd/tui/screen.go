@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	enterScreen = "\x1b[?1049h\x1b[?25l" // alternate screen, cursor hidden
+	leaveScreen = "\x1b[?25h\x1b[?1049l"
+	plain       = "\x1b[0m"
+)
+
+// Each cell is two characters wide, which makes it roughly square.
+var terrain = map[int]string{
+	cellWall:  "\x1b[90m██",
+	cellEast:  "\x1b[33m→ ",
+	cellSouth: "\x1b[33m↓ ",
+	cellWest:  "\x1b[33m← ",
+	cellNorth: "\x1b[33m↑ ",
+	cellIce:   "\x1b[96m░░",
+	cellMud:   "\x1b[38;2;140;90;40m░░",
+}
+
+const (
+	glyphGoal       = "\x1b[92m▓▓"
+	glyphCheckpoint = "\x1b[93m▒▒"
+	glyphHint       = "\x1b[93m··"
+	glyphFloor      = "  "
+)
+
+// fg is the escape sequence for a #rrggbb text color, white if it is not one.
+func fg(color string) string {
+	var r, g, b uint8
+	if _, err := fmt.Sscanf(color, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		return "\x1b[97m"
+	}
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", r, g, b)
+}
+
+// viewStart is the first cell of a view of size cells that keeps c in the
+// middle without leaving a maze of length cells.
+func viewStart(c, size, length int) int {
+	if length <= size {
+		return 0
+	}
+	return min(max(c-size/2, 0), length-size)
+}
+
+// crop shortens s to width characters.
+func crop(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		r = r[:width]
+	}
+	return string(r)
+}
+
+// draw writes the whole screen at once: the part of the maze around the
+// player, and two status lines.
+func (g *game) draw(w io.Writer) {
+	cols, rows := termSize()
+	viewW, viewH := max(cols/2, 1), max(rows-2, 1)
+
+	marks := make(map[point]string)
+	for _, c := range g.hint {
+		marks[c] = glyphHint
+	}
+	for _, c := range g.info.Checkpoints {
+		marks[c] = glyphCheckpoint
+	}
+	marks[point{g.info.GoalX, g.info.GoalY}] = glyphGoal
+	for _, c := range g.info.Goals {
+		marks[c] = glyphGoal
+	}
+	finished := 0
+	for _, p := range g.players {
+		if p.Finished {
+			finished++
+		}
+		if p.Name != g.name {
+			marks[point{p.X, p.Y}] = fg(p.Color) + "▐▌"
+		}
+	}
+	if g.joined {
+		marks[g.pos] = fg(g.color) + "██"
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	height := len(g.maze)
+	width := 0
+	if height > 0 {
+		width = len(g.maze[0])
+	}
+	ox, oy := viewStart(g.pos.X, viewW, width), viewStart(g.pos.Y, viewH, height)
+	for y := oy; y < oy+viewH; y++ {
+		for x := ox; x < ox+viewW && y < height && x < len(g.maze[y]); x++ {
+			if m, ok := marks[point{x, y}]; ok {
+				b.WriteString(m)
+			} else if t, ok := terrain[g.maze[y][x]]; ok {
+				b.WriteString(t)
+			} else {
+				b.WriteString(plain + glyphFloor)
+			}
+		}
+		b.WriteString(plain + "\x1b[K\r\n")
+	}
+
+	var status string
+	switch {
+	case g.over:
+		status = "Round over, waiting for the next one"
+	case !g.joined:
+		status = "Waiting to join"
+	default:
+		status = fmt.Sprintf("%s at %d,%d  %d/%d finished", g.name, g.pos.X, g.pos.Y, finished, len(g.players))
+	}
+	if g.status != "" {
+		status += "  |  " + g.status
+	}
+	b.WriteString("\x1b[1m" + crop(status, cols) + plain + "\x1b[K\r\n")
+	b.WriteString("\x1b[2m" + crop("arrows/WASD move  h hint  q quit", cols) + plain + "\x1b[K\x1b[J")
+	io.WriteString(w, b.String())
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// rawMode switches the console to unbuffered input without echo, with arrow
+// keys and colors as escape sequences, and returns a function that restores
+// it.
+func rawMode() (func(), error) {
+	in, out := windows.Handle(os.Stdin.Fd()), windows.Handle(os.Stdout.Fd())
+	var inMode, outMode uint32
+	if err := windows.GetConsoleMode(in, &inMode); err != nil {
+		return nil, err
+	}
+	if err := windows.GetConsoleMode(out, &outMode); err != nil {
+		return nil, err
+	}
+	raw := inMode&^(windows.ENABLE_ECHO_INPUT|windows.ENABLE_PROCESSED_INPUT|windows.ENABLE_LINE_INPUT) | windows.ENABLE_VIRTUAL_TERMINAL_INPUT
+	if err := windows.SetConsoleMode(in, raw); err != nil {
+		return nil, err
+	}
+	if err := windows.SetConsoleMode(out, outMode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING); err != nil {
+		windows.SetConsoleMode(in, inMode)
+		return nil, err
+	}
+	return func() {
+		windows.SetConsoleMode(in, inMode)
+		windows.SetConsoleMode(out, outMode)
+	}, nil
+}
+
+// termSize is the console window's width and height in characters.
+func termSize() (int, int) {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(os.Stdout.Fd()), &info); err != nil {
+		return 80, 24
+	}
+	return int(info.Window.Right-info.Window.Left) + 1, int(info.Window.Bottom-info.Window.Top) + 1
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+var (
+	flagCoins    = flag.Int("coins", 0, "coin mode: coins lying in the maze at any time; the goal does not count and the most coins win (0 disables)")
+	flagCoinTime = flag.Duration("coin-time", 3*time.Minute, "round length in coin mode")
+)
+
+var (
+	coins   []point    // guarded by mu
+	coinRng *rand.Rand // seeded from the maze, so a seed replays the same coins
+)
+
+func coinMode() bool { return *flagCoins > 0 }
+
+// scatterCoins lays out a fresh set of coins for a new round. Caller holds mu.
+func scatterCoins() {
+	coins = nil
+	if !coinMode() {
+		return
+	}
+	coinRng = rand.New(rand.NewSource(mazeSeed))
+	for len(coins) < *flagCoins && dropCoin() {
+	}
+}
+
+// dropCoin puts one coin on a free floor cell. Caller holds mu.
+func dropCoin() bool {
+	for try := 0; try < 50; try++ {
+		c := point{coinRng.Intn(mazeWidth), coinRng.Intn(mazeHeight)}
+		if c.Y >= len(maze) || c.X >= len(maze[c.Y]) || maze[c.Y][c.X] != cellFloor || c == (point{startX, startY}) || coinAt(c) >= 0 {
+			continue
+		}
+		coins = append(coins, c)
+		return true
+	}
+	return false
+}
+
+func coinAt(c point) int {
+	for i, o := range coins {
+		if o == c {
+			return i
+		}
+	}
+	return -1
+}
+
+// collectCoin gives p the coin it stands on, and drops a new one elsewhere
+// to keep the count up. Caller holds mu.
+func collectCoin(p *Player) {
+	i := coinAt(point{p.X, p.Y})
+	if i < 0 {
+		return
+	}
+	coins = append(coins[:i], coins[i+1:]...)
+	p.Coins++
+	dropCoin()
+}
+
+// coinTimeLeft is how many seconds the coin round still runs.
+func coinTimeLeft() int {
+	if !coinMode() || gameOver {
+		return 0
+	}
+	return max(0, int((*flagCoinTime - clock().Sub(startTime)).Seconds()+0.999))
+}
+
+// runCoinClock ends coin rounds when their time is up.
+func runCoinClock() {
+	for range time.Tick(time.Second) {
+		mu.Lock()
+		ended := !gameOver && len(clients) > 0 && clock().Sub(startTime) >= *flagCoinTime
+		if ended {
+			endCoinRound()
+		}
+		mu.Unlock()
+		if ended {
+			broadcast()
+		}
+	}
+}
+
+// endCoinRound ranks everyone by coins, ties going to whoever got there
+// with fewer wall hits, and marks them all finished. Caller holds mu.
+func endCoinRound() {
+	list := make([]*Player, 0, len(clients))
+	for _, p := range clients {
+		list = append(list, p)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Coins != list[j].Coins {
+			return list[i].Coins > list[j].Coins
+		}
+		return list[i].WallHits < list[j].WallHits
+	})
+	elapsed := int64(flagCoinTime.Seconds())
+	for i, p := range list {
+		p.Finished = true
+		p.FinishRank = i + 1
+		p.FinishTime = elapsed
+		p.Card = cardURL(*p)
+		p.dirty = true
+		journalFinish(*p)
+		recordFinish(*p)
+	}
+	finishRank = len(list)
+	log.Printf("COIN ROUND OVER after %s", *flagCoinTime)
+	if len(list) > 0 {
+		log.Printf("Winner: %s with %d coin(s)", list[0].Name, list[0].Coins)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+)
+
+var flagCollapseEvery = flag.Duration("collapse-every", 0, "sudden death: every this often the outermost ring around the goal turns to wall, eliminating anyone in it (0 disables)")
+
+var (
+	collapseRadius int       // cells farther from the goal than this are gone; guarded by mu
+	nextCollapse   time.Time // when the next ring falls
+)
+
+func collapseMode() bool { return *flagCollapseEvery > 0 }
+
+// ringOf is a cell's ring around the goal: its distance along the wider axis.
+func ringOf(x, y int) int {
+	return max(abs(x-goalX), abs(y-goalY))
+}
+
+// resetCollapse schedules the first ring of a new round. Caller holds mu.
+func resetCollapse() {
+	if !collapseMode() {
+		return
+	}
+	collapseRadius = max(ringOf(0, 0), ringOf(mazeWidth-1, 0), ringOf(0, mazeHeight-1), ringOf(mazeWidth-1, mazeHeight-1))
+	nextCollapse = clock().Add(*flagCollapseEvery)
+}
+
+// runCollapse brings down a ring whenever one is due.
+func runCollapse() {
+	for range time.Tick(time.Second) {
+		mu.Lock()
+		var changes []cellChange
+		if !gameOver && len(clients) > 0 && !nextCollapse.IsZero() && clock().After(nextCollapse) {
+			changes = collapseRing()
+		}
+		mu.Unlock()
+		if len(changes) > 0 {
+			broadcastMazeDiff(changes)
+		}
+		if changes != nil {
+			broadcast()
+		}
+	}
+}
+
+// collapseRing walls up the outermost ring that still has floor in it,
+// eliminates everyone standing there and, if only one runner is left with
+// nobody home yet, declares them the winner. The goal's direct neighbors
+// never fall. Caller holds mu.
+func collapseRing() []cellChange {
+	src := maze
+	grid := make([][]int, len(src))
+	for y := range src {
+		grid[y] = append([]int(nil), src[y]...)
+	}
+	changes := []cellChange{}
+	for collapseRadius > 1 && len(changes) == 0 {
+		for y, row := range grid {
+			for x, v := range row {
+				if v != cellWall && ringOf(x, y) == collapseRadius {
+					row[x] = cellWall
+					changes = append(changes, cellChange{x, y, cellWall})
+				}
+			}
+		}
+		collapseRadius--
+	}
+	if collapseRadius > 1 {
+		nextCollapse = clock().Add(*flagCollapseEvery)
+	} else {
+		nextCollapse = time.Time{}
+	}
+	if len(changes) == 0 {
+		return changes
+	}
+	maze = grid
+	goalDist = bfsDistancesTo(maze, goalX, goalY)
+	log.Printf("COLLAPSE: %d cell(s) fell, safe radius now %d", len(changes), collapseRadius)
+
+	var alive []*Player
+	for _, p := range clients {
+		if p.Finished || p.Eliminated {
+			continue
+		}
+		if !isOpen(maze, p.X, p.Y) {
+			p.Eliminated = true
+			p.dirty = true
+			log.Printf("ELIMINATED: %s was caught in the collapse", p.Name)
+			continue
+		}
+		alive = append(alive, p)
+	}
+	if len(alive) == 1 && finishRank == 0 && len(clients) > 1 {
+		p := alive[0]
+		finishRank++
+		p.Finished = true
+		p.FinishRank = finishRank
+		p.FinishTime = clock().Unix() - roundStartFor(p).Unix()
+		p.Card = cardURL(*p)
+		p.dirty = true
+		log.Printf("LAST SURVIVOR: %s wins", p.Name)
+		journalFinish(*p)
+		recordFinish(*p)
+	}
+	return changes
+}
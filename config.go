@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	flagCellSize = flag.Int("cell-size", 14, "pixels per maze cell in the web client")
+	flagViewSize = flag.String("view-size", "800x560", "size in pixels (WIDTHxHEIGHT) of the web client's view of the maze")
+	flagPalette  = flag.String("palette", "#e74c3c,#e67e22,#f1c40f,#2ecc71,#1abc9c,#3498db,#4a9eff,#9b59b6,#e84393,#fd79a8,#00cec9,#6c5ce7,#a29bfe,#ffeaa7,#dfe6e9,#636e72", "comma-separated player colors the web client offers")
+)
+
+// ClientConfig is what GET /config tells clients, so they do not have to
+// hardcode values that must match the server.
+type ClientConfig struct {
+	CellSize   int      `json:"cellSize"`
+	ViewWidth  int      `json:"viewWidth"`
+	ViewHeight int      `json:"viewHeight"`
+	Palette    []string `json:"palette"`
+	Modes      []string `json:"modes"`    // game modes and mechanics switched on
+	TickRate   float64  `json:"tickRate"` // state updates per second the server makes on its own (the minotaur), 0 if it only answers moves
+	Protocol   int      `json:"protocol"`
+	Branding   Branding `json:"branding"`
+}
+
+var clientConfig ClientConfig
+
+// loadClientConfig checks the client flags once at startup.
+func loadClientConfig() {
+	c := ClientConfig{CellSize: *flagCellSize, Protocol: protocolVersion}
+	if c.CellSize < 2 {
+		log.Fatalf("Invalid -cell-size %d: must be at least 2", c.CellSize)
+	}
+	if _, err := fmt.Sscanf(*flagViewSize, "%dx%d", &c.ViewWidth, &c.ViewHeight); err != nil || c.ViewWidth < c.CellSize || c.ViewHeight < c.CellSize {
+		log.Fatalf("Invalid -view-size %q: want WIDTHxHEIGHT in pixels", *flagViewSize)
+	}
+	for _, col := range strings.Split(*flagPalette, ",") {
+		if col = strings.TrimSpace(col); col != "" {
+			c.Palette = append(c.Palette, col)
+		}
+	}
+	clientConfig = c
+}
+
+// enabledModes names the modes the flags switch on.
+func enabledModes() []string {
+	modes := []string{}
+	add := func(on bool, name string) {
+		if on {
+			modes = append(modes, name)
+		}
+	}
+	add(teamCount() > 0, "teams")
+	add(coinMode(), "coins")
+	add(*flagCheckpoints > 0, "checkpoints")
+	add(lapCount() > 0, "laps")
+	add(*flagGoals > 1, "goals")
+	add(*flagCollisions, "collisions")
+	add(*flagMinotaur > 0, "minotaur")
+	add(*flagGhost, "ghost")
+	add(*flagDaily, "daily")
+	add(collapseMode(), "collapse")
+	add(*flagItemsEvery > 0, "items")
+	add(*flagQuakeEvery > 0, "quake")
+	add(*flagPortals > 0, "portals")
+	add(*flagHints > 0, "hints")
+	add(*flagHost, "host")
+	add(privateGame(), "private")
+	return modes
+}
+
+func handleConfig(w http.ResponseWriter, r *http.Request) {
+	c := clientConfig
+	c.Modes = enabledModes()
+	c.Branding = branding
+	if *flagMinotaur > 0 {
+		c.TickRate = float64(time.Second) / float64(*flagMinotaur)
+	}
+	json.NewEncoder(w).Encode(c)
+}
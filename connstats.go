@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// rateWindow is how far back message rates look.
+const rateWindow = 10 * time.Second
+
+// A trafficCounter counts the messages and bytes one way over a connection.
+// The rate covers the last full window and the current one, so it goes to
+// zero soon after the messages stop.
+type trafficCounter struct {
+	messages, bytes int64
+	first, start    time.Time // of the first message and the current window
+	n, last         int       // messages in the current and the last window
+}
+
+func (c *trafficCounter) count(now time.Time, size int) {
+	if c.first.IsZero() {
+		c.first = now
+	}
+	c.roll(now)
+	c.messages++
+	c.bytes += int64(size)
+	c.n++
+}
+
+func (c *trafficCounter) roll(now time.Time) {
+	switch d := now.Sub(c.start); {
+	case d >= 2*rateWindow:
+		c.start, c.n, c.last = now, 0, 0
+	case d >= rateWindow:
+		c.start, c.n, c.last = c.start.Add(rateWindow), 0, c.n
+	}
+}
+
+// rate is in messages per second.
+func (c trafficCounter) rate(now time.Time) float64 {
+	if c.first.IsZero() {
+		return 0
+	}
+	c.roll(now)
+	span := min(now.Sub(c.first), rateWindow+now.Sub(c.start))
+	r := float64(c.last+c.n) / max(span, time.Second).Seconds()
+	return math.Round(r*10) / 10
+}
+
+// ConnStats describes one WebSocket connection, for finding out why the game
+// feels slow to one player: a high RTT points at the network, a growing
+// queue at a client that cannot keep up.
+type ConnStats struct {
+	Remote      string    `json:"remote"`
+	Player      string    `json:"player"`
+	Protocol    int       `json:"protocol"` // 0 if the client never said hello
+	Connected   time.Time `json:"connected"`
+	LastActive  time.Time `json:"lastActive"` // last message from the client
+	MessagesIn  int64     `json:"messagesIn"`
+	MessagesOut int64     `json:"messagesOut"`
+	RateIn      float64   `json:"rateIn"` // messages per second over the last 10s
+	RateOut     float64   `json:"rateOut"`
+	BytesIn     int64     `json:"bytesIn"`
+	BytesOut    int64     `json:"bytesOut"`
+	RTT         float64   `json:"rttMs,omitempty"` // last ping answered; only clients with the ping capability
+	Queued      int       `json:"queued"`          // messages not yet written to the connection
+}
+
+// handleConnections serves GET /admin/connections[?player=NAME].
+func handleConnections(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("player")
+	now := time.Now()
+	list := []ConnStats{}
+	mu.Lock()
+	for conn, p := range clients {
+		if name != "" && p.Name != name {
+			continue
+		}
+		sent, queued := p.out.sentStats()
+		list = append(list, ConnStats{
+			Remote: conn.Request().RemoteAddr, Player: p.Name, Protocol: p.protocol,
+			Connected: p.connectedAt, LastActive: p.heard,
+			MessagesIn: p.received.messages, MessagesOut: sent.messages,
+			RateIn: p.received.rate(now), RateOut: sent.rate(now),
+			BytesIn: p.received.bytes, BytesOut: sent.bytes,
+			RTT: float64(p.rtt.Microseconds()) / 1000, Queued: queued,
+		})
+	}
+	mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Connected.Before(list[j].Connected) })
+	json.NewEncoder(w).Encode(list)
+}
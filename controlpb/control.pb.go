@@ -0,0 +1,736 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: control.proto
+
+package controlpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type NewRoundRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seed          int64                  `protobuf:"varint,1,opt,name=seed,proto3" json:"seed,omitempty"`            // 0 picks a random one
+	Difficulty    string                 `protobuf:"bytes,2,opt,name=difficulty,proto3" json:"difficulty,omitempty"` // empty uses the server's -difficulty
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NewRoundRequest) Reset() {
+	*x = NewRoundRequest{}
+	mi := &file_control_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NewRoundRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NewRoundRequest) ProtoMessage() {}
+
+func (x *NewRoundRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NewRoundRequest.ProtoReflect.Descriptor instead.
+func (*NewRoundRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *NewRoundRequest) GetSeed() int64 {
+	if x != nil {
+		return x.Seed
+	}
+	return 0
+}
+
+func (x *NewRoundRequest) GetDifficulty() string {
+	if x != nil {
+		return x.Difficulty
+	}
+	return ""
+}
+
+type NewRoundReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seed          int64                  `protobuf:"varint,1,opt,name=seed,proto3" json:"seed,omitempty"` // 0 for uploaded mazes
+	Width         int32                  `protobuf:"varint,2,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32                  `protobuf:"varint,3,opt,name=height,proto3" json:"height,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NewRoundReply) Reset() {
+	*x = NewRoundReply{}
+	mi := &file_control_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NewRoundReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NewRoundReply) ProtoMessage() {}
+
+func (x *NewRoundReply) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NewRoundReply.ProtoReflect.Descriptor instead.
+func (*NewRoundReply) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *NewRoundReply) GetSeed() int64 {
+	if x != nil {
+		return x.Seed
+	}
+	return 0
+}
+
+func (x *NewRoundReply) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *NewRoundReply) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+type SetMazeRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Maze:
+	//
+	//	*SetMazeRequest_Text
+	//	*SetMazeRequest_Json
+	//	*SetMazeRequest_Png
+	Maze          isSetMazeRequest_Maze `protobuf_oneof:"maze"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetMazeRequest) Reset() {
+	*x = SetMazeRequest{}
+	mi := &file_control_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetMazeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetMazeRequest) ProtoMessage() {}
+
+func (x *SetMazeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetMazeRequest.ProtoReflect.Descriptor instead.
+func (*SetMazeRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SetMazeRequest) GetMaze() isSetMazeRequest_Maze {
+	if x != nil {
+		return x.Maze
+	}
+	return nil
+}
+
+func (x *SetMazeRequest) GetText() string {
+	if x != nil {
+		if x, ok := x.Maze.(*SetMazeRequest_Text); ok {
+			return x.Text
+		}
+	}
+	return ""
+}
+
+func (x *SetMazeRequest) GetJson() string {
+	if x != nil {
+		if x, ok := x.Maze.(*SetMazeRequest_Json); ok {
+			return x.Json
+		}
+	}
+	return ""
+}
+
+func (x *SetMazeRequest) GetPng() []byte {
+	if x != nil {
+		if x, ok := x.Maze.(*SetMazeRequest_Png); ok {
+			return x.Png
+		}
+	}
+	return nil
+}
+
+type isSetMazeRequest_Maze interface {
+	isSetMazeRequest_Maze()
+}
+
+type SetMazeRequest_Text struct {
+	Text string `protobuf:"bytes,1,opt,name=text,proto3,oneof"` // the text format of /maze.txt
+}
+
+type SetMazeRequest_Json struct {
+	Json string `protobuf:"bytes,2,opt,name=json,proto3,oneof"` // a CustomMaze as uploaded with POST /maze
+}
+
+type SetMazeRequest_Png struct {
+	Png []byte `protobuf:"bytes,3,opt,name=png,proto3,oneof"` // a maze drawn as a PNG
+}
+
+func (*SetMazeRequest_Text) isSetMazeRequest_Maze() {}
+
+func (*SetMazeRequest_Json) isSetMazeRequest_Maze() {}
+
+func (*SetMazeRequest_Png) isSetMazeRequest_Maze() {}
+
+type SetMazeReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Width         int32                  `protobuf:"varint,1,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32                  `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	PathLength    int32                  `protobuf:"varint,3,opt,name=path_length,json=pathLength,proto3" json:"path_length,omitempty"` // steps from start to goal
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetMazeReply) Reset() {
+	*x = SetMazeReply{}
+	mi := &file_control_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetMazeReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetMazeReply) ProtoMessage() {}
+
+func (x *SetMazeReply) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetMazeReply.ProtoReflect.Descriptor instead.
+func (*SetMazeReply) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SetMazeReply) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *SetMazeReply) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *SetMazeReply) GetPathLength() int32 {
+	if x != nil {
+		return x.PathLength
+	}
+	return 0
+}
+
+type WatchStateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchStateRequest) Reset() {
+	*x = WatchStateRequest{}
+	mi := &file_control_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchStateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchStateRequest) ProtoMessage() {}
+
+func (x *WatchStateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchStateRequest.ProtoReflect.Descriptor instead.
+func (*WatchStateRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{4}
+}
+
+type Player struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	X             int32                  `protobuf:"varint,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y             int32                  `protobuf:"varint,2,opt,name=y,proto3" json:"y,omitempty"`
+	Name          string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Color         string                 `protobuf:"bytes,4,opt,name=color,proto3" json:"color,omitempty"`
+	Finished      bool                   `protobuf:"varint,5,opt,name=finished,proto3" json:"finished,omitempty"`
+	FinishTime    int64                  `protobuf:"varint,6,opt,name=finish_time,json=finishTime,proto3" json:"finish_time,omitempty"` // seconds
+	FinishRank    int32                  `protobuf:"varint,7,opt,name=finish_rank,json=finishRank,proto3" json:"finish_rank,omitempty"`
+	Distance      int32                  `protobuf:"varint,8,opt,name=distance,proto3" json:"distance,omitempty"` // steps left to the goal, -1 if unknown
+	Team          string                 `protobuf:"bytes,9,opt,name=team,proto3" json:"team,omitempty"`
+	Npc           bool                   `protobuf:"varint,10,opt,name=npc,proto3" json:"npc,omitempty"`
+	Ghost         bool                   `protobuf:"varint,11,opt,name=ghost,proto3" json:"ghost,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Player) Reset() {
+	*x = Player{}
+	mi := &file_control_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Player) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Player) ProtoMessage() {}
+
+func (x *Player) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Player.ProtoReflect.Descriptor instead.
+func (*Player) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Player) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *Player) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+func (x *Player) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Player) GetColor() string {
+	if x != nil {
+		return x.Color
+	}
+	return ""
+}
+
+func (x *Player) GetFinished() bool {
+	if x != nil {
+		return x.Finished
+	}
+	return false
+}
+
+func (x *Player) GetFinishTime() int64 {
+	if x != nil {
+		return x.FinishTime
+	}
+	return 0
+}
+
+func (x *Player) GetFinishRank() int32 {
+	if x != nil {
+		return x.FinishRank
+	}
+	return 0
+}
+
+func (x *Player) GetDistance() int32 {
+	if x != nil {
+		return x.Distance
+	}
+	return 0
+}
+
+func (x *Player) GetTeam() string {
+	if x != nil {
+		return x.Team
+	}
+	return ""
+}
+
+func (x *Player) GetNpc() bool {
+	if x != nil {
+		return x.Npc
+	}
+	return false
+}
+
+func (x *Player) GetGhost() bool {
+	if x != nil {
+		return x.Ghost
+	}
+	return false
+}
+
+type GameState struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Players       []*Player              `protobuf:"bytes,1,rep,name=players,proto3" json:"players,omitempty"`
+	AllFinished   bool                   `protobuf:"varint,2,opt,name=all_finished,json=allFinished,proto3" json:"all_finished,omitempty"`
+	GameOver      bool                   `protobuf:"varint,3,opt,name=game_over,json=gameOver,proto3" json:"game_over,omitempty"`
+	TimeLeft      int32                  `protobuf:"varint,4,opt,name=time_left,json=timeLeft,proto3" json:"time_left,omitempty"` // coin mode: seconds until the round ends
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GameState) Reset() {
+	*x = GameState{}
+	mi := &file_control_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GameState) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GameState) ProtoMessage() {}
+
+func (x *GameState) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GameState.ProtoReflect.Descriptor instead.
+func (*GameState) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *GameState) GetPlayers() []*Player {
+	if x != nil {
+		return x.Players
+	}
+	return nil
+}
+
+func (x *GameState) GetAllFinished() bool {
+	if x != nil {
+		return x.AllFinished
+	}
+	return false
+}
+
+func (x *GameState) GetGameOver() bool {
+	if x != nil {
+		return x.GameOver
+	}
+	return false
+}
+
+func (x *GameState) GetTimeLeft() int32 {
+	if x != nil {
+		return x.TimeLeft
+	}
+	return 0
+}
+
+type KickPlayerRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KickPlayerRequest) Reset() {
+	*x = KickPlayerRequest{}
+	mi := &file_control_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KickPlayerRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KickPlayerRequest) ProtoMessage() {}
+
+func (x *KickPlayerRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KickPlayerRequest.ProtoReflect.Descriptor instead.
+func (*KickPlayerRequest) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *KickPlayerRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type KickPlayerReply struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Kicked        int32                  `protobuf:"varint,1,opt,name=kicked,proto3" json:"kicked,omitempty"` // connections closed
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KickPlayerReply) Reset() {
+	*x = KickPlayerReply{}
+	mi := &file_control_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KickPlayerReply) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KickPlayerReply) ProtoMessage() {}
+
+func (x *KickPlayerReply) ProtoReflect() protoreflect.Message {
+	mi := &file_control_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KickPlayerReply.ProtoReflect.Descriptor instead.
+func (*KickPlayerReply) Descriptor() ([]byte, []int) {
+	return file_control_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *KickPlayerReply) GetKicked() int32 {
+	if x != nil {
+		return x.Kicked
+	}
+	return 0
+}
+
+var File_control_proto protoreflect.FileDescriptor
+
+const file_control_proto_rawDesc = "" +
+	"\n" +
+	"\rcontrol.proto\x12\n" +
+	"mazerunner\"E\n" +
+	"\x0fNewRoundRequest\x12\x12\n" +
+	"\x04seed\x18\x01 \x01(\x03R\x04seed\x12\x1e\n" +
+	"\n" +
+	"difficulty\x18\x02 \x01(\tR\n" +
+	"difficulty\"Q\n" +
+	"\rNewRoundReply\x12\x12\n" +
+	"\x04seed\x18\x01 \x01(\x03R\x04seed\x12\x14\n" +
+	"\x05width\x18\x02 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x03 \x01(\x05R\x06height\"X\n" +
+	"\x0eSetMazeRequest\x12\x14\n" +
+	"\x04text\x18\x01 \x01(\tH\x00R\x04text\x12\x14\n" +
+	"\x04json\x18\x02 \x01(\tH\x00R\x04json\x12\x12\n" +
+	"\x03png\x18\x03 \x01(\fH\x00R\x03pngB\x06\n" +
+	"\x04maze\"]\n" +
+	"\fSetMazeReply\x12\x14\n" +
+	"\x05width\x18\x01 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x02 \x01(\x05R\x06height\x12\x1f\n" +
+	"\vpath_length\x18\x03 \x01(\x05R\n" +
+	"pathLength\"\x13\n" +
+	"\x11WatchStateRequest\"\x84\x02\n" +
+	"\x06Player\x12\f\n" +
+	"\x01x\x18\x01 \x01(\x05R\x01x\x12\f\n" +
+	"\x01y\x18\x02 \x01(\x05R\x01y\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12\x14\n" +
+	"\x05color\x18\x04 \x01(\tR\x05color\x12\x1a\n" +
+	"\bfinished\x18\x05 \x01(\bR\bfinished\x12\x1f\n" +
+	"\vfinish_time\x18\x06 \x01(\x03R\n" +
+	"finishTime\x12\x1f\n" +
+	"\vfinish_rank\x18\a \x01(\x05R\n" +
+	"finishRank\x12\x1a\n" +
+	"\bdistance\x18\b \x01(\x05R\bdistance\x12\x12\n" +
+	"\x04team\x18\t \x01(\tR\x04team\x12\x10\n" +
+	"\x03npc\x18\n" +
+	" \x01(\bR\x03npc\x12\x14\n" +
+	"\x05ghost\x18\v \x01(\bR\x05ghost\"\x96\x01\n" +
+	"\tGameState\x12,\n" +
+	"\aplayers\x18\x01 \x03(\v2\x12.mazerunner.PlayerR\aplayers\x12!\n" +
+	"\fall_finished\x18\x02 \x01(\bR\vallFinished\x12\x1b\n" +
+	"\tgame_over\x18\x03 \x01(\bR\bgameOver\x12\x1b\n" +
+	"\ttime_left\x18\x04 \x01(\x05R\btimeLeft\"'\n" +
+	"\x11KickPlayerRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\")\n" +
+	"\x0fKickPlayerReply\x12\x16\n" +
+	"\x06kicked\x18\x01 \x01(\x05R\x06kicked2\x9e\x02\n" +
+	"\aControl\x12B\n" +
+	"\bNewRound\x12\x1b.mazerunner.NewRoundRequest\x1a\x19.mazerunner.NewRoundReply\x12?\n" +
+	"\aSetMaze\x12\x1a.mazerunner.SetMazeRequest\x1a\x18.mazerunner.SetMazeReply\x12D\n" +
+	"\n" +
+	"WatchState\x12\x1d.mazerunner.WatchStateRequest\x1a\x15.mazerunner.GameState0\x01\x12H\n" +
+	"\n" +
+	"KickPlayer\x12\x1d.mazerunner.KickPlayerRequest\x1a\x1b.mazerunner.KickPlayerReplyB\x12Z\x10server/controlpbb\x06proto3"
+
+var (
+	file_control_proto_rawDescOnce sync.Once
+	file_control_proto_rawDescData []byte
+)
+
+func file_control_proto_rawDescGZIP() []byte {
+	file_control_proto_rawDescOnce.Do(func() {
+		file_control_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_control_proto_rawDesc), len(file_control_proto_rawDesc)))
+	})
+	return file_control_proto_rawDescData
+}
+
+var file_control_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_control_proto_goTypes = []any{
+	(*NewRoundRequest)(nil),   // 0: mazerunner.NewRoundRequest
+	(*NewRoundReply)(nil),     // 1: mazerunner.NewRoundReply
+	(*SetMazeRequest)(nil),    // 2: mazerunner.SetMazeRequest
+	(*SetMazeReply)(nil),      // 3: mazerunner.SetMazeReply
+	(*WatchStateRequest)(nil), // 4: mazerunner.WatchStateRequest
+	(*Player)(nil),            // 5: mazerunner.Player
+	(*GameState)(nil),         // 6: mazerunner.GameState
+	(*KickPlayerRequest)(nil), // 7: mazerunner.KickPlayerRequest
+	(*KickPlayerReply)(nil),   // 8: mazerunner.KickPlayerReply
+}
+var file_control_proto_depIdxs = []int32{
+	5, // 0: mazerunner.GameState.players:type_name -> mazerunner.Player
+	0, // 1: mazerunner.Control.NewRound:input_type -> mazerunner.NewRoundRequest
+	2, // 2: mazerunner.Control.SetMaze:input_type -> mazerunner.SetMazeRequest
+	4, // 3: mazerunner.Control.WatchState:input_type -> mazerunner.WatchStateRequest
+	7, // 4: mazerunner.Control.KickPlayer:input_type -> mazerunner.KickPlayerRequest
+	1, // 5: mazerunner.Control.NewRound:output_type -> mazerunner.NewRoundReply
+	3, // 6: mazerunner.Control.SetMaze:output_type -> mazerunner.SetMazeReply
+	6, // 7: mazerunner.Control.WatchState:output_type -> mazerunner.GameState
+	8, // 8: mazerunner.Control.KickPlayer:output_type -> mazerunner.KickPlayerReply
+	5, // [5:9] is the sub-list for method output_type
+	1, // [1:5] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_control_proto_init() }
+func file_control_proto_init() {
+	if File_control_proto != nil {
+		return
+	}
+	file_control_proto_msgTypes[2].OneofWrappers = []any{
+		(*SetMazeRequest_Text)(nil),
+		(*SetMazeRequest_Json)(nil),
+		(*SetMazeRequest_Png)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_control_proto_rawDesc), len(file_control_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_control_proto_goTypes,
+		DependencyIndexes: file_control_proto_depIdxs,
+		MessageInfos:      file_control_proto_msgTypes,
+	}.Build()
+	File_control_proto = out.File
+	file_control_proto_goTypes = nil
+	file_control_proto_depIdxs = nil
+}
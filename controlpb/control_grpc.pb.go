@@ -0,0 +1,261 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: control.proto
+
+package controlpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Control_NewRound_FullMethodName   = "/mazerunner.Control/NewRound"
+	Control_SetMaze_FullMethodName    = "/mazerunner.Control/SetMaze"
+	Control_WatchState_FullMethodName = "/mazerunner.Control/WatchState"
+	Control_KickPlayer_FullMethodName = "/mazerunner.Control/KickPlayer"
+)
+
+// ControlClient is the client API for Control service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Control lets tooling and dashboards run the game server. Calls carry an
+// admin token or API key in the "authorization: Bearer ..." metadata, like
+// the HTTP endpoints.
+type ControlClient interface {
+	// NewRound starts a new round, on the uploaded maze if one is waiting.
+	// Needs the manage-rooms scope.
+	NewRound(ctx context.Context, in *NewRoundRequest, opts ...grpc.CallOption) (*NewRoundReply, error)
+	// SetMaze queues a maze for the next round. Needs the manage-rooms scope.
+	SetMaze(ctx context.Context, in *SetMazeRequest, opts ...grpc.CallOption) (*SetMazeReply, error)
+	// WatchState streams the game state after every change. Needs the
+	// read-state scope.
+	WatchState(ctx context.Context, in *WatchStateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GameState], error)
+	// KickPlayer disconnects the players with the given name. Needs the
+	// admin scope.
+	KickPlayer(ctx context.Context, in *KickPlayerRequest, opts ...grpc.CallOption) (*KickPlayerReply, error)
+}
+
+type controlClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewControlClient(cc grpc.ClientConnInterface) ControlClient {
+	return &controlClient{cc}
+}
+
+func (c *controlClient) NewRound(ctx context.Context, in *NewRoundRequest, opts ...grpc.CallOption) (*NewRoundReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NewRoundReply)
+	err := c.cc.Invoke(ctx, Control_NewRound_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) SetMaze(ctx context.Context, in *SetMazeRequest, opts ...grpc.CallOption) (*SetMazeReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetMazeReply)
+	err := c.cc.Invoke(ctx, Control_SetMaze_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlClient) WatchState(ctx context.Context, in *WatchStateRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GameState], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Control_ServiceDesc.Streams[0], Control_WatchState_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchStateRequest, GameState]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Control_WatchStateClient = grpc.ServerStreamingClient[GameState]
+
+func (c *controlClient) KickPlayer(ctx context.Context, in *KickPlayerRequest, opts ...grpc.CallOption) (*KickPlayerReply, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KickPlayerReply)
+	err := c.cc.Invoke(ctx, Control_KickPlayer_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ControlServer is the server API for Control service.
+// All implementations must embed UnimplementedControlServer
+// for forward compatibility.
+//
+// Control lets tooling and dashboards run the game server. Calls carry an
+// admin token or API key in the "authorization: Bearer ..." metadata, like
+// the HTTP endpoints.
+type ControlServer interface {
+	// NewRound starts a new round, on the uploaded maze if one is waiting.
+	// Needs the manage-rooms scope.
+	NewRound(context.Context, *NewRoundRequest) (*NewRoundReply, error)
+	// SetMaze queues a maze for the next round. Needs the manage-rooms scope.
+	SetMaze(context.Context, *SetMazeRequest) (*SetMazeReply, error)
+	// WatchState streams the game state after every change. Needs the
+	// read-state scope.
+	WatchState(*WatchStateRequest, grpc.ServerStreamingServer[GameState]) error
+	// KickPlayer disconnects the players with the given name. Needs the
+	// admin scope.
+	KickPlayer(context.Context, *KickPlayerRequest) (*KickPlayerReply, error)
+	mustEmbedUnimplementedControlServer()
+}
+
+// UnimplementedControlServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedControlServer struct{}
+
+func (UnimplementedControlServer) NewRound(context.Context, *NewRoundRequest) (*NewRoundReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NewRound not implemented")
+}
+func (UnimplementedControlServer) SetMaze(context.Context, *SetMazeRequest) (*SetMazeReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetMaze not implemented")
+}
+func (UnimplementedControlServer) WatchState(*WatchStateRequest, grpc.ServerStreamingServer[GameState]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchState not implemented")
+}
+func (UnimplementedControlServer) KickPlayer(context.Context, *KickPlayerRequest) (*KickPlayerReply, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method KickPlayer not implemented")
+}
+func (UnimplementedControlServer) mustEmbedUnimplementedControlServer() {}
+func (UnimplementedControlServer) testEmbeddedByValue()                 {}
+
+// UnsafeControlServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ControlServer will
+// result in compilation errors.
+type UnsafeControlServer interface {
+	mustEmbedUnimplementedControlServer()
+}
+
+func RegisterControlServer(s grpc.ServiceRegistrar, srv ControlServer) {
+	// If the following call pancis, it indicates UnimplementedControlServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Control_ServiceDesc, srv)
+}
+
+func _Control_NewRound_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NewRoundRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).NewRound(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_NewRound_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).NewRound(ctx, req.(*NewRoundRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_SetMaze_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetMazeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).SetMaze(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_SetMaze_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).SetMaze(ctx, req.(*SetMazeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Control_WatchState_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchStateRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ControlServer).WatchState(m, &grpc.GenericServerStream[WatchStateRequest, GameState]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Control_WatchStateServer = grpc.ServerStreamingServer[GameState]
+
+func _Control_KickPlayer_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KickPlayerRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServer).KickPlayer(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Control_KickPlayer_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServer).KickPlayer(ctx, req.(*KickPlayerRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Control_ServiceDesc is the grpc.ServiceDesc for Control service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Control_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "mazerunner.Control",
+	HandlerType: (*ControlServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "NewRound",
+			Handler:    _Control_NewRound_Handler,
+		},
+		{
+			MethodName: "SetMaze",
+			Handler:    _Control_SetMaze_Handler,
+		},
+		{
+			MethodName: "KickPlayer",
+			Handler:    _Control_KickPlayer_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchState",
+			Handler:       _Control_WatchState_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "control.proto",
+}
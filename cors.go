@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+var (
+	flagCORSOrigins   = flag.String("cors-origins", "*", "comma-separated origins (https://example.org) whose pages may call the HTTP API, * for any, empty for none but our own")
+	flagWSCheckOrigin = flag.Bool("ws-check-origin", false, "refuse WebSocket connections from browser pages outside -cors-origins")
+)
+
+// corsMaxAge is how long browsers may cache a preflight answer, in seconds.
+const corsMaxAge = "600"
+
+func corsOrigins() []string {
+	var list []string
+	for _, o := range strings.Split(*flagCORSOrigins, ",") {
+		if o = strings.TrimRight(strings.TrimSpace(o), "/"); o != "" {
+			list = append(list, o)
+		}
+	}
+	return list
+}
+
+// originAllowed reports whether a page from origin may use the server. The
+// server's own pages always may.
+func originAllowed(origin, host string) bool {
+	if u, err := url.Parse(origin); err == nil && u.Host == host {
+		return true
+	}
+	list := corsOrigins()
+	return slices.Contains(list, "*") || slices.Contains(list, origin)
+}
+
+// withCORS applies -cors-origins to every response and answers preflight
+// requests.
+func withCORS(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && originAllowed(origin, r.Host)
+		if allowed {
+			if slices.Contains(corsOrigins(), "*") {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Add("Vary", "Origin")
+			}
+			w.Header().Set("Access-Control-Expose-Headers", "X-Join-URL")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if !allowed {
+				http.Error(w, "origin not allowed", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, X-API-Key, X-Admin-Token, X-Session")
+			w.Header().Set("Access-Control-Max-Age", corsMaxAge)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// wsServer wraps a WebSocket handler, checking the Origin header against
+// -cors-origins if -ws-check-origin is set, and announces the protocol
+// version. Like websocket.Handler it refuses requests without an Origin.
+func wsServer(h websocket.Handler) websocket.Server {
+	return websocket.Server{
+		Handler: h,
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			if *flagWSCheckOrigin && !originAllowed(r.Header.Get("Origin"), r.Host) {
+				return websocket.ErrBadWebSocketOrigin
+			}
+			config.Header = protocolHeader()
+			var err error
+			config.Origin, err = websocket.Origin(config, r)
+			if err == nil && config.Origin == nil {
+				return websocket.ErrBadWebSocketOrigin
+			}
+			return err
+		},
+	}
+}
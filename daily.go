@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"hash/fnv"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var flagDaily = flag.Bool("daily", false, "daily challenge: the maze seed comes from the UTC date and times count from each player's join")
+
+// dailyKeepDays is how many past daily leaderboards are kept.
+const dailyKeepDays = 31
+
+type DailyEntry struct {
+	Name       string    `json:"name"`
+	Color      string    `json:"color"`
+	Time       int64     `json:"time"` // seconds
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+type DailyBoard struct {
+	Date    string       `json:"date"`
+	Seed    int64        `json:"seed"`
+	Entries []DailyEntry `json:"entries"`
+}
+
+// DailyStore keeps the daily challenge boards: each name's best time of
+// the day.
+type DailyStore interface {
+	RecordDaily(date string, e DailyEntry) error
+	Daily(date string) ([]DailyEntry, error)
+}
+
+// memoryDaily keeps the boards of the last dailyKeepDays days in memory.
+type memoryDaily struct {
+	mu     sync.Mutex
+	boards map[string][]DailyEntry
+}
+
+var dailyStore DailyStore = &memoryDaily{boards: make(map[string][]DailyEntry)}
+
+func dailyDate(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// dailySeed derives the maze seed for the UTC day of t, so every server
+// running the daily challenge builds the same maze.
+func dailySeed(t time.Time) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("mazerunner-daily-" + dailyDate(t)))
+	return int64(h.Sum64() >> 11)
+}
+
+// dailyDay is the date whose maze the round is played on, guarded by mu.
+var dailyDay string
+
+// errDailyMaze refuses what would replace the day's maze.
+var errDailyMaze = mazeErr("daily", "the daily challenge keeps the day's maze until midnight UTC")
+
+// dailyOnly turns off what would reshape the day's maze before the
+// rollover: earthquakes, the collapse and scheduled new mazes. Uploads and
+// the host's restarts are refused with errDailyMaze.
+func dailyOnly() {
+	if *flagQuakeEvery > 0 || *flagCollapseEvery > 0 || regenSchedule != nil {
+		log.Printf("Daily challenge: earthquakes, the collapse and the maze schedule are off, the maze stays the same all day")
+	}
+	*flagQuakeEvery, *flagCollapseEvery, regenSchedule = 0, 0, nil
+}
+
+// recordDailyFinish adds p's finish to the board of the day whose maze it
+// ran, even if it reached the goal after midnight. Caller holds mu.
+func recordDailyFinish(p Player) {
+	e := DailyEntry{Name: p.Name, Color: p.Color, Time: p.FinishTime, FinishedAt: time.Now()}
+	if err := dailyStore.RecordDaily(dailyDay, e); err != nil {
+		log.Printf("Could not record daily finish: %v", err)
+	}
+}
+
+func (m *memoryDaily) RecordDaily(date string, e DailyEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entries := m.boards[date]
+	for i, o := range entries {
+		if o.Name == e.Name {
+			if e.Time < o.Time {
+				entries[i] = e
+			}
+			return nil
+		}
+	}
+	m.boards[date] = append(entries, e)
+
+	cutoff := dailyDate(time.Now().AddDate(0, 0, -dailyKeepDays))
+	for d := range m.boards {
+		if d < cutoff {
+			delete(m.boards, d)
+		}
+	}
+	return nil
+}
+
+func (m *memoryDaily) Daily(date string) ([]DailyEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]DailyEntry{}, m.boards[date]...), nil
+}
+
+// runDailyRollover starts a new round with the next day's maze at every UTC midnight.
+func runDailyRollover() {
+	for {
+		now := time.Now().UTC()
+		next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		time.Sleep(next.Sub(now))
+		log.Printf("Daily challenge rollover to %s", dailyDate(next))
+		if err := resetGame(0, ""); err != nil {
+			log.Printf("Daily rollover failed: %v", err)
+		}
+	}
+}
+
+func handleDaily(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = dailyDate(time.Now())
+	}
+	day, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		http.Error(w, "invalid date, want YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = n
+		}
+	}
+
+	entries, err := dailyStore.Daily(date)
+	if err != nil {
+		log.Printf("Daily board query failed: %v", err)
+		http.Error(w, "could not load the daily board", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Time != entries[j].Time {
+			return entries[i].Time < entries[j].Time
+		}
+		return entries[i].FinishedAt.Before(entries[j].FinishedAt)
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	json.NewEncoder(w).Encode(DailyBoard{Date: date, Seed: dailySeed(day), Entries: entries})
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"flag"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var flagDirectorInterval = flag.Duration("director-interval", 500*time.Millisecond, "how often the broadcast overlay feed on /director is updated (0 disables it)")
+
+// The director feed is the race as commentary dashboards and stream
+// overlays need it: standings, how far everyone is from the goal, split
+// times against the fastest runner and who is about to finish. It follows
+// -spectator-delay like the other spectator feeds.
+
+// splitMarks are where split times are taken, in percent of the shortest
+// path.
+var splitMarks = []int{25, 50, 75}
+
+// DirectorView is one update of the director feed.
+type DirectorView struct {
+	Type       string            `json:"type"` // "director"
+	Seed       int64             `json:"seed"`
+	Width      int               `json:"width"`
+	Height     int               `json:"height"`
+	PathLength int               `json:"pathLength"`
+	Elapsed    int64             `json:"elapsed"` // milliseconds since the round started
+	GameOver   bool              `json:"gameOver"`
+	Record     *LeaderboardEntry `json:"record,omitempty"`  // fastest finish ever on this maze size
+	Closest    string            `json:"closest,omitempty"` // name of the runner nearest the goal
+	Standings  []DirectorEntry   `json:"standings"`
+}
+
+// DirectorEntry is a player's place in the race.
+type DirectorEntry struct {
+	Position   int     `json:"position"`
+	Name       string  `json:"name"`
+	Color      string  `json:"color"`
+	Team       string  `json:"team,omitempty"`
+	Finished   bool    `json:"finished"`
+	FinishTime int64   `json:"finishTime,omitempty"` // seconds
+	Eliminated bool    `json:"eliminated,omitempty"`
+	Distance   int     `json:"distance"` // steps left to the goal, -1 if unknown
+	Progress   int     `json:"progress"` // percent of the shortest path covered
+	Gap        int     `json:"gap"`      // steps behind the runner in front, 0 for the leader and finishers
+	Splits     []Split `json:"splits"`
+	Closest    bool    `json:"closest,omitempty"`
+}
+
+// Split is the time a player reached one of the splitMarks.
+type Split struct {
+	Mark  int   `json:"mark"`  // percent of the path
+	Time  int64 `json:"time"`  // milliseconds into the player's round
+	Delta int64 `json:"delta"` // milliseconds behind the first to reach the mark
+}
+
+var (
+	directorMu        sync.Mutex
+	directorListeners = make(map[chan []byte]bool)
+	pastDirector      pastFeed
+)
+
+// progress is the share of the shortest path a player at dist has covered,
+// in percent.
+func progress(dist int) int {
+	length := mazeMetrics.PathLength
+	if dist < 0 || length <= 0 {
+		return 0
+	}
+	return min(100, max(0, 100-dist*100/length))
+}
+
+// trackSplits notes the marks p passed since the last broadcast. Caller
+// holds mu.
+func trackSplits(p *Player) {
+	if p.Finished || p.Distance < 0 {
+		return
+	}
+	pct := progress(p.Distance)
+	for len(p.splits) < len(splitMarks) && pct >= splitMarks[len(p.splits)] {
+		p.splits = append(p.splits, clock().Sub(roundStartFor(p)).Milliseconds())
+	}
+}
+
+// directorView builds the current update. Caller holds mu.
+func directorView() DirectorView {
+	v := DirectorView{
+		Type:       "director",
+		Seed:       mazeSeed,
+		Width:      mazeWidth,
+		Height:     mazeHeight,
+		PathLength: mazeMetrics.PathLength,
+		Elapsed:    clock().Sub(startTime).Milliseconds(),
+		GameOver:   gameOver,
+		Standings:  []DirectorEntry{},
+	}
+	podiumMu.Lock()
+	if len(podium) > 0 && !coinMode() {
+		record := podium[0]
+		v.Record = &record
+	}
+	podiumMu.Unlock()
+	players := make([]*Player, 0, len(clients))
+	for _, p := range clients {
+		players = append(players, p)
+	}
+	// Finishers by rank, then runners by distance, then the eliminated.
+	slices.SortFunc(players, func(a, b *Player) int {
+		return cmp.Or(
+			cmp.Compare(standingGroup(a), standingGroup(b)),
+			cmp.Compare(a.FinishRank, b.FinishRank),
+			cmp.Compare(uint(a.Distance), uint(b.Distance)), // unknown (-1) last
+			strings.Compare(a.Name, b.Name),
+		)
+	})
+	fastest := make([]int64, len(splitMarks))
+	for _, p := range players {
+		for i, t := range p.splits {
+			if fastest[i] == 0 || t < fastest[i] {
+				fastest[i] = t
+			}
+		}
+	}
+	ahead := -1
+	for i, p := range players {
+		e := DirectorEntry{
+			Position:   i + 1,
+			Name:       p.Name,
+			Color:      p.Color,
+			Team:       p.Team,
+			Finished:   p.Finished,
+			FinishTime: p.FinishTime,
+			Eliminated: p.Eliminated,
+			Distance:   p.Distance,
+			Progress:   progress(p.Distance),
+			Splits:     []Split{},
+		}
+		if p.Finished {
+			e.Progress = 100
+		}
+		for j, t := range p.splits {
+			e.Splits = append(e.Splits, Split{Mark: splitMarks[j], Time: t, Delta: t - fastest[j]})
+		}
+		if standingGroup(p) == 1 && p.Distance >= 0 {
+			if ahead >= 0 {
+				e.Gap = p.Distance - ahead
+			} else {
+				e.Closest = true
+				v.Closest = p.Name
+			}
+			ahead = p.Distance
+		}
+		v.Standings = append(v.Standings, e)
+	}
+	return v
+}
+
+// standingGroup orders finishers before runners before the eliminated.
+func standingGroup(p *Player) int {
+	switch {
+	case p.Finished:
+		return 0
+	case p.Eliminated:
+		return 2
+	}
+	return 1
+}
+
+// runDirector sends the director feed every -director-interval. While
+// nobody races and nothing changes it stays quiet.
+func runDirector() {
+	var last []byte // the previous update, without its clock
+	for range time.Tick(*flagDirectorInterval) {
+		mu.Lock()
+		v := directorView()
+		mu.Unlock()
+		data, _ := json.Marshal(v)
+		v.Elapsed = 0
+		still, _ := json.Marshal(v)
+		if !racing(v) && bytes.Equal(still, last) {
+			continue
+		}
+		last = still
+		directorMu.Lock()
+		pastDirector.keep(data)
+		for ch := range directorListeners {
+			select {
+			case ch <- data:
+			default: // a slow overlay misses an update, the next one catches up
+			}
+		}
+		directorMu.Unlock()
+	}
+}
+
+// racing reports whether someone is still on the way to the goal.
+func racing(v DirectorView) bool {
+	return !v.GameOver && slices.ContainsFunc(v.Standings, func(e DirectorEntry) bool { return !e.Finished && !e.Eliminated })
+}
+
+// listenDirector registers a listener for the director feed; stop
+// unregisters it.
+func listenDirector() (ch chan []byte, stop func()) {
+	in := make(chan []byte, 16)
+	directorMu.Lock()
+	directorListeners[in] = true
+	directorMu.Unlock()
+	done := make(chan struct{})
+	stop = func() {
+		directorMu.Lock()
+		delete(directorListeners, in)
+		directorMu.Unlock()
+		close(done)
+	}
+	if *flagSpectatorDelay <= 0 {
+		return in, stop
+	}
+	return delayed(in, *flagSpectatorDelay, done), stop
+}
+
+// latestDirector is the update spectators may see now, nil if there is
+// none yet.
+func latestDirector() []byte {
+	directorMu.Lock()
+	defer directorMu.Unlock()
+	return pastDirector.latest()
+}
+
+// handleDirector serves /director: the latest update as JSON, or as a
+// WebSocket the latest update followed by every new one.
+func handleDirector(w http.ResponseWriter, r *http.Request) {
+	if *flagDirectorInterval <= 0 {
+		http.Error(w, "the director feed is disabled", http.StatusNotFound)
+		return
+	}
+	if credential(r) != "" {
+		if _, ok := authorize(r, scopeReadState); !ok {
+			http.Error(w, "invalid API key", http.StatusUnauthorized)
+			return
+		}
+	}
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		w.Header().Set("Cache-Control", "no-store")
+		data := latestDirector()
+		if data == nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(flagSpectatorDelay.Seconds()+0.5)))
+			http.Error(w, "the director feed starts shortly", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+		return
+	}
+	srv := wsServer(func(ws *websocket.Conn) {
+		defer ws.Close()
+		ch, stop := listenDirector()
+		defer stop()
+		if data := latestDirector(); data != nil {
+			if websocket.Message.Send(ws, string(data)) != nil {
+				return
+			}
+		}
+		// The feed is one-way; reading only notices the overlay leaving.
+		closed := make(chan struct{})
+		go func() {
+			var discard []byte
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+			close(closed)
+		}()
+		for {
+			select {
+			case msg := <-ch:
+				if websocket.Message.Send(ws, string(msg)) != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	})
+	srv.ServeHTTP(w, r)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+var (
+	flagDiscordWebhook   = flag.String("discord-webhook", "", "Discord channel webhook URL that gets round starts, winners and new records (empty disables)")
+	flagDiscordPublicKey = flag.String("discord-public-key", "", "public key of the Discord application, enables the /discord/interactions endpoint for slash commands")
+	flagDiscordAppID     = flag.String("discord-app-id", "", "Discord application id, used with -discord-bot-token to register the slash commands at startup")
+	flagDiscordBotToken  = flag.String("discord-bot-token", "", "Discord bot token, used with -discord-app-id to register the slash commands at startup")
+)
+
+const discordAPI = "https://discord.com/api/v10"
+
+// discordCommands are the slash commands. Only members allowed to manage
+// the server see /maze-reset by default.
+var discordCommands = []map[string]any{
+	{"name": "maze-standings", "description": "Show the standings of the running maze round"},
+	{"name": "maze-reset", "description": "Start a new maze round", "default_member_permissions": "32"},
+}
+
+// notifyDiscord posts the game events worth a message to the channel.
+func notifyDiscord(event string, data any) {
+	if *flagDiscordWebhook == "" {
+		return
+	}
+	var msg string
+	switch event {
+	case hookRoundStart:
+		if d, ok := data.(map[string]any); ok {
+			msg = fmt.Sprintf("🏁 A new round has started on a %vx%v maze (seed %v).", d["width"], d["height"], d["seed"])
+		}
+	case hookFinish:
+		if e, ok := data.(LeaderboardEntry); ok && e.Rank == 1 {
+			msg = fmt.Sprintf("🏆 **%s** wins the round in %s!", e.Name, formatSeconds(e.Time))
+		}
+	case hookNewRecord:
+		if d, ok := data.(map[string]any); ok {
+			e, _ := d["finish"].(LeaderboardEntry)
+			msg = fmt.Sprintf("⚡ New record on seed %d: **%s** in %s", e.Seed, e.Name, formatSeconds(e.Time))
+			if prev, ok := d["previous"].(LeaderboardEntry); ok {
+				msg += fmt.Sprintf(", beating %s's %s", prev.Name, formatSeconds(prev.Time))
+			}
+		}
+	}
+	if msg == "" {
+		return
+	}
+	body, _ := json.Marshal(map[string]any{"content": msg, "allowed_mentions": map[string]any{"parse": []string{}}})
+	go deliverWebhook(Webhook{Name: "discord", URL: *flagDiscordWebhook}, event, body)
+}
+
+func formatSeconds(s int64) string {
+	return fmt.Sprintf("%d:%02d", s/60, s%60)
+}
+
+// registerDiscordCommands installs the slash commands for the application.
+func registerDiscordCommands() {
+	if *flagDiscordAppID == "" || *flagDiscordBotToken == "" {
+		return
+	}
+	body, _ := json.Marshal(discordCommands)
+	req, _ := http.NewRequest(http.MethodPut, discordAPI+"/applications/"+*flagDiscordAppID+"/commands", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bot "+*flagDiscordBotToken)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := hookClient.Do(req)
+	if err != nil {
+		log.Printf("Could not register Discord commands: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Printf("Could not register Discord commands: %s", resp.Status)
+		return
+	}
+	log.Printf("Discord slash commands registered")
+}
+
+// standingsText renders the running round for chat.
+func standingsText() string {
+	mu.Lock()
+	list := make([]Player, 0, len(clients))
+	for _, p := range clients {
+		list = append(list, *p)
+		list[len(list)-1].Distance = distanceToGoal(p.X, p.Y)
+	}
+	mu.Unlock()
+	if len(list) == 0 {
+		return "Nobody is playing right now."
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.Finished != b.Finished {
+			return a.Finished
+		}
+		if a.Finished {
+			return a.FinishRank < b.FinishRank
+		}
+		return a.Distance < b.Distance
+	})
+	var sb strings.Builder
+	for i, p := range list {
+		if p.Finished {
+			fmt.Fprintf(&sb, "%d. **%s** finished in %s\n", i+1, p.Name, formatSeconds(p.FinishTime))
+		} else {
+			fmt.Fprintf(&sb, "%d. %s, %d steps to go\n", i+1, p.Name, p.Distance)
+		}
+	}
+	return sb.String()
+}
+
+// handleDiscordInteractions answers Discord's slash command callbacks.
+// Every request must carry a valid signature from the application.
+func handleDiscordInteractions(w http.ResponseWriter, r *http.Request) {
+	key, err := hex.DecodeString(*flagDiscordPublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		http.Error(w, "Discord interactions are not configured", http.StatusNotFound)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	sig, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+	if err != nil || !ed25519.Verify(key, append([]byte(r.Header.Get("X-Signature-Timestamp")), body...), sig) {
+		http.Error(w, "invalid request signature", http.StatusUnauthorized)
+		return
+	}
+	var in struct {
+		Type int `json:"type"`
+		Data struct {
+			Name string `json:"name"`
+		} `json:"data"`
+		Member struct {
+			User struct {
+				Username string `json:"username"`
+			} `json:"user"`
+		} `json:"member"`
+	}
+	if err := json.Unmarshal(body, &in); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if in.Type == 1 { // ping
+		json.NewEncoder(w).Encode(map[string]int{"type": 1})
+		return
+	}
+	var reply string
+	switch in.Data.Name {
+	case "maze-standings":
+		reply = standingsText()
+	case "maze-reset":
+		log.Println("Game reset requested from Discord")
+		recordAudit(AuditEntry{Actor: "discord:" + in.Member.User.Username, Action: "reset", Remote: r.RemoteAddr})
+		go func() {
+			if err := resetGame(0, ""); err != nil {
+				log.Printf("Discord reset failed: %v", err)
+			}
+		}()
+		reply = "Starting a new round."
+	default:
+		reply = "Unknown command."
+	}
+	json.NewEncoder(w).Encode(map[string]any{"type": 4, "data": map[string]any{"content": reply, "allowed_mentions": map[string]any{"parse": []string{}}}})
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxExportPixels bounds the side length of rendered maze images.
+const maxExportPixels = 2048
+
+// Colors of the rendered mazes, as the page draws them.
+var (
+	exportBackground = color.RGBA{0x1a, 0x1a, 0x1a, 255}
+	exportGoal       = color.RGBA{0xd4, 0xaa, 0x00, 255}
+	cellColors       = map[int]color.RGBA{
+		cellWall:  {0x2a, 0x2a, 0x2e, 255},
+		cellEast:  {0x5a, 0x4a, 0x2a, 255},
+		cellSouth: {0x5a, 0x4a, 0x2a, 255},
+		cellWest:  {0x5a, 0x4a, 0x2a, 255},
+		cellNorth: {0x5a, 0x4a, 0x2a, 255},
+		cellIce:   {0x1f, 0x3a, 0x4a, 255},
+		cellMud:   {0x3a, 0x2a, 0x18, 255},
+	}
+)
+
+// cellColor is how a grid cell is drawn.
+func cellColor(v int) color.RGBA {
+	if c, ok := cellColors[v]; ok {
+		return c
+	}
+	return exportBackground
+}
+
+// A paletteBuilder collects the colors of a paletted image as they are
+// used. Beyond 256 colors new ones get the closest color already in it.
+type paletteBuilder struct {
+	pal   color.Palette
+	index map[color.RGBA]uint8
+}
+
+func newPaletteBuilder(base ...color.RGBA) *paletteBuilder {
+	b := &paletteBuilder{index: map[color.RGBA]uint8{}}
+	for _, c := range base {
+		b.of(c)
+	}
+	return b
+}
+
+// of returns the palette index of c, adding c if there is room.
+func (b *paletteBuilder) of(c color.RGBA) uint8 {
+	if i, ok := b.index[c]; ok {
+		return i
+	}
+	if len(b.pal) == 256 {
+		return uint8(b.pal.Index(c))
+	}
+	b.index[c] = uint8(len(b.pal))
+	b.pal = append(b.pal, c)
+	return b.index[c]
+}
+
+// queryInt reads an integer query parameter clamped to [lo, hi].
+func queryInt(r *http.Request, name string, def, lo, hi int) int {
+	v, err := strconv.Atoi(r.URL.Query().Get(name))
+	if err != nil {
+		return def
+	}
+	return max(lo, min(hi, v))
+}
+
+// queryBool reads a 0/1/true/false query parameter.
+func queryBool(r *http.Request, name string, def bool) bool {
+	v, err := strconv.ParseBool(r.URL.Query().Get(name))
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// The last /maze.png rendered is kept: an embedded thumbnail is fetched
+// over and over while the maze and players stay the same.
+var (
+	mazePNGMu    sync.Mutex
+	mazePNGKey   string
+	mazePNGCache []byte
+)
+
+// handleMazePNG renders the current maze. Query parameters: cell (pixels per
+// cell), goal and players (0 to leave them out).
+func handleMazePNG(w http.ResponseWriter, r *http.Request) {
+	cell := queryInt(r, "cell", 8, 1, 64)
+	showGoal := queryBool(r, "goal", true)
+	showPlayers := queryBool(r, "players", true)
+
+	mu.Lock()
+	grid, gx, gy := maze, goalX, goalY
+	var players []Player
+	if showPlayers {
+		for _, p := range clients {
+			if !p.Finished {
+				players = append(players, *p)
+			}
+		}
+	}
+	mu.Unlock()
+	if len(grid) == 0 {
+		http.Error(w, "no maze has been generated", http.StatusNotFound)
+		return
+	}
+	h, wd := len(grid), len(grid[0])
+	cell = max(1, min(cell, maxExportPixels/max(wd, h)))
+
+	key := fmt.Sprintf("%d %d %t", currentMazeRevision(), cell, showGoal)
+	for _, p := range players {
+		key += fmt.Sprintf(" %d,%d%s", p.X, p.Y, p.Color)
+	}
+	mazePNGMu.Lock()
+	defer mazePNGMu.Unlock()
+	if key != mazePNGKey {
+		pal := newPaletteBuilder(exportBackground, exportGoal)
+		img := image.NewPaletted(image.Rect(0, 0, wd*cell, h*cell), nil)
+		fillCell := func(x, y int, i uint8) {
+			for py := y * cell; py < (y+1)*cell; py++ {
+				row := img.Pix[py*img.Stride:]
+				for px := x * cell; px < (x+1)*cell; px++ {
+					row[px] = i
+				}
+			}
+		}
+		for y, row := range grid {
+			for x, v := range row {
+				if v != cellFloor {
+					fillCell(x, y, pal.of(cellColor(v)))
+				}
+			}
+		}
+		if showGoal {
+			fillCell(gx, gy, pal.of(exportGoal))
+		}
+		for _, p := range players {
+			fillCell(p.X, p.Y, pal.of(parseHexColor(p.Color, color.RGBA{0xff, 0, 0, 255})))
+		}
+		img.Palette = pal.pal
+		var buf bytes.Buffer
+		png.Encode(&buf, img)
+		mazePNGKey, mazePNGCache = key, buf.Bytes()
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(mazePNGCache)
+}
+
+// queryColor reads a #rrggbb (or rrggbb) query parameter.
+func queryColor(r *http.Request, name, def string) string {
+	v := r.URL.Query().Get(name)
+	if !strings.HasPrefix(v, "#") {
+		v = "#" + v
+	}
+	if !hexColorRE.MatchString(v) {
+		return def
+	}
+	return v
+}
+
+// svgArrows are the one-way cells drawn as triangles pointing their way,
+// in a unit cell.
+var svgArrows = map[int]string{
+	cellEast:  ".25,.2 .8,.5 .25,.8",
+	cellSouth: ".2,.25 .8,.25 .5,.8",
+	cellWest:  ".75,.2 .2,.5 .75,.8",
+	cellNorth: ".2,.75 .8,.75 .5,.2",
+}
+
+// handleMazeSVG renders the current maze as SVG. Query parameters: cell,
+// wall, bg, goalColor, ice, mud and arrow (colors as rrggbb), goal and
+// markers (0 to hide).
+func handleMazeSVG(w http.ResponseWriter, r *http.Request) {
+	cell := queryInt(r, "cell", 10, 1, 100)
+	wallCol := queryColor(r, "wall", "#2a2a2e")
+	bgCol := queryColor(r, "bg", "#ffffff")
+	goalCol := queryColor(r, "goalColor", "#d4aa00")
+	terrainCols := map[int]string{
+		cellIce: queryColor(r, "ice", "#a8d8ea"),
+		cellMud: queryColor(r, "mud", "#c8a878"),
+	}
+	arrowCol := queryColor(r, "arrow", "#8a6d3b")
+
+	mu.Lock()
+	grid, gx, gy := maze, goalX, goalY
+	mu.Unlock()
+	if len(grid) == 0 {
+		http.Error(w, "no maze has been generated", http.StatusNotFound)
+		return
+	}
+	h, wd := len(grid), len(grid[0])
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+"\n", wd*cell, h*cell, wd, h)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="%s"/>`+"\n", wd, h, bgCol)
+	fmt.Fprintf(&b, `<g fill="%s">`+"\n", wallCol)
+	// One rect per horizontal run of walls keeps the file small.
+	for y, row := range grid {
+		for x := 0; x < wd; {
+			if row[x] != 1 {
+				x++
+				continue
+			}
+			run := x
+			for run < wd && row[run] == 1 {
+				run++
+			}
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="1"/>`, x, y, run-x)
+			x = run
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString("</g>\n")
+	for y, row := range grid {
+		for x, v := range row {
+			if col, ok := terrainCols[v]; ok {
+				fmt.Fprintf(&b, `<rect x="%d" y="%d" width="1" height="1" fill="%s"/>`+"\n", x, y, col)
+			} else if pts, ok := svgArrows[v]; ok {
+				fmt.Fprintf(&b, `<polygon transform="translate(%d %d)" points="%s" fill="%s"/>`+"\n", x, y, pts, arrowCol)
+			}
+		}
+	}
+	if queryBool(r, "goal", true) {
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="1" height="1" fill="%s"/>`+"\n", gx, gy, goalCol)
+	}
+	if queryBool(r, "markers", true) {
+		for _, m := range currentMarkers() {
+			col := m.Color
+			if col == "" {
+				col = "#4a9eff"
+			}
+			fmt.Fprintf(&b, `<rect x="%g" y="%g" width=".8" height=".8" fill="none" stroke="%s" stroke-width=".15"/>`, float64(m.X)+.1, float64(m.Y)+.1, col)
+			if m.Label != "" {
+				fmt.Fprintf(&b, `<text x="%g" y="%g" font-size=".8" text-anchor="middle" font-family="sans-serif" fill="%s">%s</text>`, float64(m.X)+.5, float64(m.Y)-.1, col, html.EscapeString(m.Label))
+			}
+			b.WriteByte('\n')
+		}
+	}
+	b.WriteString("</svg>\n")
+
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write([]byte(b.String()))
+}
+
+// mazeText encodes a maze in the -maze-file text format.
+func mazeText(grid [][]int, start, goal point, portals [][2]point) string {
+	var b strings.Builder
+	for y, row := range grid {
+		for x, v := range row {
+			switch {
+			case x == start.X && y == start.Y:
+				b.WriteByte('S')
+			case x == goal.X && y == goal.Y:
+				b.WriteByte('G')
+			case v == cellWall:
+				b.WriteByte('#')
+			case terrainRunes[v] != 0:
+				b.WriteRune(terrainRunes[v])
+			default:
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	for _, pr := range portals {
+		fmt.Fprintf(&b, "portal %d %d %d %d\n", pr[0].X, pr[0].Y, pr[1].X, pr[1].Y)
+	}
+	return b.String()
+}
+
+// handleMazeText serves the current maze as text (GET) and queues a text
+// maze for the next round (POST, admin only), so mazes can be copied
+// between servers.
+func handleMazeText(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		requireAdmin(func(w http.ResponseWriter, r *http.Request) {
+			m, err := customMazeFromText(http.MaxBytesReader(w, r.Body, 64<<20))
+			if err != nil {
+				writeMazeError(w, err, http.StatusUnprocessableEntity)
+				return
+			}
+			queueCustomMaze(w, r, m)
+		})(w, r)
+		return
+	}
+	mu.Lock()
+	grid, start, goal, pairs, seed := maze, point{startX, startY}, point{goalX, goalY}, portals, mazeSeed
+	mu.Unlock()
+	if len(grid) == 0 {
+		http.Error(w, "no maze has been generated", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if queryBool(r, "download", false) {
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="maze-%dx%d-%d.txt"`, len(grid[0]), len(grid), seed))
+	}
+	fmt.Fprint(w, mazeText(grid, start, goal, pairs))
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var flagGhost = flag.Bool("ghost", false, "time attack: replay the best recorded run on the current seed as a ghost to race against (needs -replays)")
+
+// ghostRun is a recorded run played back alongside the live round.
+type ghostRun struct {
+	name  string
+	color string
+	time  int64       // the run's finish time in seconds
+	steps []ghostStep // in time order
+}
+
+type ghostStep struct {
+	t    int64 // milliseconds since the round started
+	x, y int
+}
+
+var ghost *ghostRun // guarded by mu
+
+// loadGhost picks the fastest finish among the stored replays of the
+// current seed. Caller holds mu.
+func loadGhost() {
+	ghost = nil
+	if !*flagGhost || *flagReplays == "" || mazeSeed == 0 {
+		return
+	}
+	for _, run := range replayRuns(mazeSeed, mazeWidth, mazeHeight) {
+		if ghost == nil || run.time < ghost.time {
+			ghost = run
+		}
+	}
+	if ghost != nil {
+		log.Printf("GHOST: %s's run of %ds is the time to beat", ghost.name, ghost.time)
+	}
+}
+
+// replayRuns returns the fastest run of every stored replay of the w x h
+// maze built from seed.
+func replayRuns(seed int64, w, h int) []*ghostRun {
+	entries, err := os.ReadDir(*flagReplays)
+	if err != nil {
+		return nil
+	}
+	suffix := "-" + strconv.FormatInt(seed, 10) + replaySuffix
+	var runs []*ghostRun
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), suffix) {
+			continue
+		}
+		events, err := loadReplay(strings.TrimSuffix(e.Name(), replaySuffix))
+		if err != nil || len(events) == 0 || len(events[0].Maze) != h || len(events[0].Maze[0]) != w {
+			continue
+		}
+		if run := bestRun(events); run != nil {
+			runs = append(runs, run)
+		}
+	}
+	return runs
+}
+
+// worldRecordRun is the replayed run of the leaderboard's record on the
+// w x h maze built from seed. Only finishes the leaderboard took count, so
+// the record was neither assisted nor from a coin round. nil if there is no
+// record or its replay has been pruned.
+func worldRecordRun(seed int64, w, h int) *ghostRun {
+	if leaderboard == nil || *flagReplays == "" {
+		return nil
+	}
+	top, err := leaderboard.Top(LeaderboardQuery{Width: w, Height: h, Seed: &seed, Limit: 1})
+	if err != nil {
+		log.Printf("Leaderboard query failed: %v", err)
+		return nil
+	}
+	if len(top) == 0 {
+		return nil
+	}
+	for _, run := range replayRuns(seed, w, h) {
+		if run.time == top[0].Time && run.name == top[0].Name {
+			return run
+		}
+	}
+	return nil
+}
+
+// bestRun extracts the fastest finisher's path from a replay. Coin rounds
+// have no race to replay.
+func bestRun(events []replayEvent) *ghostRun {
+	if s := events[0].Settings; s != nil && s.Coins > 0 {
+		return nil
+	}
+	best := 0
+	var bestTime int64
+	for _, e := range events {
+		if e.E == "finish" && (best == 0 || e.Time < bestTime) {
+			best, bestTime = e.P, e.Time
+		}
+	}
+	if best == 0 {
+		return nil
+	}
+	run := &ghostRun{time: bestTime}
+	for _, e := range events {
+		if e.P != best {
+			continue
+		}
+		switch e.E {
+		case "join":
+			run.name, run.color = e.Name, e.Color
+			fallthrough
+		case "move":
+			run.steps = append(run.steps, ghostStep{e.T, e.X, e.Y})
+		case "finish":
+			return run
+		}
+	}
+	return run
+}
+
+// at is where the run was elapsed milliseconds in. ok is false once it
+// has finished.
+func (g *ghostRun) at(elapsed int64) (point, bool) {
+	if len(g.steps) == 0 || elapsed >= g.time*1000 && elapsed > g.steps[len(g.steps)-1].t {
+		return point{}, false
+	}
+	i := sort.Search(len(g.steps), func(i int) bool { return g.steps[i].t > elapsed })
+	s := g.steps[max(0, i-1)]
+	return point{s.x, s.y}, true
+}
+
+// ghostPlayer is the ghost as it appears in state broadcasts. ok is false
+// if there is none or it has already finished. Caller holds mu.
+func ghostPlayer() (Player, bool) {
+	if ghost == nil {
+		return Player{}, false
+	}
+	c, ok := ghost.at(clock().Sub(startTime).Milliseconds())
+	if !ok {
+		return Player{}, false
+	}
+	return Player{X: c.X, Y: c.Y, Name: ghost.name, Color: ghost.color, FinishTime: ghost.time, Distance: distanceToGoal(c.X, c.Y), Ghost: true}, true
+}
+
+// runGhost keeps the ghost moving on screen between player moves.
+func runGhost() {
+	for range time.Tick(100 * time.Millisecond) {
+		mu.Lock()
+		_, active := ghostPlayer()
+		active = active && !gameOver && len(clients) > 0
+		mu.Unlock()
+		if active {
+			broadcast()
+		}
+	}
+}
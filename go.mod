@@ -2,4 +2,27 @@ module server
 
 go 1.25.0
 
-require golang.org/x/net v0.50.0
+require (
+	golang.org/x/image v0.25.0
+	golang.org/x/net v0.53.0
+	golang.org/x/sys v0.43.0
+)
+
+require (
+	github.com/grandcat/zeroconf v1.0.0
+	github.com/mattn/go-sqlite3 v1.14.33
+	github.com/redis/go-redis/v9 v9.17.2
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	golang.org/x/crypto v0.50.0
+	google.golang.org/grpc v1.82.1
+	google.golang.org/protobuf v1.36.11
+)
+
+require (
+	github.com/cenkalti/backoff v2.2.1+incompatible // indirect
+	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/miekg/dns v1.1.27 // indirect
+	golang.org/x/text v0.36.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20260414002931-afd174a4e478 // indirect
+)
@@ -0,0 +1,99 @@
+package main
+
+import "flag"
+
+var flagGoals = flag.Int("goals", 1, "collect-all-goals: number of goal cells a player has to visit, in any order, to finish")
+
+var goals []point // goals[0] is the maze goal; nil unless -goals is above 1
+
+// pickGoals chooses k goal cells: the maze goal plus k-1 dead ends (or any
+// floor cells if there are too few) spread as far from the start, the goal
+// and each other as possible. Cells the goal cannot be reached from are
+// skipped. Fewer goals are returned on tiny mazes.
+func pickGoals(grid [][]int, dist [][]int, start, goal point, k int) []point {
+	if k <= 1 {
+		return nil
+	}
+	var ends, floor []point
+	for y, row := range dist {
+		for x, v := range row {
+			c := point{x, y}
+			if v <= 0 || c == start {
+				continue
+			}
+			floor = append(floor, c)
+			if exits(grid, x, y) == 1 {
+				ends = append(ends, c)
+			}
+		}
+	}
+	cands := ends
+	if len(cands) < k-1 {
+		cands = floor
+	}
+	picked := []point{goal}
+	nearest := make([]int, len(cands))
+	for i, c := range cands {
+		nearest[i] = min(abs(c.X-start.X)+abs(c.Y-start.Y), abs(c.X-goal.X)+abs(c.Y-goal.Y))
+	}
+	for len(picked) < k {
+		best := -1
+		for i, v := range nearest {
+			if v > 0 && (best < 0 || v > nearest[best]) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		g := cands[best]
+		picked = append(picked, g)
+		for i, c := range cands {
+			if m := abs(c.X-g.X) + abs(c.Y-g.Y); m < nearest[i] {
+				nearest[i] = m
+			}
+		}
+	}
+	return picked
+}
+
+// collectGoal records a visit of p to the goal it stands on. Caller holds mu.
+func collectGoal(p *Player) {
+	for i, g := range goals {
+		if g != (point{p.X, p.Y}) {
+			continue
+		}
+		if p.collected == nil {
+			p.collected = make([]bool, len(goals))
+		}
+		if !p.collected[i] {
+			p.collected[i] = true
+			p.Collected++
+		}
+		return
+	}
+}
+
+// nearestUncollected returns the goal closest to p (as the crow flies) that
+// p still has to visit. Caller holds mu.
+func nearestUncollected(p *Player) (point, bool) {
+	best, found := point{}, false
+	for i, g := range goals {
+		if p.collected != nil && p.collected[i] {
+			continue
+		}
+		if !found || abs(g.X-p.X)+abs(g.Y-p.Y) < abs(best.X-p.X)+abs(best.Y-p.Y) {
+			best, found = g, true
+		}
+	}
+	return best, found
+}
+
+// reachedGoal reports whether p has done everything the goal asks for:
+// standing on it, or in collect-all-goals mode having visited every goal.
+func reachedGoal(p *Player) bool {
+	if len(goals) > 0 {
+		return p.Collected >= len(goals)
+	}
+	return p.X == goalX && p.Y == goalY
+}
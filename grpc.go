@@ -0,0 +1,224 @@
+package main
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative -I controlpb controlpb/control.proto
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"strings"
+
+	"golang.org/x/net/websocket"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"server/controlpb"
+)
+
+var flagGRPC = flag.String("grpc", "", "address of the gRPC control API, e.g. :9090 (empty disables it)")
+
+// methodScopes is the scope each control call needs.
+var methodScopes = map[string]string{
+	controlpb.Control_NewRound_FullMethodName:   scopeManageRooms,
+	controlpb.Control_SetMaze_FullMethodName:    scopeManageRooms,
+	controlpb.Control_WatchState_FullMethodName: scopeReadState,
+	controlpb.Control_KickPlayer_FullMethodName: scopeAdmin,
+}
+
+func runGRPC(addr string) {
+	lis, err := listenShared("grpc", func() (net.Listener, error) { return net.Listen("tcp", addr) })
+	if err != nil {
+		log.Printf("gRPC control API disabled: %v", err)
+		return
+	}
+	s := grpc.NewServer(grpc.UnaryInterceptor(unaryAuth), grpc.StreamInterceptor(streamAuth))
+	controlpb.RegisterControlServer(s, controlServer{})
+	onHandoff(s.Stop)
+	log.Printf("gRPC control API listening on %s", addr)
+	if err := s.Serve(lis); err != nil {
+		log.Printf("gRPC control API stopped: %v", err)
+	}
+}
+
+// grpcCredential takes the credential from the "authorization: Bearer ..."
+// or "x-api-key" metadata.
+func grpcCredential(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("authorization"); len(v) > 0 {
+		return strings.TrimPrefix(v[0], "Bearer ")
+	}
+	if v := md.Get("x-api-key"); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// grpcAudit records an action taken over the control API.
+func grpcAudit(ctx context.Context, action string, params map[string]any) {
+	e := AuditEntry{Actor: actorFor(grpcCredential(ctx)), Action: action, Params: params}
+	if p, ok := peer.FromContext(ctx); ok {
+		e.Remote = p.Addr.String()
+	}
+	recordAudit(e)
+}
+
+// checkScope applies the same credentials as the HTTP endpoints.
+func checkScope(ctx context.Context, method string) error {
+	if !authConfigured() {
+		return status.Error(codes.PermissionDenied, "the control API needs an admin token or API key, none are configured (see -admin-token)")
+	}
+	cred := grpcCredential(ctx)
+	if cred == "" {
+		return status.Error(codes.Unauthenticated, "unauthorized")
+	}
+	scope := methodScopes[method]
+	if _, ok := authorizeCredential(cred, scope); !ok {
+		return status.Error(codes.PermissionDenied, "forbidden: missing scope "+scope)
+	}
+	return nil
+}
+
+func unaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (any, error) {
+	if err := checkScope(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return h(ctx, req)
+}
+
+func streamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+	if err := checkScope(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return h(srv, ss)
+}
+
+type controlServer struct {
+	controlpb.UnimplementedControlServer
+}
+
+// mazeStatus turns maze errors into InvalidArgument so callers can tell
+// a bad maze from a broken server.
+func mazeStatus(err error) error {
+	var me *MazeError
+	if errors.As(err, &me) {
+		return status.Errorf(codes.InvalidArgument, "%s: %s", me.Code, me.Message)
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func (controlServer) NewRound(ctx context.Context, req *controlpb.NewRoundRequest) (*controlpb.NewRoundReply, error) {
+	err := resetGame(req.Seed, req.Difficulty)
+	grpcAudit(ctx, "reset", map[string]any{"seed": req.Seed, "difficulty": req.Difficulty, "ok": err == nil})
+	if err != nil {
+		return nil, mazeStatus(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return &controlpb.NewRoundReply{Seed: mazeSeed, Width: int32(mazeWidth), Height: int32(mazeHeight)}, nil
+}
+
+func (controlServer) SetMaze(ctx context.Context, req *controlpb.SetMazeRequest) (*controlpb.SetMazeReply, error) {
+	var m *CustomMaze
+	var err error
+	switch v := req.Maze.(type) {
+	case *controlpb.SetMazeRequest_Text:
+		m, err = customMazeFromText(strings.NewReader(v.Text))
+	case *controlpb.SetMazeRequest_Png:
+		m, err = customMazeFromPNG(bytes.NewReader(v.Png))
+	case *controlpb.SetMazeRequest_Json:
+		m = &CustomMaze{}
+		if err = json.Unmarshal([]byte(v.Json), m); err != nil {
+			err = mazeErr("parse", "invalid maze JSON: %v", err)
+		} else {
+			err = m.validate()
+		}
+	default:
+		return nil, status.Error(codes.InvalidArgument, "no maze given")
+	}
+	if err != nil {
+		return nil, mazeStatus(err)
+	}
+	if err := setPendingMaze(m); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	log.Printf("Custom maze %dx%d set over gRPC, used for the next round", len(m.Grid[0]), len(m.Grid))
+	grpcAudit(ctx, "maze-upload", map[string]any{"width": len(m.Grid[0]), "height": len(m.Grid)})
+	return &controlpb.SetMazeReply{
+		Width:      int32(len(m.Grid[0])),
+		Height:     int32(len(m.Grid)),
+		PathLength: int32(len(shortestPath(m.Grid, m.Start, m.Goal)) - 1),
+	}, nil
+}
+
+// WatchState listens on the event bus like /events, keeping only this
+// instance's game states.
+func (controlServer) WatchState(req *controlpb.WatchStateRequest, stream controlpb.Control_WatchStateServer) error {
+	ch, stop := listen()
+	defer stop()
+	for {
+		select {
+		case msg := <-ch:
+			kind, event, ok := localEvent(msg)
+			if !ok || kind != "" {
+				continue
+			}
+			var state GameState
+			if json.Unmarshal(event, &state) != nil {
+				continue
+			}
+			if err := stream.Send(stateProto(state)); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+func stateProto(s GameState) *controlpb.GameState {
+	out := &controlpb.GameState{AllFinished: s.AllFinished, GameOver: s.GameOver, TimeLeft: int32(s.TimeLeft)}
+	for _, p := range s.Players {
+		out.Players = append(out.Players, &controlpb.Player{
+			X:          int32(p.X),
+			Y:          int32(p.Y),
+			Name:       p.Name,
+			Color:      p.Color,
+			Finished:   p.Finished,
+			FinishTime: p.FinishTime,
+			FinishRank: int32(p.FinishRank),
+			Distance:   int32(p.Distance),
+			Team:       p.Team,
+			Npc:        p.NPC,
+			Ghost:      p.Ghost,
+		})
+	}
+	return out
+}
+
+// KickPlayer closes the connections; the usual disconnect handling then
+// removes the players.
+func (controlServer) KickPlayer(ctx context.Context, req *controlpb.KickPlayerRequest) (*controlpb.KickPlayerReply, error) {
+	var conns []*websocket.Conn
+	mu.Lock()
+	for conn, p := range clients {
+		if p.Name == req.Name {
+			conns = append(conns, conn)
+		}
+	}
+	mu.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+	if len(conns) > 0 {
+		log.Printf("Kicked %d connection(s) of %s over gRPC", len(conns), req.Name)
+	}
+	grpcAudit(ctx, "kick", map[string]any{"name": req.Name, "kicked": len(conns)})
+	return &controlpb.KickPlayerReply{Kicked: int32(len(conns))}, nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+var flagHallOfFame = flag.Bool("hall-of-fame", true, "send the all-time podium of the maze size along with the game-over state")
+
+// podiumSize is how many places the hall of fame has.
+const podiumSize = 3
+
+// hallOfFameSizes are shown by GET /halloffame, as well as the current size.
+var hallOfFameSizes = []string{"small", "medium", "large", "huge"}
+
+var (
+	podiumMu sync.Mutex
+	podium   []LeaderboardEntry // of the current maze size
+)
+
+// podiumFor returns the fastest finishes on a maze size from the
+// leaderboard, each player's best only. Players without an account are
+// told apart by name.
+func podiumFor(width, height int) ([]LeaderboardEntry, error) {
+	list, err := leaderboard.Top(LeaderboardQuery{Width: width, Height: height, Limit: leaderboardMaxTop})
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	top := []LeaderboardEntry{}
+	for _, e := range list {
+		who := e.Player
+		if who == "" {
+			who = "name:" + e.Name
+		}
+		if seen[who] {
+			continue
+		}
+		seen[who] = true
+		if top = append(top, e); len(top) == podiumSize {
+			break
+		}
+	}
+	return top, nil
+}
+
+// refreshPodium reloads the podium of the current maze size. It runs when a
+// round starts and after every finish, without mu held.
+func refreshPodium() {
+	if leaderboard == nil {
+		return
+	}
+	mu.Lock()
+	width, height := mazeWidth, mazeHeight
+	mu.Unlock()
+	top, err := podiumFor(width, height)
+	if err != nil {
+		log.Printf("Hall of fame query failed: %v", err)
+		return
+	}
+	podiumMu.Lock()
+	podium = top
+	podiumMu.Unlock()
+}
+
+// gameOverPodium is the podium sent with the game-over state, if any. Coin
+// rounds are not raced against the clock and get none.
+func gameOverPodium() []LeaderboardEntry {
+	if !*flagHallOfFame || coinMode() {
+		return nil
+	}
+	podiumMu.Lock()
+	defer podiumMu.Unlock()
+	return podium
+}
+
+// handleHallOfFame serves GET /halloffame[?size=WxH]: the all-time podium
+// of each preset size and the current one, or of the size asked for.
+func handleHallOfFame(w http.ResponseWriter, r *http.Request) {
+	if leaderboard == nil {
+		http.Error(w, "the leaderboard is disabled", http.StatusNotFound)
+		return
+	}
+	if s := r.URL.Query().Get("size"); s != "" {
+		width, height, err := parseMazeSize(s)
+		if err != nil {
+			writeMazeError(w, err, http.StatusBadRequest)
+			return
+		}
+		top, err := podiumFor(width, height)
+		if err != nil {
+			log.Printf("Hall of fame query failed: %v", err)
+			http.Error(w, "could not load the hall of fame", http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(top)
+		return
+	}
+	mu.Lock()
+	sizes := [][2]int{{mazeWidth, mazeHeight}}
+	mu.Unlock()
+	for _, name := range hallOfFameSizes {
+		width, height, _ := parseMazeSize(name)
+		sizes = append(sizes, [2]int{width, height})
+	}
+	all := map[string][]LeaderboardEntry{}
+	for _, s := range sizes {
+		key := fmt.Sprintf("%dx%d", s[0], s[1])
+		if _, ok := all[key]; ok {
+			continue
+		}
+		top, err := podiumFor(s[0], s[1])
+		if err != nil {
+			log.Printf("Hall of fame query failed: %v", err)
+			http.Error(w, "could not load the hall of fame", http.StatusInternalServerError)
+			return
+		}
+		all[key] = top
+	}
+	json.NewEncoder(w).Encode(all)
+}
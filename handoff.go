@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var flagHandoffDrain = flag.Duration("handoff-drain", 10*time.Minute, "after handing the listeners to an upgraded binary (SIGUSR2), keep playing the running round with the players still connected for at most this long")
+
+// On SIGUSR2 the server starts its binary again, passing on its listening
+// sockets, and stops accepting once the new process is ready. The players
+// already connected finish their round here, then are told to reconnect,
+// which takes them to the new process. Nobody is cut off mid-round.
+
+var (
+	handoffMu        sync.Mutex
+	handoffListeners = make(map[string]net.Listener) // by port, or "grpc"
+	handoffStops     []func()                        // stop accepting on a listener
+	draining         bool
+	drained          = make(chan struct{})
+)
+
+// listenShared opens the listener named key with open, unless the process
+// that started this one handed it over.
+func listenShared(key string, open func() (net.Listener, error)) (net.Listener, error) {
+	ln, err := inheritedListener(key)
+	if err != nil {
+		return nil, err
+	}
+	if ln == nil {
+		if ln, err = open(); err != nil {
+			return nil, err
+		}
+	}
+	handoffMu.Lock()
+	handoffListeners[key] = ln
+	handoffMu.Unlock()
+	return ln, nil
+}
+
+// onHandoff registers how a server stops accepting new connections.
+func onHandoff(stop func()) {
+	handoffMu.Lock()
+	handoffStops = append(handoffStops, stop)
+	handoffMu.Unlock()
+}
+
+// shutdownServer closes the listener and idle connections of an HTTP server.
+// Hijacked connections, the WebSockets, stay open.
+func shutdownServer(shutdown func(context.Context) error, close func() error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if shutdown(ctx) != nil {
+		close()
+	}
+}
+
+// drain stops accepting, lets the connected players play out the round and
+// then sends them to the new process.
+func drain() {
+	handoffMu.Lock()
+	draining = true
+	stops := handoffStops
+	handoffMu.Unlock()
+	for _, stop := range stops {
+		go stop()
+	}
+
+	deadline := time.Now().Add(*flagHandoffDrain)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n, over := len(clients), gameOver
+		mu.Unlock()
+		if n == 0 || over {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	mu.Lock()
+	log.Printf("Drained, sending %d player(s) to the new process", len(clients))
+	var conns []*websocket.Conn
+	for conn, p := range clients {
+		if p.caps.has(capEvents) {
+			p.send([]byte(`{"type":"reconnect"}`))
+		}
+		conns = append(conns, conn)
+	}
+	mu.Unlock()
+	flushSendQueues(5 * time.Second)
+	for _, conn := range conns {
+		conn.Close()
+	}
+	close(drained)
+}
+
+// isDraining reports whether the listeners have gone to a new process.
+func isDraining() bool {
+	handoffMu.Lock()
+	defer handoffMu.Unlock()
+	return draining
+}
+
+// waitForDrain blocks until a running handoff is done. The servers return
+// as soon as they stop accepting, the connected players keep playing.
+func waitForDrain() {
+	handoffMu.Lock()
+	d := draining
+	handoffMu.Unlock()
+	if d {
+		<-drained
+	}
+}
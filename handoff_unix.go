@@ -0,0 +1,179 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// The listening sockets reach the new process as extra files, named in
+// handoffListenersEnv as key:fd pairs. It writes to the pipe in
+// handoffReadyEnv once it serves.
+const (
+	handoffListenersEnv = "MAZERUNNER_LISTENERS"
+	handoffReadyEnv     = "MAZERUNNER_READY_FD"
+)
+
+var (
+	inheritOnce sync.Once
+	inherited   map[string]*os.File
+)
+
+func inheritedFiles() map[string]*os.File {
+	inheritOnce.Do(func() {
+		inherited = make(map[string]*os.File)
+		for _, pair := range strings.Split(os.Getenv(handoffListenersEnv), ",") {
+			key, fd, ok := strings.Cut(pair, ":")
+			n, err := strconv.Atoi(fd)
+			if ok && err == nil {
+				inherited[key] = os.NewFile(uintptr(n), "listener "+key)
+			}
+		}
+		os.Unsetenv(handoffListenersEnv)
+	})
+	return inherited
+}
+
+func inheritedListener(key string) (net.Listener, error) {
+	f := inheritedFiles()[key]
+	if f == nil {
+		return nil, nil
+	}
+	defer f.Close()
+	ln, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("inherited listener %s: %w", key, err)
+	}
+	log.Printf("Took over the listener on %s", key)
+	return ln, nil
+}
+
+// handoffReady tells the process that started this one that it may stop
+// accepting.
+func handoffReady() {
+	inheritedFiles()
+	fd, err := strconv.Atoi(os.Getenv(handoffReadyEnv))
+	os.Unsetenv(handoffReadyEnv)
+	if err != nil {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "handoff ready")
+	f.Write([]byte{1})
+	f.Close()
+}
+
+// watchHandoff upgrades to the binary on disk on SIGUSR2.
+func watchHandoff() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGUSR2)
+	go func() {
+		for range sig {
+			if err := handOff(); err != nil {
+				log.Printf("Upgrade failed, carrying on: %v", err)
+				continue
+			}
+			signal.Stop(sig)
+			drain()
+			return
+		}
+	}()
+}
+
+// handOff starts the binary again with the listeners and waits until it is
+// ready.
+func handOff() error {
+	if !*flagNoMenu && !*flagDaemon {
+		return errors.New("the new process could not answer the setup menu, run with -no-menu")
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	handoffMu.Lock()
+	var names []string
+	var files []*os.File
+	for key, ln := range handoffListeners {
+		fl, ok := ln.(interface{ File() (*os.File, error) })
+		if !ok {
+			handoffMu.Unlock()
+			return fmt.Errorf("the listener on %s cannot be handed over", key)
+		}
+		f, err := fl.File()
+		if err != nil {
+			handoffMu.Unlock()
+			return err
+		}
+		defer f.Close()
+		names = append(names, fmt.Sprintf("%s:%d", key, 3+len(files)))
+		files = append(files, f)
+	}
+	handoffMu.Unlock()
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	// The rest of the round plays out here unjournaled. Ending it keeps the
+	// new process from recovering it as interrupted.
+	journalRoundEnd()
+	closeJournal()
+	// Only one process may answer for the server on the local network.
+	lanPort := stopAdvertisingLAN()
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), handoffListenersEnv+"="+strings.Join(names, ","), handoffReadyEnv+"="+strconv.Itoa(3+len(files)))
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = append(files, w)
+	log.Printf("Upgrading: starting %s", exe)
+	err = cmd.Start()
+	w.Close()
+	if err == nil {
+		ready := make(chan error, 1)
+		go func() {
+			// Fails with EOF if the new process exits first.
+			_, err := r.Read(make([]byte, 1))
+			ready <- err
+		}()
+		select {
+		case err = <-ready:
+		case <-time.After(time.Minute):
+			err = errors.New("not ready after a minute")
+		}
+		if err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+		}
+	}
+	if err != nil {
+		if err := openJournal(*flagJournal); err != nil {
+			log.Printf("Round journal disabled: %v", err)
+		}
+		journalRoundStart()
+		if lanPort != "" {
+			advertiseLAN(lanPort)
+		}
+		return fmt.Errorf("new process: %w", err)
+	}
+	handoffMu.Lock()
+	for _, ln := range handoffListeners {
+		// The socket file now belongs to the new process.
+		if ul, ok := ln.(*net.UnixListener); ok {
+			ul.SetUnlinkOnClose(false)
+		}
+	}
+	handoffMu.Unlock()
+	log.Printf("Handed the listeners to pid %d, draining", cmd.Process.Pid)
+	cmd.Process.Release()
+	return nil
+}
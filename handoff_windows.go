@@ -0,0 +1,11 @@
+package main
+
+import "net"
+
+// Windows has no SIGUSR2; services are upgraded by restarting them.
+
+func inheritedListener(key string) (net.Listener, error) { return nil, nil }
+
+func handoffReady() {}
+
+func watchHandoff() {}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"time"
+)
+
+var (
+	flagHints        = flag.Int("hints", 3, "hints each player may request per round (0 disables hints)")
+	flagHintCooldown = flag.Duration("hint-cooldown", 15*time.Second, "minimum time between two hints of the same player")
+	flagHintCells    = flag.Int("hint-cells", 5, "number of cells revealed by a hint")
+)
+
+// hintReply answers a {"type":"hint"} request. Either Path or Error is set.
+type hintReply struct {
+	Type      string  `json:"type"`
+	Path      []point `json:"path,omitempty"`
+	Remaining int     `json:"remaining"`
+	Error     string  `json:"error,omitempty"`
+	RetryIn   int     `json:"retryIn,omitempty"` // seconds until the cooldown ends
+	Assist    bool    `json:"assist,omitempty"`  // a free hint from -assist, not charged
+}
+
+// nextHint reveals the next cells on the shortest path from p's position to
+// its next checkpoint or the goal and charges p one hint. Caller holds mu.
+func nextHint(p *Player) hintReply {
+	r := hintReply{Type: "hint", Remaining: *flagHints - p.HintsUsed}
+	switch {
+	case *flagHints <= 0:
+		r.Error = "hints are disabled"
+		return r
+	case p.Finished:
+		r.Error = "already finished"
+		return r
+	case r.Remaining <= 0:
+		r.Error = fmt.Sprintf("no hints left this round (limit %d)", *flagHints)
+		return r
+	}
+	if wait := *flagHintCooldown - clock().Sub(p.lastHint); wait > 0 {
+		r.Error = "hint cooldown"
+		r.RetryIn = int(wait.Seconds() + 0.999)
+		return r
+	}
+
+	path := shortestPath(maze, point{p.X, p.Y}, nextTarget(p))
+	if len(path) < 2 {
+		r.Error = "no path to the goal"
+		return r
+	}
+	path = path[1:]
+	if len(path) > *flagHintCells {
+		path = path[:*flagHintCells]
+	}
+	p.HintsUsed++
+	p.lastHint = clock()
+	r.Path = path
+	r.Remaining--
+	return r
+}
+
+func sendHint(p *Player) {
+	mu.Lock()
+	r := nextHint(p)
+	p.dirty = true
+	mu.Unlock()
+	data, _ := json.Marshal(r)
+	p.send(data)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var flagHost = flag.Bool("host", false, "the first player to join hosts the game: the host can start new rounds from the game, hand out invite codes to a private game and hand hosting to another player")
+
+// hostConn is the connection of the current host, nil if there is none.
+// Guarded by mu.
+var hostConn *websocket.Conn
+
+// hostReply answers a restart or host message when it is refused.
+type hostReply struct {
+	Type  string `json:"type"`
+	Error string `json:"error"`
+}
+
+// claimHost makes p the host if the game has none yet. Caller holds mu.
+func claimHost(ws *websocket.Conn, p *Player) {
+	if !*flagHost || hostConn != nil {
+		return
+	}
+	hostConn = ws
+	p.Host = true
+	p.dirty = true
+}
+
+// releaseHost hands hosting to the player connected longest when the host
+// leaves. Caller holds mu and has already removed ws from clients.
+func releaseHost(ws *websocket.Conn) {
+	if hostConn != ws {
+		return
+	}
+	hostConn = nil
+	for conn, p := range clients {
+		if hostConn == nil || p.connectedAt.Before(clients[hostConn].connectedAt) {
+			hostConn = conn
+		}
+	}
+	if hostConn != nil {
+		clients[hostConn].Host = true
+		clients[hostConn].dirty = true
+		log.Printf("Host left, %s hosts the game now", clients[hostConn].Name)
+	}
+}
+
+// hostError is why ws may not use host rights, or "" if it may. Caller
+// holds mu.
+func hostError(ws *websocket.Conn) string {
+	switch {
+	case !*flagHost:
+		return "this game has no host"
+	case ws != hostConn:
+		return "only the host can do that"
+	}
+	return ""
+}
+
+func refuseHost(p *Player, typ, reason string) {
+	data, _ := json.Marshal(hostReply{Type: typ, Error: reason})
+	p.send(data)
+}
+
+// hostRestart handles {"type":"restart"}: the host starts a new round,
+// optionally with a seed and difficulty for it.
+func hostRestart(ws *websocket.Conn, p *Player, msg clientMessage) {
+	mu.Lock()
+	reason, name := hostError(ws), p.Name
+	mu.Unlock()
+	if reason == "" && *flagDaily {
+		reason = errDailyMaze.Error()
+	}
+	if reason != "" {
+		refuseHost(p, msg.Type, reason)
+		return
+	}
+	err := resetGame(msg.Seed, msg.Difficulty)
+	recordAudit(AuditEntry{Actor: "host:" + name, Action: "reset", Remote: ws.Request().RemoteAddr, Params: map[string]any{"seed": msg.Seed, "difficulty": msg.Difficulty, "ok": err == nil}})
+	if err != nil {
+		refuseHost(p, msg.Type, err.Error())
+	}
+}
+
+// hostTransfer handles {"type":"host","name":...}: the host hands hosting
+// to the named player.
+func hostTransfer(ws *websocket.Conn, p *Player, msg clientMessage) {
+	mu.Lock()
+	reason := hostError(ws)
+	var to *websocket.Conn
+	if reason == "" {
+		for conn, q := range clients {
+			if conn != ws && q.Name == msg.Name {
+				to = conn
+				break
+			}
+		}
+		if to == nil {
+			reason = "no other player of that name"
+		}
+	}
+	if reason == "" {
+		p.Host, p.dirty = false, true
+		hostConn = to
+		clients[to].Host, clients[to].dirty = true, true
+		log.Printf("%s handed hosting to %s", p.Name, msg.Name)
+	}
+	mu.Unlock()
+	if reason != "" {
+		refuseHost(p, msg.Type, reason)
+		return
+	}
+	broadcast()
+}
+
+// inviteReply answers a host's invite message with the new code.
+type inviteReply struct {
+	Type    string    `json:"type"` // "invite"
+	Code    string    `json:"code"`
+	Uses    int       `json:"uses"`
+	Expires time.Time `json:"expires"`
+	URL     string    `json:"url"`
+}
+
+// hostInvite handles {"type":"invite","uses":N,"ttl":"2h"}: the host makes
+// an invite code to the private game, as /invites does for admins. With a
+// code instead the host revokes that one.
+func hostInvite(ws *websocket.Conn, p *Player, msg clientMessage) {
+	mu.Lock()
+	reason, name := hostError(ws), p.Name
+	mu.Unlock()
+	if reason == "" && !privateGame() {
+		reason = "the game is open, nobody needs an invite"
+	}
+	if reason != "" {
+		refuseHost(p, msg.Type, reason)
+		return
+	}
+	actor, remote := "host:"+name, ws.Request().RemoteAddr
+	if msg.Code != "" {
+		if !revokeInvite(msg.Code) {
+			refuseHost(p, msg.Type, "no such invite")
+			return
+		}
+		recordAudit(AuditEntry{Actor: actor, Action: "invite-revoke", Remote: remote, Params: map[string]any{"code": normalizeInvite(msg.Code)}})
+		return
+	}
+	inv, err := createInvite(msg.Uses, msg.TTL, actor)
+	if err != nil {
+		refuseHost(p, msg.Type, err.Error())
+		return
+	}
+	recordAudit(AuditEntry{Actor: actor, Action: "invite-create", Remote: remote, Params: map[string]any{"code": inv.Code, "uses": inv.Uses, "expires": inv.Expires}})
+	data, _ := json.Marshal(inviteReply{Type: "invite", Code: inv.Code, Uses: inv.Uses, Expires: inv.Expires, URL: joinURL(ws.Request()) + "?invite=" + inv.Code})
+	p.send(data)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var flagI18nDir = flag.String("i18n-dir", "", "directory of extra translation bundles for the web page (xx.json, one object of key: text); they add languages or replace the built-in ones")
+
+// langCode is what a bundle may be called: a language tag such as de or
+// pt-BR, which also keeps the name safe to look up on disk.
+var langCode = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})?$`)
+
+func builtinBundles() fs.FS {
+	sub, _ := fs.Sub(embeddedWeb, "web/i18n")
+	return sub
+}
+
+// i18nBundles holds the translations, with -i18n-dir over the built-in ones.
+func i18nBundles() fs.FS {
+	o := overlayFS{base: builtinBundles()}
+	if *flagI18nDir != "" {
+		o.dir = os.DirFS(*flagI18nDir)
+	}
+	return o
+}
+
+// readBundle loads the bundle of one language and checks it is a JSON
+// object of strings.
+func readBundle(code string) ([]byte, error) {
+	data, err := fs.ReadFile(i18nBundles(), code+".json")
+	if err != nil {
+		return nil, err
+	}
+	var bundle map[string]string
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// languages lists the codes of every usable bundle.
+func languages() []string {
+	seen := make(map[string]bool)
+	add := func(fsys fs.FS) {
+		entries, _ := fs.ReadDir(fsys, ".")
+		for _, e := range entries {
+			code, ok := strings.CutSuffix(e.Name(), ".json")
+			if !ok || e.IsDir() || !langCode.MatchString(code) || seen[code] {
+				continue
+			}
+			if _, err := readBundle(code); err != nil {
+				log.Printf("Skipping translation bundle %s: %v", e.Name(), err)
+				continue
+			}
+			seen[code] = true
+		}
+	}
+	add(builtinBundles())
+	if *flagI18nDir != "" {
+		add(os.DirFS(*flagI18nDir))
+	}
+	list := make([]string, 0, len(seen))
+	for code := range seen {
+		list = append(list, code)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// handleLanguages serves GET /i18n, the languages the page can switch to.
+func handleLanguages(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(languages())
+}
+
+// handleBundle serves GET /i18n/{lang}.json.
+func handleBundle(w http.ResponseWriter, r *http.Request) {
+	code, ok := strings.CutSuffix(r.PathValue("file"), ".json")
+	if !ok || !langCode.MatchString(code) {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := readBundle(code)
+	if errors.Is(err, fs.ErrNotExist) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Translation bundle %s.json is broken: %v", code, err)
+		http.Error(w, "translation bundle broken", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
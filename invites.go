@@ -0,0 +1,239 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var flagInvites = flag.String("invites", "invites.json", "file keeping the invite codes of private games across restarts and handoffs (empty keeps them in memory)")
+
+// Invite codes let someone into a private game without handing out the
+// password: a code works a limited number of times and until it expires.
+// A player it let in may come back with it until then, for free, if the
+// server can tell who it is: by its login or its profile (-profiles).
+const (
+	inviteAlphabet   = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // no 0/O or 1/I to misread
+	inviteDefaultTTL = 24 * time.Hour
+	inviteMaxTTL     = 30 * 24 * time.Hour
+)
+
+// Invite is one code. Uses counts down to zero.
+type Invite struct {
+	Code     string    `json:"code"`
+	Uses     int       `json:"uses"`
+	Expires  time.Time `json:"expires"`
+	Created  time.Time `json:"created"`
+	By       string    `json:"by"`
+	Admitted []string  `json:"admitted,omitempty"` // profiles the code let in
+}
+
+var (
+	invitesMu sync.Mutex
+	invites   = make(map[string]*Invite)
+)
+
+func newInviteCode() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	for i, b := range buf {
+		buf[i] = inviteAlphabet[int(b)%len(inviteAlphabet)]
+	}
+	return string(buf[:4]) + "-" + string(buf[4:])
+}
+
+// normalizeInvite forgives case and missing or extra dashes and spaces,
+// since codes get retyped from chat.
+func normalizeInvite(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != 8 {
+		return ""
+	}
+	return code[:4] + "-" + code[4:]
+}
+
+func loadInvites(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var list []*Invite
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	invitesMu.Lock()
+	defer invitesMu.Unlock()
+	for _, inv := range list {
+		invites[inv.Code] = inv
+	}
+	log.Printf("Loaded %d invite code(s) from %s", len(list), path)
+	return nil
+}
+
+// saveInvites writes the invites that have not expired; the caller holds
+// invitesMu.
+func saveInvites() {
+	list := []*Invite{}
+	for code, inv := range invites {
+		if time.Now().After(inv.Expires) {
+			delete(invites, code)
+			continue
+		}
+		list = append(list, inv)
+	}
+	if *flagInvites == "" {
+		return
+	}
+	data, _ := json.MarshalIndent(list, "", "  ")
+	if err := os.WriteFile(*flagInvites, data, 0600); err != nil {
+		log.Printf("Could not save invites: %v", err)
+	}
+}
+
+// invitedPlayer is who a joining request says it is: the verified player
+// or the profile whose token it passes in the profile query parameter.
+func invitedPlayer(r *http.Request) string {
+	if id := verifiedPlayer(r); id != "" {
+		return id
+	}
+	if token := r.URL.Query().Get("profile"); validProfileToken(token) {
+		return profileID(token)
+	}
+	return ""
+}
+
+// useInvite lets the request in on its invite code, if it has a valid one:
+// a player the code admitted before comes back for free, anyone else spends
+// one of its uses.
+func useInvite(r *http.Request) bool {
+	code := normalizeInvite(r.URL.Query().Get("invite"))
+	if code == "" {
+		return false
+	}
+	invitesMu.Lock()
+	defer invitesMu.Unlock()
+	inv := invites[code]
+	if inv == nil || time.Now().After(inv.Expires) {
+		return false
+	}
+	if id := invitedPlayer(r); id != "" && slices.Contains(inv.Admitted, id) {
+		return true
+	}
+	if inv.Uses == 0 {
+		return false
+	}
+	inv.Uses--
+	saveInvites()
+	return true
+}
+
+// rememberInvited notes the profile of a player who joined on an invite
+// code, so the code lets it back in after a reconnect or a handoff.
+func rememberInvited(r *http.Request, profile string) {
+	code := normalizeInvite(r.URL.Query().Get("invite"))
+	if code == "" || profile == "" {
+		return
+	}
+	invitesMu.Lock()
+	defer invitesMu.Unlock()
+	inv := invites[code]
+	if inv == nil || slices.Contains(inv.Admitted, profile) {
+		return
+	}
+	inv.Admitted = append(inv.Admitted, profile)
+	saveInvites()
+}
+
+// createInvite makes a code that works uses times (1 if not positive) for
+// ttl (a day if empty).
+func createInvite(uses int, ttl, by string) (*Invite, error) {
+	if uses <= 0 {
+		uses = 1
+	}
+	d := inviteDefaultTTL
+	if ttl != "" {
+		var err error
+		if d, err = time.ParseDuration(ttl); err != nil || d <= 0 || d > inviteMaxTTL {
+			return nil, errors.New("ttl must be a duration up to 720h")
+		}
+	}
+	now := time.Now()
+	inv := &Invite{Code: newInviteCode(), Uses: uses, Expires: now.Add(d), Created: now, By: by}
+	invitesMu.Lock()
+	invites[inv.Code] = inv
+	saveInvites()
+	invitesMu.Unlock()
+	return inv, nil
+}
+
+// revokeInvite deletes a code and reports whether it existed.
+func revokeInvite(code string) bool {
+	code = normalizeInvite(code)
+	invitesMu.Lock()
+	defer invitesMu.Unlock()
+	_, ok := invites[code]
+	delete(invites, code)
+	saveInvites()
+	return ok
+}
+
+// handleInvites lists (GET), creates (POST {"uses": N, "ttl": "2h"}) and
+// revokes (DELETE ?code=) invite codes. uses defaults to 1, ttl to a day.
+// The host manages them from the game, see hostInvite.
+func handleInvites(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		invitesMu.Lock()
+		list := []Invite{}
+		for code, inv := range invites {
+			if time.Now().After(inv.Expires) {
+				delete(invites, code)
+				continue
+			}
+			list = append(list, *inv)
+		}
+		invitesMu.Unlock()
+		sort.Slice(list, func(i, j int) bool { return list[i].Created.Before(list[j].Created) })
+		json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		var req struct {
+			Uses int    `json:"uses"`
+			TTL  string `json:"ttl"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, `want {"uses": N, "ttl": "2h"}`, http.StatusBadRequest)
+				return
+			}
+		}
+		inv, err := createInvite(req.Uses, req.TTL, actorOf(r))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		audit(r, "invite-create", map[string]any{"code": inv.Code, "uses": inv.Uses, "expires": inv.Expires})
+		json.NewEncoder(w).Encode(map[string]any{"code": inv.Code, "uses": inv.Uses, "expires": inv.Expires, "url": joinURL(r) + "?invite=" + inv.Code})
+	case http.MethodDelete:
+		code := r.URL.Query().Get("code")
+		if !revokeInvite(code) {
+			http.Error(w, "no such invite", http.StatusNotFound)
+			return
+		}
+		audit(r, "invite-revoke", map[string]any{"code": normalizeInvite(code)})
+		json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+)
+
+var (
+	flagAllow = flag.String("allow", "", "comma-separated IPs or CIDRs that may connect at all, for pages and the game alike, such as a school subnet (empty allows everyone)")
+	flagDeny  = flag.String("deny", "", "comma-separated IPs or CIDRs that may not connect, even if -allow lets them")
+)
+
+var allowed, denied []netip.Prefix
+
+func loadClientFilters() {
+	var err error
+	if allowed, err = parsePrefixes(*flagAllow); err != nil {
+		log.Fatalf("Invalid -allow: %v", err)
+	}
+	if denied, err = parsePrefixes(*flagDeny); err != nil {
+		log.Fatalf("Invalid -deny: %v", err)
+	}
+}
+
+func matchesPrefix(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAllowed applies -allow and -deny to a client address. With an
+// allow list, a client whose address is unknown is refused.
+func clientAllowed(remoteAddr string) bool {
+	if len(allowed) == 0 && len(denied) == 0 {
+		return true
+	}
+	host, _, _ := net.SplitHostPort(remoteAddr)
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return len(allowed) == 0
+	}
+	addr = addr.Unmap()
+	if matchesPrefix(denied, addr) {
+		return false
+	}
+	return len(allowed) == 0 || matchesPrefix(allowed, addr)
+}
+
+// filterClients refuses requests from addresses -allow and -deny keep out,
+// WebSocket upgrades included. It runs behind behindProxies, so it sees the
+// player's address rather than the proxy's.
+func filterClients(h http.Handler) http.Handler {
+	if len(allowed) == 0 && len(denied) == 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !clientAllowed(r.RemoteAddr) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
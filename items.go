@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+var (
+	flagItemsEvery   = flag.Duration("items-every", 0, "drop a power-up into the maze this often (0 disables power-ups)")
+	flagItemsMax     = flag.Int("items-max", 5, "most power-ups lying in the maze at once")
+	flagItemDuration = flag.Duration("item-duration", 8*time.Second, "how long timed power-up effects last")
+)
+
+// Power-up kinds. Speed and wall-phase let a player move two cells per
+// step (along a corridor, or across a single wall), freeze holds everyone
+// else in place and reveal shows the picker the way to the goal.
+const (
+	itemSpeed  = "speed"
+	itemFreeze = "freeze"
+	itemReveal = "reveal"
+	itemPhase  = "phase"
+)
+
+var itemKinds = []string{itemSpeed, itemFreeze, itemReveal, itemPhase}
+
+// Item is a power-up lying in the maze.
+type Item struct {
+	ID   int    `json:"id"`
+	Kind string `json:"kind"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+}
+
+// itemEvent is broadcast when an item appears, is picked up or takes effect.
+type itemEvent struct {
+	Type     string `json:"type"`  // always "item"
+	Event    string `json:"event"` // "spawn", "pickup" or "effect"
+	Item     Item   `json:"item"`
+	Name     string `json:"name,omitempty"`     // who picked it up
+	Duration int64  `json:"duration,omitempty"` // effect length in milliseconds
+}
+
+var (
+	items      []Item // guarded by mu
+	nextItemID int
+)
+
+// runItemSpawner drops a random power-up every -items-every until the
+// maze holds -items-max of them.
+func runItemSpawner() {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for {
+		time.Sleep(*flagItemsEvery)
+		mu.Lock()
+		var it Item
+		ok := false
+		if !gameOver && len(clients) > 0 && len(items) < *flagItemsMax {
+			it, ok = spawnItem(rng)
+		}
+		mu.Unlock()
+		if ok {
+			broadcastItemEvent(itemEvent{Type: "item", Event: "spawn", Item: it})
+		}
+	}
+}
+
+// spawnItem places a random item on a free floor cell. Caller holds mu.
+func spawnItem(rng *rand.Rand) (Item, bool) {
+	for try := 0; try < 50; try++ {
+		x, y := rng.Intn(mazeWidth), rng.Intn(mazeHeight)
+		if y >= len(maze) || x >= len(maze[y]) || maze[y][x] != cellFloor || !quakeMayClose(x, y) || itemAt(x, y) >= 0 {
+			continue
+		}
+		nextItemID++
+		it := Item{ID: nextItemID, Kind: itemKinds[rng.Intn(len(itemKinds))], X: x, Y: y}
+		items = append(items, it)
+		return it, true
+	}
+	return Item{}, false
+}
+
+func itemAt(x, y int) int {
+	for i, it := range items {
+		if it.X == x && it.Y == y {
+			return i
+		}
+	}
+	return -1
+}
+
+// pickupItem hands p the item it stands on, if any, and applies it. The
+// returned events still have to be broadcast. Caller holds mu.
+func pickupItem(p *Player) []itemEvent {
+	i := itemAt(p.X, p.Y)
+	if i < 0 {
+		return nil
+	}
+	it := items[i]
+	items = append(items[:i], items[i+1:]...)
+	log.Printf("ITEM: %s picked up %s", p.Name, it.Kind)
+	evs := []itemEvent{{Type: "item", Event: "pickup", Item: it, Name: p.Name}}
+	until := clock().Add(*flagItemDuration)
+	switch it.Kind {
+	case itemFreeze:
+		for _, o := range clients {
+			if o != p && !o.Finished {
+				if end := clock().Add(penaltyFor(o, *flagItemDuration)); o.stuckUntil.Before(end) {
+					o.stuckUntil = end
+				}
+			}
+		}
+	case itemReveal:
+		if p.caps.has(capPrivate) {
+			path := shortestPath(maze, point{p.X, p.Y}, nextTarget(p))
+			data, _ := json.Marshal(hintReply{Type: "hint", Path: path, Remaining: *flagHints - p.HintsUsed})
+			p.send(data)
+		}
+		return evs
+	default:
+		if p.effects == nil {
+			p.effects = make(map[string]time.Time)
+		}
+		p.effects[it.Kind] = until
+	}
+	return append(evs, itemEvent{Type: "item", Event: "effect", Item: it, Name: p.Name, Duration: flagItemDuration.Milliseconds()})
+}
+
+// hasEffect reports whether a timed power-up is active on p.
+func hasEffect(p *Player, kind string) bool {
+	return clock().Before(p.effects[kind])
+}
+
+// activeEffects lists p's running power-ups for the state broadcast.
+func activeEffects(p *Player) []string {
+	var list []string
+	for kind := range p.effects {
+		if hasEffect(p, kind) {
+			list = append(list, kind)
+		}
+	}
+	sort.Strings(list)
+	return list
+}
+
+// longMove carries p two cells in direction d: speed allows it along open
+// cells, wall-phase across a single wall. Caller holds mu.
+func longMove(p *Player, d [2]int) bool {
+	from := point{p.X, p.Y}
+	mid := point{from.X + d[0], from.Y + d[1]}
+	to := point{mid.X + d[0], mid.Y + d[1]}
+	if *flagCollisions && (occupied(p, mid.X, mid.Y) || occupied(p, to.X, to.Y)) {
+		return false
+	}
+	switch {
+	case hasEffect(p, itemSpeed) && canStep(maze, from, mid) && canStep(maze, mid, to):
+		p.X, p.Y = mid.X, mid.Y
+		passCheckpoint(p)
+		collectGoal(p)
+	case hasEffect(p, itemPhase) && !isOpen(maze, mid.X, mid.Y) && isOpen(maze, to.X, to.Y):
+		p.X, p.Y = mid.X, mid.Y
+	default:
+		return false
+	}
+	slide(p, d)
+	return true
+}
+
+// broadcastItemEvent tells event-capable clients about an item.
+func broadcastItemEvent(ev itemEvent) {
+	data, _ := json.Marshal(ev)
+	publishEvent(data)
+	mu.Lock()
+	defer mu.Unlock()
+	for _, p := range clients {
+		if p.caps.has(capEvents) {
+			p.send(data)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+var flagPublicURL = flag.String("public-url", "", "address players use to reach the website, e.g. https://maze.example.org (detected from the request or the LAN address if empty)")
+
+// joinURL is where players open the game. Without -public-url it is the
+// address the request came to, except that localhost is swapped for the
+// machine's LAN address: the QR code is scanned by other devices.
+func joinURL(r *http.Request) string {
+	if *flagPublicURL != "" {
+		return *flagPublicURL
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host, port = r.Host, ""
+	}
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		if lan := lanAddress(); lan != "" {
+			host = lan
+		}
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	}
+	return (&url.URL{Scheme: scheme, Host: host, Path: "/"}).String()
+}
+
+// lanAddress is the first private IPv4 address of this machine, or any
+// other routable one if there is none.
+func lanAddress() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+	var fallback string
+	for _, a := range addrs {
+		n, ok := a.(*net.IPNet)
+		if !ok || n.IP.To4() == nil || !n.IP.IsGlobalUnicast() {
+			continue
+		}
+		if n.IP.IsPrivate() {
+			return n.IP.String()
+		}
+		if fallback == "" {
+			fallback = n.IP.String()
+		}
+	}
+	return fallback
+}
+
+// handleJoinPNG serves /join.png: a QR code of the join URL, ?size= pixels
+// wide (default 256).
+func handleJoinPNG(w http.ResponseWriter, r *http.Request) {
+	size := queryInt(r, "size", 256, 64, 1024)
+	link := joinURL(r)
+	png, err := qrcode.Encode(link, qrcode.Medium, size)
+	if err != nil {
+		log.Printf("QR code for %s failed: %v", link, err)
+		http.Error(w, "could not render QR code", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("X-Join-URL", link)
+	w.Write(png)
+}
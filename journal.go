@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+var flagJournal = flag.String("journal", "round.journal", "append-only journal of round events used to recover results after a crash (empty disables)")
+
+// journalEntry is one line of the round journal.
+type journalEntry struct {
+	Type       string `json:"type"` // "start", "finish" or "end"
+	Time       int64  `json:"time"` // unix milliseconds
+	Width      int    `json:"width,omitempty"`
+	Height     int    `json:"height,omitempty"`
+	Seed       int64  `json:"seed,omitempty"`
+	Name       string `json:"name,omitempty"`
+	Color      string `json:"color,omitempty"`
+	Rank       int    `json:"rank,omitempty"`
+	FinishTime int64  `json:"finishTime,omitempty"`
+	WallHits   int    `json:"wallHits,omitempty"`
+	HintsUsed  int    `json:"hintsUsed,omitempty"`
+}
+
+// RoundResult is the standings of a round rebuilt from the journal.
+type RoundResult struct {
+	StartedAt time.Time `json:"startedAt"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	Seed      int64     `json:"seed"`
+	Standings []Player  `json:"standings"`
+	Partial   bool      `json:"partial"`
+}
+
+// journalBacklog is how many entries may wait for the disk before new ones
+// are dropped; the game never waits on the journal.
+const journalBacklog = 1024
+
+var (
+	journalMu      sync.Mutex
+	journalQueue   chan []byte   // lines for the writer, nil when closed
+	journalDone    chan struct{} // closed once the writer has finished
+	recoveredRound *RoundResult
+)
+
+// openJournal recovers an unfinished round left behind by a crash and then
+// goes on appending to the journal at path.
+func openJournal(path string) error {
+	if path == "" {
+		return nil
+	}
+	res := recoverJournal(path)
+	if res != nil {
+		recoveredRound = res
+		log.Printf("RECOVERED interrupted round from %s (started %s, %d finisher(s))", path,
+			res.StartedAt.Format(time.RFC3339), len(res.Standings))
+		for _, p := range res.Standings {
+			log.Printf("  #%d %s - %ds", p.FinishRank, p.Name, p.FinishTime)
+		}
+		if data, err := json.MarshalIndent(res, "", "  "); err == nil {
+			if err := os.WriteFile(path+".recovered.json", data, 0644); err != nil {
+				log.Printf("Could not save recovered results: %v", err)
+			}
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	queue, done := make(chan []byte, journalBacklog), make(chan struct{})
+	go runJournal(f, queue, done)
+	journalMu.Lock()
+	journalQueue, journalDone = queue, done
+	journalMu.Unlock()
+	if res != nil {
+		// Recovered once is enough: the next start must not find it again.
+		writeJournal(journalEntry{Type: "end"})
+	}
+	return nil
+}
+
+// runJournal writes and syncs the journal lines until queue is closed.
+func runJournal(f *os.File, queue <-chan []byte, done chan<- struct{}) {
+	defer close(done)
+	defer f.Close()
+	for line := range queue {
+		if _, err := f.Write(line); err != nil {
+			log.Printf("Journal write failed: %v", err)
+			continue
+		}
+		f.Sync()
+	}
+}
+
+// recoverJournal returns the last round in the journal if it never reached
+// its "end" entry, i.e. the server went down mid-round.
+func recoverJournal(path string) *RoundResult {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var res *RoundResult
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e journalEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// A torn last line is expected after a crash.
+			continue
+		}
+		switch e.Type {
+		case "start":
+			res = &RoundResult{StartedAt: time.UnixMilli(e.Time), Width: e.Width, Height: e.Height, Seed: e.Seed, Partial: true}
+		case "finish":
+			if res != nil {
+				res.Standings = append(res.Standings, Player{Name: e.Name, Color: e.Color, Finished: true, FinishRank: e.Rank, FinishTime: e.FinishTime, WallHits: e.WallHits, HintsUsed: e.HintsUsed})
+			}
+		case "end":
+			res = nil
+		}
+	}
+	if res != nil {
+		sort.Slice(res.Standings, func(i, j int) bool { return res.Standings[i].FinishRank < res.Standings[j].FinishRank })
+	}
+	return res
+}
+
+// writeJournal hands an entry to the journal writer. It is called with mu
+// held, so it never waits for the disk.
+func writeJournal(e journalEntry) {
+	e.Time = time.Now().UnixMilli()
+	data, _ := json.Marshal(e)
+	journalMu.Lock()
+	defer journalMu.Unlock()
+	if journalQueue == nil {
+		return
+	}
+	select {
+	case journalQueue <- append(data, '\n'):
+	default:
+		log.Printf("Journal falling behind, %s entry dropped", e.Type)
+	}
+}
+
+// closeJournal stops journaling once everything queued is on disk.
+func closeJournal() {
+	journalMu.Lock()
+	queue, done := journalQueue, journalDone
+	journalQueue = nil
+	journalMu.Unlock()
+	if queue != nil {
+		close(queue)
+		<-done
+	}
+}
+
+func journalRoundStart() {
+	writeJournal(journalEntry{Type: "start", Width: mazeWidth, Height: mazeHeight, Seed: mazeSeed})
+}
+
+func journalFinish(p Player) {
+	writeJournal(journalEntry{Type: "finish", Name: p.Name, Color: p.Color, Rank: p.FinishRank, FinishTime: p.FinishTime, WallHits: p.WallHits, HintsUsed: p.HintsUsed})
+}
+
+func journalRoundEnd() {
+	writeJournal(journalEntry{Type: "end"})
+}
+
+func handleRecoveredResults(w http.ResponseWriter, r *http.Request) {
+	if recoveredRound == nil {
+		http.Error(w, "no interrupted round was recovered", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(recoveredRound)
+}
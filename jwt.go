@@ -0,0 +1,325 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	flagJWTSecret   = flag.String("jwt-secret", "", "shared secret verifying HS256 player tokens; with it or -jwks-url set, only players with a valid token may join")
+	flagJWKSURL     = flag.String("jwks-url", "", "URL of the JSON Web Key Set verifying RS256/ES256 player tokens from an identity provider")
+	flagJWTIssuer   = flag.String("jwt-issuer", "", "iss player tokens must carry (empty accepts any)")
+	flagJWTAudience = flag.String("jwt-audience", "", "aud player tokens must include (empty accepts any)")
+)
+
+// An identity system in front of the game hands each player a signed token,
+// given as ?token= on /ws (browsers cannot set headers on WebSockets) or in
+// the X-Player-Token header. The token's subject becomes the player's
+// account, in place of the profile token the browser would keep.
+
+// jwtLeeway allows for clocks that are slightly apart.
+const jwtLeeway = time.Minute
+
+func jwtEnabled() bool {
+	return *flagJWTSecret != "" || *flagJWKSURL != ""
+}
+
+// playerToken is the token a joining client gives.
+func playerToken(r *http.Request) string {
+	if t := r.Header.Get("X-Player-Token"); t != "" {
+		return t
+	}
+	return r.URL.Query().Get("token")
+}
+
+// refuseUnverified answers a joining request without a valid player token
+// and reports whether it did. Integrations with an API key need none.
+func refuseUnverified(w http.ResponseWriter, r *http.Request) bool {
+	if credential(r) != "" {
+		return false
+	}
+	if _, err := jwtPlayer(r); err != nil {
+		http.Error(w, "invalid player token: "+err.Error(), http.StatusUnauthorized)
+		return true
+	}
+	return false
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+type jwtClaims struct {
+	Sub string   `json:"sub"`
+	Iss string   `json:"iss"`
+	Aud audience `json:"aud"`
+	Exp float64  `json:"exp"`
+	Nbf float64  `json:"nbf"`
+}
+
+// audience is a single string or a list of them.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if json.Unmarshal(data, &one) == nil {
+		*a = audience{one}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+// jwtPlayer verifies the player token of r and returns the player id its
+// subject maps to. Without -jwt-secret and -jwks-url it returns "" and no
+// error.
+func jwtPlayer(r *http.Request) (string, error) {
+	if !jwtEnabled() {
+		return "", nil
+	}
+	token := playerToken(r)
+	if token == "" {
+		return "", errors.New("a player token is needed to join")
+	}
+	c, err := verifyJWT(token, time.Now())
+	if err != nil {
+		return "", err
+	}
+	// The issuer is part of the id, so two providers cannot hand out the
+	// same account.
+	return hashAPIKey("jwt\x00" + c.Iss + "\x00" + c.Sub)[:16], nil
+}
+
+func verifyJWT(token string, now time.Time) (*jwtClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var h jwtHeader
+	if err := decodeJWTPart(parts[0], &h); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+	if err := verifyJWTSignature(h, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+	var c jwtClaims
+	if err := decodeJWTPart(parts[1], &c); err != nil {
+		return nil, err
+	}
+	switch {
+	case c.Sub == "":
+		return nil, errors.New("token has no subject")
+	case c.Exp == 0:
+		return nil, errors.New("token has no expiry")
+	case now.Add(-jwtLeeway).After(time.Unix(int64(c.Exp), 0)):
+		return nil, errors.New("token expired")
+	case c.Nbf != 0 && now.Add(jwtLeeway).Before(time.Unix(int64(c.Nbf), 0)):
+		return nil, errors.New("token not valid yet")
+	case *flagJWTIssuer != "" && c.Iss != *flagJWTIssuer:
+		return nil, fmt.Errorf("token issued by %q", c.Iss)
+	case *flagJWTAudience != "" && !slices.Contains(c.Aud, *flagJWTAudience):
+		return nil, errors.New("token is meant for someone else")
+	}
+	return &c, nil
+}
+
+func decodeJWTPart(s string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
+
+// verifyJWTSignature checks sig over signed with the algorithm the header
+// names. Only algorithms with a key configured for them are accepted, so a
+// token cannot pick "none", or HS256 with a public key as the secret.
+func verifyJWTSignature(h jwtHeader, signed string, sig []byte) error {
+	var newHash func() hash.Hash
+	var hashID crypto.Hash
+	switch h.Alg[min(2, len(h.Alg)):] {
+	case "256":
+		newHash, hashID = sha256.New, crypto.SHA256
+	case "384":
+		newHash, hashID = sha512.New384, crypto.SHA384
+	case "512":
+		newHash, hashID = sha512.New, crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported algorithm %q", h.Alg)
+	}
+	if strings.HasPrefix(h.Alg, "HS") {
+		if *flagJWTSecret == "" {
+			return fmt.Errorf("unsupported algorithm %q", h.Alg)
+		}
+		mac := hmac.New(newHash, []byte(*flagJWTSecret))
+		mac.Write([]byte(signed))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return errors.New("invalid signature")
+		}
+		return nil
+	}
+	key, err := jwks.key(h.Kid)
+	if err != nil {
+		return err
+	}
+	d := newHash()
+	d.Write([]byte(signed))
+	digest := d.Sum(nil)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch {
+		case strings.HasPrefix(h.Alg, "RS"):
+			err = rsa.VerifyPKCS1v15(k, hashID, digest, sig)
+		case strings.HasPrefix(h.Alg, "PS"):
+			err = rsa.VerifyPSS(k, hashID, digest, sig, nil)
+		default:
+			err = fmt.Errorf("algorithm %q does not fit an RSA key", h.Alg)
+		}
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(h.Alg, "ES") || len(sig) != 2*size {
+			return errors.New("invalid signature")
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			err = errors.New("invalid signature")
+		}
+	default:
+		err = errors.New("unsupported key")
+	}
+	if err != nil {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// jwksRefresh is how often the key set is fetched again, and jwksRetry how
+// soon a token with an unknown key id may fetch it early, for providers
+// that rotate keys.
+const (
+	jwksRefresh = time.Hour
+	jwksRetry   = 30 * time.Second
+)
+
+type keySet struct {
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey // by kid
+	fetched time.Time
+}
+
+var jwks keySet
+
+var jwksClient = &http.Client{Timeout: 10 * time.Second}
+
+func (s *keySet) key(kid string) (crypto.PublicKey, error) {
+	if *flagJWKSURL == "" {
+		return nil, errors.New("no key set configured (see -jwks-url)")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.lookup(kid)
+	age := time.Since(s.fetched)
+	if (!ok && age > jwksRetry) || age > jwksRefresh {
+		if err := s.fetch(); err != nil {
+			log.Printf("Could not fetch the key set from %s: %v", *flagJWKSURL, err)
+		}
+		k, ok = s.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return k, nil
+}
+
+// lookup finds the key by id; a token without one may use the only key.
+// Caller holds s.mu.
+func (s *keySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, k := range s.keys {
+			return k, true
+		}
+	}
+	k, ok := s.keys[kid]
+	return k, ok
+}
+
+// fetch loads the key set. Keys it cannot use are skipped. Caller holds
+// s.mu.
+func (s *keySet) fetch() error {
+	s.fetched = time.Now()
+	resp, err := jwksClient.Get(*flagJWKSURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	var set struct {
+		Keys []struct {
+			Kty, Kid, Use   string
+			N, E, Crv, X, Y string
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&set); err != nil {
+		return err
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var key crypto.PublicKey
+		switch k.Kty {
+		case "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+			if err1 != nil || err2 != nil || len(e) > 4 {
+				continue
+			}
+			key = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			curve := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}[k.Crv]
+			x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+			y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+			if curve == nil || err1 != nil || err2 != nil {
+				continue
+			}
+			key = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		default:
+			continue
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("no usable keys")
+	}
+	s.keys = keys
+	return nil
+}
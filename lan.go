@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+)
+
+var (
+	flagMDNS     = flag.Bool("mdns", true, "advertise the game server on the local network over mDNS, so players find it without typing an address")
+	flagMDNSName = flag.String("mdns-name", "", "name the game server is advertised under (defaults to \"Maze Runner on <host name>\")")
+)
+
+const (
+	mdnsService = "_mazerunner._tcp"
+	mdnsDomain  = "local."
+	// discoverWait is how long /discover listens for answers.
+	discoverWait = 1500 * time.Millisecond
+)
+
+var (
+	mdnsMu     sync.Mutex
+	mdnsServer *zeroconf.Server // nil when not advertising
+	mdnsPort   string           // what mdnsServer advertises
+)
+
+// advertiseLAN announces the game server on port until the process exits
+// or stopAdvertisingLAN is called.
+func advertiseLAN(port string) {
+	if !*flagMDNS || unixSocket() != "" {
+		return
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return
+	}
+	name := *flagMDNSName
+	if name == "" {
+		host, _ := os.Hostname()
+		name = "Maze Runner on " + host
+	}
+	txt := []string{"path=/ws", fmt.Sprintf("protocol=%d", protocolVersion)}
+	srv, err := zeroconf.Register(name, mdnsService, mdnsDomain, n, txt, nil)
+	if err != nil {
+		log.Printf("LAN discovery disabled: %v", err)
+		return
+	}
+	mdnsMu.Lock()
+	mdnsServer, mdnsPort = srv, port
+	mdnsMu.Unlock()
+	log.Printf("Advertising %q on the local network", name)
+}
+
+// stopAdvertisingLAN withdraws the announcement, so an upgraded process can
+// make its own. It returns the port that was advertised, empty if none.
+func stopAdvertisingLAN() string {
+	mdnsMu.Lock()
+	defer mdnsMu.Unlock()
+	if mdnsServer == nil {
+		return ""
+	}
+	mdnsServer.Shutdown()
+	mdnsServer = nil
+	return mdnsPort
+}
+
+// lanServer is a game server found on the local network.
+type lanServer struct {
+	Name    string   `json:"name"`
+	Host    string   `json:"host"`
+	Addrs   []string `json:"addrs"`
+	Port    int      `json:"port"`
+	Address string   `json:"address"` // what to type into the server box
+}
+
+// handleDiscover lists the game servers answering on the local network.
+func handleDiscover(w http.ResponseWriter, r *http.Request) {
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		log.Printf("LAN discovery failed: %v", err)
+		http.Error(w, "LAN discovery is not available", http.StatusServiceUnavailable)
+		return
+	}
+	entries := make(chan *zeroconf.ServiceEntry)
+	ctx, cancel := context.WithTimeout(r.Context(), discoverWait)
+	defer cancel()
+	if err := resolver.Browse(ctx, mdnsService, mdnsDomain, entries); err != nil {
+		log.Printf("LAN discovery failed: %v", err)
+		http.Error(w, "LAN discovery is not available", http.StatusServiceUnavailable)
+		return
+	}
+	list := []lanServer{}
+	for e := range entries {
+		s := lanServer{Name: e.Instance, Host: e.HostName, Port: e.Port}
+		for _, ip := range e.AddrIPv4 {
+			s.Addrs = append(s.Addrs, ip.String())
+		}
+		for _, ip := range e.AddrIPv6 {
+			s.Addrs = append(s.Addrs, ip.String())
+		}
+		if len(s.Addrs) == 0 {
+			continue
+		}
+		s.Address = net.JoinHostPort(s.Addrs[0], strconv.Itoa(s.Port))
+		list = append(list, s)
+	}
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"time"
+)
+
+var flagLaps = flag.Int("laps", 1, "multi-lap race: laps to run before a player finishes; each goal visit sends the player back to its spawn")
+
+// roundStartFor is when p's current round began for timing purposes.
+// Daily challenge players arrive whenever they like, so they are timed from
+// their own join instead of the round start.
+func roundStartFor(p *Player) time.Time {
+	if *flagDaily {
+		return p.joinedAt
+	}
+	return startTime
+}
+
+// lapCount is the number of laps announced to clients, 0 for a normal race.
+func lapCount() int {
+	if raceLaps() <= 1 {
+		return 0
+	}
+	return raceLaps()
+}
+
+// completeLap books a lap for p, who just reached the goal. If laps remain
+// p is sent back to its spawn with its checkpoints and goals cleared and true is
+// returned; otherwise p is done racing. Caller holds mu.
+func completeLap(p *Player) bool {
+	if raceLaps() <= 1 {
+		return false
+	}
+	now := clock()
+	since := p.lapStart
+	if since.IsZero() {
+		since = roundStartFor(p)
+	}
+	lap := now.Sub(since).Milliseconds()
+	if p.BestLap == 0 || lap < p.BestLap {
+		p.BestLap = lap
+	}
+	p.Laps++
+	p.lapStart = now
+	if p.Laps >= raceLaps() {
+		return false
+	}
+	s := spawnPoints[p.spawn]
+	p.X, p.Y = s.X, s.Y
+	p.Checkpoint = 0
+	p.Collected = 0
+	p.collected = nil
+	if p.caps.has(capPrivate) {
+		data, _ := json.Marshal(map[string]any{"type": "lap", "lap": p.Laps, "lapTime": lap, "x": p.X, "y": p.Y})
+		p.send(data)
+	}
+	return true
+}
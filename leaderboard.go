@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var flagLeaderboard = flag.String("leaderboard", "leaderboard.db", "SQLite database recording every finish for the all-time leaderboard (empty disables)")
+
+// leaderboardMaxTop caps how many entries one request may ask for.
+const leaderboardMaxTop = 500
+
+// LeaderboardEntry is one finish on the all-time leaderboard.
+type LeaderboardEntry struct {
+	Name       string    `json:"name"`
+	Color      string    `json:"color"`
+	Player     string    `json:"player,omitempty"` // profile id, if the player has an account
+	Width      int       `json:"width"`
+	Height     int       `json:"height"`
+	Seed       int64     `json:"seed"`
+	Time       int64     `json:"time"` // seconds
+	Rank       int       `json:"rank"` // finish rank within the round
+	FinishedAt time.Time `json:"finishedAt"`
+}
+
+// LeaderboardQuery narrows the leaderboard down. Zero fields match
+// everything.
+type LeaderboardQuery struct {
+	Width, Height int
+	Seed          *int64
+	From, To      time.Time // To is exclusive
+	Limit         int
+}
+
+// matches reports whether e passes the filters; Limit is not checked.
+func (q LeaderboardQuery) matches(e LeaderboardEntry) bool {
+	switch {
+	case q.Width > 0 && (e.Width != q.Width || e.Height != q.Height):
+		return false
+	case q.Seed != nil && e.Seed != *q.Seed:
+		return false
+	case !q.From.IsZero() && e.FinishedAt.Before(q.From):
+		return false
+	case !q.To.IsZero() && !e.FinishedAt.Before(q.To):
+		return false
+	}
+	return true
+}
+
+// LeaderboardStore keeps finishes and returns the fastest ones first.
+type LeaderboardStore interface {
+	RecordFinish(e LeaderboardEntry) error
+	Top(q LeaderboardQuery) ([]LeaderboardEntry, error)
+	First() (time.Time, error) // when the oldest finish happened, zero if none
+	Close() error
+}
+
+var leaderboard LeaderboardStore // nil when disabled
+
+// openLeaderboard keeps the leaderboard in Redis when -redis is set,
+// otherwise in the SQLite file at path.
+func openLeaderboard(path string) error {
+	if redisClient != nil {
+		leaderboard = redisLeaderboard{redisClient}
+		return nil
+	}
+	if path == "" {
+		return nil
+	}
+	s, err := openSQLiteLeaderboard(path)
+	if err != nil {
+		return err
+	}
+	leaderboard = s
+	return nil
+}
+
+// finishBacklog is how many finishes may wait for the leaderboard before
+// new ones are dropped; like the journal, the game never waits on it.
+const finishBacklog = 256
+
+// finishRecord is a finish on its way to the webhooks and the leaderboard.
+type finishRecord struct {
+	entry  LeaderboardEntry
+	ranked bool // goes on the leaderboard too
+}
+
+var finishQueue = make(chan finishRecord, finishBacklog)
+
+// recordFinish announces a finish and adds it to the all-time
+// leaderboard. Coin rounds are ranked by coins, not time, and stay off the
+// leaderboard, as do finishes helped by -assist. It is called with mu held,
+// so the store and the webhooks are left to runFinishes.
+func recordFinish(p Player) {
+	countFinish(p)
+	e := LeaderboardEntry{
+		Name:       p.Name,
+		Color:      p.Color,
+		Player:     p.profile,
+		Width:      mazeWidth,
+		Height:     mazeHeight,
+		Seed:       mazeSeed,
+		Time:       p.FinishTime,
+		Rank:       p.FinishRank,
+		FinishedAt: time.Now(),
+	}
+	select {
+	case finishQueue <- finishRecord{e, leaderboard != nil && !coinMode() && !p.Assisted}:
+	default:
+		log.Printf("Leaderboard falling behind, finish of %s dropped", p.Name)
+	}
+}
+
+// runFinishes announces the queued finishes and records the ranked ones,
+// telling webhooks about new records.
+func runFinishes() {
+	for f := range finishQueue {
+		fireWebhooks(hookFinish, f.entry)
+		if f.ranked {
+			rankFinish(f.entry)
+		}
+	}
+}
+
+// rankFinish puts a finish on the leaderboard and the podium.
+func rankFinish(e LeaderboardEntry) {
+	seed := e.Seed
+	best, err := leaderboard.Top(LeaderboardQuery{Width: e.Width, Height: e.Height, Seed: &seed, Limit: 1})
+	if err != nil {
+		log.Printf("Leaderboard query failed: %v", err)
+	}
+	if err := leaderboard.RecordFinish(e); err != nil {
+		log.Printf("Could not record finish on the leaderboard: %v", err)
+		return
+	}
+	refreshPodium()
+	// The game-over state carries the podium: send it again with this
+	// finish on it.
+	mu.Lock()
+	over := gameOver
+	mu.Unlock()
+	if over && *flagHallOfFame {
+		broadcast()
+	}
+	if err == nil && (len(best) == 0 || e.Time < best[0].Time) {
+		record := map[string]any{"finish": e}
+		if len(best) > 0 {
+			record["previous"] = best[0]
+		}
+		fireWebhooks(hookNewRecord, record)
+	}
+}
+
+// parseDay accepts a date (YYYY-MM-DD, UTC) or an RFC 3339 timestamp. A
+// bare date used as the end of a range includes that whole day.
+func parseDay(s string, end bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err == nil && end {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, err
+}
+
+// parseLeaderboardQuery reads the size, seed, from, to and top filters.
+func parseLeaderboardQuery(v url.Values) (LeaderboardQuery, error) {
+	q := LeaderboardQuery{Limit: 10}
+	var err error
+	if s := v.Get("size"); s != "" {
+		if q.Width, q.Height, err = parseMazeSize(s); err != nil {
+			return q, err
+		}
+	}
+	if s := v.Get("seed"); s != "" {
+		seed, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return q, errors.New("invalid seed")
+		}
+		q.Seed = &seed
+	}
+	if s := v.Get("from"); s != "" {
+		if q.From, err = parseDay(s, false); err != nil {
+			return q, errors.New("invalid from, want YYYY-MM-DD or RFC 3339")
+		}
+	}
+	if s := v.Get("to"); s != "" {
+		if q.To, err = parseDay(s, true); err != nil {
+			return q, errors.New("invalid to, want YYYY-MM-DD or RFC 3339")
+		}
+	}
+	if s := v.Get("top"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			return q, errors.New("invalid top")
+		}
+		q.Limit = min(n, leaderboardMaxTop)
+	}
+	return q, nil
+}
+
+// handleLeaderboard serves GET /leaderboard?size=WxH&seed=N&from=DATE&to=DATE&top=N.
+func handleLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if leaderboard == nil {
+		http.Error(w, "the leaderboard is disabled", http.StatusNotFound)
+		return
+	}
+	q, err := parseLeaderboardQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	list, err := leaderboard.Top(q)
+	if err != nil {
+		log.Printf("Leaderboard query failed: %v", err)
+		http.Error(w, "could not load the leaderboard", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseDay(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		end  bool
+		want time.Time
+		ok   bool
+	}{
+		{"2024-03-01", false, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2024-03-01", true, time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC), true},
+		{"2024-12-31", true, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{"2024-03-01T12:30:00Z", false, time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), true},
+		// A timestamp is exact, also as the end of a range.
+		{"2024-03-01T12:30:00Z", true, time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), true},
+		{"2024-03-01T12:30:00+02:00", false, time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC), true},
+		{"", false, time.Time{}, false},
+		{"yesterday", false, time.Time{}, false},
+		{"2024-13-01", false, time.Time{}, false},
+		{"01/03/2024", false, time.Time{}, false},
+	} {
+		got, err := parseDay(tc.in, tc.end)
+		if (err == nil) != tc.ok {
+			t.Errorf("parseDay(%q, %v): got error %v, want ok %v", tc.in, tc.end, err, tc.ok)
+			continue
+		}
+		if tc.ok && !got.Equal(tc.want) {
+			t.Errorf("parseDay(%q, %v) = %v, want %v", tc.in, tc.end, got, tc.want)
+		}
+	}
+}
+
+func TestParseLeaderboardQuery(t *testing.T) {
+	seed := int64(42)
+	march1, march3 := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		query string
+		want  LeaderboardQuery
+		err   string
+	}{
+		{query: "", want: LeaderboardQuery{Limit: 10}},
+		{query: "size=31x21", want: LeaderboardQuery{Width: 31, Height: 21, Limit: 10}},
+		{query: "size=small", want: LeaderboardQuery{Width: 31, Height: 21, Limit: 10}},
+		{query: "seed=42&top=3", want: LeaderboardQuery{Seed: &seed, Limit: 3}},
+		{query: "from=2024-03-01&to=2024-03-02", want: LeaderboardQuery{From: march1, To: march3, Limit: 10}},
+		{query: "top=100000", want: LeaderboardQuery{Limit: leaderboardMaxTop}},
+		{query: "size=huger", err: `invalid maze size "huger"`},
+		{query: "size=5x5", err: "maze size 5x5 is too small (minimum 11x11)"},
+		{query: "seed=x", err: "invalid seed"},
+		{query: "from=soon", err: "invalid from, want YYYY-MM-DD or RFC 3339"},
+		{query: "to=2024-02-30", err: "invalid to, want YYYY-MM-DD or RFC 3339"},
+		{query: "top=0", err: "invalid top"},
+		{query: "top=-5", err: "invalid top"},
+		{query: "top=ten", err: "invalid top"},
+	} {
+		v, _ := url.ParseQuery(tc.query)
+		got, err := parseLeaderboardQuery(v)
+		if tc.err != "" {
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("%q: got error %v, want %q", tc.query, err, tc.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tc.query, err)
+			continue
+		}
+		sameSeed := (got.Seed == nil) == (tc.want.Seed == nil) && (got.Seed == nil || *got.Seed == *tc.want.Seed)
+		if got.Width != tc.want.Width || got.Height != tc.want.Height || !sameSeed ||
+			!got.From.Equal(tc.want.From) || !got.To.Equal(tc.want.To) || got.Limit != tc.want.Limit {
+			t.Errorf("%q: got %+v, want %+v", tc.query, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var flagMazeLibrary = flag.String("maze-library", "mazes.db", "SQLite database keeping every maze played or imported under a short code, for /mazes and /reset?maze=CODE (empty disables)")
+
+// LibraryMaze is a maze kept in the library.
+type LibraryMaze struct {
+	Code       string      `json:"code"`
+	Name       string      `json:"name,omitempty"`
+	Width      int         `json:"width"`
+	Height     int         `json:"height"`
+	Seed       int64       `json:"seed,omitempty"` // 0 for imported mazes
+	Plays      int         `json:"plays"`          // rounds started on it
+	SavedAt    time.Time   `json:"savedAt"`
+	LastPlayed *time.Time  `json:"lastPlayed,omitempty"`
+	Maze       *CustomMaze `json:"maze,omitempty"` // not in listings
+}
+
+// MazeLibrary keeps mazes by code. SaveMaze adds the maze or, if its code
+// is known, counts the play and keeps the name unless a new one is given.
+// LibraryMaze returns nil without an error for unknown codes. LibraryMazes
+// lists without the grids, most played first or, with recent, most recently
+// played first.
+type MazeLibrary interface {
+	SaveMaze(e *LibraryMaze) error
+	LibraryMaze(code string) (*LibraryMaze, error)
+	LibraryMazes(limit int, recent bool) ([]LibraryMaze, error)
+	Close() error
+}
+
+var (
+	library  MazeLibrary // nil when disabled
+	mazeCode string      // library code of the current maze; guarded by mu
+)
+
+func openMazeLibrary(path string) error {
+	if path == "" {
+		return nil
+	}
+	s, err := openSQLiteLibrary(path)
+	if err != nil {
+		return err
+	}
+	library = s
+	return nil
+}
+
+// Codes leave out 0, 1, I and O, which are easily mixed up when read aloud.
+var codeEncoding = base32.NewEncoding("23456789ABCDEFGHJKLMNPQRSTUVWXYZ").WithPadding(base32.NoPadding)
+
+// libraryCode derives the code from the maze itself, so the same layout
+// always gets the same code, whoever saves it.
+func libraryCode(m *CustomMaze) string {
+	data, _ := json.Marshal(m)
+	sum := sha256.Sum256(data)
+	return codeEncoding.EncodeToString(sum[:5])
+}
+
+// libraryRoundStart saves the maze of the round that just started.
+func libraryRoundStart() {
+	mu.Lock()
+	m := &CustomMaze{Grid: make([][]int, len(maze)), Start: point{startX, startY}, Goal: point{goalX, goalY}, Portals: portals}
+	for y, row := range maze {
+		m.Grid[y] = append([]int(nil), row...)
+	}
+	seed := mazeSeed
+	mu.Unlock()
+	code := libraryCode(m)
+	mu.Lock()
+	mazeCode = code
+	mu.Unlock()
+	if library == nil {
+		return
+	}
+	now := clock().UTC()
+	e := &LibraryMaze{Code: code, Width: len(m.Grid[0]), Height: len(m.Grid), Seed: seed, Plays: 1, SavedAt: now, LastPlayed: &now, Maze: m}
+	if err := library.SaveMaze(e); err != nil {
+		log.Printf("Could not save maze %s to the library: %v", code, err)
+	}
+}
+
+// libraryMaze looks up a maze to play by its code.
+func libraryMaze(code string) (*CustomMaze, error) {
+	if library == nil {
+		return nil, mazeErr("library", "the maze library is disabled")
+	}
+	e, err := library.LibraryMaze(strings.ToUpper(code))
+	if err != nil {
+		log.Printf("Maze library lookup failed: %v", err)
+		return nil, mazeErr("library", "could not load maze %s", code)
+	}
+	if e == nil {
+		return nil, mazeErr("unknown", "no maze with code %s", code)
+	}
+	return e.Maze, nil
+}
+
+// handleMazes serves GET /mazes[?limit=N&sort=recent].
+func handleMazes(w http.ResponseWriter, r *http.Request) {
+	if library == nil {
+		http.Error(w, "the maze library is disabled", http.StatusNotFound)
+		return
+	}
+	list, err := library.LibraryMazes(queryInt(r, "limit", 50, 1, 1000), r.URL.Query().Get("sort") == "recent")
+	if err != nil {
+		log.Printf("Maze library query failed: %v", err)
+		http.Error(w, "could not load the maze library", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// handleLibraryMaze serves GET /mazes/{code} with the grid.
+func handleLibraryMaze(w http.ResponseWriter, r *http.Request) {
+	if library == nil {
+		http.Error(w, "the maze library is disabled", http.StatusNotFound)
+		return
+	}
+	e, err := library.LibraryMaze(strings.ToUpper(r.PathValue("code")))
+	if err != nil {
+		log.Printf("Maze library lookup failed: %v", err)
+		http.Error(w, "could not load maze", http.StatusInternalServerError)
+		return
+	}
+	if e == nil {
+		http.Error(w, "unknown maze", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(e)
+}
+
+// handleMazeImport serves POST /mazes[?name=...]: a maze in any format
+// POST /maze takes goes into the library without being played.
+func handleMazeImport(w http.ResponseWriter, r *http.Request) {
+	if library == nil {
+		http.Error(w, "the maze library is disabled", http.StatusNotFound)
+		return
+	}
+	m, err := readUploadedMaze(w, r)
+	if err != nil {
+		writeMazeError(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+	e := &LibraryMaze{Code: libraryCode(m), Name: r.URL.Query().Get("name"), Width: len(m.Grid[0]), Height: len(m.Grid), SavedAt: clock().UTC(), Maze: m}
+	if err := library.SaveMaze(e); err != nil {
+		log.Printf("Could not import maze: %v", err)
+		http.Error(w, "could not save maze", http.StatusInternalServerError)
+		return
+	}
+	audit(r, "maze-import", map[string]any{"code": e.Code, "name": e.Name, "width": e.Width, "height": e.Height})
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "code": e.Code})
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var flagPingInterval = flag.Duration("ping-interval", 15*time.Second, "how often clients that asked for pings get one; a client silent for three intervals is disconnected (0 disables)")
+
+// A crashed browser or a machine that dropped off the network does not close
+// its connection: nothing arrives and, until the socket buffers fill, writes
+// still succeed. Clients with the ping capability answer every ping, so one
+// that has been silent for a while is gone and gets disconnected.
+
+type pingMessage struct {
+	Type string `json:"type"`
+	T    int64  `json:"t"` // server time in milliseconds, echoed in the pong
+}
+
+func runPings() {
+	interval := *flagPingInterval
+	for range time.Tick(interval) {
+		data, _ := json.Marshal(pingMessage{Type: "ping", T: time.Now().UnixMilli()})
+		var dead []*websocket.Conn
+		mu.Lock()
+		for conn, p := range clients {
+			if !p.caps.has(capPing) {
+				continue
+			}
+			if silent := time.Since(p.heard); silent > 3*interval {
+				log.Printf("Dropping %s [%s]: silent for %v", conn.Request().RemoteAddr, p.Name, silent.Round(time.Second))
+				dead = append(dead, conn)
+				continue
+			}
+			p.send(data)
+		}
+		mu.Unlock()
+		// The usual disconnect handling removes the players.
+		for _, conn := range dead {
+			conn.Close()
+		}
+	}
+}
+
+// handlePong records the round trip of the ping answered.
+func handlePong(p *Player, sent int64) {
+	if sent <= 0 {
+		return
+	}
+	rtt := time.Since(time.UnixMilli(sent))
+	if rtt < 0 {
+		return
+	}
+	mu.Lock()
+	p.rtt = rtt
+	mu.Unlock()
+}
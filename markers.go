@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// Marker annotates a maze cell, e.g. "decision point A" in a lesson or a
+// sponsor logo at an event. Markers belong to the current maze and are
+// dropped when a new one is generated.
+type Marker struct {
+	ID    int    `json:"id"`
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+	Label string `json:"label,omitempty"`
+	Color string `json:"color,omitempty"`
+	Icon  string `json:"icon,omitempty"`
+}
+
+var (
+	markersMu    sync.Mutex
+	markers      []Marker
+	nextMarkerID = 1
+	hexColorRE   = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+func currentMarkers() []Marker {
+	markersMu.Lock()
+	defer markersMu.Unlock()
+	return append([]Marker{}, markers...)
+}
+
+func clearMarkers() {
+	markersMu.Lock()
+	markers = nil
+	markersMu.Unlock()
+}
+
+// broadcastMarkers tells connected clients about changed markers.
+func broadcastMarkers() {
+	data, _ := json.Marshal(map[string]any{"type": "markers", "markers": currentMarkers()})
+	mu.Lock()
+	defer mu.Unlock()
+	for _, p := range clients {
+		if !p.caps.has(capEvents) {
+			continue
+		}
+		p.send(data)
+	}
+}
+
+// handleMarkers lists markers publicly; adding (POST) and removing (DELETE
+// ?id= or everything) needs the manage-rooms scope.
+func handleMarkers(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(currentMarkers())
+	case http.MethodPost:
+		requireScope(scopeManageRooms, addMarker)(w, r)
+	case http.MethodDelete:
+		requireScope(scopeManageRooms, deleteMarker)(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// validate checks that m fits a width x height maze.
+func (m Marker) validate(width, height int) error {
+	switch {
+	case m.Y < 0 || m.Y >= height || m.X < 0 || m.X >= width:
+		return errors.New("marker is outside the maze")
+	case utf8.RuneCountInString(m.Label) > 40 || utf8.RuneCountInString(m.Icon) > 4:
+		return errors.New("label is limited to 40 and icon to 4 characters")
+	case m.Color != "" && !hexColorRE.MatchString(m.Color):
+		return errors.New("color must look like #rrggbb")
+	}
+	return nil
+}
+
+func addMarker(w http.ResponseWriter, r *http.Request) {
+	var m Marker
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil {
+		http.Error(w, "invalid marker JSON", http.StatusBadRequest)
+		return
+	}
+	mu.Lock()
+	width, height := mazeWidth, mazeHeight
+	mu.Unlock()
+	if err := m.validate(width, height); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	markersMu.Lock()
+	m.ID = nextMarkerID
+	nextMarkerID++
+	markers = append(markers, m)
+	markersMu.Unlock()
+	log.Printf("Marker %d added at (%d, %d): %q", m.ID, m.X, m.Y, m.Label)
+	markersChanged()
+	broadcastMarkers()
+	json.NewEncoder(w).Encode(m)
+}
+
+func deleteMarker(w http.ResponseWriter, r *http.Request) {
+	idStr := r.URL.Query().Get("id")
+	markersMu.Lock()
+	if idStr == "" {
+		markers = nil
+	} else {
+		id, _ := strconv.Atoi(idStr)
+		found := false
+		for i, m := range markers {
+			if m.ID == id {
+				markers = append(markers[:i], markers[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			markersMu.Unlock()
+			http.Error(w, "no such marker", http.StatusNotFound)
+			return
+		}
+	}
+	markersMu.Unlock()
+	markersChanged()
+	broadcastMarkers()
+	json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkerValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		marker Marker
+		err    string
+	}{
+		{"plain", Marker{X: 3, Y: 4}, ""},
+		{"everything set", Marker{X: 30, Y: 20, Label: "decision point A", Color: "#FF8800", Icon: "🚩"}, ""},
+		{"left of the maze", Marker{X: -1, Y: 4}, "marker is outside the maze"},
+		{"above the maze", Marker{X: 3, Y: -1}, "marker is outside the maze"},
+		{"right of the maze", Marker{X: 31, Y: 4}, "marker is outside the maze"},
+		{"below the maze", Marker{X: 3, Y: 21}, "marker is outside the maze"},
+		// Lengths count characters, so a label in any script gets as many.
+		{"longest label", Marker{Label: strings.Repeat("ü", 40)}, ""},
+		{"label too long", Marker{Label: strings.Repeat("a", 41)}, "label is limited to 40 and icon to 4 characters"},
+		{"longest icon", Marker{Icon: "🚩🚩🚩🚩"}, ""},
+		{"icon too long", Marker{Icon: "flags"}, "label is limited to 40 and icon to 4 characters"},
+		{"short color", Marker{Color: "#f80"}, "color must look like #rrggbb"},
+		{"named color", Marker{Color: "orange"}, "color must look like #rrggbb"},
+		{"color with more after it", Marker{Color: "#ff8800;background:url(x)"}, "color must look like #rrggbb"},
+	} {
+		got := ""
+		if err := tc.marker.validate(31, 21); err != nil {
+			got = err.Error()
+		}
+		if got != tc.err {
+			t.Errorf("%s: got error %q, want %q", tc.name, got, tc.err)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var flagMatches = flag.String("matches", "matches.db", "SQLite database keeping the results of completed rounds for /matches (empty disables)")
+
+// MatchSettings are the game rules a round was played with.
+type MatchSettings struct {
+	Difficulty  string `json:"difficulty,omitempty"`
+	Spawns      int    `json:"spawns,omitempty"`
+	Collisions  bool   `json:"collisions,omitempty"`
+	Checkpoints int    `json:"checkpoints,omitempty"`
+	Laps        int    `json:"laps,omitempty"`
+	Goals       int    `json:"goals,omitempty"`
+	Portals     int    `json:"portals,omitempty"`
+	Hints       int    `json:"hints"`
+	Items       bool   `json:"items,omitempty"`
+	Quakes      bool   `json:"quakes,omitempty"`
+	Coins       int    `json:"coins,omitempty"`
+	CoinTime    int64  `json:"coinTime,omitempty"` // seconds
+	Minotaur    bool   `json:"minotaur,omitempty"`
+	Teams       int    `json:"teams,omitempty"`
+	Collapse    int64  `json:"collapseEvery,omitempty"` // seconds
+}
+
+// Match is a completed round.
+type Match struct {
+	ID        int64         `json:"id"`
+	StartedAt time.Time     `json:"startedAt"`
+	EndedAt   time.Time     `json:"endedAt"`
+	Width     int           `json:"width"`
+	Height    int           `json:"height"`
+	Seed      int64         `json:"seed"` // 0 for uploaded mazes
+	Daily     string        `json:"daily,omitempty"`
+	Settings  MatchSettings `json:"settings"`
+	Standings []Player      `json:"standings"`
+}
+
+// MatchStore keeps completed rounds. Match returns nil without an error
+// for unknown ids; Matches lists the newest first, below id before if it
+// is not 0.
+type MatchStore interface {
+	SaveMatch(m *Match) error
+	Match(id int64) (*Match, error)
+	Matches(limit int, before int64) ([]Match, error)
+	Close() error
+}
+
+var matches MatchStore // nil when disabled
+
+func openMatches(path string) error {
+	if path == "" {
+		return nil
+	}
+	s, err := openSQLiteMatches(path)
+	if err != nil {
+		return err
+	}
+	matches = s
+	return nil
+}
+
+func currentSettings() MatchSettings {
+	s := MatchSettings{
+		Difficulty:  raceDifficulty(settings),
+		Spawns:      len(spawnPoints),
+		Collisions:  *flagCollisions,
+		Checkpoints: len(checkpoints),
+		Goals:       len(goals),
+		Portals:     len(portals),
+		Hints:       *flagHints,
+		Items:       *flagItemsEvery > 0,
+		Quakes:      *flagQuakeEvery > 0,
+		Coins:       *flagCoins,
+		Minotaur:    minotaurOn,
+		Teams:       teamCount(),
+	}
+	if n := lapCount(); n > 1 {
+		s.Laps = n
+	}
+	if coinMode() {
+		s.CoinTime = int64(flagCoinTime.Seconds())
+	}
+	if collapseMode() {
+		s.Collapse = int64(flagCollapseEvery.Seconds())
+	}
+	return s
+}
+
+// newMatch sums up the round that just ended, nil without -matches. Caller
+// holds mu.
+func newMatch() *Match {
+	if matches == nil {
+		return nil
+	}
+	m := &Match{
+		StartedAt: startTime,
+		EndedAt:   time.Now(),
+		Width:     mazeWidth,
+		Height:    mazeHeight,
+		Seed:      mazeSeed,
+		Settings:  currentSettings(),
+	}
+	if *flagDaily {
+		m.Daily = dailyDay
+	}
+	for _, p := range clients {
+		m.Standings = append(m.Standings, *p)
+	}
+	sort.SliceStable(m.Standings, func(i, j int) bool {
+		a, b := m.Standings[i], m.Standings[j]
+		if a.Finished != b.Finished {
+			return a.Finished
+		}
+		return a.FinishRank < b.FinishRank
+	})
+	return m
+}
+
+// saveMatch stores a match newMatch made, without holding mu.
+func saveMatch(m *Match) {
+	if m == nil {
+		return
+	}
+	if err := matches.SaveMatch(m); err != nil {
+		log.Printf("Could not save match: %v", err)
+		return
+	}
+	log.Printf("Match %d saved", m.ID)
+}
+
+// writeMatchesCSV writes one row per player and match.
+func writeMatchesCSV(w http.ResponseWriter, list []Match) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"match", "started_at", "ended_at", "width", "height", "seed", "rank", "name", "team", "finished", "time", "wall_hits", "hints_used", "coins"})
+	for _, m := range list {
+		for _, p := range m.Standings {
+			cw.Write([]string{
+				strconv.FormatInt(m.ID, 10),
+				m.StartedAt.UTC().Format(time.RFC3339),
+				m.EndedAt.UTC().Format(time.RFC3339),
+				strconv.Itoa(m.Width),
+				strconv.Itoa(m.Height),
+				strconv.FormatInt(m.Seed, 10),
+				strconv.Itoa(p.FinishRank),
+				p.Name,
+				p.Team,
+				strconv.FormatBool(p.Finished),
+				strconv.FormatInt(p.FinishTime, 10),
+				strconv.Itoa(p.WallHits),
+				strconv.Itoa(p.HintsUsed),
+				strconv.Itoa(p.Coins),
+			})
+		}
+	}
+	cw.Flush()
+}
+
+// handleMatches serves GET /matches?limit=N&before=ID[&format=csv].
+func handleMatches(w http.ResponseWriter, r *http.Request) {
+	if matches == nil {
+		http.Error(w, "match history is disabled", http.StatusNotFound)
+		return
+	}
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			limit = min(n, 1000)
+		}
+	}
+	before, _ := strconv.ParseInt(r.URL.Query().Get("before"), 10, 64)
+	list, err := matches.Matches(limit, before)
+	if err != nil {
+		log.Printf("Match history query failed: %v", err)
+		http.Error(w, "could not load matches", http.StatusInternalServerError)
+		return
+	}
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Disposition", `attachment; filename="matches.csv"`)
+		writeMatchesCSV(w, list)
+		return
+	}
+	json.NewEncoder(w).Encode(list)
+}
+
+// handleMatch serves GET /matches/{id}[?format=csv].
+func handleMatch(w http.ResponseWriter, r *http.Request) {
+	if matches == nil {
+		http.Error(w, "match history is disabled", http.StatusNotFound)
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid match id", http.StatusBadRequest)
+		return
+	}
+	m, err := matches.Match(id)
+	if err != nil {
+		log.Printf("Match lookup failed: %v", err)
+		http.Error(w, "could not load match", http.StatusInternalServerError)
+		return
+	}
+	if m == nil {
+		http.Error(w, "unknown match", http.StatusNotFound)
+		return
+	}
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Disposition", `attachment; filename="match-`+strconv.FormatInt(id, 10)+`.csv"`)
+		writeMatchesCSV(w, []Match{*m})
+		return
+	}
+	json.NewEncoder(w).Encode(m)
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+type point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// CustomMaze is a hand-designed maze uploaded through POST /maze.
+type CustomMaze struct {
+	Grid  [][]int `json:"grid"`
+	Start point   `json:"start"`
+	Goal  point   `json:"goal"`
+	// Portals pairs up teleporter cells. Without any, -portals generates them.
+	Portals [][2]point `json:"portals,omitempty"`
+}
+
+// MazeError is a maze rejected by validation, returned to API clients as
+// {"ok": false, "error": {"code": ..., "message": ...}}.
+type MazeError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *MazeError) Error() string { return e.Message }
+
+func mazeErr(code, format string, args ...any) *MazeError {
+	return &MazeError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func writeMazeError(w http.ResponseWriter, err error, status int) {
+	var me *MazeError
+	if !errors.As(err, &me) {
+		me = &MazeError{Code: "invalid", Message: err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"ok": false, "error": me})
+}
+
+var (
+	pendingMu   sync.Mutex
+	pendingMaze *CustomMaze // replaces the generated maze of the next round
+)
+
+func isOpen(grid [][]int, x, y int) bool {
+	return y >= 0 && y < len(grid) && x >= 0 && x < len(grid[y]) && grid[y][x] != cellWall
+}
+
+// bfsDistances returns the walking distance (in moves) from (fromX, fromY)
+// to every cell, -1 for walls and unreachable cells.
+func bfsDistances(grid [][]int, fromX, fromY int) [][]int {
+	return bfs(grid, point{fromX, fromY}, false)
+}
+
+// bfsDistancesTo returns the walking distance from every cell to (toX, toY).
+// It differs from bfsDistances only where one-way cells or ice make routes
+// asymmetric.
+func bfsDistancesTo(grid [][]int, toX, toY int) [][]int {
+	return bfs(grid, point{toX, toY}, true)
+}
+
+func bfs(grid [][]int, from point, reverse bool) [][]int {
+	dist := make([][]int, len(grid))
+	for y := range grid {
+		dist[y] = make([]int, len(grid[y]))
+		for x := range dist[y] {
+			dist[y][x] = -1
+		}
+	}
+	if !isOpen(grid, from.X, from.Y) {
+		return dist
+	}
+	// Without ice every move ends on the neighbor, so walking backwards is
+	// just checking the step the other way round.
+	var preds map[point][]point
+	if reverse && hasIce(grid) {
+		preds = predecessors(grid)
+	}
+	dist[from.Y][from.X] = 0
+	queue := []point{from}
+	var next []point
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+		next = next[:0]
+		switch {
+		case preds != nil:
+			next = append(next, preds[c]...)
+		case reverse:
+			for _, d := range [4][2]int{{0, 1}, {0, -1}, {1, 0}, {-1, 0}} {
+				if n := (point{c.X + d[0], c.Y + d[1]}); canStep(grid, n, c) {
+					next = append(next, n)
+				}
+			}
+		default:
+			for _, d := range [4][2]int{{0, 1}, {0, -1}, {1, 0}, {-1, 0}} {
+				if n, ok := step(grid, c, d); ok {
+					next = append(next, n)
+				}
+			}
+		}
+		for _, n := range next {
+			if dist[n.Y][n.X] < 0 {
+				dist[n.Y][n.X] = dist[c.Y][c.X] + 1
+				queue = append(queue, n)
+			}
+		}
+	}
+	return dist
+}
+
+// shortestPath returns the cells from start to goal inclusive, or nil if the
+// goal cannot be reached. Cells slid over on ice are left out, so every
+// cell is where one move ends.
+func shortestPath(grid [][]int, start, goal point) []point {
+	dist := bfsDistancesTo(grid, goal.X, goal.Y)
+	if !isOpen(grid, start.X, start.Y) || dist[start.Y][start.X] < 0 {
+		return nil
+	}
+	path := []point{start}
+	for c := start; c != goal; {
+		for _, d := range [4][2]int{{0, 1}, {0, -1}, {1, 0}, {-1, 0}} {
+			if n, ok := step(grid, c, d); ok && dist[n.Y][n.X] == dist[c.Y][c.X]-1 {
+				c = n
+				break
+			}
+		}
+		path = append(path, c)
+	}
+	return path
+}
+
+func (m *CustomMaze) validate() error {
+	h := len(m.Grid)
+	if h == 0 {
+		return mazeErr("empty", "grid is empty")
+	}
+	w := len(m.Grid[0])
+	if err := validateMazeSize(w, h); err != nil {
+		return mazeErr("size", "%v", err)
+	}
+	for y, row := range m.Grid {
+		if len(row) != w {
+			return mazeErr("ragged", "row %d has %d cells, expected %d", y, len(row), w)
+		}
+		for x, c := range row {
+			if c < cellFloor || c > cellMud {
+				return mazeErr("cell", "cell (%d, %d) has value %d, expected 0 (floor), 1 (wall), 2-5 (one-way east, south, west, north), 6 (ice) or 7 (mud)", x, y, c)
+			}
+		}
+	}
+	used := map[point]bool{m.Start: true, m.Goal: true}
+	for i, pr := range m.Portals {
+		for _, c := range pr {
+			if !isOpen(m.Grid, c.X, c.Y) {
+				return mazeErr("portal", "portal %d end (%d, %d) is not a floor cell", i, c.X, c.Y)
+			}
+			if used[c] {
+				return mazeErr("portal", "portal %d end (%d, %d) is already the start, the goal or another portal", i, c.X, c.Y)
+			}
+			used[c] = true
+		}
+	}
+	return checkSolvable(m.Grid, m.Start, m.Goal)
+}
+
+// checkSolvable makes sure start and goal are floor cells connected by a path.
+func checkSolvable(grid [][]int, start, goal point) error {
+	if !isOpen(grid, start.X, start.Y) {
+		return mazeErr("start", "start (%d, %d) is not a floor cell", start.X, start.Y)
+	}
+	if !isOpen(grid, goal.X, goal.Y) {
+		return mazeErr("goal", "goal (%d, %d) is not a floor cell", goal.X, goal.Y)
+	}
+	if bfsDistances(grid, start.X, start.Y)[goal.Y][goal.X] < 0 {
+		return mazeErr("unsolvable", "goal (%d, %d) is not reachable from start (%d, %d)", goal.X, goal.Y, start.X, start.Y)
+	}
+	return nil
+}
+
+// mazeCandidate is a maze with its layout, built and checked off to the
+// side. Only the one a round is played on is installed, so players never
+// see or walk a maze that gets rejected.
+type mazeCandidate struct {
+	grid        [][]int
+	start, goal point
+	seed        int64 // 0 for an uploaded maze
+	layout      mazeLayout
+}
+
+// customCandidate lays out an uploaded maze for a round played with s.
+func customCandidate(m *CustomMaze, s GameSettings) *mazeCandidate {
+	rng := rand.New(rand.NewSource(newSeed()))
+	return &mazeCandidate{grid: m.Grid, start: m.Start, goal: m.Goal, layout: layoutMaze(m.Grid, m.Start, m.Goal, rng, s, m.Portals)}
+}
+
+// verify checks that a round can be played on c.
+func (c *mazeCandidate) verify() error {
+	return checkSolvable(c.grid, c.start, c.goal)
+}
+
+// install makes c the current maze. Caller holds mu.
+func (c *mazeCandidate) install() {
+	maze, mazeWidth, mazeHeight = c.grid, len(c.grid[0]), len(c.grid)
+	startX, startY, goalX, goalY = c.start.X, c.start.Y, c.goal.X, c.goal.Y
+	mazeSeed = c.seed
+	if c.seed == 0 {
+		log.Printf("Custom maze loaded %dx%d. Start at (%d, %d), goal at (%d, %d)", mazeWidth, mazeHeight, startX, startY, goalX, goalY)
+	} else {
+		log.Printf("Maze generated %dx%d (seed %d). Goal at (%d, %d)", mazeWidth, mazeHeight, mazeSeed, goalX, goalY)
+	}
+	c.layout.install()
+}
+
+// setPendingMaze makes m the next round's maze. The daily challenge keeps
+// the day's maze, so there it is refused.
+func setPendingMaze(m *CustomMaze) error {
+	if *flagDaily {
+		return errDailyMaze
+	}
+	pendingMu.Lock()
+	pendingMaze = m
+	pendingMu.Unlock()
+	return nil
+}
+
+// takePendingMaze returns the uploaded maze queued for this round, if any.
+func takePendingMaze() *CustomMaze {
+	pendingMu.Lock()
+	defer pendingMu.Unlock()
+	m := pendingMaze
+	pendingMaze = nil
+	return m
+}
+
+// readUploadedMaze parses and validates a JSON CustomMaze, a maze drawn as
+// a PNG (Content-Type image/png) or the text format (Content-Type text/plain).
+func readUploadedMaze(w http.ResponseWriter, r *http.Request) (*CustomMaze, error) {
+	body := http.MaxBytesReader(w, r.Body, 64<<20)
+	ctype, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+	switch ctype {
+	case "image/png":
+		return customMazeFromPNG(body)
+	case "text/plain":
+		return customMazeFromText(body)
+	}
+	m := &CustomMaze{}
+	if err := json.NewDecoder(body).Decode(m); err != nil {
+		return nil, mazeErr("parse", "invalid maze JSON: %v", err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func handleMazeUpload(w http.ResponseWriter, r *http.Request) {
+	m, err := readUploadedMaze(w, r)
+	if err != nil {
+		writeMazeError(w, err, http.StatusUnprocessableEntity)
+		return
+	}
+	queueCustomMaze(w, r, m)
+}
+
+// handleValidate reports whether a maze is playable: the queued upload with
+// GET, or a candidate in the request body with POST (nothing is queued;
+// admins only, like uploads).
+func handleValidate(w http.ResponseWriter, r *http.Request) {
+	var m *CustomMaze
+	if r.Method == http.MethodPost {
+		var err error
+		if m, err = readUploadedMaze(w, r); err != nil {
+			writeMazeError(w, err, http.StatusOK)
+			return
+		}
+	} else {
+		pendingMu.Lock()
+		m = pendingMaze
+		pendingMu.Unlock()
+		if m == nil {
+			writeMazeError(w, mazeErr("none", "no uploaded maze is waiting for the next round"), http.StatusNotFound)
+			return
+		}
+		if err := m.validate(); err != nil {
+			writeMazeError(w, err, http.StatusOK)
+			return
+		}
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"ok":         true,
+		"width":      len(m.Grid[0]),
+		"height":     len(m.Grid),
+		"pathLength": len(shortestPath(m.Grid, m.Start, m.Goal)) - 1,
+	})
+}
+
+// queueCustomMaze makes a validated maze the next round's maze.
+func queueCustomMaze(w http.ResponseWriter, r *http.Request, m *CustomMaze) {
+	if err := setPendingMaze(m); err != nil {
+		writeMazeError(w, err, http.StatusConflict)
+		return
+	}
+	log.Printf("Custom maze %dx%d uploaded, used for the next round", len(m.Grid[0]), len(m.Grid))
+	audit(r, "maze-upload", map[string]any{"width": len(m.Grid[0]), "height": len(m.Grid)})
+	json.NewEncoder(w).Encode(map[string]any{"ok": true, "width": len(m.Grid[0]), "height": len(m.Grid), "nextRound": true})
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GET /maze is what every client fetches on joining, so a round start
+// brings many at once. The grid is serialized and compressed once per
+// revision instead of on every request, and clients that have it already
+// get a 304.
+
+var (
+	mazeCacheMu  sync.Mutex
+	mazeRevision int // counts the grids served, new maze or changed cells
+	mazeGrid     mazeBody
+	mazeBits     mazeBody // ?format=bitset
+)
+
+// A mazeBody is one format of the grid, ready to send. It is empty before
+// the first round.
+type mazeBody struct {
+	plain, gz []byte
+	etag      string
+}
+
+func newMazeBody(v any) mazeBody {
+	data, _ := json.Marshal(v)
+	data = append(data, '\n')
+	var buf bytes.Buffer
+	gz, _ := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	gz.Write(data)
+	gz.Close()
+	sum := sha256.Sum256(data)
+	return mazeBody{data, buf.Bytes(), `"` + hex.EncodeToString(sum[:8]) + `"`}
+}
+
+// BitsetMaze is the grid in about a tenth of the space: one bit per cell, set
+// for walls, row by row from the top left, lowest bit of each byte first.
+// The rare cells that are neither wall nor floor, like ice, are listed
+// apart, and so are the markers (the plain grid has no room for them).
+type BitsetMaze struct {
+	Width   int          `json:"width"`
+	Height  int          `json:"height"`
+	Walls   []byte       `json:"walls"` // base64 in JSON
+	Cells   []cellChange `json:"cells,omitempty"`
+	Markers []Marker     `json:"markers,omitempty"`
+}
+
+func bitsetMaze(grid [][]int) BitsetMaze {
+	b := BitsetMaze{Height: len(grid)}
+	if len(grid) > 0 {
+		b.Width = len(grid[0])
+	}
+	b.Walls = make([]byte, (b.Width*b.Height+7)/8)
+	for y, row := range grid {
+		for x, v := range row {
+			switch v {
+			case cellWall:
+				i := y*b.Width + x
+				b.Walls[i/8] |= 1 << (i % 8)
+			case cellFloor:
+			default:
+				b.Cells = append(b.Cells, cellChange{x, y, v})
+			}
+		}
+	}
+	return b
+}
+
+// mazeChanged serializes the current grid for /maze and returns its
+// revision. It runs when a round starts and when cells change.
+func mazeChanged() int {
+	mazeCacheMu.Lock()
+	defer mazeCacheMu.Unlock()
+	mazeRevision++
+	serializeMaze()
+	return mazeRevision
+}
+
+// markersChanged serializes the grid again with the new markers. The
+// revision stays: the cells are the same.
+func markersChanged() {
+	mazeCacheMu.Lock()
+	defer mazeCacheMu.Unlock()
+	serializeMaze()
+}
+
+// serializeMaze builds the bodies /maze serves. Caller holds mazeCacheMu.
+func serializeMaze() {
+	// The grid is replaced, never edited in place, once it is in play.
+	mu.Lock()
+	grid := maze
+	mu.Unlock()
+	bits := bitsetMaze(grid)
+	bits.Markers = currentMarkers()
+	mazeGrid, mazeBits = newMazeBody(grid), newMazeBody(bits)
+}
+
+// currentMazeRevision is the revision /maze serves.
+func currentMazeRevision() int {
+	mazeCacheMu.Lock()
+	defer mazeCacheMu.Unlock()
+	return mazeRevision
+}
+
+// handleMaze serves GET /maze[?format=bitset]. The ETag changes with the
+// grid; clients revalidate every time, as cells may change at any moment.
+func handleMaze(w http.ResponseWriter, r *http.Request) {
+	mazeCacheMu.Lock()
+	body := mazeGrid
+	if r.URL.Query().Get("format") == "bitset" {
+		body = mazeBits
+	}
+	mazeCacheMu.Unlock()
+	data, gz, etag := body.plain, body.gz, body.etag
+	if data == nil {
+		http.Error(w, "no maze yet", http.StatusServiceUnavailable)
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("ETag", etag)
+	h.Set("Vary", "Accept-Encoding")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		h.Set("Content-Encoding", "gzip")
+		data = gz
+	}
+	h.Set("Content-Length", strconv.Itoa(len(data)))
+	w.Write(data)
+}
+
+func etagMatches(header, etag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == etag || t == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"strings"
+)
+
+var (
+	flagMazePNG  = flag.String("maze-png", "", "load the first maze from a black/white PNG (one pixel per cell, red pixel = goal, green pixel = start)")
+	flagMazeFile = flag.String("maze-file", "", "load the first maze from a text file (# wall, . floor, S start, G goal, >v<^ one-way, ~ ice, % mud, then \"portal X1 Y1 X2 Y2\" lines)")
+)
+
+// customMazeFromPNG turns an image into a maze: dark pixels are walls, light
+// pixels floor. A pure red pixel marks the goal and an optional pure green
+// pixel the start. The size in the header is checked before any pixel is
+// decoded, so a small file cannot claim a huge image.
+func customMazeFromPNG(r io.Reader) (*CustomMaze, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, mazeErr("parse", "reading PNG: %v", err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, mazeErr("parse", "decoding PNG: %v", err)
+	}
+	if err := validateMazeSize(cfg.Width, cfg.Height); err != nil {
+		return nil, mazeErr("size", "%v; draw one pixel per cell", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, mazeErr("parse", "decoding PNG: %v", err)
+	}
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	m := &CustomMaze{Grid: make([][]int, h), Start: point{-1, -1}, Goal: point{-1, -1}}
+	for y := 0; y < h; y++ {
+		m.Grid[y] = make([]int, w)
+		for x := 0; x < w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			r, g, bl = r>>8, g>>8, bl>>8
+			switch {
+			case r > 200 && g < 80 && bl < 80:
+				m.Goal = point{x, y}
+			case g > 200 && r < 80 && bl < 80:
+				m.Start = point{x, y}
+			case (299*r+587*g+114*bl)/1000 < 128:
+				m.Grid[y][x] = 1
+			}
+		}
+	}
+	if m.Goal.X < 0 {
+		return nil, mazeErr("goal", "no goal found, mark it with a red (#ff0000) pixel")
+	}
+	if m.Start.X < 0 {
+		m.Start = firstOpenCell(m.Grid)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// customMazeFromText parses the plain text maze format: one line per row,
+// '#' for walls, '.' for floor, 'S' for the start and 'G' for the goal.
+// '>', 'v', '<' and '^' are one-way cells, '~' is ice and '%' mud. Lines
+// "portal X1 Y1 X2 Y2" after the rows pair up teleporter cells.
+func customMazeFromText(r io.Reader) (*CustomMaze, error) {
+	m := &CustomMaze{Start: point{-1, -1}, Goal: point{-1, -1}}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*maxMazeDim)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if rest, ok := strings.CutPrefix(line, "portal "); ok {
+			var pr [2]point
+			if _, err := fmt.Sscan(rest, &pr[0].X, &pr[0].Y, &pr[1].X, &pr[1].Y); err != nil {
+				return nil, mazeErr("parse", "line %d: want portal X1 Y1 X2 Y2", n)
+			}
+			m.Portals = append(m.Portals, pr)
+			continue
+		}
+		if len(m.Portals) > 0 {
+			return nil, mazeErr("parse", "line %d: rows of the maze must come before the portals", n)
+		}
+		// Columns count characters, not bytes.
+		y, cells := len(m.Grid), []rune(line)
+		row := make([]int, len(cells))
+		for x, c := range cells {
+			switch c {
+			case '#':
+				row[x] = 1
+			case '.':
+			case 'S':
+				if m.Start.X >= 0 {
+					return nil, mazeErr("start", "line %d, column %d: second start, the maze has one S", n, x+1)
+				}
+				m.Start = point{x, y}
+			case 'G':
+				if m.Goal.X >= 0 {
+					return nil, mazeErr("goal", "line %d, column %d: second goal, the maze has one G", n, x+1)
+				}
+				m.Goal = point{x, y}
+			case '>':
+				row[x] = cellEast
+			case 'v':
+				row[x] = cellSouth
+			case '<':
+				row[x] = cellWest
+			case '^':
+				row[x] = cellNorth
+			case '~':
+				row[x] = cellIce
+			case '%':
+				row[x] = cellMud
+			default:
+				return nil, mazeErr("parse", "line %d, column %d: unexpected %q (want #, ., S, G, one of >v<^, ~ or %%)", n, x+1, c)
+			}
+		}
+		m.Grid = append(m.Grid, row)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	// Editors like to leave an empty last line behind.
+	for len(m.Grid) > 0 && len(m.Grid[len(m.Grid)-1]) == 0 {
+		m.Grid = m.Grid[:len(m.Grid)-1]
+	}
+	if m.Goal.X < 0 {
+		return nil, mazeErr("goal", "no goal found, mark it with a G")
+	}
+	if m.Start.X < 0 {
+		m.Start = firstOpenCell(m.Grid)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// firstOpenCell scans from the top-left for a floor cell.
+func firstOpenCell(grid [][]int) point {
+	for y := range grid {
+		for x := range grid[y] {
+			if grid[y][x] == 0 {
+				return point{x, y}
+			}
+		}
+	}
+	return point{-1, -1}
+}
+
+// loadStartupMaze reads the maze named on the command line, if any.
+func loadStartupMaze() (*CustomMaze, error) {
+	path, parse := *flagMazePNG, customMazeFromPNG
+	switch {
+	case *flagMazePNG != "" && *flagMazeFile != "":
+		return nil, errors.New("use either -maze-png or -maze-file, not both")
+	case *flagMazeFile != "":
+		path, parse = *flagMazeFile, customMazeFromText
+	case *flagMazePNG == "":
+		return nil, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parse(f)
+}
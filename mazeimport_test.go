@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// textMaze draws an 11x11 room walled in all round, with marks written
+// over its cells and extra lines added after the rows.
+func textMaze(marks map[point]string, extra ...string) string {
+	var b strings.Builder
+	for y := range 11 {
+		for x := range 11 {
+			switch {
+			case marks[point{x, y}] != "":
+				b.WriteString(marks[point{x, y}])
+			case x == 0 || y == 0 || x == 10 || y == 10:
+				b.WriteByte('#')
+			default:
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+	}
+	for _, l := range extra {
+		b.WriteString(l + "\n")
+	}
+	return b.String()
+}
+
+func TestCustomMazeFromText(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		text    string
+		code    string // MazeError code, "" when the maze is accepted
+		message string // part of the error message
+		start   point
+		goal    point
+		portals int
+	}{
+		{name: "start and goal", text: textMaze(map[point]string{{1, 1}: "S", {9, 9}: "G"}), start: point{1, 1}, goal: point{9, 9}},
+		{name: "start defaults to the first floor cell", text: textMaze(map[point]string{{9, 9}: "G"}), start: point{1, 1}, goal: point{9, 9}},
+		{name: "windows line endings", text: strings.ReplaceAll(textMaze(map[point]string{{5, 5}: "G"}), "\n", "\r\n"), start: point{1, 1}, goal: point{5, 5}},
+		{name: "terrain", text: textMaze(map[point]string{{2, 1}: ">", {3, 1}: "~", {4, 1}: "%", {9, 9}: "G"}), start: point{1, 1}, goal: point{9, 9}},
+		{name: "portals", text: textMaze(map[point]string{{9, 9}: "G"}, "portal 1 9 9 1", "portal 3 3 7 7"), start: point{1, 1}, goal: point{9, 9}, portals: 2},
+		{name: "no goal", text: textMaze(nil), code: "goal", message: "no goal"},
+		{name: "second start", text: textMaze(map[point]string{{1, 1}: "S", {2, 2}: "S", {9, 9}: "G"}), code: "start", message: "line 3, column 3"},
+		{name: "second goal", text: textMaze(map[point]string{{8, 9}: "G", {9, 9}: "G"}), code: "goal", message: "line 10, column 10"},
+		{name: "unknown character", text: textMaze(map[point]string{{4, 2}: "x", {9, 9}: "G"}), code: "parse", message: "line 3, column 5"},
+		{name: "multi-byte character", text: textMaze(map[point]string{{2, 1}: "é", {9, 9}: "G"}), code: "parse", message: "line 2, column 3"},
+		{name: "ragged rows", text: textMaze(map[point]string{{10, 4}: "##", {9, 9}: "G"}), code: "ragged"},
+		{name: "too small", text: "#####\n#S.G#\n#####\n", code: "size"},
+		{name: "unreachable goal", text: textMaze(map[point]string{{8, 9}: "#", {9, 8}: "#", {9, 9}: "G"}), code: "unsolvable"},
+		{name: "bad portal line", text: textMaze(map[point]string{{9, 9}: "G"}, "portal 1 9"), code: "parse", message: "line 12"},
+		{name: "portal on a wall", text: textMaze(map[point]string{{9, 9}: "G"}, "portal 0 0 5 5"), code: "portal"},
+		{name: "rows after the portals", text: textMaze(map[point]string{{9, 9}: "G"}, "portal 1 9 9 1", "#.........#"), code: "parse", message: "line 13"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := customMazeFromText(strings.NewReader(tc.text))
+			if tc.code != "" {
+				var me *MazeError
+				if !errors.As(err, &me) || me.Code != tc.code || !strings.Contains(me.Message, tc.message) {
+					t.Fatalf("got error %v, want code %q mentioning %q", err, tc.code, tc.message)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Start != tc.start || m.Goal != tc.goal || len(m.Portals) != tc.portals {
+				t.Errorf("got start %v, goal %v and %d portals, want %v, %v and %d", m.Start, m.Goal, len(m.Portals), tc.start, tc.goal, tc.portals)
+			}
+		})
+	}
+}
+
+func TestCustomMazeFromPNG(t *testing.T) {
+	red, green := color.RGBA{255, 0, 0, 255}, color.RGBA{0, 255, 0, 255}
+	for _, tc := range []struct {
+		name        string
+		size        int
+		marks       map[point]color.RGBA
+		code        string
+		start, goal point
+	}{
+		{name: "start and goal", size: 11, marks: map[point]color.RGBA{{2, 2}: green, {8, 8}: red}, start: point{2, 2}, goal: point{8, 8}},
+		{name: "start defaults to the first floor cell", size: 11, marks: map[point]color.RGBA{{8, 8}: red}, start: point{1, 1}, goal: point{8, 8}},
+		{name: "no goal", size: 11, marks: map[point]color.RGBA{{2, 2}: green}, code: "goal"},
+		{name: "too small", size: 5, marks: map[point]color.RGBA{{2, 2}: red}, code: "size"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			img := image.NewRGBA(image.Rect(0, 0, tc.size, tc.size))
+			for y := range tc.size {
+				for x := range tc.size {
+					c := color.RGBA{255, 255, 255, 255}
+					if x == 0 || y == 0 || x == tc.size-1 || y == tc.size-1 {
+						c = color.RGBA{0, 0, 0, 255}
+					}
+					if mark, ok := tc.marks[point{x, y}]; ok {
+						c = mark
+					}
+					img.Set(x, y, c)
+				}
+			}
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, img); err != nil {
+				t.Fatal(err)
+			}
+			m, err := customMazeFromPNG(&buf)
+			if tc.code != "" {
+				var me *MazeError
+				if !errors.As(err, &me) || me.Code != tc.code {
+					t.Fatalf("got error %v, want code %q", err, tc.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.Start != tc.start || m.Goal != tc.goal {
+				t.Errorf("got start %v and goal %v, want %v and %v", m.Start, m.Goal, tc.start, tc.goal)
+			}
+		})
+	}
+}
+
+// TestMazeTextRoundTrip exports mazes as /maze.txt does and imports them
+// again, as when a maze is copied between servers.
+func TestMazeTextRoundTrip(t *testing.T) {
+	for _, seed := range []int64{1, 2, 3} {
+		c, err := generateMaze(31, 21, seed, settings)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Generated mazes have no terrain or portals unless asked for, so
+		// add some of each.
+		grid := make([][]int, len(c.grid))
+		for y, row := range c.grid {
+			grid[y] = append([]int(nil), row...)
+		}
+		var ends []point
+		for y, row := range grid {
+			for x, v := range row {
+				if p := (point{x, y}); v == cellFloor && p != c.start && p != c.goal {
+					ends = append(ends, p)
+				}
+			}
+		}
+		grid[ends[0].Y][ends[0].X] = cellMud
+		grid[ends[1].Y][ends[1].X] = cellIce
+		portals := [][2]point{{ends[2], ends[3]}, {ends[4], ends[5]}}
+		want := &CustomMaze{Grid: grid, Start: c.start, Goal: c.goal, Portals: portals}
+
+		got, err := customMazeFromText(strings.NewReader(mazeText(want.Grid, want.Start, want.Goal, want.Portals)))
+		if err != nil {
+			t.Fatalf("seed %d: %v", seed, err)
+		}
+		if libraryCode(got) != libraryCode(want) {
+			t.Errorf("seed %d: maze changed on the way:\n%s\nbecame\n%s", seed, mazeText(want.Grid, want.Start, want.Goal, want.Portals), mazeText(got.Grid, got.Start, got.Goal, got.Portals))
+		}
+	}
+}
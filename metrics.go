@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+)
+
+var flagDifficulty = flag.String("difficulty", "", "regenerate mazes until they rate easy, medium or hard (empty accepts any)")
+
+// difficultyAttempts bounds how many mazes are tried for a difficulty target.
+const difficultyAttempts = 30
+
+// MazeMetrics describes how hard a maze is to solve.
+type MazeMetrics struct {
+	PathLength   int     `json:"pathLength"`   // steps on the shortest start-goal path
+	DeadEnds     int     `json:"deadEnds"`     // floor cells with a single exit
+	Junctions    int     `json:"junctions"`    // floor cells with three or more exits
+	BranchFactor float64 `json:"branchFactor"` // average side exits per cell on the solution path
+	Difficulty   float64 `json:"difficulty"`   // normalized score, 0 (trivial) to 1 (hard)
+	Level        string  `json:"level"`        // easy, medium or hard
+}
+
+var mazeMetrics MazeMetrics
+
+// goalDist holds every cell's walking distance to the goal (-1 for walls and
+// cut-off cells), so player progress is a lookup instead of a search.
+var goalDist [][]int
+
+// distanceToGoal returns how many steps (x, y) is from the goal, or -1.
+func distanceToGoal(x, y int) int {
+	if y < 0 || y >= len(goalDist) || x < 0 || x >= len(goalDist[y]) {
+		return -1
+	}
+	return goalDist[y][x]
+}
+
+// difficultyTargets are the score each level aims for.
+var difficultyTargets = map[string]float64{"easy": 0.25, "medium": 0.5, "hard": 0.75}
+
+func exits(grid [][]int, x, y int) int {
+	n := 0
+	for _, d := range [4][2]int{{0, 1}, {0, -1}, {1, 0}, {-1, 0}} {
+		if isOpen(grid, x+d[0], y+d[1]) {
+			n++
+		}
+	}
+	return n
+}
+
+func computeMetrics(grid [][]int, start, goal point) MazeMetrics {
+	var m MazeMetrics
+	floor := 0
+	for y, row := range grid {
+		for x, v := range row {
+			if v == cellWall {
+				continue
+			}
+			floor++
+			switch e := exits(grid, x, y); {
+			case e == 1 && point{x, y} != start && point{x, y} != goal:
+				m.DeadEnds++
+			case e >= 3:
+				m.Junctions++
+			}
+		}
+	}
+	path := shortestPath(grid, start, goal)
+	if len(path) == 0 || floor == 0 {
+		return m
+	}
+	m.PathLength = len(path) - 1
+	side := 0
+	for i, p := range path {
+		e := exits(grid, p.X, p.Y)
+		// Cells in the middle of the path use two exits themselves.
+		if i == 0 || i == len(path)-1 {
+			e--
+		} else {
+			e -= 2
+		}
+		side += max(e, 0)
+	}
+	m.BranchFactor = math.Round(float64(side)/float64(len(path))*1000) / 1000
+
+	// Three ingredients, each squashed into [0, 1]: how winding the path is
+	// compared to a straight line, how many wrong turns branch off it and
+	// how many dead ends there are to get lost in.
+	direct := max(1, abs(goal.X-start.X)+abs(goal.Y-start.Y))
+	winding := clamp01((float64(m.PathLength)/float64(direct) - 1) / 4)
+	branching := clamp01(m.BranchFactor / 0.5)
+	deadEnds := clamp01(float64(m.DeadEnds) / float64(floor) / 0.15)
+	m.Difficulty = math.Round((0.5*winding+0.25*branching+0.25*deadEnds)*1000) / 1000
+	switch {
+	case m.Difficulty < 0.4:
+		m.Level = "easy"
+	case m.Difficulty < 0.6:
+		m.Level = "medium"
+	default:
+		m.Level = "hard"
+	}
+	return m
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+// mazeLayout is what the server derives from a maze: how hard it is, how
+// far every cell is from the goal and where players, checkpoints, goals and
+// portals go.
+type mazeLayout struct {
+	metrics     MazeMetrics
+	goalDist    [][]int
+	spawns      []point
+	checkpoints []point
+	goals       []point
+	portals     [][2]point
+}
+
+// layoutMaze derives the layout of a maze for a round played with s.
+// Portals an upload configured are kept; otherwise they are drawn from rng.
+func layoutMaze(grid [][]int, start, goal point, rng *rand.Rand, s GameSettings, configured [][2]point) mazeLayout {
+	l := mazeLayout{metrics: computeMetrics(grid, start, goal), goalDist: bfsDistancesTo(grid, goal.X, goal.Y)}
+	l.spawns = pickSpawns(grid, l.goalDist, start, *flagSpawns)
+	l.checkpoints = placeCheckpoints(grid, start, goal, raceCheckpoints(s))
+	l.goals = pickGoals(grid, l.goalDist, start, goal, raceGoals(s))
+	l.portals = configured
+	if len(configured) == 0 {
+		reserved := append([]point{start, goal}, l.spawns...)
+		reserved = append(reserved, l.checkpoints...)
+		reserved = append(reserved, l.goals...)
+		l.portals = pickPortals(grid, l.goalDist, rng, *flagPortals, reserved)
+	}
+	return l
+}
+
+// install makes l the layout of the current maze. Caller holds mu.
+func (l mazeLayout) install() {
+	mazeMetrics, goalDist, spawnPoints = l.metrics, l.goalDist, l.spawns
+	checkpoints, goals, portals = l.checkpoints, l.goals, l.portals
+	log.Printf("Maze metrics: path %d, dead ends %d, branch factor %.2f, difficulty %.3f (%s)",
+		mazeMetrics.PathLength, mazeMetrics.DeadEnds, mazeMetrics.BranchFactor, mazeMetrics.Difficulty, mazeMetrics.Level)
+}
+
+// generateWithDifficulty generates mazes until one rates as level, keeping
+// the closest one if none does. An explicit seed or empty level skips the
+// search. Like generateMaze it only returns the maze.
+func generateWithDifficulty(w, h int, seed int64, level string, s GameSettings) (*mazeCandidate, error) {
+	if level == "" || seed != 0 {
+		return generateMaze(w, h, seed, s)
+	}
+	target, ok := difficultyTargets[level]
+	if !ok {
+		return nil, fmt.Errorf("unknown difficulty %q (want easy, medium or hard)", level)
+	}
+	var best *mazeCandidate
+	bestDist := math.Inf(1)
+	for i := 0; i < difficultyAttempts; i++ {
+		c, err := generateMaze(w, h, 0, s)
+		if err != nil {
+			return nil, err
+		}
+		if c.layout.metrics.Level == level {
+			return c, nil
+		}
+		if d := math.Abs(c.layout.metrics.Difficulty - target); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	log.Printf("No %s maze after %d attempts, using the closest one", level, difficultyAttempts)
+	return best, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+)
+
+var flagMinotaur = flag.Duration("minotaur", 0, "let a minotaur chase the nearest player, moving one cell this often; caught players go back to their spawn (0 disables)")
+
+// minotaurName is how the monster shows up in the player list.
+const minotaurName = "Minotaur"
+
+var (
+	minotaur   point // guarded by mu
+	minotaurOn bool
+)
+
+// placeMinotaur puts the monster halfway along the solution path, far from
+// the spawns but in the way. Caller holds mu.
+func placeMinotaur() {
+	minotaurOn = *flagMinotaur > 0
+	if !minotaurOn {
+		return
+	}
+	path := shortestPath(maze, point{startX, startY}, point{goalX, goalY})
+	if len(path) == 0 {
+		minotaurOn = false
+		return
+	}
+	minotaur = path[len(path)/2]
+}
+
+// runMinotaur moves the monster every tick while a round is running.
+func runMinotaur() {
+	for range time.Tick(*flagMinotaur) {
+		mu.Lock()
+		moved := minotaurOn && !gameOver && len(clients) > 0 && stepMinotaur()
+		mu.Unlock()
+		if moved {
+			broadcast()
+		}
+	}
+}
+
+// stepMinotaur walks one move toward the nearest unfinished player and
+// catches whoever it lands on. Caller holds mu.
+func stepMinotaur() bool {
+	dist := bfsDistances(maze, minotaur.X, minotaur.Y)
+	var prey *Player
+	for _, p := range clients {
+		if p.Finished || dist[p.Y][p.X] < 0 {
+			continue
+		}
+		if prey == nil || dist[p.Y][p.X] < dist[prey.Y][prey.X] {
+			prey = p
+		}
+	}
+	if prey == nil {
+		return false
+	}
+	if path := shortestPath(maze, minotaur, point{prey.X, prey.Y}); len(path) > 1 {
+		minotaur = path[1]
+	}
+	for _, p := range clients {
+		if catchPlayer(p) {
+			sendPosition(p, p.X, p.Y)
+		}
+	}
+	return true
+}
+
+// catchPlayer sends p back to its spawn if it shares a cell with the
+// minotaur. Spawns are safe, or a caught player could be caught again on
+// arrival. Caller holds mu.
+func catchPlayer(p *Player) bool {
+	if !minotaurOn || p.Finished || minotaur != (point{p.X, p.Y}) {
+		return false
+	}
+	s := spawnPoints[p.spawn]
+	if s == minotaur {
+		return false
+	}
+	log.Printf("MINOTAUR caught %s", p.Name)
+	p.X, p.Y = s.X, s.Y
+	p.Caught++
+	p.dirty = true
+	return true
+}
+
+// minotaurPlayer is the monster as it appears in state broadcasts.
+func minotaurPlayer() Player {
+	return Player{X: minotaur.X, Y: minotaur.Y, Name: minotaurName, Color: "#8b0000", Distance: -1, NPC: true}
+}
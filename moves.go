@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+var flagCollisions = flag.Bool("collisions", false, "a cell holds at most one player; moves into occupied cells are refused")
+
+// ClumsinessEntry is a connected player's collision record since it joined.
+type ClumsinessEntry struct {
+	Name         string  `json:"name"`
+	WallHits     int     `json:"wallHits"`
+	Moves        int     `json:"moves"`
+	InvalidMoves int     `json:"invalidMoves"` // jumps the client should never send
+	HitRate      float64 `json:"hitRate"`      // wall hits per attempted move
+}
+
+var (
+	clumsyMu   sync.Mutex
+	clumsiness = make(map[*Player]*ClumsinessEntry)
+)
+
+// clumsyEntry is p's record, created on its first move. Caller holds mu and
+// clumsyMu.
+func clumsyEntry(p *Player) *ClumsinessEntry {
+	e := clumsiness[p]
+	if e == nil {
+		e = &ClumsinessEntry{}
+		clumsiness[p] = e
+	}
+	e.Name = p.Name
+	return e
+}
+
+// forgetClumsiness drops the record of a player that left.
+func forgetClumsiness(p *Player) {
+	clumsyMu.Lock()
+	delete(clumsiness, p)
+	clumsyMu.Unlock()
+}
+
+// applyMove validates a move request: players may only step onto an
+// adjacent floor cell (two cells with a speed or wall-phase power-up),
+// through one-way cells only in their direction and not at all while stuck
+// in mud or frozen. Ice may carry the player further. Returns false if the
+// move was refused, in which case the client has to be told where the
+// player really is. Caller holds mu.
+func applyMove(p *Player, x, y int) bool {
+	if p.Finished || p.Eliminated {
+		return false
+	}
+	clumsyMu.Lock()
+	defer clumsyMu.Unlock()
+	e := clumsyEntry(p)
+	steps := abs(x-p.X) + abs(y-p.Y)
+	long := steps == 2 && (x == p.X || y == p.Y) && (hasEffect(p, itemSpeed) || hasEffect(p, itemPhase))
+	if steps != 1 && !long {
+		p.invalidMoves++
+		e.InvalidMoves++
+		if p.invalidMoves == 10 {
+			log.Printf("SUSPICIOUS: %s sent %d invalid moves this round", p.Name, p.invalidMoves)
+		}
+		return false
+	}
+	if clock().Before(p.stuckUntil) {
+		return false
+	}
+	if long {
+		if !longMove(p, [2]int{(x - p.X) / 2, (y - p.Y) / 2}) {
+			p.WallHits++
+			e.WallHits++
+			return false
+		}
+		p.moved++
+		e.Moves++
+		return true
+	}
+	if !canStep(maze, point{p.X, p.Y}, point{x, y}) {
+		p.WallHits++
+		e.WallHits++
+		return false
+	}
+	if *flagCollisions && occupied(p, x, y) {
+		p.Blocked++
+		return false
+	}
+	slide(p, [2]int{x - p.X, y - p.Y})
+	p.moved++
+	e.Moves++
+	return true
+}
+
+// occupied reports whether another player stands on x,y. The goal and the
+// spawn points are never blocked: finished players stay on the goal and a
+// new round starts everyone stacked on the spawns. Caller holds mu.
+func occupied(p *Player, x, y int) bool {
+	if x == goalX && y == goalY {
+		return false
+	}
+	for _, s := range spawnPoints {
+		if s.X == x && s.Y == y {
+			return false
+		}
+	}
+	for _, o := range clients {
+		if o != p && o.X == x && o.Y == y {
+			return true
+		}
+	}
+	return false
+}
+
+// sendPosition corrects a client whose idea of its position went stale.
+// Clients that did not negotiate private frames cannot parse it. Caller
+// holds mu.
+func sendPosition(p *Player, x, y int) {
+	if !p.caps.has(capPrivate) {
+		return
+	}
+	data, _ := json.Marshal(map[string]any{"type": "position", "x": x, "y": y})
+	p.send(data)
+}
+
+// handleClumsiness serves the wall-hit leaderboard of the connected
+// players, clumsiest first.
+func handleClumsiness(w http.ResponseWriter, r *http.Request) {
+	limit := queryInt(r, "limit", 20, 1, 1000)
+	clumsyMu.Lock()
+	list := make([]ClumsinessEntry, 0, len(clumsiness))
+	for _, e := range clumsiness {
+		c := *e
+		if attempts := c.Moves + c.WallHits; attempts > 0 {
+			c.HitRate = float64(c.WallHits) / float64(attempts)
+		}
+		list = append(list, c)
+	}
+	clumsyMu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].WallHits != list[j].WallHits {
+			return list[i].WallHits > list[j].WallHits
+		}
+		return list[i].Name < list[j].Name
+	})
+	if len(list) > limit {
+		list = list[:limit]
+	}
+	json.NewEncoder(w).Encode(list)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	flagGitHubClientID     = flag.String("github-client-id", "", `client id of a GitHub OAuth app, enabling "log in with GitHub" (empty disables)`)
+	flagGitHubClientSecret = flag.String("github-client-secret", "", "client secret of the GitHub OAuth app")
+	flagGoogleClientID     = flag.String("google-client-id", "", `client id of a Google OAuth client, enabling "log in with Google" (empty disables)`)
+	flagGoogleClientSecret = flag.String("google-client-secret", "", "client secret of the Google OAuth client")
+	flagSessionSecret      = flag.String("session-secret", "", "HMAC secret for signing login sessions (random per start if empty, which logs everyone out on restart)")
+)
+
+// Logging in is optional: guests play as before, with the profile token
+// their browser keeps. A logged-in player's stats follow the account to any
+// browser. The session is a signed cookie, which the browser also sends on
+// the /ws handshake, so the game port must be on the website's host.
+
+const (
+	sessionCookie = "maze_session"
+	stateCookie   = "maze_oauth_state"
+	sessionTTL    = 30 * 24 * time.Hour
+)
+
+var sessionKey []byte
+
+// An oauthProvider is a login service speaking OAuth 2 authorization codes.
+type oauthProvider struct {
+	authURL, tokenURL, userURL string
+	scope                      string
+	clientID, clientSecret     *string
+	// user reads the account id and display name from the user endpoint.
+	user func(data []byte) (id, name string, err error)
+}
+
+var oauthProviders = map[string]*oauthProvider{
+	"github": {
+		authURL:  "https://github.com/login/oauth/authorize",
+		tokenURL: "https://github.com/login/oauth/access_token",
+		userURL:  "https://api.github.com/user",
+		clientID: flagGitHubClientID, clientSecret: flagGitHubClientSecret,
+		user: func(data []byte) (string, string, error) {
+			var u struct {
+				ID    int64  `json:"id"`
+				Login string `json:"login"`
+			}
+			if err := json.Unmarshal(data, &u); err != nil || u.ID == 0 {
+				return "", "", errors.New("no user id")
+			}
+			return strconv.FormatInt(u.ID, 10), u.Login, nil
+		},
+	},
+	"google": {
+		authURL:  "https://accounts.google.com/o/oauth2/v2/auth",
+		tokenURL: "https://oauth2.googleapis.com/token",
+		userURL:  "https://openidconnect.googleapis.com/v1/userinfo",
+		scope:    "openid profile",
+		clientID: flagGoogleClientID, clientSecret: flagGoogleClientSecret,
+		user: func(data []byte) (string, string, error) {
+			var u struct {
+				Sub  string `json:"sub"`
+				Name string `json:"name"`
+			}
+			if err := json.Unmarshal(data, &u); err != nil || u.Sub == "" {
+				return "", "", errors.New("no user id")
+			}
+			return u.Sub, u.Name, nil
+		},
+	},
+}
+
+var oauthClient = &http.Client{Timeout: 10 * time.Second}
+
+// loginProviders lists the providers set up with a client id, for the page.
+func loginProviders() []string {
+	var names []string
+	for _, name := range []string{"github", "google"} {
+		if *oauthProviders[name].clientID != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func initSessionKey() {
+	if *flagSessionSecret != "" {
+		sessionKey = []byte(*flagSessionSecret)
+		return
+	}
+	sessionKey = make([]byte, 32)
+	rand.Read(sessionKey)
+	if len(loginProviders()) > 0 {
+		log.Println("No -session-secret set, logged-in players will be logged out after a restart")
+	}
+}
+
+// A loginSession is what the session cookie holds.
+type loginSession struct {
+	Player   string `json:"p"` // public player id of the account
+	Name     string `json:"n"`
+	Provider string `json:"v"`
+	Expires  int64  `json:"e"` // Unix seconds
+}
+
+func signSession(payload string) string {
+	mac := hmac.New(sha256.New, sessionKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// sessionOf returns the login session r carries, nil for guests.
+func sessionOf(r *http.Request) *loginSession {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil || len(loginProviders()) == 0 {
+		return nil
+	}
+	payload, sig, ok := strings.Cut(c.Value, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(signSession(payload))) {
+		return nil
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil
+	}
+	var s loginSession
+	if json.Unmarshal(data, &s) != nil || time.Now().Unix() > s.Expires {
+		return nil
+	}
+	return &s
+}
+
+// verifiedPlayer is the account a joining request proves, by player token
+// or login session; "" for guests.
+func verifiedPlayer(r *http.Request) string {
+	if id, _ := jwtPlayer(r); id != "" {
+		return id
+	}
+	if s := sessionOf(r); s != nil {
+		return s.Player
+	}
+	return ""
+}
+
+// oauthRedirect is the callback URL registered with the provider. It should
+// come from -public-url: providers only redirect to addresses they know.
+func oauthRedirect(r *http.Request, provider string) string {
+	base := strings.TrimSuffix(*flagPublicURL, "/")
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + "/auth/" + provider + "/callback"
+}
+
+func secureCookies(r *http.Request) bool {
+	return r.TLS != nil || strings.HasPrefix(*flagPublicURL, "https:")
+}
+
+// handleLogin serves GET /auth/{provider}: off to the provider's login page.
+func handleLogin(w http.ResponseWriter, r *http.Request) {
+	p := oauthProviders[r.PathValue("provider")]
+	if p == nil || *p.clientID == "" {
+		http.Error(w, "unknown login provider", http.StatusNotFound)
+		return
+	}
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	state := hex.EncodeToString(buf)
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Value: state, Path: "/auth/", MaxAge: 600, HttpOnly: true, Secure: secureCookies(r), SameSite: http.SameSiteLaxMode})
+	q := url.Values{
+		"client_id":     {*p.clientID},
+		"redirect_uri":  {oauthRedirect(r, r.PathValue("provider"))},
+		"response_type": {"code"},
+		"state":         {state},
+	}
+	if p.scope != "" {
+		q.Set("scope", p.scope)
+	}
+	http.Redirect(w, r, p.authURL+"?"+q.Encode(), http.StatusFound)
+}
+
+// handleLoginCallback serves GET /auth/{provider}/callback: the provider
+// sends the player back with a code, which is traded for the account.
+func handleLoginCallback(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("provider")
+	p := oauthProviders[name]
+	if p == nil || *p.clientID == "" {
+		http.Error(w, "unknown login provider", http.StatusNotFound)
+		return
+	}
+	c, err := r.Cookie(stateCookie)
+	if err != nil || c.Value == "" || c.Value != r.URL.Query().Get("state") {
+		http.Error(w, "login expired, please try again", http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: stateCookie, Path: "/auth/", MaxAge: -1})
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		http.Redirect(w, r, "/", http.StatusFound) // the player cancelled
+		return
+	}
+	id, display, err := p.login(code, oauthRedirect(r, name))
+	if err != nil {
+		log.Printf("Login with %s failed: %v", name, err)
+		http.Error(w, "login failed", http.StatusBadGateway)
+		return
+	}
+	s := loginSession{
+		Player:   hashAPIKey("oauth\x00" + name + "\x00" + id)[:16],
+		Name:     display,
+		Provider: name,
+		Expires:  time.Now().Add(sessionTTL).Unix(),
+	}
+	data, _ := json.Marshal(s)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Value: payload + "." + signSession(payload), Path: "/", MaxAge: int(sessionTTL.Seconds()), HttpOnly: true, Secure: secureCookies(r), SameSite: http.SameSiteLaxMode})
+	log.Printf("%s logged in with %s as player %s", display, name, s.Player)
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// handleLogout serves GET /auth/logout.
+func handleLogout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
+// login trades the code for an access token and looks up the account.
+func (p *oauthProvider) login(code, redirect string) (id, name string, err error) {
+	form := url.Values{
+		"client_id":     {*p.clientID},
+		"client_secret": {*p.clientSecret},
+		"code":          {code},
+		"redirect_uri":  {redirect},
+		"grant_type":    {"authorization_code"},
+	}
+	req, _ := http.NewRequest(http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	data, err := oauthFetch(req)
+	if err != nil {
+		return "", "", fmt.Errorf("token: %v", err)
+	}
+	var tok struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &tok); err != nil || tok.AccessToken == "" {
+		return "", "", fmt.Errorf("token: no access token (%s)", tok.Error)
+	}
+	req, _ = http.NewRequest(http.MethodGet, p.userURL, nil)
+	req.Header.Set("Authorization", "Bearer "+tok.AccessToken)
+	req.Header.Set("Accept", "application/json")
+	if data, err = oauthFetch(req); err != nil {
+		return "", "", fmt.Errorf("user: %v", err)
+	}
+	return p.user(data)
+}
+
+func oauthFetch(req *http.Request) ([]byte, error) {
+	resp, err := oauthClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	return data, nil
+}
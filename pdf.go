@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// buildPDF assembles a minimal PDF: one page per content stream, all of the
+// same size, with Helvetica available as /F1. Enough for printable mazes
+// without pulling in a PDF library.
+func buildPDF(pages []string, pageW, pageH float64) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	obj := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-3 are fixed; every page then takes a page and a content object.
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>")
+	for i, content := range pages {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %g %g] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pageW, pageH, 5+2*i))
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write([]byte(content))
+		zw.Close()
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", len(offsets), z.Len())
+		buf.Write(z.Bytes())
+		buf.WriteString("\nendstream\nendobj\n")
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+func pdfString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`)
+	return "(" + r.Replace(s) + ")"
+}
+
+// handleMazePDF serves a printable handout: the maze on page one and the
+// same maze with its solution drawn in on page two. Terrain is drawn as on
+// /maze.svg.
+func handleMazePDF(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	grid, start, goal, seed := maze, point{startX, startY}, point{goalX, goalY}, mazeSeed
+	mu.Unlock()
+	if len(grid) == 0 {
+		http.Error(w, "no maze has been generated", http.StatusNotFound)
+		return
+	}
+	h, wd := len(grid), len(grid[0])
+
+	// A4, turned to landscape for wide mazes.
+	pageW, pageH := 595.0, 842.0
+	if wd > h {
+		pageW, pageH = pageH, pageW
+	}
+	const margin, header = 36.0, 28.0
+	cell := min((pageW-2*margin)/float64(wd), (pageH-2*margin-header)/float64(h))
+	ox := (pageW - cell*float64(wd)) / 2
+	top := pageH - margin - header
+	cellXY := func(x, y int) (float64, float64) {
+		return ox + float64(x)*cell, top - float64(y+1)*cell
+	}
+
+	var maze strings.Builder
+	maze.WriteString("0.15 0.15 0.18 rg\n")
+	for y, row := range grid {
+		for x := 0; x < wd; {
+			if row[x] != 1 {
+				x++
+				continue
+			}
+			run := x
+			for run < wd && row[run] == 1 {
+				run++
+			}
+			px, py := cellXY(x, y)
+			fmt.Fprintf(&maze, "%.2f %.2f %.2f %.2f re\n", px, py, float64(run-x)*cell, cell)
+			x = run
+		}
+	}
+	maze.WriteString("f\n")
+	// Terrain in the print colors of /maze.svg: ice and mud as tinted cells,
+	// one-way cells as triangles pointing their way.
+	for y, row := range grid {
+		for x, v := range row {
+			px, py := cellXY(x, y)
+			switch v {
+			case cellIce:
+				fmt.Fprintf(&maze, "0.66 0.85 0.92 rg %.2f %.2f %.2f %.2f re f\n", px, py, cell, cell)
+			case cellMud:
+				fmt.Fprintf(&maze, "0.78 0.66 0.47 rg %.2f %.2f %.2f %.2f re f\n", px, py, cell, cell)
+			}
+			pts, ok := svgArrows[v]
+			if !ok {
+				continue
+			}
+			maze.WriteString("0.54 0.43 0.23 rg")
+			for i, pt := range strings.Fields(pts) {
+				var ux, uy float64
+				fmt.Sscanf(pt, "%g,%g", &ux, &uy)
+				op := "l"
+				if i == 0 {
+					op = "m"
+				}
+				// The arrows are drawn for y pointing down, PDF's points up.
+				fmt.Fprintf(&maze, " %.2f %.2f %s", px+ux*cell, py+(1-uy)*cell, op)
+			}
+			maze.WriteString(" f\n")
+		}
+	}
+	sx, sy := cellXY(start.X, start.Y)
+	gx, gy := cellXY(goal.X, goal.Y)
+	fmt.Fprintf(&maze, "0.18 0.8 0.44 rg %.2f %.2f %.2f %.2f re f\n", sx, sy, cell, cell)
+	fmt.Fprintf(&maze, "0.83 0.67 0 rg %.2f %.2f %.2f %.2f re f\n", gx, gy, cell, cell)
+
+	title := fmt.Sprintf("Maze Runner  %dx%d", wd, h)
+	if seed != 0 {
+		title += fmt.Sprintf("  seed %d", seed)
+	}
+	heading := func(s string) string {
+		return fmt.Sprintf("BT /F1 14 Tf 0 0 0 rg %.2f %.2f Td %s Tj ET\n", ox, pageH-margin-14, pdfString(s))
+	}
+
+	var solution strings.Builder
+	path := shortestPath(grid, start, goal)
+	if len(path) > 1 {
+		fmt.Fprintf(&solution, "0.91 0.3 0.24 RG %.2f w 1 J 1 j\n", max(cell*0.35, 0.5))
+		for i, p := range path {
+			px, py := cellXY(p.X, p.Y)
+			op := "l"
+			if i == 0 {
+				op = "m"
+			}
+			fmt.Fprintf(&solution, "%.2f %.2f %s\n", px+cell/2, py+cell/2, op)
+		}
+		solution.WriteString("S\n")
+	}
+
+	pdf := buildPDF([]string{
+		heading(title) + maze.String(),
+		heading(title+"  - solution") + maze.String() + solution.String(),
+	}, pageW, pageH)
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="maze-%dx%d.pdf"`, wd, h))
+	w.Write(pdf)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Playback speed limits; 1 is real time.
+const (
+	minReplaySpeed = 0.1
+	maxReplaySpeed = 32
+)
+
+var errNoReplay = errors.New("no such replay")
+
+// replayPath maps a replay id (its file name without suffix) to the file.
+func replayPath(id string) (string, error) {
+	if *flagReplays == "" || id == "" || strings.HasPrefix(id, ".") || filepath.Base(id) != id {
+		return "", errNoReplay
+	}
+	return filepath.Join(*flagReplays, id+replaySuffix), nil
+}
+
+// loadReplay reads every event of a replay. A replay cut short by a reset
+// or crash has no "end" event and a possibly torn last line, which is
+// ignored.
+func loadReplay(id string) ([]replayEvent, error) {
+	path, err := replayPath(id)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errNoReplay
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	var events []replayEvent
+	sc := bufio.NewScanner(gz)
+	sc.Buffer(nil, 16<<20) // the start line carries the whole maze
+	for sc.Scan() {
+		var e replayEvent
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			break
+		}
+		events = append(events, e)
+	}
+	if len(events) == 0 || events[0].E != "start" {
+		return nil, errors.New("replay has no start event")
+	}
+	return events, nil
+}
+
+// handleReplays serves GET /replays: the ids of the stored replays, newest
+// first.
+func handleReplays(w http.ResponseWriter, r *http.Request) {
+	ids := []string{}
+	if *flagReplays != "" {
+		entries, _ := os.ReadDir(*flagReplays)
+		for _, e := range entries {
+			if id, ok := strings.CutSuffix(e.Name(), replaySuffix); ok && !e.IsDir() {
+				ids = append(ids, id)
+			}
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	json.NewEncoder(w).Encode(ids)
+}
+
+// replayFor loads the replay named in the request, answering with an error
+// itself if that fails.
+func replayFor(w http.ResponseWriter, r *http.Request) []replayEvent {
+	events, err := loadReplay(r.PathValue("id"))
+	if errors.Is(err, errNoReplay) {
+		http.Error(w, "unknown replay", http.StatusNotFound)
+		return nil
+	}
+	if err != nil {
+		log.Printf("Could not read replay %s: %v", r.PathValue("id"), err)
+		http.Error(w, "could not read replay", http.StatusInternalServerError)
+		return nil
+	}
+	return events
+}
+
+// handleReplay serves GET /replays/{id}: the raw event stream as JSON
+// lines.
+func handleReplay(w http.ResponseWriter, r *http.Request) {
+	events := replayFor(w, r)
+	if events == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		enc.Encode(e)
+	}
+}
+
+// handleReplayMaze and handleReplayInfo mirror /maze and /info for the
+// replayed round, so a client pointed at /replays/{id} instead of the
+// server root renders the recording like a live game.
+func handleReplayMaze(w http.ResponseWriter, r *http.Request) {
+	if events := replayFor(w, r); events != nil {
+		json.NewEncoder(w).Encode(events[0].Maze)
+	}
+}
+
+func handleReplayInfo(w http.ResponseWriter, r *http.Request) {
+	events := replayFor(w, r)
+	if events == nil {
+		return
+	}
+	start := events[0]
+	info := MazeInfo{Height: len(start.Maze), Seed: start.Seed, Spawns: start.Spawns, Markers: []Marker{}}
+	if info.Height > 0 {
+		info.Width = len(start.Maze[0])
+	}
+	if start.Goal != nil {
+		info.GoalX, info.GoalY = start.Goal.X, start.Goal.Y
+	}
+	if len(start.Spawns) > 0 {
+		info.StartX, info.StartY = start.Spawns[0].X, start.Spawns[0].Y
+	}
+	if s := start.Settings; s != nil {
+		info.Collide = s.Collisions
+		info.Laps = s.Laps
+	}
+	json.NewEncoder(w).Encode(info)
+}
+
+// handleReplayWS serves the playback WebSocket at /replays/{id}/ws. It
+// sends the same state messages as /ws, paced like the original round
+// and sped up by ?speed= (default 1). Clients may change the speed while
+// watching by sending {"type":"speed","speed":4}.
+func handleReplayWS(w http.ResponseWriter, r *http.Request) {
+	events := replayFor(w, r)
+	if events == nil {
+		return
+	}
+	speed := 1.0
+	if v, err := strconv.ParseFloat(r.URL.Query().Get("speed"), 64); err == nil {
+		speed = v
+	}
+	srv := wsServer(func(ws *websocket.Conn) {
+		defer ws.Close()
+		pb := &playback{speed: clampSpeed(speed)}
+		go pb.readControls(ws)
+		pb.run(ws, events)
+	})
+	srv.ServeHTTP(w, r)
+}
+
+func clampSpeed(s float64) float64 {
+	return min(max(s, minReplaySpeed), maxReplaySpeed)
+}
+
+// playback is one viewer watching a replay.
+type playback struct {
+	mu     sync.Mutex
+	speed  float64
+	closed bool
+}
+
+func (pb *playback) readControls(ws *websocket.Conn) {
+	for {
+		var msg struct {
+			Type  string  `json:"type"`
+			Speed float64 `json:"speed"`
+		}
+		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+			pb.mu.Lock()
+			pb.closed = true
+			pb.mu.Unlock()
+			if err != io.EOF {
+				log.Printf("Replay viewer read error: %v", err)
+			}
+			return
+		}
+		if msg.Type == "speed" && msg.Speed > 0 {
+			pb.mu.Lock()
+			pb.speed = clampSpeed(msg.Speed)
+			pb.mu.Unlock()
+		}
+	}
+}
+
+// wait sleeps until the replay clock has advanced by ms, following speed
+// changes as they come. It returns false once the viewer has gone.
+func (pb *playback) wait(ms int64) bool {
+	left := float64(ms)
+	for {
+		pb.mu.Lock()
+		speed, closed := pb.speed, pb.closed
+		pb.mu.Unlock()
+		if closed {
+			return false
+		}
+		if left <= 0 {
+			return true
+		}
+		step := min(left, 100*speed)
+		time.Sleep(time.Duration(step / speed * float64(time.Millisecond)))
+		left -= step
+	}
+}
+
+// run plays events back, sending a state message after each group of
+// events that happened at the same moment.
+func (pb *playback) run(ws *websocket.Conn, events []replayEvent) {
+	start := events[0]
+	grid := make([][]int, len(start.Maze))
+	for y := range start.Maze {
+		grid[y] = append([]int(nil), start.Maze[y]...)
+	}
+	goal := point{}
+	if start.Goal != nil {
+		goal = *start.Goal
+	}
+	dist := bfsDistancesTo(grid, goal.X, goal.Y)
+	players := map[int]*Player{}
+	var monster *Player
+	finished := 0
+	ended := false
+
+	now := int64(0)
+	for i, e := range events {
+		if !pb.wait(e.T - now) {
+			return
+		}
+		now = max(now, e.T)
+		switch e.E {
+		case "join":
+			p := players[e.P]
+			if p == nil {
+				p = &Player{}
+				players[e.P] = p
+			}
+			p.X, p.Y, p.Name, p.Color, p.Team = e.X, e.Y, e.Name, e.Color, e.Team
+		case "move":
+			if e.P == 0 {
+				m := minotaurPlayer()
+				m.X, m.Y = e.X, e.Y
+				monster = &m
+			} else if p := players[e.P]; p != nil {
+				p.X, p.Y = e.X, e.Y
+			}
+		case "finish":
+			if p := players[e.P]; p != nil {
+				p.Finished, p.FinishRank, p.FinishTime = true, e.Rank, e.Time
+				finished++
+			}
+		case "leave":
+			delete(players, e.P)
+		case "cells":
+			for _, c := range e.Changes {
+				grid[c.Y][c.X] = c.Cell
+			}
+			dist = bfsDistancesTo(grid, goal.X, goal.Y)
+			data, _ := json.Marshal(map[string]any{"type": "maze-diff", "changes": e.Changes})
+			websocket.Message.Send(ws, string(data))
+		case "end":
+			ended = true
+		}
+		if i+1 < len(events) && events[i+1].T == e.T {
+			continue
+		}
+
+		ids := make([]int, 0, len(players))
+		for id := range players {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		state := GameState{Players: []Player{}, GameOver: ended}
+		for _, id := range ids {
+			p := *players[id]
+			p.Distance = -1
+			if p.Y < len(dist) && p.X < len(dist[p.Y]) {
+				p.Distance = dist[p.Y][p.X]
+			}
+			state.Players = append(state.Players, p)
+		}
+		state.AllFinished = ended && finished > 0
+		if monster != nil {
+			state.Players = append(state.Players, *monster)
+		}
+		data, _ := json.Marshal(state)
+		if err := websocket.Message.Send(ws, string(data)); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var (
+	flagPollTimeout  = flag.Duration("poll-timeout", 30*time.Second, "REST polling sessions without a request for this long are closed")
+	flagPollSessions = flag.Int("poll-sessions", 256, "maximum number of REST polling sessions at once")
+)
+
+// pollQueue is how many typed messages a polling session keeps until its
+// client picks them up; older ones are dropped.
+const pollQueue = 256
+
+// pollReplyWait is how long a move waits for the game to answer before the
+// request returns without the answer; it is picked up with the next one.
+const pollReplyWait = 100 * time.Millisecond
+
+// lastState is the GameState most recently broadcast. Guarded by mu.
+var lastState []byte
+
+// A pollSession is a player without a WebSocket. Its requests go over a
+// WebSocket to handleWS all the same, only one that never leaves the
+// process, so polling players play by exactly the same rules.
+type pollSession struct {
+	ws    *websocket.Conn
+	heard chan struct{} // signalled whenever the game sends something
+
+	mu       sync.Mutex
+	messages []json.RawMessage // typed messages not yet picked up
+	lastSeen time.Time
+}
+
+var (
+	pollMu       sync.Mutex
+	pollSessions = make(map[string]*pollSession)
+	pollStarting int // sessions counted against -poll-sessions while their pipe is dialled
+
+	pipeOnce sync.Once
+	pipeLn   = &pipeListener{conns: make(chan net.Conn)}
+)
+
+// pipeListener hands in-memory connections to an HTTP server running the
+// regular WebSocket handler.
+type pipeListener struct {
+	conns chan net.Conn
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) { return <-l.conns, nil }
+func (l *pipeListener) Close() error              { return nil }
+func (l *pipeListener) Addr() net.Addr            { return pollAddr("poll") }
+
+// pollAddr is the address of the polling client, so the game logs and
+// limits it like any other connection.
+type pollAddr string
+
+func (a pollAddr) Network() string { return "tcp" }
+func (a pollAddr) String() string  { return string(a) }
+
+type addrConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+func (c addrConn) RemoteAddr() net.Addr { return c.remote }
+
+func dialPipe(remote string, header http.Header) (*websocket.Conn, error) {
+	pipeOnce.Do(func() {
+		go http.Serve(pipeLn, websocket.Handler(handleWS))
+		go expirePollSessions()
+	})
+	client, server := net.Pipe()
+	pipeLn.conns <- addrConn{server, pollAddr(remote)}
+	config, err := websocket.NewConfig("ws://poll/ws", "http://poll")
+	if err != nil {
+		return nil, err
+	}
+	config.Header = header
+	return websocket.NewClient(config, client)
+}
+
+// receive collects what the game sends the session. Game states are left
+// out, /state always has the latest.
+func (s *pollSession) receive() {
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(s.ws, &data); err != nil {
+			return
+		}
+		select {
+		case s.heard <- struct{}{}:
+		default:
+		}
+		var typed struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(data, &typed) != nil || typed.Type == "" || typed.Type == "state" {
+			continue
+		}
+		s.mu.Lock()
+		if len(s.messages) == pollQueue {
+			s.messages = s.messages[1:]
+		}
+		s.messages = append(s.messages, data)
+		s.mu.Unlock()
+	}
+}
+
+// take returns the waiting messages and keeps the session alive.
+func (s *pollSession) take() []json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastSeen = time.Now()
+	msgs := s.messages
+	s.messages = nil
+	if msgs == nil {
+		msgs = []json.RawMessage{}
+	}
+	return msgs
+}
+
+func expirePollSessions() {
+	for range time.Tick(*flagPollTimeout / 3) {
+		pollMu.Lock()
+		for token, s := range pollSessions {
+			s.mu.Lock()
+			idle := time.Since(s.lastSeen)
+			s.mu.Unlock()
+			if idle > *flagPollTimeout {
+				s.ws.Close()
+				delete(pollSessions, token)
+			}
+		}
+		pollMu.Unlock()
+	}
+}
+
+// lookupSession finds the session named by the X-Session header or the
+// session query parameter.
+func lookupSession(r *http.Request) *pollSession {
+	token := r.Header.Get("X-Session")
+	if token == "" {
+		token = r.URL.Query().Get("session")
+	}
+	pollMu.Lock()
+	defer pollMu.Unlock()
+	return pollSessions[token]
+}
+
+// handlePollSession starts a polling session (POST /session) and returns
+// its token.
+func handlePollSession(w http.ResponseWriter, r *http.Request) {
+	if credential(r) != "" {
+		name, ok := authorize(r, scopeReadState)
+		if !ok {
+			http.Error(w, "invalid API key", http.StatusUnauthorized)
+			return
+		}
+		log.Printf("Polling client authenticated as %q", name)
+	}
+	if refuseUnverified(w, r) || refuseBanned(w, r) {
+		return
+	}
+	if !mayJoin(r) {
+		http.Error(w, "this game is private, a password is needed to join", http.StatusUnauthorized)
+		return
+	}
+	if refuseFull(w) {
+		return
+	}
+	// The slot is taken before dialling, so concurrent requests cannot all
+	// pass the check and go over the limit together.
+	pollMu.Lock()
+	full := len(pollSessions)+pollStarting >= *flagPollSessions
+	if !full {
+		pollStarting++
+	}
+	pollMu.Unlock()
+	if full {
+		http.Error(w, "too many polling sessions", http.StatusServiceUnavailable)
+		return
+	}
+	// The game tells who the player is from these, as on /ws.
+	header := http.Header{}
+	if t := playerToken(r); t != "" {
+		header.Set("X-Player-Token", t)
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		header.Set("Cookie", c.String())
+	}
+	// The address as bans and connection limits see it, port kept for logs.
+	_, port, _ := net.SplitHostPort(r.RemoteAddr)
+	ws, err := dialPipe(net.JoinHostPort(clientIP(r), port), header)
+	if err != nil {
+		pollMu.Lock()
+		pollStarting--
+		pollMu.Unlock()
+		log.Printf("Could not start polling session: %v", err)
+		http.Error(w, "could not start session", http.StatusInternalServerError)
+		return
+	}
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	token := "ps_" + hex.EncodeToString(buf)
+	s := &pollSession{ws: ws, heard: make(chan struct{}, 1), lastSeen: time.Now()}
+	go s.receive()
+	pollMu.Lock()
+	pollStarting--
+	pollSessions[token] = s
+	pollMu.Unlock()
+	json.NewEncoder(w).Encode(map[string]any{"session": token, "timeout": flagPollTimeout.Seconds()})
+}
+
+// handlePollMove takes any message a WebSocket client could send (moves,
+// hello, hint) and answers with the messages waiting for the session.
+func handlePollMove(w http.ResponseWriter, r *http.Request) {
+	s := lookupSession(r)
+	if s == nil {
+		http.Error(w, "unknown or expired session", http.StatusUnauthorized)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil || !json.Valid(data) {
+		http.Error(w, "want a JSON message", http.StatusBadRequest)
+		return
+	}
+	// Forget what was heard before, only the answer to this message counts.
+	select {
+	case <-s.heard:
+	default:
+	}
+	if err := websocket.Message.Send(s.ws, string(data)); err != nil {
+		http.Error(w, "session closed", http.StatusGone)
+		return
+	}
+	// The game answers every message with at least a state; replies it
+	// sends along come before that.
+	select {
+	case <-s.heard:
+	case <-time.After(pollReplyWait):
+	}
+	json.NewEncoder(w).Encode(map[string]any{"messages": s.take()})
+}
+
+// handleState serves GET /state: the latest GameState. With a session it
+// also returns the session's waiting messages and keeps it alive, as
+// {"state": ..., "messages": [...]}. Without one the caller is a spectator,
+// who gets the state of -spectator-delay ago.
+func handleState(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	mu.Lock()
+	state := lastState
+	mu.Unlock()
+	if state == nil {
+		broadcast()
+		mu.Lock()
+		state = lastState
+		mu.Unlock()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if s := lookupSession(r); s != nil {
+		json.NewEncoder(w).Encode(map[string]any{"state": json.RawMessage(state), "messages": s.take()})
+		return
+	}
+	if *flagSpectatorDelay > 0 {
+		mu.Lock()
+		state = pastStates.latest()
+		mu.Unlock()
+		if state == nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(flagSpectatorDelay.Seconds()+0.5)))
+			http.Error(w, "the spectator view is delayed, it starts shortly", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.Write(state)
+}
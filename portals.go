@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"math/rand"
+)
+
+var flagPortals = flag.Int("portals", 0, "number of teleporter portal pairs placed in generated mazes (uploaded mazes may bring their own)")
+
+// portals are the teleporter pairs of the current maze. Stepping on either
+// end moves the player to the other.
+var portals [][2]point
+
+// pickPortals pairs up n*2 dead ends chosen with rng, leaving out cells
+// that already mean something (start, goals, spawns, checkpoints). Dead
+// ends are used so a portal never sits in a corridor players must walk
+// through anyway.
+func pickPortals(grid [][]int, dist [][]int, rng *rand.Rand, n int, reserved []point) [][2]point {
+	if n <= 0 {
+		return nil
+	}
+	skip := make(map[point]bool, len(reserved))
+	for _, c := range reserved {
+		skip[c] = true
+	}
+	var ends []point
+	for y, row := range dist {
+		for x, v := range row {
+			if c := (point{x, y}); v > 0 && !skip[c] && exits(grid, x, y) == 1 {
+				ends = append(ends, c)
+			}
+		}
+	}
+	rng.Shuffle(len(ends), func(i, j int) { ends[i], ends[j] = ends[j], ends[i] })
+	var pairs [][2]point
+	for i := 0; i+1 < len(ends) && len(pairs) < n; i += 2 {
+		pairs = append(pairs, [2]point{ends[i], ends[i+1]})
+	}
+	return pairs
+}
+
+// portalTwin returns the other end of the portal at c, if c is one.
+func portalTwin(c point) (point, bool) {
+	for _, pr := range portals {
+		switch c {
+		case pr[0]:
+			return pr[1], true
+		case pr[1]:
+			return pr[0], true
+		}
+	}
+	return point{}, false
+}
+
+// usePortal teleports p if it just stepped on a portal and returns where it
+// came from. With collisions on, an occupied far end keeps the portal shut.
+// Caller holds mu.
+func usePortal(p *Player) (point, bool) {
+	from := point{p.X, p.Y}
+	to, ok := portalTwin(from)
+	if !ok || !isOpen(maze, to.X, to.Y) || *flagCollisions && occupied(p, to.X, to.Y) {
+		return point{}, false
+	}
+	p.X, p.Y = to.X, to.Y
+	return from, true
+}
+
+// broadcastPortal tells event-capable clients that a player jumped.
+func broadcastPortal(name string, from, to point) {
+	data, _ := json.Marshal(map[string]any{"type": "portal", "name": name, "from": from, "to": to})
+	publishEvent(data)
+	mu.Lock()
+	defer mu.Unlock()
+	for _, p := range clients {
+		if p.caps.has(capEvents) {
+			p.send(data)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	mrand "math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var flagPracticeRuns = flag.Int("practice-runs", 1000, "solo practice mazes that may be in play at once (0 disables practice)")
+
+// practiceTTL is how long a practice maze may take before it expires.
+const practiceTTL = time.Hour
+
+// PracticeMaze is a solo maze handed out by GET /practice. The browser plays
+// it on its own; the shared game never sees it.
+type PracticeMaze struct {
+	ID      string  `json:"id"` // for submitting the run
+	Seed    int64   `json:"seed"`
+	Width   int     `json:"width"`
+	Height  int     `json:"height"`
+	StartX  int     `json:"startX"`
+	StartY  int     `json:"startY"`
+	GoalX   int     `json:"goalX"`
+	GoalY   int     `json:"goalY"`
+	MudWait int64   `json:"mudDelay,omitempty"` // milliseconds a mud cell holds a player
+	Maze    [][]int `json:"maze"`
+}
+
+// PracticeResult answers a finished practice run.
+type PracticeResult struct {
+	Time         int64 `json:"time"`                   // seconds, from handing out the maze to the goal
+	PersonalBest bool  `json:"personalBest,omitempty"` // faster than the player ever was on this maze
+	PreviousBest int64 `json:"previousBest,omitempty"`
+}
+
+// practiceRun is a maze handed out and not yet finished. Only what it is
+// built from is kept; the grid is built again when the run comes back.
+type practiceRun struct {
+	seed          int64
+	width, height int
+	issued        time.Time
+}
+
+// build makes the run's maze as generateMaze would, without touching the
+// game.
+func (run *practiceRun) build() (grid [][]int, start, goal point) {
+	rng := mrand.New(mrand.NewSource(run.seed))
+	grid, gx, gy := buildMaze(run.width, run.height, rng)
+	start, goal = point{1, 1}, point{gx, gy}
+	addTerrain(grid, rng, *flagIce, *flagMud, start, goal)
+	return grid, start, goal
+}
+
+var (
+	practiceMu   sync.Mutex
+	practiceRuns = make(map[string]*practiceRun)
+)
+
+// handleNewPractice serves GET /practice[?size=small&seed=N]: a fresh maze
+// of the server's size, or of a preset size. The same seed and size give
+// the same maze as a race, so practice counts toward its personal best.
+func handleNewPractice(w http.ResponseWriter, r *http.Request) {
+	if *flagPracticeRuns <= 0 {
+		http.Error(w, "practice is disabled", http.StatusNotFound)
+		return
+	}
+	mu.Lock()
+	width, height := mazeWidth, mazeHeight
+	mu.Unlock()
+	if s := r.URL.Query().Get("size"); s != "" {
+		var err error
+		if width, height, err = presetMazeSize(s); err != nil {
+			writeMazeError(w, err, http.StatusBadRequest)
+			return
+		}
+	}
+	seed := newSeed()
+	if v := r.URL.Query().Get("seed"); v != "" {
+		var err error
+		if seed, err = strconv.ParseInt(v, 10, 64); err != nil || seed == 0 {
+			http.Error(w, "invalid seed", http.StatusBadRequest)
+			return
+		}
+	}
+
+	run := &practiceRun{seed: seed, width: width, height: height, issued: clock()}
+	grid, start, goal := run.build()
+
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	id := hex.EncodeToString(buf)
+	practiceMu.Lock()
+	for k, old := range practiceRuns {
+		if clock().Sub(old.issued) > practiceTTL {
+			delete(practiceRuns, k)
+		}
+	}
+	full := len(practiceRuns) >= *flagPracticeRuns
+	if !full {
+		practiceRuns[id] = run
+	}
+	practiceMu.Unlock()
+	if full {
+		http.Error(w, "too many practice runs, try again later", http.StatusServiceUnavailable)
+		return
+	}
+
+	json.NewEncoder(w).Encode(PracticeMaze{
+		ID: id, Seed: seed, Width: width, Height: height,
+		StartX: start.X, StartY: start.Y, GoalX: goal.X, GoalY: goal.Y,
+		MudWait: flagMudDelay.Milliseconds(), Maze: grid,
+	})
+}
+
+// handleFinishPractice serves POST /practice/{id} with the steps the player
+// took, {"path": [{"x":..,"y":..}, ...], "profile": token}. The path is
+// checked against the maze; the time is the server's own, so a run cannot
+// claim to be faster than it was.
+func handleFinishPractice(w http.ResponseWriter, r *http.Request) {
+	practiceMu.Lock()
+	run := practiceRuns[r.PathValue("id")]
+	practiceMu.Unlock()
+	if run == nil || clock().Sub(run.issued) > practiceTTL {
+		http.Error(w, "unknown or expired practice run", http.StatusNotFound)
+		return
+	}
+	var req struct {
+		Path    []point `json:"path"`
+		Profile string  `json:"profile"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<20)).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if err := run.check(req.Path); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	practiceMu.Lock()
+	delete(practiceRuns, r.PathValue("id"))
+	practiceMu.Unlock()
+
+	// The store takes a zero best for none.
+	res := PracticeResult{Time: max(1, int64(clock().Sub(run.issued).Seconds()))}
+	if profiles != nil && validProfileToken(req.Profile) {
+		id, size := profileID(req.Profile), fmt.Sprintf("%dx%d", run.width, run.height)
+		best, err := profiles.PersonalBest(id, size, run.seed)
+		if err == nil && (best == 0 || res.Time < best) {
+			err = profiles.RecordPersonalBest(id, size, run.seed, res.Time)
+			res.PersonalBest, res.PreviousBest = err == nil, best
+		}
+		if err != nil {
+			log.Printf("Could not save practice best of %s: %v", id, err)
+		}
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// handlePracticeGhost serves GET /practice/{id}/ghost: the world record on
+// the run's maze, streamed as server-sent "ghost" events on the run's own
+// clock, the way the ghost rides along in a race. A "done" event follows
+// when it reaches the goal; without a record the answer is 404.
+func handlePracticeGhost(w http.ResponseWriter, r *http.Request) {
+	practiceMu.Lock()
+	run := practiceRuns[r.PathValue("id")]
+	practiceMu.Unlock()
+	if run == nil || clock().Sub(run.issued) > practiceTTL {
+		http.Error(w, "unknown or expired practice run", http.StatusNotFound)
+		return
+	}
+	g := worldRecordRun(run.seed, run.width, run.height)
+	if g == nil {
+		http.Error(w, "there is no world record to race on this maze", http.StatusNotFound)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	last := point{-1, -1}
+	for {
+		c, ok := g.at(clock().Sub(run.issued).Milliseconds())
+		if !ok {
+			fmt.Fprint(w, "event: done\ndata: {}\n\n")
+			flusher.Flush()
+			return
+		}
+		if c != last {
+			last = c
+			data, _ := json.Marshal(Player{X: c.X, Y: c.Y, Name: g.name, Color: g.color, FinishTime: g.time, Ghost: true})
+			if _, err := fmt.Fprintf(w, "event: ghost\ndata: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+		select {
+		case <-tick.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// check replays path, the cells the player stepped to, from the start. Ice
+// carries a step on as in the game, so the next cell follows where the
+// slide ended.
+func (run *practiceRun) check(path []point) error {
+	grid, at, goal := run.build()
+	for _, c := range path {
+		d := [2]int{c.X - at.X, c.Y - at.Y}
+		if abs(d[0])+abs(d[1]) != 1 {
+			return fmt.Errorf("the path jumps from %d,%d to %d,%d", at.X, at.Y, c.X, c.Y)
+		}
+		next, ok := step(grid, at, d)
+		if !ok {
+			return fmt.Errorf("the path runs into a wall at %d,%d", c.X, c.Y)
+		}
+		at = next
+	}
+	if at != goal {
+		return fmt.Errorf("the path ends at %d,%d, not at the goal", at.X, at.Y)
+	}
+	return nil
+}
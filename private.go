@@ -0,0 +1,38 @@
+package main
+
+import (
+	"crypto/subtle"
+	"flag"
+	"net/http"
+)
+
+var flagPassword = flag.String("password", "", "make the game private: players must give this password to join (empty lets anyone in)")
+
+func privateGame() bool {
+	return *flagPassword != ""
+}
+
+// joinPassword is the password a joining client gives, in the password
+// query parameter (browsers cannot set headers on WebSockets) or the
+// X-Password header.
+func joinPassword(r *http.Request) string {
+	if pw := r.Header.Get("X-Password"); pw != "" {
+		return pw
+	}
+	return r.URL.Query().Get("password")
+}
+
+// mayJoin reports whether the request may join the game, with the password
+// or an invite code. Integrations with a valid API key need neither.
+func mayJoin(r *http.Request) bool {
+	if !privateGame() {
+		return true
+	}
+	if _, ok := authorize(r, scopeReadState); ok {
+		return true
+	}
+	if subtle.ConstantTimeCompare([]byte(joinPassword(r)), []byte(*flagPassword)) == 1 {
+		return true
+	}
+	return useInvite(r)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+var flagProfiles = flag.String("profiles", "", "SQLite database keeping player accounts and lifetime stats across restarts (empty disables)")
+
+// Profile is a player's account with lifetime stats.
+type Profile struct {
+	ID          string           `json:"id"`
+	Name        string           `json:"name"`
+	Color       string           `json:"color"`
+	GamesPlayed int              `json:"gamesPlayed"`
+	Wins        int              `json:"wins"`
+	BestTimes   map[string]int64 `json:"bestTimes"` // seconds, by maze size ("71x41")
+	PlayTime    int64            `json:"playTime"`  // seconds in rounds, all together
+}
+
+// GameRecord is what one round adds to a profile.
+type GameRecord struct {
+	Name     string
+	Color    string
+	Won      bool
+	Size     string // maze size, "WxH"
+	Time     int64  // finish time in seconds, 0 if the player did not finish a race
+	PlayTime int64  // seconds
+}
+
+// ProfileStore keeps profiles. Profile returns nil without an error for
+// unknown ids.
+type ProfileStore interface {
+	Profile(id string) (*Profile, error)
+	RecordGame(id string, g GameRecord) error
+	PersonalBest(id, size string, seed int64) (int64, error) // 0 if none
+	RecordPersonalBest(id, size string, seed, t int64) error
+	Close() error
+}
+
+var profiles ProfileStore // nil when accounts are disabled
+
+func openProfiles(path string) error {
+	if path == "" {
+		return nil
+	}
+	s, err := openSQLiteProfiles(path)
+	if err != nil {
+		return err
+	}
+	profiles = s
+	return nil
+}
+
+// Clients keep a secret profile token; the public id, used in URLs and
+// stats, is derived from it so nobody can play under someone else's id.
+func newProfileToken() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return "pt_" + hex.EncodeToString(buf)
+}
+
+func profileID(token string) string {
+	return hashAPIKey(token)[:16]
+}
+
+func validProfileToken(token string) bool {
+	return len(token) == 35 && token[:3] == "pt_"
+}
+
+// claimProfile ties the connection to the profile behind token, handing out
+// a new token if the client has none yet. It returns the token the client
+// should keep. Caller holds mu.
+func claimProfile(p *Player, token string) string {
+	if profiles == nil || p.verified {
+		return ""
+	}
+	if !validProfileToken(token) {
+		token = newProfileToken()
+	}
+	p.profile = profileID(token)
+	return token
+}
+
+// gameRecord sums up p's round for its profile. ok is false if p has no
+// profile or did not take part. Caller holds mu.
+func gameRecord(p *Player) (id string, g GameRecord, ok bool) {
+	if p.profile == "" || p.moved == 0 {
+		return "", g, false
+	}
+	g = GameRecord{
+		Name:     p.Name,
+		Color:    p.Color,
+		Won:      p.Finished && p.FinishRank == 1,
+		Size:     mazeSize(),
+		PlayTime: int64(time.Since(p.joinedAt).Seconds()),
+	}
+	if p.Finished {
+		g.PlayTime = p.FinishTime
+		if !coinMode() {
+			g.Time = p.FinishTime
+		}
+	}
+	return p.profile, g, true
+}
+
+func mazeSize() string {
+	return fmt.Sprintf("%dx%d", mazeWidth, mazeHeight)
+}
+
+// loadPersonalBest looks up p's best time on the current maze. Uploaded
+// mazes have no seed to tell them apart and get none. Caller holds mu.
+func loadPersonalBest(p *Player) {
+	p.best = 0
+	if profiles == nil || p.profile == "" || mazeSeed == 0 {
+		return
+	}
+	t, err := profiles.PersonalBest(p.profile, mazeSize(), mazeSeed)
+	if err != nil {
+		log.Printf("Could not load personal best of %s: %v", p.profile, err)
+		return
+	}
+	p.best = t
+}
+
+// finishEvent is broadcast when a player reaches the goal.
+type finishEvent struct {
+	Type         string `json:"type"` // always "finish"
+	Name         string `json:"name"`
+	Rank         int    `json:"rank"`
+	Time         int64  `json:"time"`                   // seconds
+	PersonalBest bool   `json:"personalBest,omitempty"` // faster than the player ever was on this maze
+	PreviousBest int64  `json:"previousBest,omitempty"`
+}
+
+// newFinishEvent describes p's finish and saves it if it is a personal
+// best. Caller holds mu.
+func newFinishEvent(p *Player) finishEvent {
+	ev := finishEvent{Type: "finish", Name: p.Name, Rank: p.FinishRank, Time: p.FinishTime, PreviousBest: p.best}
+	if profiles == nil || p.profile == "" || mazeSeed == 0 || (p.best > 0 && p.FinishTime >= p.best) {
+		return ev
+	}
+	if err := profiles.RecordPersonalBest(p.profile, mazeSize(), mazeSeed, p.FinishTime); err != nil {
+		log.Printf("Could not save personal best of %s: %v", p.profile, err)
+		return ev
+	}
+	ev.PersonalBest = true
+	p.best = p.FinishTime
+	return ev
+}
+
+func broadcastFinish(ev finishEvent) {
+	data, _ := json.Marshal(ev)
+	publishEvent(data)
+	mu.Lock()
+	defer mu.Unlock()
+	for _, p := range clients {
+		if p.caps.has(capEvents) {
+			p.send(data)
+		}
+	}
+}
+
+// profileUpdate is a record waiting to be written once mu is released.
+type profileUpdate struct {
+	id string
+	g  GameRecord
+}
+
+func saveProfiles(updates []profileUpdate) {
+	for _, u := range updates {
+		if err := profiles.RecordGame(u.id, u.g); err != nil {
+			log.Printf("Could not save profile %s: %v", u.id, err)
+		}
+	}
+}
+
+// handlePlayerStats serves /players/{id}/stats.
+func handlePlayerStats(w http.ResponseWriter, r *http.Request) {
+	if profiles == nil {
+		http.Error(w, "player accounts are disabled", http.StatusNotFound)
+		return
+	}
+	prof, err := profiles.Profile(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Profile lookup failed: %v", err)
+		http.Error(w, "could not load profile", http.StatusInternalServerError)
+		return
+	}
+	if prof == nil {
+		http.Error(w, "unknown player", http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(prof)
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// protocolVersion is bumped whenever the meaning of an existing message
+// changes. Adding a capability does not need a bump. Server and client agree
+// on the lower of their versions in the hello.
+//
+// Version 2 puts every message in an envelope that starts with the version,
+// {"v":2,"type":...}, states included ("type":"state"), so a client can tell
+// a message it does not know from a state and skip it.
+const protocolVersion = 2
+
+// minProtocol is the oldest version still served without complaint. Older
+// clients, including ones that never say hello (version 0), get a
+// deprecation warning.
+const minProtocol = 1
+
+var flagProtocolSunset = flag.String("protocol-sunset", "", "date (YYYY-MM-DD) after which deprecated protocol versions stop being served; sent with deprecation warnings")
+
+// Capabilities a client can ask for in its hello. Clients that never say
+// hello get the original protocol: untyped state frames only.
+const (
+	capEvents  = "events"   // typed broadcast events such as marker updates
+	capPrivate = "private"  // frames addressed to one player (position corrections)
+	capMazeRLE = "maze-rle" // changed cells as runs with the maze revision, instead of maze-diff
+	capPing    = "ping"     // pings to answer with a pong; silent clients are disconnected
+)
+
+// serverCaps lists everything this server can do. Features still being
+// worked on (compression, binary frames, delta updates) get added here once
+// they exist, and older clients simply never ask for them.
+var serverCaps = map[string]bool{
+	capEvents:  true,
+	capPrivate: true,
+	capMazeRLE: true,
+	capPing:    true,
+}
+
+// capSet holds the capabilities agreed with one connection.
+type capSet map[string]bool
+
+func (c capSet) has(name string) bool { return c[name] }
+
+func (c capSet) list() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// warningMessage tells a client about something it should fix but that does
+// not break it yet.
+type warningMessage struct {
+	Type    string `json:"type"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Current int    `json:"current"`          // protocol version the server speaks
+	Sunset  string `json:"sunset,omitempty"` // when the deprecated behavior goes away
+}
+
+var (
+	protoMu   sync.Mutex
+	protoSeen = map[int]int{} // connections per protocol version since startup
+)
+
+// countProtocol records which protocol a connection speaks and reports
+// whether it was not known before. Legacy clients are counted on their first
+// message, since only then is it clear that no hello is coming.
+func countProtocol(p *Player, version int) bool {
+	if p.protoCounted {
+		return false
+	}
+	p.protoCounted = true
+	p.protocol = version
+	protoMu.Lock()
+	protoSeen[version]++
+	protoMu.Unlock()
+	return true
+}
+
+// warnProtocol sends the warning for a client speaking version, if any.
+func warnProtocol(p *Player, addr string, version int) {
+	w := protocolWarning(version)
+	if w == nil {
+		return
+	}
+	log.Printf("Client %s speaks protocol %d: %s", addr, version, w.Code)
+	data, _ := json.Marshal(w)
+	p.send(data)
+}
+
+// protocolWarning returns the warning for a client speaking version, if any.
+func protocolWarning(version int) *warningMessage {
+	w := &warningMessage{Type: "warning", Current: protocolVersion, Sunset: *flagProtocolSunset}
+	switch {
+	case version < minProtocol:
+		w.Code = "protocol_deprecated"
+		w.Message = fmt.Sprintf("protocol version %d is deprecated, please update to version %d", version, protocolVersion)
+		if w.Sunset != "" {
+			w.Message += " before " + w.Sunset
+		}
+	case version > protocolVersion:
+		w.Code = "protocol_newer"
+		w.Message = fmt.Sprintf("server speaks protocol version %d, newer features are unavailable", protocolVersion)
+		w.Sunset = ""
+	default:
+		return nil
+	}
+	return w
+}
+
+// helloMessage is the optional first message a client sends.
+type helloMessage struct {
+	Type     string   `json:"type"`
+	Protocol int      `json:"protocol"`
+	Caps     []string `json:"caps"`
+	Profile  string   `json:"profile,omitempty"` // token of the player's account
+}
+
+// welcomeMessage answers a hello with the capabilities the server will use
+// on this connection; anything the client asked for and is not listed here
+// is unsupported.
+type welcomeMessage struct {
+	Type     string   `json:"type"`
+	Protocol int      `json:"protocol"`
+	Caps     []string `json:"caps"`
+	Profile  string   `json:"profile,omitempty"`  // token to keep and send in the next hello
+	PlayerID string   `json:"playerId,omitempty"` // public id for /players/{id}/stats
+	Server   string   `json:"server"`             // server version, as in /version
+	Versions []int    `json:"versions"`           // protocol versions this server speaks
+}
+
+// agreedProtocol is the version spoken with a client that asked for
+// requested.
+func agreedProtocol(requested int) int {
+	return max(minProtocol, min(requested, protocolVersion))
+}
+
+// envelope wraps a typed message for protocol 2.
+func envelope(data []byte) []byte {
+	return append([]byte(`{"v":2,`), data[1:]...)
+}
+
+// stateEnvelope wraps a state for protocol 2.
+func stateEnvelope(data []byte) []byte {
+	return append([]byte(`{"v":2,"type":"state",`), data[1:]...)
+}
+
+// negotiate keeps the requested capabilities the server supports.
+func negotiate(requested []string) capSet {
+	caps := capSet{}
+	for _, name := range requested {
+		if serverCaps[name] {
+			caps[name] = true
+		}
+	}
+	return caps
+}
+
+// handleHello records what the client supports and replies with a welcome.
+func handleHello(ws *websocket.Conn, p *Player, hello helloMessage) {
+	if profiles != nil && !p.verified && validProfileToken(hello.Profile) {
+		if b := activeBan("", profileID(hello.Profile)); b != nil {
+			log.Printf("Refusing banned player %s from %s", profileID(hello.Profile), ws.Request().RemoteAddr)
+			sendBanned(ws, p, b)
+			return
+		}
+	}
+	caps := negotiate(hello.Caps)
+	mu.Lock()
+	p.caps = caps
+	p.dirty = true
+	countProtocol(p, hello.Protocol)
+	token := claimProfile(p, hello.Profile)
+	profile := p.profile
+	loadPersonalBest(p)
+	agreed := agreedProtocol(hello.Protocol)
+	p.out.setProtocol(agreed)
+	var versions []int
+	for v := minProtocol; v <= protocolVersion; v++ {
+		versions = append(versions, v)
+	}
+	data, _ := json.Marshal(welcomeMessage{Type: "welcome", Protocol: agreed, Caps: caps.list(), Profile: token, PlayerID: p.profile, Server: versionInfo().Version, Versions: versions})
+	p.send(data)
+	// Spawn now: before the hello the player could not be told where.
+	if !p.Finished && p.moved == 0 {
+		sendJoin(p, assignSpawn(p))
+	}
+	mu.Unlock()
+	rememberInvited(ws.Request(), profile)
+	warnProtocol(p, ws.Request().RemoteAddr, hello.Protocol)
+}
+
+// handleProtocol reports which protocol versions clients use, so we know
+// when a compatibility path has no users left.
+func handleProtocol(w http.ResponseWriter, r *http.Request) {
+	active := map[string]int{}
+	mu.Lock()
+	for _, p := range clients {
+		if p.protoCounted {
+			active[strconv.Itoa(p.protocol)]++
+		}
+	}
+	mu.Unlock()
+	seen := map[string]int{}
+	protoMu.Lock()
+	for v, n := range protoSeen {
+		seen[strconv.Itoa(v)] = n
+	}
+	protoMu.Unlock()
+	json.NewEncoder(w).Encode(map[string]any{
+		"current": protocolVersion,
+		"minimum": minProtocol,
+		"sunset":  *flagProtocolSunset,
+		"active":  active,
+		"seen":    seen,
+	})
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+var flagTrustedProxies = flag.String("trusted-proxies", "", "comma-separated IPs or CIDRs of reverse proxies whose X-Forwarded-For / X-Real-IP headers are believed")
+
+var trustedProxies []netip.Prefix
+
+// parsePrefixes reads a comma-separated list of IPs and CIDRs, as in
+// -trusted-proxies. Single addresses become /32 (or /128) prefixes.
+func parsePrefixes(list string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+func isTrustedProxy(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range trustedProxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// realClientIP works out who is behind a request that came through trusted
+// proxies. X-Forwarded-For is read from the right, since only the hops we
+// trust appended honestly; the first untrusted address is the client.
+func realClientIP(r *http.Request) (netip.Addr, bool) {
+	// Only a proxy on this machine can reach a Unix socket.
+	if unixSocket() == "" {
+		peer, err := netip.ParseAddrPort(r.RemoteAddr)
+		if err != nil || !isTrustedProxy(peer.Addr()) {
+			return netip.Addr{}, false
+		}
+	}
+	var hops []string
+	for _, h := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		if i == 0 || !isTrustedProxy(addr) {
+			return addr.Unmap(), true
+		}
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap(), true
+	}
+	return netip.Addr{}, false
+}
+
+// behindProxies replaces RemoteAddr with the client's address for requests
+// relayed by a trusted proxy, so logging and per-client limits see the
+// player instead of the proxy.
+func behindProxies(h http.Handler) http.Handler {
+	if len(trustedProxies) == 0 && unixSocket() == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, ok := realClientIP(r); ok {
+			r.RemoteAddr = net.JoinHostPort(addr.String(), "0")
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func loadTrustedProxies() {
+	var err error
+	if trustedProxies, err = parsePrefixes(*flagTrustedProxies); err != nil {
+		log.Fatalf("Invalid -trusted-proxies: %v", err)
+	}
+}
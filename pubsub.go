@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+var flagInstance = flag.String("instance", "", "name of this server in the cross-instance event feed (defaults to host name and process id)")
+
+// busMessage is what travels between instances and to /events listeners:
+// one of the typed messages sent to players, tagged with its origin.
+type busMessage struct {
+	Instance string          `json:"instance"`
+	Event    json.RawMessage `json:"event"`
+}
+
+var (
+	outbox = make(chan []byte, 1024) // events waiting to be published
+
+	listenersMu sync.Mutex
+	listeners   = make(map[chan []byte]bool)
+)
+
+func instanceName() string {
+	if *flagInstance != "" {
+		return *flagInstance
+	}
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// publishEvent hands a marshalled player message to the event bus. It
+// never blocks: when the bus falls behind, events are dropped rather than
+// stalling the game.
+func publishEvent(data []byte) {
+	select {
+	case outbox <- data:
+	default:
+	}
+}
+
+// runEventBus publishes this instance's events. With -redis they go
+// through Redis pub/sub, so /events on every instance sees the events of
+// all of them; otherwise they go straight to the local listeners.
+func runEventBus() {
+	name := instanceName()
+	channel := redisKey("events")
+	if redisClient != nil {
+		go subscribeEvents(channel)
+	}
+	for data := range outbox {
+		msg, _ := json.Marshal(busMessage{Instance: name, Event: data})
+		if redisClient == nil {
+			deliverEvent(msg)
+			continue
+		}
+		if err := redisClient.Publish(context.Background(), channel, msg).Err(); err != nil {
+			log.Printf("Event publish failed: %v", err)
+		}
+	}
+}
+
+func subscribeEvents(channel string) {
+	sub := redisClient.Subscribe(context.Background(), channel)
+	for m := range sub.Channel() {
+		deliverEvent([]byte(m.Payload))
+	}
+}
+
+// listen registers a listener for bus messages; stop unregisters it. The
+// listeners are spectators, so with -spectator-delay the messages come
+// that much later.
+func listen() (ch chan []byte, stop func()) {
+	in := make(chan []byte, 256)
+	listenersMu.Lock()
+	listeners[in] = true
+	listenersMu.Unlock()
+	done := make(chan struct{})
+	stop = func() {
+		listenersMu.Lock()
+		delete(listeners, in)
+		listenersMu.Unlock()
+		close(done)
+	}
+	if *flagSpectatorDelay <= 0 {
+		return in, stop
+	}
+	return delayed(in, *flagSpectatorDelay, done), stop
+}
+
+// localEvent unwraps a bus message of this instance. kind is the type of a
+// typed message and empty for game states.
+func localEvent(msg []byte) (kind string, event []byte, ok bool) {
+	var bm busMessage
+	if json.Unmarshal(msg, &bm) != nil || bm.Instance != instanceName() {
+		return "", nil, false
+	}
+	var typed struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(bm.Event, &typed)
+	return typed.Type, bm.Event, true
+}
+
+func deliverEvent(msg []byte) {
+	listenersMu.Lock()
+	defer listenersMu.Unlock()
+	for ch := range listeners {
+		select {
+		case ch <- msg:
+		default: // a slow listener misses events instead of holding up the rest
+		}
+	}
+}
+
+// handleEvents serves the /events WebSocket: every game event of every
+// instance, for spectators, scoreboards and bots. Plain GET requests get
+// this instance's events as server-sent events instead.
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	if credential(r) != "" {
+		if _, ok := authorize(r, scopeReadState); !ok {
+			http.Error(w, "invalid API key", http.StatusUnauthorized)
+			return
+		}
+	}
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		serveEventStream(w, r)
+		return
+	}
+	srv := wsServer(func(ws *websocket.Conn) {
+		defer ws.Close()
+		ch, stop := listen()
+		defer stop()
+		// The feed is one-way; reading only notices the listener leaving.
+		closed := make(chan struct{})
+		go func() {
+			var discard []byte
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+			close(closed)
+		}()
+		for {
+			select {
+			case msg := <-ch:
+				if websocket.Message.Send(ws, string(msg)) != nil {
+					return
+				}
+			case <-closed:
+				return
+			}
+		}
+	})
+	srv.ServeHTTP(w, r)
+}
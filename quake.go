@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"maps"
+	"math/rand"
+	"slices"
+	"time"
+)
+
+var (
+	flagQuakeEvery = flag.Duration("quake-every", 0, "shift the maze mid-round this often, opening and closing a few walls (0 disables)")
+	flagQuakeCells = flag.Int("quake-cells", 4, "walls opened or closed per earthquake")
+)
+
+// cellChange is one cell of a maze-diff message.
+type cellChange struct {
+	X    int `json:"x"`
+	Y    int `json:"y"`
+	Cell int `json:"cell"`
+}
+
+// runQuakes shakes the maze every -quake-every while a round is running.
+func runQuakes() {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	for {
+		time.Sleep(*flagQuakeEvery)
+		mu.Lock()
+		var changes []cellChange
+		if !gameOver && len(clients) > 0 {
+			changes = quake(rng, *flagQuakeCells)
+		}
+		mu.Unlock()
+		if len(changes) > 0 {
+			log.Printf("EARTHQUAKE: %d cell(s) shifted", len(changes))
+			broadcastMazeDiff(changes)
+			broadcast()
+		}
+	}
+}
+
+// quake opens or closes up to n walls. A change is only kept if no cell
+// loses its way to the goal, so the round stays winnable for everyone. The
+// grid is copied, never edited in place, as handlers may still be reading
+// the old one. Caller holds mu.
+func quake(rng *rand.Rand, n int) []cellChange {
+	src := maze
+	grid := make([][]int, len(src))
+	for y := range src {
+		grid[y] = append([]int(nil), src[y]...)
+	}
+	reachable := countReachable(goalDist)
+	var changes []cellChange
+	for try := 0; try < n*8 && len(changes) < n; try++ {
+		x, y := 1+rng.Intn(len(grid[0])-2), 1+rng.Intn(len(grid)-2)
+		old := grid[y][x]
+		switch {
+		case old == cellWall && straightCorridor(grid, x, y):
+			grid[y][x] = cellFloor
+		case old == cellFloor && straightCorridor(grid, x, y) && quakeMayClose(x, y):
+			grid[y][x] = cellWall
+		default:
+			continue
+		}
+		dist := bfsDistancesTo(grid, goalX, goalY)
+		after := countReachable(dist)
+		if old == cellFloor {
+			after++ // the closed cell itself does not count as lost
+		}
+		if after < reachable {
+			grid[y][x] = old
+			continue
+		}
+		reachable = countReachable(dist)
+		changes = append(changes, cellChange{x, y, grid[y][x]})
+	}
+	// A new round may have brought a new maze meanwhile.
+	if len(maze) == 0 || &maze[0][0] != &src[0][0] {
+		return nil
+	}
+	if len(changes) > 0 {
+		maze = grid
+		goalDist = bfsDistancesTo(maze, goalX, goalY)
+	}
+	return changes
+}
+
+// quakeMayClose keeps cells that mean something, and players, from being
+// walled in. Caller holds mu.
+func quakeMayClose(x, y int) bool {
+	c := point{x, y}
+	special := []point{{startX, startY}, {goalX, goalY}}
+	special = append(special, spawnPoints...)
+	special = append(special, checkpoints...)
+	special = append(special, goals...)
+	for _, pr := range portals {
+		special = append(special, pr[0], pr[1])
+	}
+	for _, s := range special {
+		if s == c {
+			return false
+		}
+	}
+	for _, p := range clients {
+		if p.X == x && p.Y == y {
+			return false
+		}
+	}
+	return true
+}
+
+func countReachable(dist [][]int) int {
+	n := 0
+	for _, row := range dist {
+		for _, v := range row {
+			if v >= 0 {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// broadcastMazeDiff tells event-capable clients which cells changed, as
+// runs to those that asked for them.
+func broadcastMazeDiff(changes []cellChange) {
+	rev := mazeChanged()
+	replayCells(changes)
+	data, _ := json.Marshal(map[string]any{"type": "maze-diff", "changes": changes})
+	publishEvent(data)
+	mu.Lock()
+	defer mu.Unlock()
+	rle, _ := json.Marshal(map[string]any{"type": "maze-rle", "revision": rev, "runs": cellRuns(changes, mazeWidth)})
+	for _, p := range clients {
+		switch {
+		case p.caps.has(capMazeRLE):
+			p.send(rle)
+		case p.caps.has(capEvents):
+			p.send(data)
+		}
+	}
+}
+
+// cellRuns run-length encodes changes as [first, count, cell] triples. Cells
+// are numbered row by row, so a run may go on into the next row.
+func cellRuns(changes []cellChange, width int) [][3]int {
+	// A cell may change twice; the last change is the one that holds.
+	cells := make(map[int]int, len(changes))
+	for _, c := range changes {
+		cells[c.Y*width+c.X] = c.Cell
+	}
+	var runs [][3]int
+	for _, i := range slices.Sorted(maps.Keys(cells)) {
+		if n := len(runs); n > 0 && runs[n-1][0]+runs[n-1][1] == i && runs[n-1][2] == cells[i] {
+			runs[n-1][1]++
+			continue
+		}
+		runs = append(runs, [3]int{i, 1, cells[i]})
+	}
+	return runs
+}
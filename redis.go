@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	flagRedis       = flag.String("redis", "", "Redis URL (redis://host:6379/0) for state shared between server instances: leaderboards and daily boards (empty keeps them local)")
+	flagRedisPrefix = flag.String("redis-prefix", "mazerunner:", "prefix of every Redis key, so several deployments can share one Redis")
+)
+
+var redisClient *redis.Client // nil unless -redis is set
+
+// openRedis connects to -redis and moves the shared stores there.
+func openRedis(url string) error {
+	if url == "" {
+		return nil
+	}
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return err
+	}
+	c := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.Ping(ctx).Err(); err != nil {
+		c.Close()
+		return err
+	}
+	redisClient = c
+	dailyStore = redisDaily{c}
+	return nil
+}
+
+func redisKey(parts ...string) string {
+	return *flagRedisPrefix + strings.Join(parts, ":")
+}
+
+// redisLeaderboard is the LeaderboardStore kept in a Redis sorted set
+// scored by finish time. Members are the finish timestamp followed by the
+// entry as JSON, so equal times sort oldest first.
+type redisLeaderboard struct {
+	c *redis.Client
+}
+
+func (s redisLeaderboard) RecordFinish(e LeaderboardEntry) error {
+	ctx := context.Background()
+	data, _ := json.Marshal(e)
+	member := fmt.Sprintf("%013d|%s", e.FinishedAt.UnixMilli(), data)
+	pipe := s.c.TxPipeline()
+	pipe.ZAdd(ctx, redisKey("finishes"), redis.Z{Score: float64(e.Time), Member: member})
+	pipe.SetNX(ctx, redisKey("finishes", "first"), e.FinishedAt.UnixMilli(), 0)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Top walks the set fastest first and filters as it goes.
+func (s redisLeaderboard) Top(q LeaderboardQuery) ([]LeaderboardEntry, error) {
+	const page = 500
+	ctx := context.Background()
+	list := []LeaderboardEntry{}
+	for start := int64(0); len(list) < q.Limit; start += page {
+		members, err := s.c.ZRange(ctx, redisKey("finishes"), start, start+page-1).Result()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			_, data, _ := strings.Cut(m, "|")
+			var e LeaderboardEntry
+			if json.Unmarshal([]byte(data), &e) != nil || !q.matches(e) {
+				continue
+			}
+			if list = append(list, e); len(list) == q.Limit {
+				break
+			}
+		}
+		if len(members) < page {
+			break
+		}
+	}
+	return list, nil
+}
+
+func (s redisLeaderboard) First() (time.Time, error) {
+	ms, err := s.c.Get(context.Background(), redisKey("finishes", "first")).Int64()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(ms).UTC(), nil
+}
+
+func (s redisLeaderboard) Close() error {
+	return nil // the client is shared
+}
+
+// redisDaily keeps each daily board as a hash from name to entry that
+// expires dailyKeepDays after its day.
+type redisDaily struct {
+	c *redis.Client
+}
+
+// keepBest stores the entry unless the name already has a faster time.
+var keepBest = redis.NewScript(`
+local old = redis.call('HGET', KEYS[1], ARGV[1])
+if old and cjson.decode(old).time <= tonumber(ARGV[3]) then return 0 end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+redis.call('EXPIRE', KEYS[1], ARGV[4])
+return 1`)
+
+func (s redisDaily) RecordDaily(date string, e DailyEntry) error {
+	data, _ := json.Marshal(e)
+	ttl := int64((dailyKeepDays + 1) * 24 * time.Hour / time.Second)
+	return keepBest.Run(context.Background(), s.c, []string{redisKey("daily", date)}, e.Name, data, e.Time, ttl).Err()
+}
+
+func (s redisDaily) Daily(date string) ([]DailyEntry, error) {
+	values, err := s.c.HVals(context.Background(), redisKey("daily", date)).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]DailyEntry, 0, len(values))
+	for _, v := range values {
+		var e DailyEntry
+		if json.Unmarshal([]byte(v), &e) == nil {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	flagRegistry     = flag.String("registry", "", "URL of a server registry to list this server on, e.g. https://registry.example.org (needs -public-url, empty keeps it unlisted)")
+	flagRegistryName = flag.String("registry-name", "", "name the server is listed under (defaults to \"Maze Runner on <host name>\")")
+	flagRegistryHost = flag.Bool("registry-host", false, "act as a server registry: accept heartbeats at /registry/heartbeat and list servers at /registry/servers")
+)
+
+const (
+	heartbeatEvery = 30 * time.Second
+	// listingTTL drops servers that missed a few heartbeats.
+	listingTTL = 3 * heartbeatEvery
+	// maxListings caps the registry, heartbeats need no credentials.
+	maxListings = 500
+)
+
+// serverListing is one public server as the registry lists it.
+type serverListing struct {
+	Name     string    `json:"name"`
+	URL      string    `json:"url"` // the website players open
+	Players  int       `json:"players"`
+	Width    int       `json:"width"`
+	Height   int       `json:"height"`
+	Protocol int       `json:"protocol"`
+	Private  bool      `json:"private,omitempty"` // joining needs a password
+	Seen     time.Time `json:"seen"`
+}
+
+var (
+	listingsMu sync.Mutex
+	listings   = make(map[string]serverListing) // by URL
+)
+
+// runHeartbeat reports this server to -registry until the process exits or
+// hands its listeners to an upgrade, which reports itself.
+func runHeartbeat() {
+	if *flagRegistry == "" {
+		return
+	}
+	if *flagPublicURL == "" {
+		log.Printf("Not listing the server on %s: set -public-url to the address players should open", *flagRegistry)
+		return
+	}
+	name := *flagRegistryName
+	if name == "" {
+		host, _ := os.Hostname()
+		name = "Maze Runner on " + host
+	}
+	endpoint := strings.TrimRight(*flagRegistry, "/") + "/registry/heartbeat"
+	failing := false
+	for ; !isDraining(); time.Sleep(heartbeatEvery) {
+		mu.Lock()
+		l := serverListing{Name: name, URL: *flagPublicURL, Players: len(clients), Width: mazeWidth, Height: mazeHeight, Protocol: protocolVersion, Private: privateGame()}
+		mu.Unlock()
+		body, _ := json.Marshal(l)
+		err := postHeartbeat(endpoint, body)
+		// Only log changes, the registry may be down for a while.
+		if err != nil && !failing {
+			log.Printf("Registry heartbeat failed: %v", err)
+		} else if err == nil && failing {
+			log.Printf("Registry heartbeat to %s works again", *flagRegistry)
+		}
+		failing = err != nil
+	}
+}
+
+func postHeartbeat(endpoint string, body []byte) error {
+	resp, err := hookClient.Post(endpoint, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+// sentFromHost reports whether the host in a listing's URL resolves to the
+// address the heartbeat came from, so nobody can list someone else's site.
+func sentFromHost(r *http.Request, host string) bool {
+	from, err := netip.ParseAddr(clientIP(r))
+	if err != nil {
+		return false
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return false
+	}
+	for _, a := range addrs {
+		if a.Unmap() == from {
+			return true
+		}
+	}
+	return false
+}
+
+// webURL parses s and reports whether it is an absolute http or https URL,
+// the only kind a listing may send players to.
+func webURL(s string) (*url.URL, bool) {
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, false
+	}
+	return u, true
+}
+
+// handleHeartbeat takes a server's heartbeat when this server is a
+// registry. Only the server a listing points to may send it.
+func handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	if !*flagRegistryHost {
+		http.Error(w, "this server is not a registry", http.StatusNotFound)
+		return
+	}
+	var l serverListing
+	if err := json.NewDecoder(io.LimitReader(r.Body, 4<<10)).Decode(&l); err != nil || l.Name == "" {
+		http.Error(w, `want {"name": "...", "url": "https://...", "players": N, ...}`, http.StatusBadRequest)
+		return
+	}
+	u, ok := webURL(l.URL)
+	if !ok {
+		http.Error(w, "url must be an absolute http or https URL", http.StatusBadRequest)
+		return
+	}
+	if !sentFromHost(r, u.Hostname()) {
+		http.Error(w, "the heartbeat must come from the host the url names", http.StatusForbidden)
+		return
+	}
+	l.Name = truncate(l.Name, 60)
+	l.Seen = time.Now().UTC()
+	listingsMu.Lock()
+	_, known := listings[l.URL]
+	if !known && len(listings) >= maxListings {
+		expireListings()
+		if len(listings) >= maxListings {
+			listingsMu.Unlock()
+			http.Error(w, "the registry is full", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	if !known {
+		log.Printf("Registry: %q at %s is now listed", l.Name, l.URL)
+	}
+	listings[l.URL] = l
+	listingsMu.Unlock()
+	json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+}
+
+// handleRegistryServers lists the servers with a recent heartbeat, the
+// busiest first.
+func handleRegistryServers(w http.ResponseWriter, r *http.Request) {
+	if !*flagRegistryHost {
+		http.Error(w, "this server is not a registry", http.StatusNotFound)
+		return
+	}
+	listingsMu.Lock()
+	expireListings()
+	list := []serverListing{}
+	for _, l := range listings {
+		list = append(list, l)
+	}
+	listingsMu.Unlock()
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Players != list[j].Players {
+			return list[i].Players > list[j].Players
+		}
+		return list[i].Name < list[j].Name
+	})
+	json.NewEncoder(w).Encode(list)
+}
+
+// expireListings drops servers that stopped sending heartbeats. Caller
+// holds listingsMu.
+func expireListings() {
+	for key, l := range listings {
+		if time.Since(l.Seen) > listingTTL {
+			delete(listings, key)
+		}
+	}
+}
+
+// handleServers serves /servers for the menu's server browser: the list
+// from -registry, or this server's own list if it is the registry. Menus
+// open the listed URLs, so listings that are not web pages are dropped
+// whatever the registry sends.
+func handleServers(w http.ResponseWriter, r *http.Request) {
+	if *flagRegistry == "" {
+		handleRegistryServers(w, r)
+		return
+	}
+	resp, err := hookClient.Get(strings.TrimRight(*flagRegistry, "/") + "/registry/servers")
+	if err != nil {
+		log.Printf("Server list from registry failed: %v", err)
+		http.Error(w, "registry unreachable", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		http.Error(w, "registry answered "+resp.Status, http.StatusBadGateway)
+		return
+	}
+	var upstream []serverListing
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&upstream); err != nil {
+		log.Printf("Server list from registry unreadable: %v", err)
+		http.Error(w, "registry sent an invalid list", http.StatusBadGateway)
+		return
+	}
+	list := make([]serverListing, 0, len(upstream))
+	for _, l := range upstream {
+		if _, ok := webURL(l.URL); ok {
+			list = append(list, l)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(list)
+}
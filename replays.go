@@ -0,0 +1,224 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	flagReplays      = flag.String("replays", "replays", "directory where every round is recorded as a replay file (empty disables)")
+	flagReplayKeep   = flag.Int("replay-keep", 200, "most replay files kept; the oldest are deleted first (0 keeps all)")
+	flagReplayMaxAge = flag.Duration("replay-max-age", 30*24*time.Hour, "delete replay files older than this (0 keeps them forever)")
+)
+
+const replaySuffix = ".replay.gz"
+
+// replayEvent is one line of a replay file. T is milliseconds since the
+// round started and P a player number that stays the same for the whole
+// round; 0 is the minotaur.
+type replayEvent struct {
+	T int64  `json:"t"`
+	E string `json:"e"` // start, join, move, finish, leave, cells or end
+	P int    `json:"p,omitempty"`
+
+	// move and join
+	X int `json:"x,omitempty"`
+	Y int `json:"y,omitempty"`
+
+	// join
+	Name  string `json:"name,omitempty"`
+	Color string `json:"color,omitempty"`
+	Team  string `json:"team,omitempty"`
+
+	// finish
+	Rank int   `json:"rank,omitempty"`
+	Time int64 `json:"time,omitempty"` // seconds
+
+	// cells
+	Changes []cellChange `json:"changes,omitempty"`
+
+	// start
+	Started  int64          `json:"started,omitempty"` // unix milliseconds
+	Seed     int64          `json:"seed,omitempty"`
+	Maze     [][]int        `json:"maze,omitempty"`
+	Goal     *point         `json:"goal,omitempty"`
+	Spawns   []point        `json:"spawns,omitempty"`
+	Settings *MatchSettings `json:"settings,omitempty"`
+}
+
+// replayRecorder writes the replay of the running round.
+type replayRecorder struct {
+	f     *os.File
+	gz    *gzip.Writer
+	enc   *json.Encoder
+	start time.Time
+	ids   map[*Player]int
+	last  map[int]Player // what the replay last said about each player
+	next  int
+}
+
+var (
+	replayMu sync.Mutex
+	replay   *replayRecorder
+)
+
+// replayRoundStart closes the previous replay, if any, and starts recording
+// the new round.
+func replayRoundStart() {
+	replayMu.Lock()
+	defer replayMu.Unlock()
+	replay.close()
+	replay = nil
+	if *flagReplays == "" {
+		return
+	}
+	if err := os.MkdirAll(*flagReplays, 0755); err != nil {
+		log.Printf("Replay recording disabled: %v", err)
+		return
+	}
+	pruneReplays()
+	now := time.Now()
+	name := fmt.Sprintf("%s-%d%s", now.UTC().Format("20060102-150405"), mazeSeed, replaySuffix)
+	f, err := os.Create(filepath.Join(*flagReplays, name))
+	if err != nil {
+		log.Printf("Could not record replay: %v", err)
+		return
+	}
+	gz := gzip.NewWriter(f)
+	replay = &replayRecorder{f: f, gz: gz, enc: json.NewEncoder(gz), start: now, ids: map[*Player]int{}, last: map[int]Player{}, next: 1}
+	settings := currentSettings()
+	replay.write(replayEvent{E: "start", Started: now.UnixMilli(), Seed: mazeSeed, Maze: maze, Goal: &point{goalX, goalY}, Spawns: spawnPoints, Settings: &settings})
+}
+
+// replayTick records whatever changed since the last call: players who
+// joined (or renamed themselves), moved, finished or left. It runs with
+// every broadcast, so every position a client was shown is in the replay.
+// Caller holds mu.
+func replayTick() {
+	replayMu.Lock()
+	defer replayMu.Unlock()
+	r := replay
+	if r == nil {
+		return
+	}
+	seen := make(map[int]bool, len(clients))
+	for _, p := range clients {
+		id, ok := r.ids[p]
+		if !ok {
+			id = r.next
+			r.next++
+			r.ids[p] = id
+		}
+		seen[id] = true
+		was, known := r.last[id]
+		switch {
+		case !known || was.Name != p.Name || was.Color != p.Color || was.Team != p.Team:
+			r.write(replayEvent{E: "join", P: id, X: p.X, Y: p.Y, Name: p.Name, Color: p.Color, Team: p.Team})
+		case was.X != p.X || was.Y != p.Y:
+			r.write(replayEvent{E: "move", P: id, X: p.X, Y: p.Y})
+		}
+		if p.Finished && !was.Finished {
+			r.write(replayEvent{E: "finish", P: id, Rank: p.FinishRank, Time: p.FinishTime})
+		}
+		r.last[id] = *p
+	}
+	for p, id := range r.ids {
+		if !seen[id] {
+			r.write(replayEvent{E: "leave", P: id})
+			delete(r.ids, p)
+			delete(r.last, id)
+		}
+	}
+	if minotaurOn {
+		was, known := r.last[0]
+		if !known || was.X != minotaur.X || was.Y != minotaur.Y {
+			r.write(replayEvent{E: "move", X: minotaur.X, Y: minotaur.Y})
+			r.last[0] = Player{X: minotaur.X, Y: minotaur.Y}
+		}
+	}
+}
+
+// replayCells records walls that opened or closed mid-round.
+func replayCells(changes []cellChange) {
+	replayMu.Lock()
+	defer replayMu.Unlock()
+	if replay != nil {
+		replay.write(replayEvent{E: "cells", Changes: changes})
+	}
+}
+
+// endReplay stops recording the round that just ended and hands its
+// replay over to be finished once mu is released; a new round records into
+// a file of its own meanwhile. Caller holds mu.
+func endReplay() *replayRecorder {
+	replayMu.Lock()
+	defer replayMu.Unlock()
+	r := replay
+	replay = nil
+	return r
+}
+
+// finish writes the end of a completed round's replay and closes the file.
+func (r *replayRecorder) finish() {
+	if r == nil {
+		return
+	}
+	r.write(replayEvent{E: "end"})
+	r.close()
+}
+
+func (r *replayRecorder) write(e replayEvent) {
+	e.T = time.Since(r.start).Milliseconds()
+	if err := r.enc.Encode(e); err != nil {
+		log.Printf("Replay write failed: %v", err)
+	}
+}
+
+func (r *replayRecorder) close() {
+	if r == nil {
+		return
+	}
+	if err := r.gz.Close(); err != nil {
+		log.Printf("Replay write failed: %v", err)
+	}
+	r.f.Close()
+}
+
+// pruneReplays applies -replay-keep and -replay-max-age. File names start
+// with the UTC start time, so sorting them sorts by age.
+func pruneReplays() {
+	entries, err := os.ReadDir(*flagReplays)
+	if err != nil {
+		return
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), replaySuffix) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	for i, name := range names {
+		old := false
+		if *flagReplayMaxAge > 0 {
+			if info, err := os.Stat(filepath.Join(*flagReplays, name)); err == nil {
+				old = time.Since(info.ModTime()) > *flagReplayMaxAge
+			}
+		}
+		// Keep one slot free for the replay about to be started.
+		if old || (*flagReplayKeep > 0 && i >= *flagReplayKeep-1) {
+			if err := os.Remove(filepath.Join(*flagReplays, name)); err != nil {
+				log.Printf("Could not delete old replay: %v", err)
+			}
+		}
+	}
+}
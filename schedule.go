@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	flagRegenSchedule = flag.String("regen-schedule", "", `start a new maze on a schedule, in the server's local time: a cron expression ("0 * * * *" is every hour on the hour), @hourly, @daily, @weekly or "@every 30m" (empty disables)`)
+	flagRegenWarning  = flag.Duration("regen-warning", time.Minute, "how long before a scheduled new maze players see the countdown")
+)
+
+var (
+	regenSchedule schedule  // nil without -regen-schedule
+	regenAt       time.Time // the scheduled new maze, set while the countdown runs
+)
+
+// A schedule returns the first time after t it fires, or the zero time if
+// it never does.
+type schedule interface {
+	next(t time.Time) time.Time
+}
+
+// everySchedule fires at multiples of its interval counted from midnight
+// UTC, so "@every 30m" means on the hour and at half past.
+type everySchedule time.Duration
+
+func (e everySchedule) next(t time.Time) time.Time {
+	d := time.Duration(e)
+	return t.Truncate(d).Add(d)
+}
+
+// cronSchedule is a five-field cron expression: minute, hour, day of month,
+// month and day of week (0 or 7 is Sunday).
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64 // bit n set if n matches
+	anyDom, anyDow                bool
+}
+
+var cronShorthands = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+}
+
+func parseSchedule(s string) (schedule, error) {
+	s = strings.TrimSpace(s)
+	if v, ok := strings.CutPrefix(s, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(v))
+		if err != nil || d < time.Minute {
+			return nil, fmt.Errorf("invalid interval %q, want a duration of at least 1m", v)
+		}
+		return everySchedule(d), nil
+	}
+	if v, ok := cronShorthands[s]; ok {
+		s = v
+	}
+	fields := strings.Fields(s)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q, want five cron fields or @hourly, @daily, @weekly, @every <duration>", s)
+	}
+	var c cronSchedule
+	var err error
+	for i, f := range []struct {
+		bits   *uint64
+		lo, hi int
+		name   string
+		star   *bool // set if the field is *
+	}{
+		{&c.minute, 0, 59, "minute", nil},
+		{&c.hour, 0, 23, "hour", nil},
+		{&c.dom, 1, 31, "day of month", &c.anyDom},
+		{&c.month, 1, 12, "month", nil},
+		{&c.dow, 0, 7, "day of week", &c.anyDow},
+	} {
+		if *f.bits, err = parseCronField(fields[i], f.lo, f.hi); err != nil {
+			return nil, fmt.Errorf("%s: %v", f.name, err)
+		}
+		if f.star != nil {
+			*f.star = fields[i] == "*"
+		}
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return &c, nil
+}
+
+// parseCronField reads a comma separated list of *, n, a-b, each optionally
+// followed by /step.
+func parseCronField(f string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(f, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q", stepStr)
+			}
+		}
+		from, to := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if from, err = strconv.Atoi(a); err != nil {
+				return 0, fmt.Errorf("invalid value %q", rng)
+			}
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(b); err != nil {
+					return 0, fmt.Errorf("invalid value %q", rng)
+				}
+			} else if hasStep {
+				to = hi
+			}
+		}
+		if from < lo || to > hi || from > to {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, lo, hi)
+		}
+		for v := from; v <= to; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// dayMatches follows cron: if both day fields are restricted, either may
+// match.
+func (c *cronSchedule) dayMatches(t time.Time) bool {
+	dom, dow := c.dom&(1<<t.Day()) != 0, c.dow&(1<<int(t.Weekday())) != 0
+	if c.anyDom || c.anyDow {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		y, m, d := t.Date()
+		switch {
+		case c.month&(1<<int(m)) == 0:
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, t.Location())
+		case !c.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, t.Location())
+		case c.hour&(1<<t.Hour()) == 0:
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, t.Location())
+		case c.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// loadRegenSchedule checks -regen-schedule at startup.
+func loadRegenSchedule() {
+	if *flagRegenSchedule == "" {
+		return
+	}
+	s, err := parseSchedule(*flagRegenSchedule)
+	if err == nil && s.next(clock()).IsZero() {
+		err = errors.New("it never fires")
+	}
+	if err != nil {
+		log.Fatalf("Invalid -regen-schedule: %v", err)
+	}
+	regenSchedule = s
+}
+
+// runRegenSchedule starts a new maze whenever the schedule fires, after
+// -regen-warning of countdown in the state.
+func runRegenSchedule() {
+	for {
+		at := regenSchedule.next(clock())
+		if at.IsZero() {
+			log.Printf("Maze schedule has no more dates")
+			return
+		}
+		time.Sleep(time.Until(at.Add(-*flagRegenWarning)))
+		mu.Lock()
+		regenAt = at
+		mu.Unlock()
+		log.Printf("New maze scheduled at %s", at.Format("15:04"))
+		broadcast()
+		time.Sleep(time.Until(at))
+		mu.Lock()
+		regenAt = time.Time{}
+		mu.Unlock()
+		if err := resetGame(0, ""); err != nil {
+			log.Printf("Scheduled new maze failed: %v", err)
+		}
+	}
+}
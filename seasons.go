@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+var (
+	flagSeasonStart = flag.String("season-start", "2026-01-05", "UTC date (YYYY-MM-DD) the first leaderboard season began")
+	flagSeasonWeeks = flag.Int("season-weeks", 13, "length of a leaderboard season in weeks")
+)
+
+// A period cuts the leaderboard into buckets: weeks, months or seasons.
+// Each bucket has a key such as 2026-W42, 2026-10 or S3. Finishes are
+// never deleted, so closed buckets stay available as archives.
+type period struct {
+	bucket func(t time.Time) (key string, from, to time.Time)
+	parse  func(key string) (from, to time.Time, err error)
+}
+
+var periods = map[string]period{
+	"weekly":  {weekBucket, parseWeek},
+	"monthly": {monthBucket, parseMonth},
+	"season":  {seasonBucket, parseSeason},
+}
+
+// LeaderboardPeriod is one bucket of a periodic leaderboard.
+type LeaderboardPeriod struct {
+	Period  string             `json:"period"`
+	Key     string             `json:"key"`
+	From    time.Time          `json:"from"`
+	To      time.Time          `json:"to"` // exclusive
+	Current bool               `json:"current"`
+	Entries []LeaderboardEntry `json:"entries"`
+}
+
+func weekBucket(t time.Time) (string, time.Time, time.Time) {
+	t = t.UTC()
+	year, week := t.ISOWeek()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	from := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	return fmt.Sprintf("%d-W%02d", year, week), from, from.AddDate(0, 0, 7)
+}
+
+func parseWeek(key string) (time.Time, time.Time, error) {
+	var year, week int
+	if _, err := fmt.Sscanf(key, "%d-W%d", &year, &week); err != nil || week < 1 || week > 53 {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid week %q, want YYYY-Www", key)
+	}
+	// January 4th is always in week 1.
+	jan4 := time.Date(year, 1, 4, 0, 0, 0, 0, time.UTC)
+	from := jan4.AddDate(0, 0, -(int(jan4.Weekday())+6)%7+7*(week-1))
+	return from, from.AddDate(0, 0, 7), nil
+}
+
+func monthBucket(t time.Time) (string, time.Time, time.Time) {
+	t = t.UTC()
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return from.Format("2006-01"), from, from.AddDate(0, 1, 0)
+}
+
+func parseMonth(key string) (time.Time, time.Time, error) {
+	from, err := time.Parse("2006-01", key)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid month %q, want YYYY-MM", key)
+	}
+	return from, from.AddDate(0, 1, 0), nil
+}
+
+func seasonStart() time.Time {
+	t, err := time.Parse("2006-01-02", *flagSeasonStart)
+	if err != nil {
+		return time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	}
+	return t
+}
+
+// seasonBounds returns the span of season n, counting from 1.
+func seasonBounds(n int) (time.Time, time.Time) {
+	days := 7 * max(1, *flagSeasonWeeks)
+	from := seasonStart().AddDate(0, 0, (n-1)*days)
+	return from, from.AddDate(0, 0, days)
+}
+
+func seasonBucket(t time.Time) (string, time.Time, time.Time) {
+	days := 7 * max(1, *flagSeasonWeeks)
+	n := max(1, int(t.Sub(seasonStart()).Hours()/24)/days+1)
+	from, to := seasonBounds(n)
+	return fmt.Sprintf("S%d", n), from, to
+}
+
+func parseSeason(key string) (time.Time, time.Time, error) {
+	var n int
+	if _, err := fmt.Sscanf(key, "S%d", &n); err != nil || n < 1 {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid season %q, want S1, S2, ...", key)
+	}
+	from, to := seasonBounds(n)
+	return from, to, nil
+}
+
+// runSeasonRollover announces the winners of every week, month and season
+// as it closes.
+func runSeasonRollover() {
+	for {
+		now := time.Now()
+		next := now.Add(24 * time.Hour)
+		for _, p := range periods {
+			if _, _, to := p.bucket(now); to.Before(next) {
+				next = to
+			}
+		}
+		time.Sleep(time.Until(next))
+		closed := next.Add(-time.Second)
+		for name, p := range periods {
+			key, from, to := p.bucket(closed)
+			if to.After(next) {
+				continue
+			}
+			list, err := leaderboard.Top(LeaderboardQuery{From: from, To: to, Limit: 1})
+			switch {
+			case err != nil:
+				log.Printf("Leaderboard rollover failed: %v", err)
+			case len(list) == 0:
+				log.Printf("LEADERBOARD %s %s closed without finishes", name, key)
+			default:
+				log.Printf("LEADERBOARD %s %s closed, winner: %s in %ds", name, key, list[0].Name, list[0].Time)
+			}
+		}
+	}
+}
+
+// handlePeriodLeaderboard serves GET /leaderboard/{period} for the running
+// bucket and /leaderboard/{period}/{key} for any other. The /leaderboard
+// size, seed and top filters apply.
+func handlePeriodLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if leaderboard == nil {
+		http.Error(w, "the leaderboard is disabled", http.StatusNotFound)
+		return
+	}
+	name := r.PathValue("period")
+	p, ok := periods[name]
+	if !ok {
+		http.Error(w, "unknown period, want weekly, monthly or season", http.StatusNotFound)
+		return
+	}
+	q, err := parseLeaderboardQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	current, _, _ := p.bucket(time.Now())
+	res := LeaderboardPeriod{Period: name, Key: r.PathValue("key")}
+	if res.Key == "" {
+		res.Key, res.From, res.To = p.bucket(time.Now())
+	} else if res.From, res.To, err = p.parse(res.Key); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	res.Current = res.Key == current
+	q.From, q.To = res.From, res.To
+	if res.Entries, err = leaderboard.Top(q); err != nil {
+		log.Printf("Leaderboard query failed: %v", err)
+		http.Error(w, "could not load the leaderboard", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+// handleLeaderboardArchive serves GET /leaderboard/{period}/archive: the
+// keys of every bucket since the first recorded finish, newest first.
+func handleLeaderboardArchive(w http.ResponseWriter, r *http.Request) {
+	if leaderboard == nil {
+		http.Error(w, "the leaderboard is disabled", http.StatusNotFound)
+		return
+	}
+	p, ok := periods[r.PathValue("period")]
+	if !ok {
+		http.Error(w, "unknown period, want weekly, monthly or season", http.StatusNotFound)
+		return
+	}
+	first, err := leaderboard.First()
+	if err != nil {
+		log.Printf("Leaderboard query failed: %v", err)
+		http.Error(w, "could not load the leaderboard", http.StatusInternalServerError)
+		return
+	}
+	keys := []string{}
+	if !first.IsZero() {
+		for t := time.Now(); ; {
+			key, from, _ := p.bucket(t)
+			if len(keys) > 0 && keys[len(keys)-1] == key {
+				break // before the first season
+			}
+			keys = append(keys, key)
+			if !from.After(first) {
+				break
+			}
+			t = from.Add(-time.Second)
+		}
+	}
+	json.NewEncoder(w).Encode(keys)
+}
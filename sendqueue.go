@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// sendQueueLimit is how many messages may wait for one connection before it
+// counts as stuck and is closed.
+const sendQueueLimit = 256
+
+var flagWriteTimeout = flag.Duration("write-timeout", 10*time.Second, "how long writing one message to a client may take before the connection counts as dead and is closed")
+
+// A sendQueue holds the messages for one connection, written out by a
+// goroutine of its own. Broadcasts only queue, so mu is never held while
+// waiting on a slow client. A state queued right behind another replaces it:
+// the client only needs the newest.
+type sendQueue struct {
+	ws      *websocket.Conn
+	mu      sync.Mutex
+	queue   []outMessage
+	writing bool
+	closed  bool
+	version int // protocol agreed in the hello
+	wake    chan struct{}
+	sent    trafficCounter
+}
+
+type outMessage struct {
+	data  []byte
+	state bool
+}
+
+// textFrames sends bytes as a text frame, as websocket.Message only does
+// for strings, without copying them into one for every recipient.
+var textFrames = websocket.Codec{Marshal: func(v any) ([]byte, byte, error) {
+	return v.([]byte), websocket.TextFrame, nil
+}}
+
+func newSendQueue(ws *websocket.Conn) *sendQueue {
+	o := &sendQueue{ws: ws, wake: make(chan struct{}, 1)}
+	go o.run()
+	return o
+}
+
+func (o *sendQueue) run() {
+	for range o.wake {
+		for {
+			o.mu.Lock()
+			if len(o.queue) == 0 {
+				o.writing = false
+				o.mu.Unlock()
+				break
+			}
+			m := o.queue[0]
+			o.queue = o.queue[1:]
+			o.writing = true
+			o.mu.Unlock()
+			// A client that stopped reading would block the writer forever.
+			// Closing the connection makes the reader fail and clean up.
+			o.ws.SetWriteDeadline(time.Now().Add(*flagWriteTimeout))
+			if err := textFrames.Send(o.ws, m.data); err != nil {
+				var ne net.Error
+				if errors.As(err, &ne) && ne.Timeout() {
+					log.Printf("Dropping %s: a write took over %v", o.ws.Request().RemoteAddr, *flagWriteTimeout)
+				}
+				o.close()
+				o.ws.Close()
+				return
+			}
+			o.mu.Lock()
+			o.sent.count(time.Now(), len(m.data))
+			o.mu.Unlock()
+		}
+	}
+}
+
+// put queues a typed message, which must not be changed afterwards: it may
+// go to many connections.
+func (o *sendQueue) put(data []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.version >= 2 {
+		data = envelope(data)
+	}
+	o.add(data, false)
+}
+
+// putState queues a state, plain or in its protocol 2 envelope.
+func (o *sendQueue) putState(plain, enveloped []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.version >= 2 {
+		o.add(enveloped, true)
+	} else {
+		o.add(plain, true)
+	}
+}
+
+func (o *sendQueue) setProtocol(version int) {
+	o.mu.Lock()
+	o.version = version
+	o.mu.Unlock()
+}
+
+// add queues one message. Caller holds o.mu.
+func (o *sendQueue) add(data []byte, state bool) {
+	if o.closed {
+		return
+	}
+	n := len(o.queue)
+	switch {
+	case state && n > 0 && o.queue[n-1].state:
+		o.queue[n-1].data = data
+	case n >= sendQueueLimit:
+		log.Printf("Dropping %s: %d messages unsent", o.ws.Request().RemoteAddr, n)
+		o.closed = true
+		o.queue = nil
+		close(o.wake)
+		// Closing writes a close frame, which waits for the stuck writer;
+		// the caller holds o.mu and often mu.
+		go o.ws.Close()
+		return
+	default:
+		o.queue = append(o.queue, outMessage{data, state})
+	}
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pending counts the messages not yet written, including one being written.
+func (o *sendQueue) pending() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		return 0
+	}
+	n := len(o.queue)
+	if o.writing {
+		n++
+	}
+	return n
+}
+
+// sentStats returns what was written so far and how many messages wait.
+func (o *sendQueue) sentStats() (sent trafficCounter, queued int) {
+	o.mu.Lock()
+	sent = o.sent
+	o.mu.Unlock()
+	return sent, o.pending()
+}
+
+// flush waits until everything queued is written, or timeout passes.
+func (o *sendQueue) flush(timeout time.Duration) {
+	for deadline := time.Now().Add(timeout); o.pending() > 0 && time.Now().Before(deadline); {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// close stops the writer; whatever is still queued is dropped.
+func (o *sendQueue) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if !o.closed {
+		o.closed = true
+		o.queue = nil
+		close(o.wake)
+	}
+}
+
+// send queues a typed message for the player.
+func (p *Player) send(data []byte) {
+	p.out.put(data)
+}
+
+// flushSendQueues waits until every queued message is written, or timeout
+// passes.
+func flushSendQueues(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		waiting := 0
+		mu.Lock()
+		for _, p := range clients {
+			waiting += p.out.pending()
+		}
+		mu.Unlock()
+		if waiting == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
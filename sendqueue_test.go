@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// BenchmarkBroadcastSlowClients measures how long a broadcast takes with
+// clients that read everything and with clients that stopped reading. Each
+// connection has its own writer, so stuck clients cost a broadcast no more
+// than reading ones.
+func BenchmarkBroadcastSlowClients(b *testing.B) {
+	log.SetOutput(io.Discard)
+	c, err := generateMaze(31, 21, 1, settings)
+	if err != nil {
+		b.Fatal(err)
+	}
+	mu.Lock()
+	c.install()
+	mu.Unlock()
+	for _, n := range []int{10, 100} {
+		for _, stuck := range []bool{false, true} {
+			name := fmt.Sprintf("reading=%d", n)
+			if stuck {
+				name = fmt.Sprintf("stuck=%d", n)
+			}
+			b.Run(name, func(b *testing.B) { benchmarkBroadcast(b, n, stuck) })
+		}
+	}
+}
+
+func benchmarkBroadcast(b *testing.B, n int, stuck bool) {
+	conns := joinBenchClients(b, n, stuck)
+	b.ResetTimer()
+	for range b.N {
+		broadcast()
+	}
+	b.StopTimer()
+	leaveBenchClients(conns)
+}
+
+// joinBenchClients connects n players through pipes and waits until they
+// are in the game. Stuck ones never read what the game sends them.
+func joinBenchClients(b *testing.B, n int, stuck bool) []*websocket.Conn {
+	var conns []*websocket.Conn
+	for i := range n {
+		ws, err := dialPipe(fmt.Sprintf("bench:%d", i), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		conns = append(conns, ws)
+		// Nobody reads the client end of a stuck client's pipe, so the
+		// game's first write to it never finishes.
+		if !stuck {
+			go func() {
+				var discard []byte
+				for websocket.Message.Receive(ws, &discard) == nil {
+				}
+			}()
+		}
+	}
+	for {
+		mu.Lock()
+		joined := len(clients)
+		mu.Unlock()
+		if joined == n {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return conns
+}
+
+// leaveBenchClients disconnects conns and waits until the game has let
+// them go.
+func leaveBenchClients(conns []*websocket.Conn) {
+	for _, ws := range conns {
+		ws.Close()
+	}
+	for {
+		mu.Lock()
+		left := len(clients) == 0
+		mu.Unlock()
+		if left {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
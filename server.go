@@ -24,42 +24,148 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
 	"log"
 	"math/rand"
 	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+	"unicode"
 
 	"golang.org/x/net/websocket"
 )
 
 type Player struct {
-	X          int    `json:"x"`
-	Y          int    `json:"y"`
-	Name       string `json:"name"`
-	Color      string `json:"color"`
-	Finished   bool   `json:"finished"`
-	FinishTime int64  `json:"finishTime"`
-	FinishRank int    `json:"finishRank"`
+	X          int      `json:"x"`
+	Y          int      `json:"y"`
+	Name       string   `json:"name"`
+	Color      string   `json:"color"`
+	Finished   bool     `json:"finished"`
+	FinishTime int64    `json:"finishTime"`
+	FinishRank int      `json:"finishRank"`
+	Card       string   `json:"card,omitempty"` // signed result card URL
+	WallHits   int      `json:"wallHits"`
+	Blocked    int      `json:"blocked,omitempty"` // moves refused by another player in the way
+	HintsUsed  int      `json:"hintsUsed"`
+	Distance   int      `json:"distance"`   // steps left to the goal, -1 if unknown
+	Checkpoint int      `json:"checkpoint"` // checkpoints passed this round
+	Laps       int      `json:"laps"`
+	BestLap    int64    `json:"bestLap,omitempty"` // milliseconds
+	Collected  int      `json:"collected"`         // goals visited in collect-all-goals mode
+	Effects    []string `json:"effects,omitempty"` // active power-ups
+	Coins      int      `json:"coins"`
+	Caught     int      `json:"caught,omitempty"` // times the minotaur sent the player back
+	Team       string   `json:"team,omitempty"`
+	Eliminated bool     `json:"eliminated,omitempty"` // sudden death: caught in the collapse
+	NPC        bool     `json:"npc,omitempty"`        // the minotaur, not a connected player
+	Ghost      bool     `json:"ghost,omitempty"`      // replay of the best recorded run on this seed
+	Host       bool     `json:"host,omitempty"`       // may start rounds and hand hosting on
+	Assisted   bool     `json:"assisted,omitempty"`   // got help from -assist this round
+	Ack        uint64   `json:"ack,omitempty"`        // seq of the last move message applied
+
+	joinedAt     time.Time
+	connectedAt  time.Time
+	invalidMoves int
+	lastHint     time.Time
+	caps         capSet
+	protocol     int // 0 until the client says hello
+	protoCounted bool
+	moved        int // accepted moves this round
+	spawn        int // index into spawnPoints
+	lapStart     time.Time
+	collected    []bool    // indexed like goals
+	stuckUntil   time.Time // mud or a freeze holds the player until then
+	effects      map[string]time.Time
+	profile      string        // public id of the player's account, if any
+	verified     bool          // profile comes from a player token, not the client
+	best         int64         // personal best on this maze in seconds, 0 if none
+	out          *sendQueue    // messages waiting to be written to the connection
+	dirty        bool          // changed since its last broadcast
+	encoded      []byte        // as in the last broadcast
+	heard        time.Time     // last message from the client
+	rtt          time.Duration // round trip of the last ping answered
+	received     trafficCounter
+	splits       []int64   // milliseconds into the round at each of splitMarks
+	assisting    bool      // trailing the field, see -assist
+	lastAssist   time.Time // last free hint, or when assisting began
+}
+
+// clientMessage is what players send over the WebSocket. Messages without
+// a type are position updates.
+type clientMessage struct {
+	Type       string        `json:"type,omitempty"`
+	X          int           `json:"x"`
+	Y          int           `json:"y"`
+	Name       string        `json:"name"`
+	Color      string        `json:"color"`
+	Team       string        `json:"team,omitempty"`       // team mode: the team the player wants
+	Protocol   int           `json:"protocol,omitempty"`   // hello only
+	Caps       []string      `json:"caps,omitempty"`       // hello only
+	Profile    string        `json:"profile,omitempty"`    // hello only: the client's profile token
+	Seed       int64         `json:"seed,omitempty"`       // restart only
+	Difficulty string        `json:"difficulty,omitempty"` // restart only
+	Uses       int           `json:"uses,omitempty"`       // invite only
+	TTL        string        `json:"ttl,omitempty"`        // invite only
+	Code       string        `json:"code,omitempty"`       // invite only: the code to revoke
+	Settings   *GameSettings `json:"settings,omitempty"`   // settings only
+	Seq        uint64        `json:"seq,omitempty"`        // moves: counts up by one per message, 0 if the client does not count
+	T          int64         `json:"t,omitempty"`          // pong only: t of the ping answered
 }
 
 type GameState struct {
-	AllFinished bool     `json:"allFinished"`
-	Players     []Player `json:"players"`
-	GameOver    bool     `json:"gameOver"`
+	AllFinished bool               `json:"allFinished"`
+	Players     []Player           `json:"players"`
+	GameOver    bool               `json:"gameOver"`
+	Checkpoints int                `json:"checkpoints,omitempty"`  // checkpoints to pass before the goal
+	Laps        int                `json:"laps,omitempty"`         // laps to run, if more than one
+	Goals       int                `json:"goals,omitempty"`        // goals to collect, if more than one
+	Coins       []point            `json:"coins,omitempty"`        // coin mode: coins lying in the maze
+	TimeLeft    int                `json:"timeLeft,omitempty"`     // coin mode: seconds until the round ends
+	Teams       []TeamStanding     `json:"teams,omitempty"`        // team mode leaderboard
+	SafeRadius  int                `json:"safeRadius,omitempty"`   // sudden death: rings around the goal still standing
+	Collapse    int64              `json:"nextCollapse,omitempty"` // sudden death: unix milliseconds of the next collapse
+	NextMaze    int64              `json:"nextMaze,omitempty"`     // unix milliseconds of a scheduled new maze, during its countdown
+	HallOfFame  []LeaderboardEntry `json:"hallOfFame,omitempty"`   // game over: the all-time podium of this maze size
+	Gen         uint64             `json:"gen"`                    // counts the states broadcast
 }
 
+var stateGen uint64 // generation of the last state broadcast
+
 type MazeInfo struct {
-	GoalX  int `json:"goalX"`
-	GoalY  int `json:"goalY"`
-	Width  int `json:"width"`
-	Height int `json:"height"`
+	GoalX   int           `json:"goalX"`
+	GoalY   int           `json:"goalY"`
+	Width   int           `json:"width"`
+	Height  int           `json:"height"`
+	StartX  int           `json:"startX"`
+	StartY  int           `json:"startY"`
+	Seed    int64         `json:"seed"`
+	Spawns  []point       `json:"spawns"`
+	Custom  bool          `json:"custom,omitempty"` // uploaded instead of generated
+	Daily   string        `json:"daily,omitempty"`  // UTC date of the daily challenge
+	Collide bool          `json:"collisions,omitempty"`
+	Checks  []point       `json:"checkpoints,omitempty"` // in the order they must be passed
+	Laps    int           `json:"laps,omitempty"`
+	Goals   []point       `json:"goals,omitempty"` // collect-all-goals mode, goals[0] is the maze goal
+	Portals [][2]point    `json:"portals,omitempty"`
+	MudWait int64         `json:"mudDelay,omitempty"` // milliseconds a mud cell holds a player
+	Items   []Item        `json:"items,omitempty"`
+	Teams   []string      `json:"teams,omitempty"`
+	Markers []Marker      `json:"markers"`
+	Metrics MazeMetrics   `json:"metrics"`
+	Private bool          `json:"private,omitempty"`  // joining needs a password
+	Login   bool          `json:"login,omitempty"`    // joining needs a player token
+	Code    string        `json:"code,omitempty"`     // maze library code, for /reset?maze=CODE
+	Rules   *GameSettings `json:"settings,omitempty"` // what the host set for this round
+	Rev     int           `json:"revision"`           // changes whenever GET /maze does
 }
 
 var (
@@ -68,40 +174,89 @@ var (
 	mazeHeight = 41
 	goalX      = 69
 	goalY      = 39
+	startX     = 1
+	startY     = 1
 	clients    = make(map[*websocket.Conn]*Player)
 	mu         sync.Mutex
 	finishRank = 0
 	gameOver   = false
 	startTime  time.Time
+	mazeSeed   int64
 )
 
-func generateMaze() {
-	h, w := mazeHeight, mazeWidth
-	log.Printf("Generating maze %dx%d...", w, h)
-	maze = make([][]int, h)
+// newSeed picks a random seed. It stays below 2^53 so browsers can show and
+// send it back without losing precision.
+func newSeed() int64 {
+	return rand.Int63() >> 10
+}
+
+// maxMazeDim caps either side of the maze. Larger grids take too much memory
+// and produce a /maze payload no browser will happily parse.
+const maxMazeDim = 4001
+
+func validateMazeSize(w, h int) error {
+	if w < 11 || h < 11 {
+		return fmt.Errorf("maze size %dx%d is too small (minimum 11x11)", w, h)
+	}
+	if w > maxMazeDim || h > maxMazeDim {
+		return fmt.Errorf("maze size %dx%d is too large (maximum %dx%d)", w, h, maxMazeDim, maxMazeDim)
+	}
+	return nil
+}
+
+// generateMaze builds a w x h maze from seed, laid out for a round played
+// with s; the same seed and size always produce the same maze. A zero seed
+// picks a random one. The game is left alone until the maze is installed.
+func generateMaze(w, h int, seed int64, s GameSettings) (*mazeCandidate, error) {
+	if err := validateMazeSize(w, h); err != nil {
+		return nil, err
+	}
+	if seed == 0 {
+		seed = newSeed()
+	}
+	rng := rand.New(rand.NewSource(seed))
+	grid, gx, gy := buildMaze(w, h, rng)
+	start, goal := point{1, 1}, point{gx, gy}
+	addTerrain(grid, rng, *flagIce, *flagMud, start, goal)
+	return &mazeCandidate{grid: grid, start: start, goal: goal, seed: seed, layout: layoutMaze(grid, start, goal, rng, s, nil)}, nil
+}
+
+// buildMaze carves a w x h maze starting at (1, 1) and returns it with the
+// goal cell. It only draws from rng, so a seed always yields the same maze.
+func buildMaze(w, h int, rng *rand.Rand) ([][]int, int, int) {
+	maze := make([][]int, h)
 	for y := range maze {
 		maze[y] = make([]int, w)
 		for x := range maze[y] {
 			maze[y][x] = 1
 		}
 	}
-	rand.Seed(time.Now().UnixNano())
-	var walk func(x, y int)
-	walk = func(x, y int) {
-		maze[y][x] = 0
-		dirs := [][2]int{{0, 2}, {0, -2}, {2, 0}, {-2, 0}}
-		rand.Shuffle(len(dirs), func(i, j int) { dirs[i], dirs[j] = dirs[j], dirs[i] })
+	// Depth-first backtracker with an explicit stack; the recursive version
+	// ran out of stack on very large custom sizes.
+	dirs := [][2]int{{0, 2}, {0, -2}, {2, 0}, {-2, 0}}
+	stack := [][2]int{{1, 1}}
+	maze[1][1] = 0
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		x, y := cur[0], cur[1]
+		rng.Shuffle(len(dirs), func(i, j int) { dirs[i], dirs[j] = dirs[j], dirs[i] })
+		moved := false
 		for _, d := range dirs {
 			nx, ny := x+d[0], y+d[1]
 			if nx > 0 && nx < w-1 && ny > 0 && ny < h-1 && maze[ny][nx] == 1 {
 				maze[y+d[1]/2][x+d[0]/2] = 0
-				walk(nx, ny)
+				maze[ny][nx] = 0
+				stack = append(stack, [2]int{nx, ny})
+				moved = true
+				break
 			}
 		}
+		if !moved {
+			stack = stack[:len(stack)-1]
+		}
 	}
-	walk(1, 1)
-	goalX = w - 2
-	goalY = h - 2
+	goalX := w - 2
+	goalY := h - 2
 	// Make sure goal is even (reachable by maze generator)
 	if goalX%2 == 0 {
 		goalX--
@@ -110,40 +265,148 @@ func generateMaze() {
 		goalY--
 	}
 	maze[goalY][goalX] = 0
-	log.Printf("Maze generated. Goal at (%d, %d)", goalX, goalY)
+	return maze, goalX, goalY
 }
 
 func broadcast() {
 	mu.Lock()
-	defer mu.Unlock()
 
-	var list []Player
+	// Only players that changed since the last broadcast are serialized
+	// again. Distance and effects also change without the player doing
+	// anything, with the maze or the clock.
+	players := make([][]byte, 0, len(clients)+2)
 	allDone := true
 	playerCount := len(clients)
 
 	for _, p := range clients {
-		list = append(list, *p)
-		if !p.Finished {
+		dist, effects := distanceToGoal(p.X, p.Y), activeEffects(p)
+		if p.dirty || p.encoded == nil || dist != p.Distance || !slices.Equal(effects, p.Effects) {
+			p.Distance, p.Effects = dist, effects
+			p.encoded, _ = json.Marshal(p)
+			p.dirty = false
+		}
+		trackSplits(p)
+		players = append(players, p.encoded)
+		if !p.Finished && !p.Eliminated {
 			allDone = false
 		}
 	}
 
+	if minotaurOn {
+		data, _ := json.Marshal(minotaurPlayer())
+		players = append(players, data)
+	}
+	if g, ok := ghostPlayer(); ok {
+		data, _ := json.Marshal(g)
+		players = append(players, data)
+	}
+	replayTick()
+
+	var ended *roundEnd
 	if allDone && playerCount > 0 && !gameOver {
 		gameOver = true
 		log.Println("GAME OVER: All players have reached the goal!")
+		journalRoundEnd()
+		ended = endRound()
 	}
 
+	stateGen++
 	state := GameState{
 		AllFinished: allDone && playerCount > 0,
-		Players:     list,
 		GameOver:    gameOver,
+		Gen:         stateGen,
+		Checkpoints: len(checkpoints),
+		Laps:        lapCount(),
+		Goals:       len(goals),
+		Coins:       coins,
+		TimeLeft:    coinTimeLeft(),
+		Teams:       teamStandings(),
+	}
+	if collapseMode() {
+		state.SafeRadius = collapseRadius
+		if !nextCollapse.IsZero() {
+			state.Collapse = nextCollapse.UnixMilli()
+		}
+	}
+	if !regenAt.IsZero() {
+		state.NextMaze = regenAt.UnixMilli()
+	}
+	if gameOver {
+		state.HallOfFame = gameOverPodium()
 	}
 
+	// The players go in as they are: encoding/json would check every byte of
+	// them again. Only allFinished comes before them.
 	data, _ := json.Marshal(state)
-	for conn := range clients {
-		if err := websocket.Message.Send(conn, string(data)); err != nil {
-			// Don't log every write error
+	data = bytes.Replace(data, []byte(`"players":null`), append(append([]byte(`"players":[`), bytes.Join(players, []byte(","))...), ']'), 1)
+	lastState = data
+	pastStates.keep(data)
+	publishEvent(data)
+	enveloped := stateEnvelope(data)
+	for _, p := range clients {
+		p.out.putState(data, enveloped)
+	}
+	mu.Unlock()
+	ended.record()
+}
+
+// roundEnd is what a round that just ended leaves to be stored and
+// announced. broadcast collects it under mu and records it after letting
+// go, so the database and webhooks never hold up the game.
+type roundEnd struct {
+	replay *replayRecorder
+	match  *Match
+	hook   map[string]any
+}
+
+// endRound collects the end of the round. Caller holds mu.
+func endRound() *roundEnd {
+	var list []Player
+	for _, p := range clients {
+		list = append(list, *p)
+	}
+	return &roundEnd{
+		replay: endReplay(),
+		match:  newMatch(),
+		hook:   map[string]any{"seed": mazeSeed, "width": mazeWidth, "height": mazeHeight, "players": list},
+	}
+}
+
+// record writes the replay out, saves the match and tells webhooks. Caller
+// does not hold mu.
+func (e *roundEnd) record() {
+	if e == nil {
+		return
+	}
+	e.replay.finish()
+	saveMatch(e.match)
+	countGame()
+	fireWebhooks(hookRoundOver, e.hook)
+}
+
+// maxNameLen matches the name field of the web client.
+const maxNameLen = 12
+
+// playerName cleans a name a client sent: letters, digits, spaces and a
+// little punctuation, at most maxNameLen of them. What is left may be empty.
+func playerName(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || strings.ContainsRune(" -_.'!?", r) {
+			return r
 		}
+		return -1
+	}, s)
+	return strings.TrimSpace(truncate(strings.Join(strings.Fields(s), " "), maxNameLen))
+}
+
+// setLook takes the name and color a client sent, keeping p's own where
+// they are not acceptable. Caller holds mu.
+func setLook(p *Player, name, color string) {
+	if name = playerName(name); name != "" {
+		p.Name = name
+	}
+	if hexColorRE.MatchString(color) {
+		p.Color = color
 	}
 }
 
@@ -151,11 +414,14 @@ func handleWS(ws *websocket.Conn) {
 	startTimeConnection := time.Now()
 	remoteAddr := ws.Request().RemoteAddr
 	log.Printf("New connection from %s", remoteAddr)
-	
-	p := &Player{X: 1, Y: 1, Name: "Anon", Color: "#ff0000"}
+	// A player token or login session ties the connection to an account.
+	verified := verifiedPlayer(ws.Request())
 
 	mu.Lock()
+	p := &Player{X: startX, Y: startY, Name: "Anon", Color: "#ff0000", Team: autoTeam(), joinedAt: clock(), connectedAt: clock(), heard: time.Now(), profile: verified, verified: verified != "", out: newSendQueue(ws)}
 	clients[ws] = p
+	claimHost(ws, p)
+	countConnections(len(clients))
 	mu.Unlock()
 
 	broadcast()
@@ -163,55 +429,241 @@ func handleWS(ws *websocket.Conn) {
 	defer func() {
 		mu.Lock()
 		delete(clients, ws)
+		releaseHost(ws)
+		forgetClumsiness(p)
+		id, g, played := gameRecord(p)
 		mu.Unlock()
+		p.out.close()
 		ws.Close()
+		if played {
+			saveProfiles([]profileUpdate{{id, g}})
+		}
 		broadcast()
 		duration := time.Since(startTimeConnection)
 		log.Printf("Connection closed (duration: %v): %s [%s]", duration, remoteAddr, p.Name)
 	}()
 
 	for {
-		var msg Player
-		if err := websocket.JSON.Receive(ws, &msg); err != nil {
+		var raw []byte
+		var msg clientMessage
+		err := websocket.Message.Receive(ws, &raw)
+		if err == nil {
+			err = json.Unmarshal(raw, &msg)
+		}
+		if err != nil {
 			if err != io.EOF {
 				log.Printf("Read error from %s: %v", remoteAddr, err)
 			}
 			break
 		}
+		mu.Lock()
+		p.heard = time.Now()
+		p.received.count(p.heard, len(raw))
+		mu.Unlock()
+		switch msg.Type {
+		case "hello":
+			handleHello(ws, p, helloMessage{Type: msg.Type, Protocol: msg.Protocol, Caps: msg.Caps, Profile: msg.Profile})
+			continue
+		case "hint":
+			sendHint(p)
+			continue
+		case "restart":
+			hostRestart(ws, p, msg)
+			continue
+		case "host":
+			hostTransfer(ws, p, msg)
+			continue
+		case "invite":
+			hostInvite(ws, p, msg)
+			continue
+		case "settings":
+			hostSettings(ws, p, msg)
+			continue
+		case "pong":
+			handlePong(p, msg.T)
+			continue
+		case "", "move":
+		default:
+			// A newer client's message; it is no move.
+			continue
+		}
 
 		mu.Lock()
-		wasFinished := p.Finished
-		p.X, p.Y, p.Name, p.Color = msg.X, msg.Y, msg.Name, msg.Color
+		// A move older than one already applied arrived out of order and
+		// would take the player back.
+		if msg.Seq != 0 && msg.Seq <= p.Ack {
+			mu.Unlock()
+			continue
+		}
+		if msg.Seq != 0 {
+			p.Ack = msg.Seq
+		}
+		if countProtocol(p, 0) {
+			warnProtocol(p, remoteAddr, 0)
+		}
+		p.dirty = true
+		setLook(p, msg.Name, msg.Color)
+		chooseTeam(p, msg.Team)
+		wants := msg.X != p.X || msg.Y != p.Y
+		refused := wants && !applyMove(p, msg.X, msg.Y)
+		if wants && !refused && p.moved == 1 {
+			countPlayer(p, clientIP(ws.Request()))
+		}
+		var from point
+		jumped := false
+		var itemEvents []itemEvent
+		var finished *finishEvent
+		if wants && !refused {
+			itemEvents = pickupItem(p)
+			collectCoin(p)
+			catchPlayer(p)
+			from, jumped = usePortal(p)
+		}
+		x, y, name := p.X, p.Y, p.Name
 
-		if msg.Finished && !wasFinished {
+		if !p.Finished && !coinMode() && reachedGoal(p) && p.Checkpoint >= len(checkpoints) && !completeLap(p) {
 			p.Finished = true
 			finishRank++
 			p.FinishRank = finishRank
-			p.FinishTime = time.Now().Unix() - startTime.Unix()
+			p.FinishTime = clock().Unix() - roundStartFor(p).Unix()
+			p.Card = cardURL(*p)
 			log.Printf("PLAYER FINISHED! Name: %s | Rank: %d | Time: %ds", p.Name, p.FinishRank, p.FinishTime)
+			journalFinish(*p)
+			recordFinish(*p)
+			if *flagDaily {
+				recordDailyFinish(*p)
+			}
+			ev := newFinishEvent(p)
+			finished = &ev
 		}
 		mu.Unlock()
 
+		// Refused moves, ice and portals all leave the player somewhere
+		// else than the client put it.
+		if x != msg.X || y != msg.Y {
+			sendPosition(p, x, y)
+		}
+		if jumped {
+			broadcastPortal(name, from, point{x, y})
+		}
+		for _, ev := range itemEvents {
+			broadcastItemEvent(ev)
+		}
+		if finished != nil {
+			broadcastFinish(*finished)
+		}
 		broadcast()
 	}
 }
 
-func resetGame() {
+// resetMu serializes new rounds, which /reset, the host, the schedules,
+// Discord and gRPC can all ask for at once.
+var resetMu sync.Mutex
+
+// resetGame starts a new round on a fresh (or uploaded) maze with the
+// host's settings. A zero seed and an empty difficulty take them from the
+// settings or the flags. The maze is built and checked before anything
+// changes: if it turns out to be unsolvable the current round keeps running
+// and the error is returned.
+func resetGame(seed int64, difficulty string) error {
+	resetMu.Lock()
+	defer resetMu.Unlock()
 	log.Println("Game reset requested via API")
 	mu.Lock()
+	s, w, h := nextSettings, mazeWidth, mazeHeight
+	mu.Unlock()
+	if s.Size != "" {
+		w, h, _ = presetMazeSize(s.Size)
+	}
+	if seed == 0 {
+		seed = s.Seed
+	}
+	if difficulty == "" {
+		difficulty = raceDifficulty(s)
+	}
+	// The daily challenge plays the day's maze whoever asks for a round.
+	var custom *CustomMaze
+	day := clock()
+	if *flagDaily {
+		seed = dailySeed(day)
+	} else {
+		custom = takePendingMaze()
+	}
+	var c *mazeCandidate
+	var err error
+	if custom != nil {
+		c = customCandidate(custom, s)
+	} else {
+		c, err = generateWithDifficulty(w, h, seed, difficulty, s)
+	}
+	if err == nil {
+		err = c.verify()
+	}
+	if err != nil {
+		log.Printf("Refusing to start a new round: %v", err)
+		return err
+	}
+	clearMarkers()
+	mu.Lock()
+	settings = s
+	c.install()
+	startTime = clock()
+	if *flagDaily {
+		dailyDay = dailyDate(day)
+	}
 	finishRank = 0
 	gameOver = false
+	nextSpawn = 0
+	items = nil
+	scatterCoins()
+	placeMinotaur()
+	resetCollapse()
+	loadGhost()
+	var played []profileUpdate
 	for _, p := range clients {
-		p.X = 1
-		p.Y = 1
+		if id, g, ok := gameRecord(p); ok {
+			played = append(played, profileUpdate{id, g})
+		}
+		p.dirty = true
 		p.Finished = false
 		p.FinishRank = 0
 		p.FinishTime = 0
+		p.Card = ""
+		p.WallHits = 0
+		p.Blocked = 0
+		p.Checkpoint = 0
+		p.Laps = 0
+		p.BestLap = 0
+		p.lapStart = time.Time{}
+		p.Collected = 0
+		p.collected = nil
+		p.stuckUntil = time.Time{}
+		p.effects = nil
+		p.Coins = 0
+		p.Caught = 0
+		p.Eliminated = false
+		p.invalidMoves = 0
+		p.HintsUsed = 0
+		p.lastHint = time.Time{}
+		p.moved = 0
+		p.splits = nil
+		p.Assisted = false
+		p.assisting = false
+		p.joinedAt = clock()
+	}
+	for _, p := range clients {
+		sendJoin(p, assignSpawn(p))
 	}
 	mu.Unlock()
-	generateMaze()
-	startTime = time.Now()
+	saveProfiles(played)
+	journalRoundStart()
+	replayRoundStart()
+	webhookRoundStart()
+	libraryRoundStart()
+	refreshPodium()
+	mazeChanged()
 	broadcast()
+	return nil
 }
 
 func readLine(reader *bufio.Reader) string {
@@ -222,48 +674,266 @@ func readLine(reader *bufio.Reader) string {
 
 func setupGameHandlers(mux *http.ServeMux) {
 	mux.HandleFunc("/maze", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		json.NewEncoder(w).Encode(maze)
+		if r.Method == http.MethodPost {
+			requireAdmin(handleMazeUpload)(w, r)
+			return
+		}
+		handleMaze(w, r)
+	})
+	mux.HandleFunc("/maze.png", handleMazePNG)
+	mux.HandleFunc("/maze.svg", handleMazeSVG)
+	mux.HandleFunc("/maze.txt", handleMazeText)
+	mux.HandleFunc("/maze.pdf", handleMazePDF)
+	mux.HandleFunc("/validate", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			// Checking a candidate costs as much as uploading it.
+			requireAdmin(handleValidate)(w, r)
+			return
+		}
+		handleValidate(w, r)
+	})
+	mux.HandleFunc("/mazes", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			requireAdmin(handleMazeImport)(w, r)
+			return
+		}
+		handleMazes(w, r)
 	})
+	mux.HandleFunc("/mazes/{code}", handleLibraryMaze)
 	mux.HandleFunc("/info", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		json.NewEncoder(w).Encode(MazeInfo{GoalX: goalX, GoalY: goalY, Width: mazeWidth, Height: mazeHeight})
+		// One snapshot of the round, so a reset halfway through cannot mix
+		// two mazes. The layout slices are replaced, never changed in place.
+		mu.Lock()
+		info := MazeInfo{GoalX: goalX, GoalY: goalY, Width: mazeWidth, Height: mazeHeight, StartX: startX, StartY: startY, Spawns: spawnPoints, Seed: mazeSeed, Custom: mazeSeed == 0, Metrics: mazeMetrics}
+		if *flagDaily {
+			info.Daily = dailyDay
+		}
+		info.Checks = checkpoints
+		info.Laps = lapCount()
+		info.Goals = goals
+		info.Portals = portals
+		info.Code = mazeCode
+		info.Items = append([]Item(nil), items...)
+		if settings != (GameSettings{}) {
+			rules := settings
+			info.Rules = &rules
+		}
+		mu.Unlock()
+		info.Markers = currentMarkers()
+		info.Collide = *flagCollisions
+		info.MudWait = flagMudDelay.Milliseconds()
+		info.Private = privateGame()
+		info.Login = jwtEnabled()
+		info.Rev = currentMazeRevision()
+		for _, t := range teamDefs[:teamCount()] {
+			info.Teams = append(info.Teams, t.Name)
+		}
+		json.NewEncoder(w).Encode(info)
 	})
-	mux.Handle("/ws", websocket.Handler(handleWS))
-	mux.HandleFunc("/reset", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		resetGame()
-		json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		// Integrations may identify themselves with an API key; a wrong
+		// key is refused rather than silently treated as a guest.
+		if credential(r) != "" {
+			name, ok := authorize(r, scopeReadState)
+			if !ok {
+				http.Error(w, "invalid API key", http.StatusUnauthorized)
+				return
+			}
+			log.Printf("WebSocket client authenticated as %q", name)
+		}
+		if refuseUnverified(w, r) || refuseBanned(w, r) {
+			return
+		}
+		if !mayJoin(r) {
+			http.Error(w, "this game is private, a password is needed to join", http.StatusUnauthorized)
+			return
+		}
+		if refuseFull(w) {
+			return
+		}
+		ws := wsServer(handleWS)
+		ws.ServeHTTP(w, r)
 	})
+	mux.HandleFunc("POST /registry/heartbeat", handleHeartbeat)
+	mux.HandleFunc("/registry/servers", handleRegistryServers)
+	mux.HandleFunc("POST /session", handlePollSession)
+	mux.HandleFunc("POST /move", handlePollMove)
+	mux.HandleFunc("GET /state", handleState)
+	mux.HandleFunc("/results/recovered", handleRecoveredResults)
+	mux.HandleFunc("/daily", handleDaily)
+	mux.HandleFunc("/cards/", handleCard)
+	mux.HandleFunc("/markers", handleMarkers)
+	mux.HandleFunc("/clumsiness", handleClumsiness)
+	mux.HandleFunc("/protocol", handleProtocol)
+	mux.HandleFunc("GET /stats", handleStats)
+	mux.HandleFunc("/version", handleVersion)
+	mux.HandleFunc("/config", handleConfig)
+	mux.HandleFunc("/players/{id}/stats", handlePlayerStats)
+	mux.HandleFunc("GET /practice", handleNewPractice)
+	mux.HandleFunc("POST /practice/{id}", handleFinishPractice)
+	mux.HandleFunc("GET /practice/{id}/ghost", handlePracticeGhost)
+	mux.HandleFunc("/leaderboard", handleLeaderboard)
+	mux.HandleFunc("GET /halloffame", handleHallOfFame)
+	mux.HandleFunc("/leaderboard/{period}", handlePeriodLeaderboard)
+	mux.HandleFunc("/leaderboard/{period}/{key}", handlePeriodLeaderboard)
+	mux.HandleFunc("/leaderboard/{period}/archive", handleLeaderboardArchive)
+	mux.HandleFunc("/matches", handleMatches)
+	mux.HandleFunc("/events", handleEvents)
+	mux.HandleFunc("/director", handleDirector)
+	mux.HandleFunc("/replays", handleReplays)
+	mux.HandleFunc("/replays/{id}", handleReplay)
+	mux.HandleFunc("/replays/{id}/maze", handleReplayMaze)
+	mux.HandleFunc("/replays/{id}/info", handleReplayInfo)
+	mux.HandleFunc("/replays/{id}/ws", handleReplayWS)
+	mux.HandleFunc("GET /replays/{id}/animation", handleReplayAnimation)
+	mux.HandleFunc("/matches/{id}", handleMatch)
+	mux.HandleFunc("/admin/keys", requireAdmin(handleAPIKeys))
+	mux.HandleFunc("/admin/webhooks", requireAdmin(handleWebhooks))
+	mux.HandleFunc("/admin/audit", requireAdmin(handleAudit))
+	mux.HandleFunc("/admin/connections", requireAdmin(handleConnections))
+	mux.HandleFunc("/admin/bans", requireAdmin(handleBans))
+	mux.HandleFunc("/invites", requireScope(scopeManageRooms, handleInvites))
+	mux.HandleFunc("POST /discord/interactions", handleDiscordInteractions)
+	mux.HandleFunc("/reset", requireScopeIfConfigured(scopeManageRooms, func(w http.ResponseWriter, r *http.Request) {
+		var seed int64
+		if v := r.URL.Query().Get("seed"); v != "" {
+			var err error
+			if seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+				http.Error(w, "invalid seed", http.StatusBadRequest)
+				return
+			}
+		}
+		difficulty := r.URL.Query().Get("difficulty")
+		code := r.URL.Query().Get("maze")
+		if code != "" {
+			m, err := libraryMaze(code)
+			if err != nil {
+				writeMazeError(w, err, http.StatusNotFound)
+				return
+			}
+			if err := setPendingMaze(m); err != nil {
+				writeMazeError(w, err, http.StatusConflict)
+				return
+			}
+		}
+		err := resetGame(seed, difficulty)
+		audit(r, "reset", map[string]any{"seed": seed, "difficulty": difficulty, "maze": code, "ok": err == nil})
+		if err != nil {
+			writeMazeError(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+	}))
 }
 
 func setupWebsiteHandlers(mux *http.ServeMux, gamePort string) {
+	mux.HandleFunc("/discover", handleDiscover)
+	mux.HandleFunc("/join.png", handleJoinPNG)
+	mux.HandleFunc("/servers", handleServers)
+	mux.HandleFunc("GET /i18n", handleLanguages)
+	mux.HandleFunc("GET /i18n/{file}", handleBundle)
+	mux.HandleFunc("GET /auth/{provider}", handleLogin)
+	mux.HandleFunc("GET /auth/{provider}/callback", handleLoginCallback)
+	mux.HandleFunc("GET /auth/logout", handleLogout)
+	assets := webAssets()
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		// Inject the game port if it differs, or if we want to be explicit
-		// We replace the placeholder <!--SERVER_CONFIG--> with a small script
-		configScript := ""
-		if gamePort != "" {
-			configScript = fmt.Sprintf("<script>window.DEFAULT_GAME_PORT='%s';</script>", gamePort)
+		if serveWebAsset(w, r, assets) {
+			return
 		}
-		
-		content := strings.Replace(htmlContent, "<!--SERVER_CONFIG-->", configScript, 1)
-		fmt.Fprint(w, content)
+		cfg := pageConfig(gamePort)
+		if s := sessionOf(r); s != nil {
+			cfg.Account = s.Name
+		}
+		renderPage(w, assets, cfg)
 	})
 }
 
-func main() {
-	logFile, err := os.OpenFile("server.log", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+var (
+	flagNoMenu   = flag.Bool("no-menu", false, "skip the interactive setup menu and use the flags below")
+	flagServe    = flag.String("serve", "both", "what to run without the menu: game, web or both")
+	flagSize     = flag.String("size", "medium", "maze size without the menu: small, medium, large, huge or WIDTHxHEIGHT")
+	flagWebPort  = flag.String("web-port", "8080", "website port without the menu")
+	flagGamePort = flag.String("game-port", "", "game server port without the menu (defaults to -web-port)")
+	flagSeed     = flag.Int64("seed", 0, "seed for the first maze, so it can be regenerated exactly (0 picks a random one)")
+	flagLogFile  = flag.String("log-file", "server.log", "path of the server log file")
+	flagDaemon   = flag.Bool("daemon", false, "detach from the terminal and run in the background (Unix, implies -no-menu)")
+	flagPidFile  = flag.String("pid-file", "", "write the process id to this file (with -daemon)")
+	flagService  = flag.String("service", "", "manage the Windows service: install, uninstall, start or stop")
+)
+
+// serverConfig is what the setup menu (or the flags) decided to run.
+type serverConfig struct {
+	Choice   string // "1" game server only, "2" website only, "3" both
+	GamePort string
+	WebPort  string
+}
+
+func configFromFlags() (serverConfig, error) {
+	var cfg serverConfig
+	switch *flagServe {
+	case "game":
+		cfg.Choice = "1"
+	case "web":
+		cfg.Choice = "2"
+	case "both":
+		cfg.Choice = "3"
+	default:
+		return cfg, fmt.Errorf("unknown -serve value %q (want game, web or both)", *flagServe)
+	}
+	w, h, err := parseMazeSize(*flagSize)
 	if err != nil {
-		fmt.Println("Failed to open log file:", err)
-	} else {
-		defer logFile.Close()
-		multi := io.MultiWriter(os.Stdout, logFile)
-		log.SetOutput(multi)
+		return cfg, err
 	}
-	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
+	mazeWidth, mazeHeight = w, h
+	cfg.WebPort = *flagWebPort
+	cfg.GamePort = *flagGamePort
+	if cfg.GamePort == "" {
+		cfg.GamePort = cfg.WebPort
+	}
+	switch cfg.Choice {
+	case "1":
+		cfg.WebPort = ""
+	case "2":
+		cfg.GamePort = ""
+	}
+	return cfg, nil
+}
 
-	log.Println("=== Starting Maze Runner Server Session ===")
+// parseMazeSize understands the menu presets by name as well as WIDTHxHEIGHT.
+// Even custom dimensions are bumped to the next odd number.
+func parseMazeSize(s string) (int, int, error) {
+	switch strings.ToLower(s) {
+	case "small":
+		return 31, 21, nil
+	case "", "medium":
+		return 71, 41, nil
+	case "large":
+		return 101, 61, nil
+	case "huge":
+		return 151, 81, nil
+	}
+	wStr, hStr, ok := strings.Cut(strings.ToLower(s), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid maze size %q", s)
+	}
+	w, err1 := strconv.Atoi(wStr)
+	h, err2 := strconv.Atoi(hStr)
+	if err1 != nil || err2 != nil {
+		return 0, 0, fmt.Errorf("invalid maze size %q", s)
+	}
+	if err := validateMazeSize(w, h); err != nil {
+		return 0, 0, err
+	}
+	if w%2 == 0 {
+		w++
+	}
+	if h%2 == 0 {
+		h++
+	}
+	return w, h, nil
+}
+
+func runMenu(cfg *serverConfig) {
 	reader := bufio.NewReader(os.Stdin)
 
 	fmt.Println("+------------------------------------------+")
@@ -320,7 +990,8 @@ func main() {
 			hStr := readLine(reader)
 			w, _ := strconv.Atoi(wStr)
 			h, _ := strconv.Atoi(hStr)
-			if w < 11 || h < 11 {
+			if err := validateMazeSize(w, h); err != nil {
+				fmt.Println("Error:", err, "- using default size 71x41")
 				mazeWidth, mazeHeight = 71, 41
 			} else {
 				if w%2==0 { w++ }
@@ -359,14 +1030,211 @@ func main() {
 		if gamePort == "" { gamePort = webPort }
 	}
 
-	log.Printf("Ports configured - Web: %s, Game: %s", webPort, gamePort)
+	cfg.Choice, cfg.GamePort, cfg.WebPort = choice, gamePort, webPort
+}
 
-	if choice != "2" {
-		generateMaze()
+func main() {
+	flag.Parse()
+	if *flagSimulate != "" {
+		os.Exit(runSimulation(*flagSimulate, os.Stdout))
+	}
+
+	if *flagService != "" {
+		if err := controlService(*flagService); err != nil {
+			fmt.Println("Service:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	asService := runningAsService()
+	if *flagDaemon {
+		detached, err := daemonize(*flagPidFile)
+		if err != nil {
+			fmt.Println("Failed to start in background:", err)
+			os.Exit(1)
+		}
+		if detached {
+			return
+		}
+	}
+	background := asService || *flagDaemon
+
+	logPath := *flagLogFile
+	if asService && !filepath.IsAbs(logPath) {
+		// Services start in the system directory, keep the log next to the binary.
+		if exe, err := os.Executable(); err == nil {
+			logPath = filepath.Join(filepath.Dir(exe), logPath)
+		}
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		fmt.Println("Failed to open log file:", err)
+	} else {
+		defer logFile.Close()
+		if background {
+			log.SetOutput(logFile)
+		} else {
+			multi := io.MultiWriter(os.Stdout, logFile)
+			log.SetOutput(multi)
+		}
+	}
+	log.SetFlags(log.Ldate | log.Ltime | log.Lmicroseconds)
+
+	log.Println("=== Starting Maze Runner Server Session ===")
+
+	var cfg serverConfig
+	if background || *flagNoMenu {
+		cfg, err = configFromFlags()
+		if err != nil {
+			log.Fatalf("Invalid configuration: %v", err)
+		}
+		log.Printf("Non-interactive start (mode %s), maze size: %dx%d", cfg.Choice, mazeWidth, mazeHeight)
+	} else {
+		runMenu(&cfg)
+	}
+
+	log.Printf("Ports configured - Web: %s, Game: %s", cfg.WebPort, cfg.GamePort)
+	loadTrustedProxies()
+	loadClientFilters()
+	initSessionKey()
+	loadClientConfig()
+	loadBranding()
+	loadRegenSchedule()
+
+	if cfg.Choice != "2" {
+		initCardKey()
+		if err := loadAPIKeys(*flagAPIKeys); err != nil {
+			log.Printf("Could not load API keys: %v", err)
+		}
+		if *flagInvites != "" {
+			if err := loadInvites(*flagInvites); err != nil {
+				log.Printf("Could not load invites: %v", err)
+			}
+		}
+		if err := loadWebhooks(*flagWebhooks); err != nil {
+			log.Printf("Could not load webhooks: %v", err)
+		}
+		if err := openAuditLog(*flagAuditLog); err != nil {
+			log.Printf("Audit log disabled: %v", err)
+		}
+		go registerDiscordCommands()
+		if err := openJournal(*flagJournal); err != nil {
+			log.Printf("Round journal disabled: %v", err)
+		}
+		if err := openProfiles(*flagProfiles); err != nil {
+			log.Printf("Player accounts disabled: %v", err)
+		}
+		if err := openRedis(*flagRedis); err != nil {
+			log.Fatalf("Could not connect to Redis: %v", err)
+		}
+		go runEventBus()
+		go runFinishes()
+		if err := openLeaderboard(*flagLeaderboard); err != nil {
+			log.Printf("Leaderboard disabled: %v", err)
+		} else if leaderboard != nil {
+			go runSeasonRollover()
+		}
+		if err := openMatches(*flagMatches); err != nil {
+			log.Printf("Match history disabled: %v", err)
+		}
+		if err := openMazeLibrary(*flagMazeLibrary); err != nil {
+			log.Printf("Maze library disabled: %v", err)
+		}
+		if err := openBans(*flagBans); err != nil {
+			log.Printf("Bans disabled: %v", err)
+		}
+		seed := *flagSeed
+		if *flagDaily {
+			now := time.Now()
+			seed, dailyDay = dailySeed(now), dailyDate(now)
+			log.Printf("Daily challenge mode for %s", dailyDay)
+			dailyOnly()
+			go runDailyRollover()
+		}
+		if regenSchedule != nil {
+			go runRegenSchedule()
+		}
+		if *flagQuakeEvery > 0 {
+			go runQuakes()
+		}
+		if *flagItemsEvery > 0 {
+			go runItemSpawner()
+		}
+		custom, err := loadStartupMaze()
+		if err != nil {
+			log.Fatalf("Could not load maze: %v", err)
+		}
+		var c *mazeCandidate
+		if custom != nil {
+			c = customCandidate(custom, settings)
+		} else if c, err = generateWithDifficulty(mazeWidth, mazeHeight, seed, *flagDifficulty, settings); err != nil {
+			log.Fatalf("Maze generation failed: %v", err)
+		}
+		if err := c.verify(); err != nil {
+			log.Fatalf("Refusing to start: %v", err)
+		}
+		mu.Lock()
+		c.install()
+		mu.Unlock()
+	}
+	startTime = clock()
+	if cfg.Choice != "2" {
+		journalRoundStart()
+		replayRoundStart()
+		webhookRoundStart()
+		libraryRoundStart()
+		refreshPodium()
+		mazeChanged()
+		mu.Lock()
+		scatterCoins()
+		placeMinotaur()
+		resetCollapse()
+		loadGhost()
+		mu.Unlock()
+		if coinMode() {
+			go runCoinClock()
+		}
+		if *flagMinotaur > 0 {
+			go runMinotaur()
+		}
+		if collapseMode() {
+			go runCollapse()
+		}
+		if *flagGhost {
+			go runGhost()
+		}
+		if *flagGRPC != "" {
+			go runGRPC(*flagGRPC)
+		}
+		advertiseLAN(cfg.GamePort)
+		go runHeartbeat()
+		if *flagPingInterval > 0 {
+			go runPings()
+		}
+		if *flagDirectorInterval > 0 {
+			go runDirector()
+		}
+		if *flagAssist {
+			go runAssist()
+		}
 	}
-	startTime = time.Now()
 
+	watchHandoff()
+	if asService {
+		if err := runService(cfg); err != nil {
+			log.Fatalf("Service failed: %v", err)
+		}
+		return
+	}
+	runServers(cfg)
+}
+
+// runServers starts the HTTP listeners selected in cfg and blocks until they stop.
+func runServers(cfg serverConfig) {
+	choice, gamePort, webPort := cfg.Choice, cfg.GamePort, cfg.WebPort
 	var wg sync.WaitGroup
+	defer waitForDrain()
+	handoffReady()
 
 	// --- Start Servers ---
 	if choice == "1" {
@@ -374,7 +1242,7 @@ func main() {
 		mux := http.NewServeMux()
 		setupGameHandlers(mux)
 		log.Printf("Starting Game Server on port %s...", gamePort)
-		if err := http.ListenAndServe(":"+gamePort, mux); err != nil {
+		if err := listenAndServe(gamePort, mux); err != nil {
 			log.Fatalf("Game Server failed: %v", err)
 		}
 	} else if choice == "2" {
@@ -383,7 +1251,7 @@ func main() {
 		// No game port known/needed really, user must input manual IP if game server exists elsewhere
 		setupWebsiteHandlers(mux, "") 
 		log.Printf("Starting Website on port %s...", webPort)
-		if err := http.ListenAndServe(":"+webPort, mux); err != nil {
+		if err := listenAndServe(webPort, mux); err != nil {
 			log.Fatalf("Website failed: %v", err)
 		}
 	} else {
@@ -394,11 +1262,14 @@ func main() {
 			setupGameHandlers(mux)
 			setupWebsiteHandlers(mux, gamePort)
 			log.Printf("Starting Combined Server on port %s...", webPort)
-			if err := http.ListenAndServe(":"+webPort, mux); err != nil {
+			if err := listenAndServe(webPort, mux); err != nil {
 				log.Fatalf("Server failed: %v", err)
 			}
 		} else {
 			// Dual Server
+			if unixSocket() != "" {
+				log.Fatalf("A Unix socket serves a single port, use the same game and web port with -bind %s", *flagBind)
+			}
 			wg.Add(2)
 			
 			go func() {
@@ -406,7 +1277,7 @@ func main() {
 				mux := http.NewServeMux()
 				setupGameHandlers(mux)
 				log.Printf("Starting Game Server on port %s...", gamePort)
-				if err := http.ListenAndServe(":"+gamePort, mux); err != nil {
+				if err := listenAndServe(gamePort, mux); err != nil {
 					log.Println("Game Server failed:", err)
 				}
 			}()
@@ -416,7 +1287,7 @@ func main() {
 				mux := http.NewServeMux()
 				setupWebsiteHandlers(mux, gamePort)
 				log.Printf("Starting Website on port %s...", webPort)
-				if err := http.ListenAndServe(":"+webPort, mux); err != nil {
+				if err := listenAndServe(webPort, mux); err != nil {
 					log.Println("Website failed:", err)
 				}
 			}()
@@ -425,314 +1296,3 @@ func main() {
 		}
 	}
 }
-
-const htmlContent = `<!DOCTYPE html>
-<html lang="en">
-<head>
-<meta charset="UTF-8">
-<meta name="viewport" content="width=device-width, initial-scale=1.0">
-<title>Maze Runner</title>
-<!--SERVER_CONFIG-->
-<style>
-*{margin:0;padding:0;box-sizing:border-box}
-body{background:#111;color:#ccc;font-family:system-ui,sans-serif;display:flex;flex-direction:column;align-items:center;justify-content:center;height:100vh;overflow:hidden}
-#ui{background:#1a1a1a;padding:32px 40px;border-radius:16px;text-align:center;border:1px solid #2a2a2a;max-width:420px;width:90vw}
-#ui h1{font-size:2.2rem;font-weight:800;color:#e8e8e8;margin-bottom:4px}
-#ui .sub{color:#666;font-size:.8rem;margin-bottom:24px;letter-spacing:2px}
-.fg{margin-bottom:16px;text-align:left}
-.fg label{display:block;font-size:.7rem;letter-spacing:1px;color:#555;margin-bottom:6px;text-transform:uppercase}
-.fg input[type=text]{width:100%;padding:10px 14px;background:#222;border:1px solid #333;border-radius:8px;color:#eee;font-size:.95rem;outline:none}
-.fg input[type=text]:focus{border-color:#555}
-.srv{margin-bottom:16px;padding:12px;background:#161616;border:1px dashed #2a2a2a;border-radius:8px}
-.srv .hint{font-size:.65rem;color:#444;margin-top:4px}
-.colors{display:grid;grid-template-columns:repeat(8,1fr);gap:6px;margin-bottom:10px}
-.cs{aspect-ratio:1;border-radius:6px;cursor:pointer;border:2px solid transparent;transition:border-color .15s}
-.cs:hover{border-color:rgba(255,255,255,.3)}
-.cs.sel{border-color:#fff}
-.ccr{display:flex;align-items:center;gap:8px;margin-bottom:20px}
-.ccr input[type=color]{width:32px;height:32px;border:none;border-radius:6px;cursor:pointer;background:none;padding:0}
-.ccr input[type=color]::-webkit-color-swatch-wrapper{padding:1px}
-.ccr input[type=color]::-webkit-color-swatch{border-radius:5px;border:none}
-.ccr span{font-size:.75rem;color:#555}
-.cprev{width:32px;height:32px;border-radius:6px;border:1px solid #333}
-#startBtn{width:100%;padding:14px;font-size:1rem;font-weight:700;border:none;border-radius:10px;cursor:pointer;background:#e8e8e8;color:#111;transition:background .2s}
-#startBtn:hover{background:#fff}
-#langBtn{position:absolute;top:12px;right:12px;background:none;border:1px solid #333;color:#888;padding:4px 10px;border-radius:6px;cursor:pointer;font-size:.75rem}
-#langBtn:hover{border-color:#555;color:#ccc}
-canvas{display:none;border-radius:8px}
-#lb{position:fixed;top:16px;right:16px;background:rgba(17,17,17,.92);padding:14px 16px;border-radius:10px;border:1px solid #222;min-width:180px;display:none;font-size:.8rem}
-#lb h3{font-size:.65rem;letter-spacing:2px;color:#555;margin-bottom:8px;text-transform:uppercase}
-.le{display:flex;align-items:center;padding:4px 0;gap:6px;border-bottom:1px solid #1a1a1a}
-.le .rk{width:20px;height:20px;border-radius:4px;display:flex;align-items:center;justify-content:center;font-size:.65rem;font-weight:700;background:#222}
-.le .rk.g{background:#b8860b;color:#fff}.le .rk.s{background:#708090;color:#fff}.le .rk.br{background:#8B4513;color:#fff}
-.ld{width:6px;height:6px;border-radius:50%;flex-shrink:0}
-.fb{font-size:.55rem;background:#2d5a2d;padding:1px 5px;border-radius:3px;color:#8f8;margin-left:auto}
-#tm{position:fixed;top:16px;left:16px;background:rgba(17,17,17,.92);padding:10px 14px;border-radius:10px;border:1px solid #222;display:none;font-size:1.3rem;font-weight:700;color:#888}
-#tm .tl{font-size:.55rem;letter-spacing:1px;color:#444;display:block}
-#pc{position:fixed;bottom:16px;left:16px;font-size:.7rem;color:#444;display:none}
-#go{display:none;position:fixed;inset:0;background:rgba(0,0,0,.92);z-index:1000;flex-direction:column;align-items:center;justify-content:center}
-.goc{background:#1a1a1a;padding:40px;border-radius:16px;text-align:center;max-width:440px;width:90vw;border:1px solid #2a2a2a}
-.goc h2{font-size:2rem;font-weight:800;color:#e8e8e8;margin-bottom:4px}
-.goc .gs{color:#666;font-size:.8rem;margin-bottom:24px}
-.fr{text-align:left;margin-bottom:24px}
-.fre{display:flex;align-items:center;padding:10px 12px;margin-bottom:6px;background:#161616;border-radius:8px;gap:10px}
-.fre:first-child{background:#1a1700;border:1px solid #333000}
-.frn{font-size:1.2rem;font-weight:800;width:32px;color:#444}
-.fre:nth-child(1) .frn{color:#b8860b}
-.fre:nth-child(2) .frn{color:#708090}
-.fre:nth-child(3) .frn{color:#8B4513}
-.frc{width:10px;height:10px;border-radius:50%}
-.frname{flex:1;font-weight:600;font-size:.9rem}
-.frt{font-size:.8rem;color:#555;font-family:monospace}
-#bb{padding:12px 32px;font-size:.9rem;font-weight:600;border:1px solid #333;border-radius:10px;cursor:pointer;background:transparent;color:#ccc;transition:background .2s}
-#bb:hover{background:#222}
-#mc{display:none;position:fixed;bottom:16px;right:16px;z-index:100}
-.dp{display:grid;grid-template-columns:44px 44px 44px;grid-template-rows:44px 44px 44px;gap:3px}
-.dp button{background:#1a1a1a;border:1px solid #2a2a2a;border-radius:8px;color:#888;font-size:1rem;cursor:pointer}
-.dp button:active{background:#333}
-.dp .em{background:none;border:none}
-@media(hover:none)and(pointer:coarse){#mc{display:block!important}}
-</style>
-</head>
-<body>
-<div id="lb"></div>
-<div id="tm"><span class="tl" data-i="time">Time</span><span id="tv">00:00</span></div>
-<div id="pc"></div>
-<div id="ui" style="position:relative">
-    <button id="langBtn" onclick="toggleLang()">DE</button>
-    <h1>MAZE RUNNER</h1>
-    <p class="sub">MULTIPLAYER LABYRINTH</p>
-    <div class="fg"><label data-i="playerName">Player Name</label><input type="text" id="name" data-pi="namePh" placeholder="Enter name..." maxlength="12"></div>
-    <div class="srv"><div class="fg" style="margin:0"><label data-i="serverIp">Server IP (optional)</label><input type="text" id="sip" placeholder="e.g. 192.168.1.100:8080"></div><p class="hint" data-i="serverHint">Leave empty = current server</p></div>
-    <label style="font-size:.65rem;letter-spacing:1px;color:#555;text-transform:uppercase" data-i="color">Color</label>
-    <div class="colors" id="co" style="margin-top:6px"></div>
-    <div class="ccr"><input type="color" id="cc" value="#4a9eff"><span data-i="customColor">custom color</span><div style="flex:1"></div><div class="cprev" id="cp" style="background:#4a9eff"></div></div>
-    <button id="startBtn" onclick="start()" data-i="startGame">START GAME</button>
-</div>
-<canvas id="c"></canvas>
-<div id="mc"><div class="dp">
-    <div class="em"></div><button onclick="move(0,-1)">&#9650;</button><div class="em"></div>
-    <button onclick="move(-1,0)">&#9668;</button><div class="em"></div><button onclick="move(1,0)">&#9658;</button>
-    <div class="em"></div><button onclick="move(0,1)">&#9660;</button><div class="em"></div>
-</div></div>
-<div id="go"><div class="goc">
-    <h2 data-i="gameOver">GAME OVER</h2>
-    <p class="gs" data-i="allFinished">All players reached the goal!</p>
-    <div class="fr" id="frs"></div>
-    <button id="bb" onclick="backToMenu()" data-i="backMenu">Back to Menu</button>
-</div></div>
-
-<script>
-const canvas=document.getElementById('c'),ctx=canvas.getContext('2d');
-let maze=[],ws,myPlayer={x:1,y:1,name:"",color:"#4a9eff",finished:false};
-let gameStartTime=0,timerInterval=null,selColor="#4a9eff",gameEnded=false;
-let mazeCanvas=null,camX=0,camY=0,lastPlayers=[];
-let GOALX=69,GOALY=39,MW=71,MH=41;
-const CELL=14,VIEWW=800,VIEWH=560;
-
-// --- i18n ---
-let lang='en';
-const T={
-    en:{playerName:"Player Name",namePh:"Enter name...",serverIp:"Server IP (optional)",serverHint:"Leave empty = current server",color:"Color",customColor:"custom color",startGame:"START GAME",time:"Time",ranking:"Ranking",goal:"GOAL",players:"Players",atGoal:"at goal",gameOver:"GAME OVER",allFinished:"All players reached the goal!",backMenu:"Back to Menu",connFail:"Connection failed!",error:"Error"},
-    de:{playerName:"Spielername",namePh:"Name eingeben...",serverIp:"Server IP (optional)",serverHint:"Leer lassen = aktueller Server",color:"Farbe",customColor:"eigene Farbe",startGame:"SPIEL STARTEN",time:"Zeit",ranking:"Rangliste",goal:"ZIEL",players:"Spieler",atGoal:"am Ziel",gameOver:"SPIEL VORBEI",allFinished:"Alle Spieler haben das Ziel erreicht!",backMenu:"Zurueck zum Menue",connFail:"Verbindung fehlgeschlagen!",error:"Fehler"}
-};
-function t(k){return T[lang][k]||k}
-function applyLang(){
-    document.querySelectorAll('[data-i]').forEach(el=>{el.textContent=t(el.dataset.i)});
-    document.querySelectorAll('[data-pi]').forEach(el=>{el.placeholder=t(el.dataset.pi)});
-    document.getElementById('langBtn').textContent=lang==='en'?'DE':'EN';
-}
-function toggleLang(){lang=lang==='en'?'de':'en';applyLang()}
-applyLang();
-
-const colors=["#e74c3c","#e67e22","#f1c40f","#2ecc71","#1abc9c","#3498db","#4a9eff","#9b59b6","#e84393","#fd79a8","#00cec9","#6c5ce7","#a29bfe","#ffeaa7","#dfe6e9","#636e72"];
-
-function renderColors(){
-    const c=document.getElementById('co');c.innerHTML='';
-    colors.forEach(cl=>{const d=document.createElement('div');d.className='cs'+(cl===selColor?' sel':'');d.style.background=cl;d.onclick=()=>{selColor=cl;document.getElementById('cp').style.background=cl;document.getElementById('cc').value=cl;renderColors()};c.appendChild(d)})
-}
-document.getElementById('cc').addEventListener('input',e=>{selColor=e.target.value;document.getElementById('cp').style.background=e.target.value;renderColors()});
-renderColors();
-
-function startTimer(){
-    gameStartTime=Date.now();
-    timerInterval=setInterval(()=>{if(gameEnded)return;const s=Math.floor((Date.now()-gameStartTime)/1000);document.getElementById('tv').textContent=String(Math.floor(s/60)).padStart(2,'0')+':'+String(s%60).padStart(2,'0')},1000)
-}
-
-function move(dx,dy){
-    if(myPlayer.finished||gameEnded)return;
-    let nx=myPlayer.x+dx,ny=myPlayer.y+dy;
-    if(maze[ny]&&maze[ny][nx]===0){myPlayer.x=nx;myPlayer.y=ny;if(nx===GOALX&&ny===GOALY)myPlayer.finished=true;send()}
-}
-
-function buildMazeCanvas(){
-    mazeCanvas=document.createElement('canvas');
-    mazeCanvas.width=maze[0].length*CELL;
-    mazeCanvas.height=maze.length*CELL;
-    const mc=mazeCanvas.getContext('2d');
-    for(let y=0;y<maze.length;y++){
-        for(let x=0;x<maze[y].length;x++){
-            if(maze[y][x]===0){
-                mc.fillStyle=(x+y)%2===0?'#1a1a1a':'#1c1c1c';
-                mc.fillRect(x*CELL,y*CELL,CELL,CELL);
-            }
-        }
-    }
-    for(let y=0;y<maze.length;y++){
-        for(let x=0;x<maze[y].length;x++){
-            if(maze[y][x]===1){
-                const px=x*CELL,py=y*CELL;
-                mc.fillStyle='#2a2a2e';mc.fillRect(px,py,CELL,CELL);
-                mc.fillStyle='#353540';mc.fillRect(px,py,CELL,2);
-                mc.fillStyle='#30303a';mc.fillRect(px,py,2,CELL);
-                mc.fillStyle='#1a1a20';mc.fillRect(px,py+CELL-1,CELL,1);mc.fillRect(px+CELL-1,py,1,CELL);
-                if((x*7+y*13)%5===0){mc.fillStyle='rgba(255,255,255,0.03)';mc.fillRect(px+4,py+4,2,2)}
-            }
-        }
-    }
-    const gx=GOALX*CELL,gy=GOALY*CELL;
-    mc.fillStyle='#2a2200';mc.fillRect(gx-CELL,gy-CELL,CELL*3,CELL*3);
-    mc.fillStyle='#3a3200';mc.fillRect(gx,gy,CELL,CELL);
-}
-
-async function start(){
-    myPlayer.name=document.getElementById('name').value||"Runner";
-    myPlayer.color=selColor;myPlayer.x=1;myPlayer.y=1;myPlayer.finished=false;gameEnded=false;
-    const sip=document.getElementById('sip').value.trim();
-    
-    // Auto-detect host logic
-    let host=sip;
-    if(!host) {
-        // Use default game port injected by server if available, otherwise window.location.host
-        if(window.DEFAULT_GAME_PORT) {
-             host = window.location.hostname + ":" + window.DEFAULT_GAME_PORT;
-        } else {
-             host = window.location.host;
-        }
-    }
-    // Fallback if port missing but needed? usually location.host includes port.
-    // If user enters IP without port, adding default 8080 isn't always right if game runs on different port.
-    // But for simplicty:
-    if(host && !host.includes(':') && !window.DEFAULT_GAME_PORT) host=host+':8080';
-    
-    const pr=location.protocol==='https:'?'https':'http';
-    const wpr=location.protocol==='https:'?'wss':'ws';
-    try{
-        const infoRes=await fetch(pr+'://'+host+'/info');
-        const info=await infoRes.json();
-        GOALX=info.goalX;GOALY=info.goalY;MW=info.width;MH=info.height;
-
-        const res=await fetch(pr+'://'+host+'/maze');maze=await res.json();
-        canvas.width=VIEWW;canvas.height=VIEWH;
-        buildMazeCanvas();
-        ws=new WebSocket(wpr+'://'+host+'/ws');
-        ws.onopen=()=>{
-            document.getElementById('ui').style.display='none';
-            canvas.style.display='block';
-            document.getElementById('lb').style.display='block';
-            document.getElementById('tm').style.display='block';
-            document.getElementById('pc').style.display='block';
-            startTimer();send();requestAnimationFrame(gameLoop);
-        };
-        ws.onmessage=e=>{
-            const st=JSON.parse(e.data);lastPlayers=st.players||[];
-            if(st.allFinished&&st.players&&st.players.length>0&&!gameEnded){gameEnded=true;clearInterval(timerInterval);showGameOver(st.players)}
-        };
-        ws.onerror=()=>alert(t('connFail'));
-        ws.onclose=()=>{if(!gameEnded)console.log("Disconnected")};
-        window.onkeydown=e=>{
-            if(myPlayer.finished||gameEnded)return;
-            let dx=0,dy=0;
-            if(e.key==="ArrowUp"||e.key==="w")dy=-1;
-            if(e.key==="ArrowDown"||e.key==="s")dy=1;
-            if(e.key==="ArrowLeft"||e.key==="a")dx=-1;
-            if(e.key==="ArrowRight"||e.key==="d")dx=1;
-            if(dx||dy){e.preventDefault();move(dx,dy)}
-        };
-    }catch(err){alert(t('error')+': '+err)}
-}
-
-function gameLoop(){
-    if(gameEnded)return;
-    draw(lastPlayers);
-    requestAnimationFrame(gameLoop);
-}
-
-function draw(players){
-    const targetCX=myPlayer.x*CELL-VIEWW/2+CELL/2;
-    const targetCY=myPlayer.y*CELL-VIEWH/2+CELL/2;
-    camX+=(targetCX-camX)*0.12;camY+=(targetCY-camY)*0.12;
-    const mw=maze[0].length*CELL,mh=maze.length*CELL;
-    camX=Math.max(0,Math.min(camX,mw-VIEWW));
-    camY=Math.max(0,Math.min(camY,mh-VIEWH));
-
-    ctx.fillStyle="#111";ctx.fillRect(0,0,VIEWW,VIEWH);
-    ctx.drawImage(mazeCanvas,-camX,-camY);
-
-    const gx=GOALX*CELL-camX,gy=GOALY*CELL-camY;
-    const tt=Date.now()/1000;
-    ctx.fillStyle='#888';ctx.fillRect(gx+2,gy-8,2,CELL+8);
-    const wave=Math.sin(tt*3)*2;
-    ctx.fillStyle='#d4aa00';ctx.beginPath();ctx.moveTo(gx+4,gy-8);ctx.lineTo(gx+14+wave,gy-4);ctx.lineTo(gx+4,gy);ctx.fill();
-
-    const sorted=[...players].sort((a,b)=>{
-        if(a.finished&&!b.finished)return -1;if(!a.finished&&b.finished)return 1;
-        if(a.finished&&b.finished)return a.finishRank-b.finishRank;return 0
-    });
-
-    let totalP=players.length,finP=players.filter(p=>p.finished).length;
-    document.getElementById('pc').textContent=totalP+' '+t('players')+' | '+finP+' '+t('atGoal');
-
-    let lh='<h3>'+t('ranking')+'</h3>';
-    sorted.forEach(p=>{
-        const rc=p.finished?(p.finishRank===1?'g':p.finishRank===2?'s':p.finishRank===3?'br':''):'';
-        lh+='<div class="le"><div class="rk '+rc+'">'+(p.finished?p.finishRank:'·')+'</div>';
-        lh+='<div class="ld" style="background:'+p.color+'"></div><span>'+p.name+'</span>';
-        if(p.finished)lh+='<span class="fb">'+t('goal')+'</span>';
-        lh+='</div>';
-    });
-    document.getElementById('lb').innerHTML=lh;
-
-    sorted.forEach(p=>{
-        if(p.finished)return;
-        const px=p.x*CELL-camX,py=p.y*CELL-camY;
-        if(px<-CELL||px>VIEWW+CELL||py<-CELL||py>VIEWH+CELL)return;
-        ctx.fillStyle='rgba(0,0,0,0.4)';ctx.beginPath();ctx.ellipse(px+CELL/2,py+CELL-1,CELL/2-1,3,0,0,Math.PI*2);ctx.fill();
-        ctx.fillStyle=p.color;ctx.beginPath();ctx.arc(px+CELL/2,py+CELL/2,CELL/2-1,0,Math.PI*2);ctx.fill();
-        ctx.fillStyle='rgba(255,255,255,0.2)';ctx.beginPath();ctx.arc(px+CELL/2-1,py+CELL/2-2,CELL/4,0,Math.PI*2);ctx.fill();
-        ctx.font='bold 9px system-ui';
-        const tw=ctx.measureText(p.name).width;
-        ctx.fillStyle='rgba(0,0,0,0.6)';
-        const tagX=px+CELL/2-tw/2-3,tagY=py-12;
-        ctx.fillRect(tagX,tagY,tw+6,12);
-        ctx.fillStyle='#eee';ctx.fillText(p.name,tagX+3,tagY+9);
-    });
-}
-
-function send(){if(ws&&ws.readyState===1)ws.send(JSON.stringify(myPlayer))}
-
-function showGameOver(players){
-    document.getElementById('go').style.display='flex';canvas.style.display='none';
-    const r=document.getElementById('frs');
-    const s=[...players].sort((a,b)=>a.finishRank-b.finishRank);
-    let h='';
-    s.forEach((p,i)=>{
-        const m=(i+1)+'.';
-        const ts=p.finishTime?Math.floor(p.finishTime/60)+':'+String(p.finishTime%60).padStart(2,'0'):'--';
-        h+='<div class="fre"><div class="frn">'+m+'</div><div class="frc" style="background:'+p.color+'"></div><div class="frname">'+p.name+'</div><div class="frt">'+ts+'</div></div>';
-    });
-    r.innerHTML=h;
-    applyLang();
-}
-
-function backToMenu(){
-    if(ws)ws.close();clearInterval(timerInterval);
-    document.getElementById('go').style.display='none';canvas.style.display='none';
-    document.getElementById('lb').style.display='none';document.getElementById('tm').style.display='none';
-    document.getElementById('pc').style.display='none';document.getElementById('ui').style.display='block';
-    myPlayer={x:1,y:1,name:myPlayer.name,color:myPlayer.color,finished:false};gameEnded=false;
-}
-</script>
-</body>
-</html>
-`
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io"
+	"log"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestResetGameConcurrent starts rounds from several goroutines while the
+// maze is read the way moves read it. Run it with -race: a reset must only
+// ever show a whole maze with its own layout.
+func TestResetGameConcurrent(t *testing.T) {
+	log.SetOutput(io.Discard)
+	*flagReplays, *flagDaily, *flagGhost = "", false, false
+	mu.Lock()
+	mazeWidth, mazeHeight = 31, 21
+	mu.Unlock()
+
+	done := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(1)
+	go func() {
+		defer readers.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			mu.Lock()
+			if len(maze) > 0 && (len(goalDist) != len(maze) || distanceToGoal(goalX, goalY) != 0 || distanceToGoal(startX, startY) != mazeMetrics.PathLength) {
+				t.Errorf("maze and layout disagree: goal at distance %d, start at %d, path length %d", distanceToGoal(goalX, goalY), distanceToGoal(startX, startY), mazeMetrics.PathLength)
+			}
+			mu.Unlock()
+		}
+	}()
+
+	var resets sync.WaitGroup
+	for i := range 4 {
+		resets.Add(1)
+		go func() {
+			defer resets.Done()
+			for j := range 5 {
+				if err := resetGame(int64(1+i*5+j), ""); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	resets.Wait()
+	close(done)
+	readers.Wait()
+}
+
+// BenchmarkRoundEnd measures the broadcast that ends a round, with the
+// match saved to SQLite and the replay written out, and reports how long
+// a move had to wait for the game lock meanwhile.
+func BenchmarkRoundEnd(b *testing.B) {
+	log.SetOutput(io.Discard)
+	dir := b.TempDir()
+	*flagReplays, *flagDaily, *flagGhost = filepath.Join(dir, "replays"), false, false
+	if err := openMatches(filepath.Join(dir, "matches.db")); err != nil {
+		b.Fatal(err)
+	}
+	defer func() {
+		matches.Close()
+		matches = nil
+		*flagReplays = ""
+	}()
+	c, err := generateMaze(31, 21, 1, settings)
+	if err != nil {
+		b.Fatal(err)
+	}
+	mu.Lock()
+	c.install()
+	mu.Unlock()
+	conns := joinBenchClients(b, 20, false)
+	defer leaveBenchClients(conns)
+
+	b.ResetTimer()
+	var waited time.Duration
+	for range b.N {
+		b.StopTimer()
+		replayRoundStart()
+		mu.Lock()
+		gameOver = false
+		for _, p := range clients {
+			p.Finished, p.dirty = true, true
+		}
+		mu.Unlock()
+		stop, worst := make(chan struct{}), make(chan time.Duration)
+		go func() {
+			var w time.Duration
+			for {
+				select {
+				case <-stop:
+					worst <- w
+					return
+				default:
+				}
+				t := time.Now()
+				mu.Lock()
+				mu.Unlock()
+				w = max(w, time.Since(t))
+			}
+		}()
+		b.StartTimer()
+		broadcast()
+		b.StopTimer()
+		close(stop)
+		waited += <-worst
+	}
+	b.ReportMetric(float64(waited.Microseconds())/float64(b.N), "µs-lock-wait/op")
+}
@@ -0,0 +1,70 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+)
+
+// daemonChildEnv marks the re-executed background copy of the server.
+const daemonChildEnv = "MAZERUNNER_DAEMON_CHILD"
+
+func runningAsService() bool { return false }
+
+func controlService(cmd string) error {
+	return errors.New("Windows services are not available on this platform, use -daemon instead")
+}
+
+func runService(cfg serverConfig) error {
+	return errors.New("not running as a Windows service")
+}
+
+// daemonize re-executes the binary detached from the terminal. It returns
+// detached=true in the original process, which should then exit. In the
+// background copy it writes the pid file and returns detached=false.
+func daemonize(pidFile string) (bool, error) {
+	if os.Getenv(daemonChildEnv) == "1" {
+		if pidFile == "" {
+			return false, nil
+		}
+		if err := os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+			return false, fmt.Errorf("writing pid file: %w", err)
+		}
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+		go func() {
+			s := <-sig
+			log.Printf("Received %v, shutting down", s)
+			os.Remove(pidFile)
+			os.Exit(0)
+		}()
+		return false, nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return false, err
+	}
+	null, err := os.OpenFile(os.DevNull, os.O_RDWR, 0)
+	if err != nil {
+		return false, err
+	}
+	defer null.Close()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), daemonChildEnv+"=1")
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = null, null, null
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return false, err
+	}
+	fmt.Printf("Maze Runner is running in the background (pid %d)\n", cmd.Process.Pid)
+	return true, nil
+}
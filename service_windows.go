@@ -0,0 +1,132 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const serviceName = "MazeRunner"
+
+func runningAsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+func daemonize(pidFile string) (bool, error) {
+	return false, errors.New("-daemon is not supported on Windows, use -service install instead")
+}
+
+// serviceArgs are the flags the service is registered with: everything we
+// were started with except -service itself.
+func serviceArgs() []string {
+	var args []string
+	in := os.Args[1:]
+	for i := 0; i < len(in); i++ {
+		a := in[i]
+		name := strings.TrimLeft(a, "-")
+		if name == "service" {
+			i++ // skip the separate value
+			continue
+		}
+		if strings.HasPrefix(name, "service=") {
+			continue
+		}
+		args = append(args, a)
+	}
+	return append(args, "-no-menu")
+}
+
+func controlService(cmd string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+
+	switch cmd {
+	case "install":
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		if s, err := m.OpenService(serviceName); err == nil {
+			s.Close()
+			return fmt.Errorf("service %s already exists", serviceName)
+		}
+		s, err := m.CreateService(serviceName, exe, mgr.Config{
+			DisplayName: "Maze Runner Server",
+			Description: "Multiplayer maze game server",
+			StartType:   mgr.StartAutomatic,
+		}, serviceArgs()...)
+		if err != nil {
+			return err
+		}
+		defer s.Close()
+		fmt.Printf("Service %s installed\n", serviceName)
+	case "uninstall":
+		s, err := m.OpenService(serviceName)
+		if err != nil {
+			return fmt.Errorf("service %s is not installed", serviceName)
+		}
+		defer s.Close()
+		if err := s.Delete(); err != nil {
+			return err
+		}
+		fmt.Printf("Service %s removed\n", serviceName)
+	case "start":
+		s, err := m.OpenService(serviceName)
+		if err != nil {
+			return fmt.Errorf("service %s is not installed", serviceName)
+		}
+		defer s.Close()
+		return s.Start()
+	case "stop":
+		s, err := m.OpenService(serviceName)
+		if err != nil {
+			return fmt.Errorf("service %s is not installed", serviceName)
+		}
+		defer s.Close()
+		_, err = s.Control(svc.Stop)
+		return err
+	default:
+		return fmt.Errorf("unknown service command %q (want install, uninstall, start or stop)", cmd)
+	}
+	return nil
+}
+
+type mazeService struct {
+	cfg serverConfig
+}
+
+func (s *mazeService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	go runServers(s.cfg)
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for req := range requests {
+		switch req.Cmd {
+		case svc.Interrogate:
+			status <- req.CurrentStatus
+			// Windows wants a second answer shortly after the first.
+			time.Sleep(100 * time.Millisecond)
+			status <- req.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			log.Println("Service stop requested")
+			status <- svc.Status{State: svc.StopPending}
+			return false, 0
+		}
+	}
+	return false, 0
+}
+
+func runService(cfg serverConfig) error {
+	return svc.Run(serviceName, &mazeService{cfg: cfg})
+}
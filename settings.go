@@ -0,0 +1,149 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/websocket"
+)
+
+// The host sets the rules of the game between rounds, as a room's creator
+// would set the room's if the server ran several games. Settings apply from
+// the next round on; what is left out stays as the flags say.
+
+// GameSettings are the rules the host chose.
+type GameSettings struct {
+	Size        string `json:"size,omitempty"`        // small, medium, large or huge
+	Seed        int64  `json:"seed,omitempty"`        // the same maze every round, 0 for a new one
+	Difficulty  string `json:"difficulty,omitempty"`  // easy, medium or hard
+	Laps        int    `json:"laps,omitempty"`        // win condition: laps to run
+	Goals       int    `json:"goals,omitempty"`       // win condition: goals to collect
+	Checkpoints *int   `json:"checkpoints,omitempty"` // checkpoints to pass, 0 for none
+	MaxPlayers  int    `json:"maxPlayers,omitempty"`  // players at once, 0 for any number
+}
+
+// Upper bounds of what a host may ask for.
+const (
+	maxSettingLaps        = 10
+	maxSettingGoals       = 10
+	maxSettingCheckpoints = 10
+)
+
+var (
+	settings     GameSettings // the running round's, guarded by mu
+	nextSettings GameSettings // the next round's, guarded by mu
+)
+
+// presetMazeSize is parseMazeSize limited to the menu presets, for sizes
+// players rather than the operator ask for.
+func presetMazeSize(s string) (int, int, error) {
+	if !slices.Contains(hallOfFameSizes, strings.ToLower(s)) {
+		return 0, 0, fmt.Errorf("unknown maze size %q (want %s)", s, strings.Join(hallOfFameSizes, ", "))
+	}
+	return parseMazeSize(s)
+}
+
+func (s GameSettings) validate() error {
+	if *flagDaily && (s.Size != "" || s.Seed != 0 || s.Difficulty != "") {
+		return errDailyMaze
+	}
+	if s.Size != "" {
+		if _, _, err := presetMazeSize(s.Size); err != nil {
+			return err
+		}
+	}
+	if _, ok := difficultyTargets[s.Difficulty]; s.Difficulty != "" && !ok {
+		return fmt.Errorf("unknown difficulty %q (want easy, medium or hard)", s.Difficulty)
+	}
+	switch {
+	case s.Seed < 0:
+		return fmt.Errorf("seed must not be negative")
+	case s.Laps < 0 || s.Laps > maxSettingLaps:
+		return fmt.Errorf("laps must be between 1 and %d", maxSettingLaps)
+	case s.Goals < 0 || s.Goals > maxSettingGoals:
+		return fmt.Errorf("goals must be between 1 and %d", maxSettingGoals)
+	case s.Checkpoints != nil && (*s.Checkpoints < 0 || *s.Checkpoints > maxSettingCheckpoints):
+		return fmt.Errorf("checkpoints must be between 0 and %d", maxSettingCheckpoints)
+	case s.MaxPlayers < 0:
+		return fmt.Errorf("maxPlayers must not be negative")
+	}
+	return nil
+}
+
+// raceLaps is how many laps the running round has.
+func raceLaps() int {
+	return cmp.Or(settings.Laps, *flagLaps)
+}
+
+// raceGoals is how many goals a round played with s has.
+func raceGoals(s GameSettings) int {
+	return cmp.Or(s.Goals, *flagGoals)
+}
+
+// raceCheckpoints is how many checkpoints a round played with s has.
+func raceCheckpoints(s GameSettings) int {
+	if s.Checkpoints != nil {
+		return *s.Checkpoints
+	}
+	return *flagCheckpoints
+}
+
+// raceDifficulty is the difficulty new mazes are generated for.
+func raceDifficulty(s GameSettings) string {
+	return cmp.Or(s.Difficulty, *flagDifficulty)
+}
+
+// gameFull reports whether the host's player cap is reached.
+func gameFull() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return settings.MaxPlayers > 0 && len(clients) >= settings.MaxPlayers
+}
+
+// refuseFull answers a join with 503 when the game is full.
+func refuseFull(w http.ResponseWriter) bool {
+	if !gameFull() {
+		return false
+	}
+	http.Error(w, "the game is full", http.StatusServiceUnavailable)
+	return true
+}
+
+// settingsMessage answers a host's settings message with the settings the
+// next round will use.
+type settingsMessage struct {
+	Type     string       `json:"type"` // "settings"
+	Settings GameSettings `json:"settings"`
+}
+
+// hostSettings handles {"type":"settings","settings":{...}}: the host sets
+// the rules of the rounds to come. The new settings replace the old ones
+// as a whole.
+func hostSettings(ws *websocket.Conn, p *Player, msg clientMessage) {
+	var s GameSettings
+	if msg.Settings != nil {
+		s = *msg.Settings
+	}
+	mu.Lock()
+	reason, name := hostError(ws), p.Name
+	mu.Unlock()
+	if reason == "" {
+		if err := s.validate(); err != nil {
+			reason = err.Error()
+		}
+	}
+	if reason != "" {
+		refuseHost(p, msg.Type, reason)
+		return
+	}
+	mu.Lock()
+	nextSettings = s
+	mu.Unlock()
+	recordAudit(AuditEntry{Actor: "host:" + name, Action: "settings", Remote: ws.Request().RemoteAddr, Params: map[string]any{"settings": s}})
+	data, _ := json.Marshal(settingsMessage{Type: "settings", Settings: s})
+	p.send(data)
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var flagSimulate = flag.String("simulate", "", "run the scripted game in this JSON file on a fake clock, print the state after every step as JSON lines and exit (for regression tests of the rules)")
+
+// clock is where the game rules get the time. Simulations replace it, so
+// cooldowns, mud and finish times depend only on the script.
+var clock = time.Now
+
+// simEpoch is the fake time a simulation starts at.
+var simEpoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// simTimeout bounds the wait for the game's answer to one step.
+const simTimeout = 5 * time.Second
+
+// A simScript is a game played without a network: the same seed and steps
+// always give the same states.
+type simScript struct {
+	Seed  int64     `json:"seed"`
+	Size  string    `json:"size"` // as -size, small if empty
+	Steps []simStep `json:"steps"`
+}
+
+// A simStep is one scripted input.
+type simStep struct {
+	At     int64  `json:"at"`     // milliseconds since the start, never decreasing
+	Do     string `json:"do"`     // join, move, hint, leave or reset
+	Player string `json:"player"` // name of the player acting (not for reset)
+	Dir    string `json:"dir"`    // move: up, down, left or right
+	X      *int   `json:"x"`      // move: target cell instead of dir
+	Y      *int   `json:"y"`
+	Seed   int64  `json:"seed"` // reset: seed of the next maze
+	Team   string `json:"team"` // join: team wanted in team mode
+}
+
+// simResult is one output line.
+type simResult struct {
+	Step     int               `json:"step"`
+	At       int64             `json:"at"`
+	Do       string            `json:"do"`
+	Player   string            `json:"player,omitempty"`
+	Messages []json.RawMessage `json:"messages,omitempty"` // typed messages the player got for the step
+	State    GameState         `json:"state"`
+	Error    string            `json:"error,omitempty"`
+}
+
+var simDirs = map[string]point{"up": {0, -1}, "down": {0, 1}, "left": {-1, 0}, "right": {1, 0}}
+
+// simPlayer is a scripted player on an in-memory connection.
+type simPlayer struct {
+	name   string
+	team   string
+	ws     *websocket.Conn
+	frames chan []byte
+	pos    point
+}
+
+// listen reads everything the game sends. It must never stop reading while
+// the connection is open, or the player's send queue fills up.
+func (sp *simPlayer) listen() {
+	defer close(sp.frames)
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(sp.ws, &data); err != nil {
+			return
+		}
+		sp.frames <- data
+	}
+}
+
+// await collects typed messages until one of type want.
+func (sp *simPlayer) await(want string) ([]json.RawMessage, error) {
+	var typed []json.RawMessage
+	timeout := time.After(simTimeout)
+	for {
+		select {
+		case data, ok := <-sp.frames:
+			if !ok {
+				return typed, errors.New("connection closed")
+			}
+			typ := sp.note(data)
+			if typ == "" {
+				continue
+			}
+			typed = append(typed, data)
+			if typ == want {
+				return typed, nil
+			}
+		case <-timeout:
+			return typed, errors.New("no answer from the game")
+		}
+	}
+}
+
+// drain returns the typed messages already received, without waiting.
+func (sp *simPlayer) drain() []json.RawMessage {
+	var typed []json.RawMessage
+	for {
+		select {
+		case data, ok := <-sp.frames:
+			if !ok {
+				return typed
+			}
+			if typ := sp.note(data); typ != "" {
+				typed = append(typed, data)
+			}
+		default:
+			return typed
+		}
+	}
+}
+
+// note follows the player's position and returns the message type, empty
+// for states and the welcome, which names the server build and would make
+// results differ between versions.
+func (sp *simPlayer) note(data []byte) string {
+	var m struct {
+		Type string `json:"type"`
+		X, Y int
+	}
+	json.Unmarshal(data, &m)
+	switch m.Type {
+	case "welcome", "state":
+		return ""
+	case "join", "position", "lap":
+		sp.pos = point{m.X, m.Y}
+	}
+	return m.Type
+}
+
+func (sp *simPlayer) send(x, y int) error {
+	return websocket.JSON.Send(sp.ws, clientMessage{X: x, Y: y, Name: sp.name, Color: "#888888", Team: sp.team})
+}
+
+// currentState is the last state broadcast. Each broadcast stores a new
+// slice, so steps tell a fresh one from an equal one by its address.
+func currentState() []byte {
+	mu.Lock()
+	defer mu.Unlock()
+	return lastState
+}
+
+// waitState waits for a broadcast after before, the state when the step
+// began. Reading the frames is no help: the last one may still be on its way
+// from the connection to the channel.
+func waitState(before []byte) error {
+	deadline := time.Now().Add(simTimeout)
+	for {
+		now := currentState()
+		if len(now) > 0 && (len(before) == 0 || &now[0] != &before[0]) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return errors.New("no answer from the game")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// runSimulation plays the script at path and writes the results to out. It
+// returns the exit code.
+func runSimulation(path string, out io.Writer) int {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		var script simScript
+		if err = json.Unmarshal(data, &script); err == nil {
+			err = simulate(script, out)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Simulation:", err)
+		return 1
+	}
+	return 0
+}
+
+func simulate(script simScript, out io.Writer) error {
+	// Nothing of a simulation is kept, and it is alone on its clock.
+	log.SetOutput(io.Discard)
+	*flagReplays, *flagDaily, *flagGhost = "", false, false
+	var clockMu sync.Mutex
+	now := simEpoch
+	clock = func() time.Time {
+		clockMu.Lock()
+		defer clockMu.Unlock()
+		return now
+	}
+
+	size := script.Size
+	if size == "" {
+		size = "small"
+	}
+	w, h, err := parseMazeSize(size)
+	if err != nil {
+		return err
+	}
+	if script.Seed == 0 {
+		return errors.New("the script needs a seed")
+	}
+	// States count from zero, also after an earlier simulation in the same
+	// process.
+	mu.Lock()
+	mazeWidth, mazeHeight = w, h
+	stateGen = 0
+	mu.Unlock()
+	if err := resetGame(script.Seed, ""); err != nil {
+		return err
+	}
+
+	players := make(map[string]*simPlayer)
+	enc := json.NewEncoder(out)
+	for i, step := range script.Steps {
+		at := simEpoch.Add(time.Duration(step.At) * time.Millisecond)
+		clockMu.Lock()
+		if at.Before(now) {
+			clockMu.Unlock()
+			return fmt.Errorf("step %d: at %d is before the previous step", i, step.At)
+		}
+		now = at
+		clockMu.Unlock()
+
+		res := simResult{Step: i, At: step.At, Do: step.Do, Player: step.Player}
+		if err := simStepRun(step, players, &res); err != nil {
+			res.Error = err.Error()
+		}
+		// Messages the step caused may still be queued for some players.
+		flushSendQueues(simTimeout)
+		mu.Lock()
+		json.Unmarshal(lastState, &res.State)
+		mu.Unlock()
+		for name, sp := range players {
+			if typed := sp.drain(); name == step.Player {
+				res.Messages = append(res.Messages, typed...)
+			}
+		}
+		sort.Slice(res.State.Players, func(i, j int) bool { return res.State.Players[i].Name < res.State.Players[j].Name })
+		if err := enc.Encode(res); err != nil {
+			return err
+		}
+	}
+	for _, sp := range players {
+		sp.ws.Close()
+	}
+	// Let the game see everyone leave, so the next simulation starts on an
+	// empty game.
+	for deadline := time.Now().Add(simTimeout); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		mu.Lock()
+		n := len(clients)
+		mu.Unlock()
+		if n == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+func simStepRun(step simStep, players map[string]*simPlayer, res *simResult) error {
+	if step.Do == "reset" {
+		return resetGame(step.Seed, "")
+	}
+	if step.Player == "" {
+		return fmt.Errorf("%q needs a player", step.Do)
+	}
+	sp := players[step.Player]
+	if sp == nil && step.Do != "join" {
+		return fmt.Errorf("%s has not joined", step.Player)
+	}
+	before := currentState()
+	var err error
+	switch step.Do {
+	case "join":
+		if sp != nil {
+			return fmt.Errorf("%s has already joined", step.Player)
+		}
+		sp = &simPlayer{name: step.Player, team: step.Team, frames: make(chan []byte, 4096)}
+		if sp.ws, err = dialPipe("sim:"+step.Player, nil); err != nil {
+			return err
+		}
+		go sp.listen()
+		players[step.Player] = sp
+		if err = waitState(before); err != nil {
+			return err
+		}
+		if err = websocket.JSON.Send(sp.ws, clientMessage{Type: "hello", Protocol: protocolVersion, Caps: []string{capEvents, capPrivate}}); err != nil {
+			return err
+		}
+		if res.Messages, err = sp.await("join"); err != nil {
+			return err
+		}
+		before = currentState()
+		if err = sp.send(sp.pos.X, sp.pos.Y); err != nil {
+			return err
+		}
+		err = waitState(before)
+	case "move":
+		to := sp.pos
+		if d, ok := simDirs[step.Dir]; ok {
+			to = point{sp.pos.X + d.X, sp.pos.Y + d.Y}
+		} else if step.X != nil && step.Y != nil {
+			to = point{*step.X, *step.Y}
+		} else {
+			return errors.New("move needs a dir or x and y")
+		}
+		sp.pos = to
+		if err = sp.send(to.X, to.Y); err != nil {
+			return err
+		}
+		err = waitState(before)
+	case "hint":
+		if err = websocket.JSON.Send(sp.ws, clientMessage{Type: "hint"}); err != nil {
+			return err
+		}
+		res.Messages, err = sp.await("hint")
+	case "leave":
+		delete(players, step.Player)
+		sp.ws.Close()
+		for range sp.frames {
+		}
+		// The game broadcasts a state without the player once it notices
+		// the closed connection.
+		err = waitState(before)
+	default:
+		return fmt.Errorf("unknown step %q", step.Do)
+	}
+	return err
+}
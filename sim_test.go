@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestSimulate plays testdata/simulate.json and compares the states with
+// the ones saved in testdata/simulate.golden. After a deliberate change of
+// the rules, go test -run Simulate -update saves the new ones.
+func TestSimulate(t *testing.T) {
+	data, err := os.ReadFile("testdata/simulate.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var script simScript
+	if err := json.Unmarshal(data, &script); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := simulate(script, &out); err != nil {
+		t.Fatal(err)
+	}
+	const golden = "testdata/simulate.golden"
+	if *update {
+		if err := os.WriteFile(golden, out.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, wantLines := bytes.Split(out.Bytes(), []byte("\n")), bytes.Split(want, []byte("\n"))
+	if len(got) != len(wantLines) {
+		t.Errorf("got %d lines, want %d", len(got), len(wantLines))
+	}
+	for i := range min(len(got), len(wantLines)) {
+		if !bytes.Equal(got[i], wantLines[i]) {
+			t.Errorf("line %d differs\n got: %s\nwant: %s", i+1, got[i], wantLines[i])
+		}
+	}
+}
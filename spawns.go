@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+)
+
+var flagSpawns = flag.Int("spawns", 1, "number of distinct spawn points; players are spread over them round-robin")
+
+var (
+	spawnPoints []point // spawnPoints[0] is always the maze start
+	nextSpawn   int     // round-robin cursor, reset every round
+)
+
+// spawnTolerance is how far, as a fraction of the start's distance to the
+// goal, a spawn may be closer to the goal than the start.
+const spawnTolerance = 0.1
+
+// pickSpawns chooses n floor cells that are about as far from the goal as
+// the start and as far from each other as possible. dist is the walking
+// distance of every cell to the goal. If the maze has too few such cells
+// the tolerance is widened until n fit, or fewer spawns are returned.
+func pickSpawns(grid [][]int, dist [][]int, start point, n int) []point {
+	spawns := []point{start}
+	if n <= 1 || !isOpen(grid, start.X, start.Y) {
+		return spawns
+	}
+	d := dist[start.Y][start.X]
+	var cands []point
+	for tol := spawnTolerance; tol <= 1 && len(cands) < n-1; tol *= 2 {
+		cands = cands[:0]
+		lo := d - int(float64(d)*tol)
+		for y := range dist {
+			for x, v := range dist[y] {
+				if v >= lo && v <= d && (x != start.X || y != start.Y) {
+					cands = append(cands, point{x, y})
+				}
+			}
+		}
+	}
+	// Greedy farthest-point selection keeps the spawns spread over the maze.
+	nearest := make([]int, len(cands))
+	for i, c := range cands {
+		nearest[i] = abs(c.X-start.X) + abs(c.Y-start.Y)
+	}
+	for len(spawns) < n {
+		best := -1
+		for i, v := range nearest {
+			if v > 0 && (best < 0 || v > nearest[best]) {
+				best = i
+			}
+		}
+		if best < 0 {
+			break
+		}
+		s := cands[best]
+		spawns = append(spawns, s)
+		for i, c := range cands {
+			if m := abs(c.X-s.X) + abs(c.Y-s.Y); m < nearest[i] {
+				nearest[i] = m
+			}
+		}
+	}
+	return spawns
+}
+
+// assignSpawn moves p to the next spawn point and returns its index.
+// Clients that cannot receive private frames would not learn where they
+// are, so they always start at the maze start. Caller holds mu.
+func assignSpawn(p *Player) int {
+	i := 0
+	if p.caps.has(capPrivate) && len(spawnPoints) > 1 {
+		i = nextSpawn % len(spawnPoints)
+		nextSpawn++
+	}
+	p.X, p.Y = spawnPoints[i].X, spawnPoints[i].Y
+	p.spawn = i
+	return i
+}
+
+// sendJoin tells a player where it spawns this round. Caller holds mu.
+func sendJoin(p *Player, spawn int) {
+	if !p.caps.has(capPrivate) {
+		return
+	}
+	loadPersonalBest(p)
+	msg := map[string]any{"type": "join", "x": p.X, "y": p.Y, "spawn": spawn, "distance": distanceToGoal(p.X, p.Y)}
+	if p.best > 0 {
+		msg["best"] = p.best
+	}
+	data, _ := json.Marshal(msg)
+	p.send(data)
+}
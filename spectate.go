@@ -0,0 +1,87 @@
+package main
+
+import (
+	"flag"
+	"time"
+)
+
+var flagSpectatorDelay = flag.Duration("spectator-delay", 0, "hold what spectators see (/events, the event stream, gRPC WatchState and /state without a session) this far behind the game, so a stream cannot give routes away (0 shows it live)")
+
+// spectatorQueue caps how many messages a delayed listener holds back; the
+// oldest are dropped beyond that, as when a listener falls behind.
+const spectatorQueue = 4096
+
+// delayed forwards what arrives on in once it is d old, in order, until
+// done is closed.
+func delayed(in <-chan []byte, d time.Duration, done <-chan struct{}) chan []byte {
+	out := make(chan []byte, cap(in))
+	go func() {
+		type held struct {
+			due time.Time
+			msg []byte
+		}
+		var queue []held
+		timer := time.NewTimer(d)
+		timer.Stop()
+		for {
+			var due <-chan time.Time
+			if len(queue) > 0 {
+				timer.Reset(time.Until(queue[0].due))
+				due = timer.C
+			}
+			select {
+			case msg := <-in:
+				if len(queue) == spectatorQueue {
+					queue = queue[1:]
+				}
+				queue = append(queue, held{time.Now().Add(d), msg})
+			case <-due:
+				for len(queue) > 0 && !time.Now().Before(queue[0].due) {
+					select {
+					case out <- queue[0].msg:
+					default: // a slow listener misses events, as on the bus
+					}
+					queue = queue[1:]
+				}
+			case <-done:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// A pastFeed holds what was sent within the last -spectator-delay, and the
+// message before, for spectators asking for the latest one they may see.
+type pastFeed []pastMessage
+
+type pastMessage struct {
+	at   time.Time
+	data []byte
+}
+
+// keep remembers a message; without a delay only the latest one is kept.
+func (f *pastFeed) keep(data []byte) {
+	now := time.Now()
+	*f = append(*f, pastMessage{now, data})
+	for len(*f) > 1 && now.Sub((*f)[1].at) >= *flagSpectatorDelay {
+		*f = (*f)[1:]
+	}
+}
+
+// latest is the last message sent at least -spectator-delay ago, nil if
+// there is none yet.
+func (f pastFeed) latest() []byte {
+	var data []byte
+	for _, m := range f {
+		if time.Since(m.at) < *flagSpectatorDelay {
+			break
+		}
+		data = m.data
+	}
+	return data
+}
+
+// pastStates are the game states for spectators. Guarded by mu.
+var pastStates pastFeed
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// openSQLite opens the database at path and makes sure schema exists.
+func openSQLite(path, schema string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	// One writer at a time keeps SQLite from answering "database is locked".
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+const profileSchema = `
+CREATE TABLE IF NOT EXISTS profiles (
+	id        TEXT PRIMARY KEY,
+	name      TEXT NOT NULL,
+	color     TEXT NOT NULL,
+	games     INTEGER NOT NULL DEFAULT 0,
+	wins      INTEGER NOT NULL DEFAULT 0,
+	play_time INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS best_times (
+	id   TEXT NOT NULL,
+	size TEXT NOT NULL,
+	time INTEGER NOT NULL,
+	PRIMARY KEY (id, size)
+);
+CREATE TABLE IF NOT EXISTS personal_bests (
+	id   TEXT NOT NULL,
+	size TEXT NOT NULL,
+	seed INTEGER NOT NULL,
+	time INTEGER NOT NULL,
+	PRIMARY KEY (id, size, seed)
+);`
+
+// sqliteProfiles is the ProfileStore backed by a SQLite file.
+type sqliteProfiles struct {
+	db *sql.DB
+}
+
+func openSQLiteProfiles(path string) (*sqliteProfiles, error) {
+	db, err := openSQLite(path, profileSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteProfiles{db: db}, nil
+}
+
+func (s *sqliteProfiles) Profile(id string) (*Profile, error) {
+	p := &Profile{ID: id, BestTimes: map[string]int64{}}
+	err := s.db.QueryRow(`SELECT name, color, games, wins, play_time FROM profiles WHERE id = ?`, id).
+		Scan(&p.Name, &p.Color, &p.GamesPlayed, &p.Wins, &p.PlayTime)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT size, time FROM best_times WHERE id = ?`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var size string
+		var t int64
+		if err := rows.Scan(&size, &t); err != nil {
+			return nil, err
+		}
+		p.BestTimes[size] = t
+	}
+	return p, rows.Err()
+}
+
+func (s *sqliteProfiles) RecordGame(id string, g GameRecord) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	won := 0
+	if g.Won {
+		won = 1
+	}
+	_, err = tx.Exec(`INSERT INTO profiles (id, name, color, games, wins, play_time) VALUES (?, ?, ?, 1, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, color = excluded.color,
+			games = games + 1, wins = wins + excluded.wins, play_time = play_time + excluded.play_time`,
+		id, g.Name, g.Color, won, g.PlayTime)
+	if err != nil {
+		return err
+	}
+	if g.Time > 0 {
+		_, err = tx.Exec(`INSERT INTO best_times (id, size, time) VALUES (?, ?, ?)
+			ON CONFLICT (id, size) DO UPDATE SET time = min(time, excluded.time)`,
+			id, g.Size, g.Time)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteProfiles) PersonalBest(id, size string, seed int64) (int64, error) {
+	var t int64
+	err := s.db.QueryRow(`SELECT time FROM personal_bests WHERE id = ? AND size = ? AND seed = ?`, id, size, seed).Scan(&t)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return t, err
+}
+
+func (s *sqliteProfiles) RecordPersonalBest(id, size string, seed, t int64) error {
+	_, err := s.db.Exec(`INSERT INTO personal_bests (id, size, seed, time) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id, size, seed) DO UPDATE SET time = min(time, excluded.time)`,
+		id, size, seed, t)
+	return err
+}
+
+func (s *sqliteProfiles) Close() error {
+	return s.db.Close()
+}
+
+const leaderboardSchema = `
+CREATE TABLE IF NOT EXISTS finishes (
+	name        TEXT NOT NULL,
+	color       TEXT NOT NULL,
+	player      TEXT NOT NULL DEFAULT '',
+	width       INTEGER NOT NULL,
+	height      INTEGER NOT NULL,
+	seed        INTEGER NOT NULL,
+	time        INTEGER NOT NULL,
+	rank        INTEGER NOT NULL,
+	finished_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS finishes_by_time ON finishes (time, finished_at);`
+
+// sqliteLeaderboard is the LeaderboardStore backed by a SQLite file.
+type sqliteLeaderboard struct {
+	db *sql.DB
+}
+
+func openSQLiteLeaderboard(path string) (*sqliteLeaderboard, error) {
+	db, err := openSQLite(path, leaderboardSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteLeaderboard{db: db}, nil
+}
+
+func (s *sqliteLeaderboard) RecordFinish(e LeaderboardEntry) error {
+	_, err := s.db.Exec(`INSERT INTO finishes (name, color, player, width, height, seed, time, rank, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Name, e.Color, e.Player, e.Width, e.Height, e.Seed, e.Time, e.Rank, e.FinishedAt.UnixMilli())
+	return err
+}
+
+func (s *sqliteLeaderboard) Top(q LeaderboardQuery) ([]LeaderboardEntry, error) {
+	var where []string
+	var args []any
+	if q.Width > 0 {
+		where = append(where, "width = ? AND height = ?")
+		args = append(args, q.Width, q.Height)
+	}
+	if q.Seed != nil {
+		where = append(where, "seed = ?")
+		args = append(args, *q.Seed)
+	}
+	if !q.From.IsZero() {
+		where = append(where, "finished_at >= ?")
+		args = append(args, q.From.UnixMilli())
+	}
+	if !q.To.IsZero() {
+		where = append(where, "finished_at < ?")
+		args = append(args, q.To.UnixMilli())
+	}
+	query := `SELECT name, color, player, width, height, seed, time, rank, finished_at FROM finishes`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY time, finished_at LIMIT ?"
+	args = append(args, q.Limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []LeaderboardEntry{}
+	for rows.Next() {
+		var e LeaderboardEntry
+		var at int64
+		if err := rows.Scan(&e.Name, &e.Color, &e.Player, &e.Width, &e.Height, &e.Seed, &e.Time, &e.Rank, &at); err != nil {
+			return nil, err
+		}
+		e.FinishedAt = time.UnixMilli(at).UTC()
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqliteLeaderboard) First() (time.Time, error) {
+	var at sql.NullInt64
+	if err := s.db.QueryRow(`SELECT min(finished_at) FROM finishes`).Scan(&at); err != nil || !at.Valid {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(at.Int64).UTC(), nil
+}
+
+func (s *sqliteLeaderboard) Close() error {
+	return s.db.Close()
+}
+
+const matchSchema = `
+CREATE TABLE IF NOT EXISTS matches (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	started_at INTEGER NOT NULL,
+	ended_at   INTEGER NOT NULL,
+	width      INTEGER NOT NULL,
+	height     INTEGER NOT NULL,
+	seed       INTEGER NOT NULL,
+	daily      TEXT NOT NULL DEFAULT '',
+	settings   TEXT NOT NULL,
+	standings  TEXT NOT NULL
+);`
+
+// sqliteMatches is the MatchStore backed by a SQLite file. Settings and
+// standings are stored as JSON.
+type sqliteMatches struct {
+	db *sql.DB
+}
+
+func openSQLiteMatches(path string) (*sqliteMatches, error) {
+	db, err := openSQLite(path, matchSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteMatches{db: db}, nil
+}
+
+func (s *sqliteMatches) SaveMatch(m *Match) error {
+	settings, _ := json.Marshal(m.Settings)
+	standings, _ := json.Marshal(m.Standings)
+	res, err := s.db.Exec(`INSERT INTO matches (started_at, ended_at, width, height, seed, daily, settings, standings)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.StartedAt.UnixMilli(), m.EndedAt.UnixMilli(), m.Width, m.Height, m.Seed, m.Daily, settings, standings)
+	if err != nil {
+		return err
+	}
+	m.ID, err = res.LastInsertId()
+	return err
+}
+
+const matchColumns = `id, started_at, ended_at, width, height, seed, daily, settings, standings`
+
+func scanMatch(row interface{ Scan(...any) error }) (Match, error) {
+	var m Match
+	var started, ended int64
+	var settings, standings []byte
+	if err := row.Scan(&m.ID, &started, &ended, &m.Width, &m.Height, &m.Seed, &m.Daily, &settings, &standings); err != nil {
+		return m, err
+	}
+	m.StartedAt = time.UnixMilli(started).UTC()
+	m.EndedAt = time.UnixMilli(ended).UTC()
+	if err := json.Unmarshal(settings, &m.Settings); err != nil {
+		return m, err
+	}
+	return m, json.Unmarshal(standings, &m.Standings)
+}
+
+func (s *sqliteMatches) Match(id int64) (*Match, error) {
+	m, err := scanMatch(s.db.QueryRow(`SELECT `+matchColumns+` FROM matches WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (s *sqliteMatches) Matches(limit int, before int64) ([]Match, error) {
+	query := `SELECT ` + matchColumns + ` FROM matches`
+	var args []any
+	if before > 0 {
+		query += ` WHERE id < ?`
+		args = append(args, before)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	rows, err := s.db.Query(query, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Match{}
+	for rows.Next() {
+		m, err := scanMatch(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, m)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqliteMatches) Close() error {
+	return s.db.Close()
+}
+
+const librarySchema = `
+CREATE TABLE IF NOT EXISTS mazes (
+	code      TEXT PRIMARY KEY,
+	name      TEXT NOT NULL DEFAULT '',
+	width     INTEGER NOT NULL,
+	height    INTEGER NOT NULL,
+	seed      INTEGER NOT NULL,
+	plays     INTEGER NOT NULL DEFAULT 0,
+	saved_at  INTEGER NOT NULL,
+	played_at INTEGER NOT NULL DEFAULT 0,
+	maze      TEXT NOT NULL
+);`
+
+// sqliteLibrary is the MazeLibrary backed by a SQLite file.
+type sqliteLibrary struct {
+	db *sql.DB
+}
+
+func openSQLiteLibrary(path string) (*sqliteLibrary, error) {
+	db, err := openSQLite(path, librarySchema)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteLibrary{db: db}, nil
+}
+
+func (s *sqliteLibrary) SaveMaze(e *LibraryMaze) error {
+	grid, _ := json.Marshal(e.Maze)
+	var played int64
+	if e.LastPlayed != nil {
+		played = e.LastPlayed.UnixMilli()
+	}
+	_, err := s.db.Exec(`INSERT INTO mazes (code, name, width, height, seed, plays, saved_at, played_at, maze)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (code) DO UPDATE SET plays = plays + excluded.plays,
+			played_at = max(played_at, excluded.played_at),
+			name = CASE WHEN excluded.name <> '' THEN excluded.name ELSE name END`,
+		e.Code, e.Name, e.Width, e.Height, e.Seed, e.Plays, e.SavedAt.UnixMilli(), played, grid)
+	return err
+}
+
+func scanLibraryMaze(row interface{ Scan(...any) error }, withGrid bool) (LibraryMaze, error) {
+	var e LibraryMaze
+	var saved, played int64
+	var grid []byte
+	dest := []any{&e.Code, &e.Name, &e.Width, &e.Height, &e.Seed, &e.Plays, &saved, &played}
+	if withGrid {
+		dest = append(dest, &grid)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return e, err
+	}
+	e.SavedAt = time.UnixMilli(saved).UTC()
+	if played > 0 {
+		t := time.UnixMilli(played).UTC()
+		e.LastPlayed = &t
+	}
+	if withGrid {
+		e.Maze = &CustomMaze{}
+		return e, json.Unmarshal(grid, e.Maze)
+	}
+	return e, nil
+}
+
+const libraryColumns = `code, name, width, height, seed, plays, saved_at, played_at`
+
+func (s *sqliteLibrary) LibraryMaze(code string) (*LibraryMaze, error) {
+	e, err := scanLibraryMaze(s.db.QueryRow(`SELECT `+libraryColumns+`, maze FROM mazes WHERE code = ?`, code), true)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *sqliteLibrary) LibraryMazes(limit int, recent bool) ([]LibraryMaze, error) {
+	order := `plays DESC, played_at DESC`
+	if recent {
+		order = `played_at DESC, saved_at DESC`
+	}
+	rows, err := s.db.Query(`SELECT `+libraryColumns+` FROM mazes ORDER BY `+order+` LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []LibraryMaze{}
+	for rows.Next() {
+		e, err := scanLibraryMaze(rows, false)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqliteLibrary) Close() error {
+	return s.db.Close()
+}
+
+const banSchema = `
+CREATE TABLE IF NOT EXISTS bans (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	ip         TEXT NOT NULL DEFAULT '',
+	player     TEXT NOT NULL DEFAULT '',
+	reason     TEXT NOT NULL DEFAULT '',
+	banned_by  TEXT NOT NULL DEFAULT '',
+	created_at INTEGER NOT NULL,
+	expires_at INTEGER
+);
+CREATE INDEX IF NOT EXISTS bans_by_ip ON bans (ip);
+CREATE INDEX IF NOT EXISTS bans_by_player ON bans (player);`
+
+// sqliteBans is the BanStore backed by a SQLite file. Expired bans are kept
+// as a record.
+type sqliteBans struct {
+	db *sql.DB
+}
+
+func openSQLiteBans(path string) (*sqliteBans, error) {
+	db, err := openSQLite(path, banSchema)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteBans{db: db}, nil
+}
+
+func (s *sqliteBans) AddBan(b *Ban) error {
+	var expires sql.NullInt64
+	if b.Expires != nil {
+		expires = sql.NullInt64{Int64: b.Expires.UnixMilli(), Valid: true}
+	}
+	res, err := s.db.Exec(`INSERT INTO bans (ip, player, reason, banned_by, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		b.IP, b.Player, b.Reason, b.By, b.Created.UnixMilli(), expires)
+	if err != nil {
+		return err
+	}
+	b.ID, err = res.LastInsertId()
+	return err
+}
+
+const banColumns = `id, ip, player, reason, banned_by, created_at, expires_at`
+
+func scanBan(row interface{ Scan(...any) error }) (Ban, error) {
+	var b Ban
+	var created int64
+	var expires sql.NullInt64
+	if err := row.Scan(&b.ID, &b.IP, &b.Player, &b.Reason, &b.By, &created, &expires); err != nil {
+		return b, err
+	}
+	b.Created = time.UnixMilli(created).UTC()
+	if expires.Valid {
+		at := time.UnixMilli(expires.Int64).UTC()
+		b.Expires = &at
+	}
+	return b, nil
+}
+
+func (s *sqliteBans) ActiveBan(ip, player string, now time.Time) (*Ban, error) {
+	b, err := scanBan(s.db.QueryRow(`SELECT `+banColumns+` FROM bans
+		WHERE ((ip != '' AND ip = ?) OR (player != '' AND player = ?)) AND (expires_at IS NULL OR expires_at > ?)
+		ORDER BY id DESC LIMIT 1`, ip, player, now.UnixMilli()))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (s *sqliteBans) Bans(now time.Time) ([]Ban, error) {
+	rows, err := s.db.Query(`SELECT `+banColumns+` FROM bans WHERE expires_at IS NULL OR expires_at > ? ORDER BY id DESC`, now.UnixMilli())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []Ban{}
+	for rows.Next() {
+		b, err := scanBan(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, b)
+	}
+	return list, rows.Err()
+}
+
+func (s *sqliteBans) LiftBan(id int64) (bool, error) {
+	res, err := s.db.Exec(`DELETE FROM bans WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *sqliteBans) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// sseKeepAlive is how often an idle event stream gets a comment line, so
+// proxies do not time it out between rounds.
+const sseKeepAlive = 15 * time.Second
+
+// serveEventStream follows the race as server-sent events: "state" events
+// carry the GameState the players get, the typed messages (finish, item,
+// portal, ...) come under their own type.
+func serveEventStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx would hold the stream back
+	ch, stop := listen()
+	defer stop()
+	fmt.Fprint(w, "retry: 2000\n\n")
+	flusher.Flush()
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case msg := <-ch:
+			kind, event, ok := localEvent(msg)
+			if !ok {
+				continue
+			}
+			if kind == "" {
+				kind = "state"
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", kind, event); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
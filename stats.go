@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Totals for a status page, counted since the server started.
+var (
+	statsMu       sync.Mutex
+	serverStarted = time.Now()
+	gamesPlayed   int
+	seenPlayers   = map[string]bool{}    // account id, or address for guests
+	finishTimes   = map[string][]int64{} // seconds, by maze size
+	peakPlayers   int
+	peakAt        time.Time
+)
+
+// countGame counts a round that ended.
+func countGame() {
+	statsMu.Lock()
+	gamesPlayed++
+	statsMu.Unlock()
+}
+
+// countPlayer counts p once, however many rounds and connections it plays.
+// Guests, without an account, are told apart by address.
+func countPlayer(p *Player, ip string) {
+	id := p.profile
+	if id == "" {
+		id = "ip:" + ip
+	}
+	statsMu.Lock()
+	seenPlayers[id] = true
+	statsMu.Unlock()
+}
+
+// countFinish records a finish time. Coin rounds have none.
+func countFinish(p Player) {
+	if coinMode() {
+		return
+	}
+	statsMu.Lock()
+	finishTimes[mazeSize()] = append(finishTimes[mazeSize()], p.FinishTime)
+	statsMu.Unlock()
+}
+
+// countConnections notes how many players are connected. Caller holds mu.
+func countConnections(n int) {
+	statsMu.Lock()
+	if n > peakPlayers {
+		peakPlayers, peakAt = n, time.Now()
+	}
+	statsMu.Unlock()
+}
+
+// FinishStats sums up the finish times on one maze size, in seconds.
+type FinishStats struct {
+	Finishes int     `json:"finishes"`
+	Average  float64 `json:"average"`
+	Median   float64 `json:"median"`
+}
+
+// ServerStats is what GET /stats returns.
+type ServerStats struct {
+	StartedAt     time.Time              `json:"startedAt"`
+	Uptime        int64                  `json:"uptime"` // seconds
+	GamesPlayed   int                    `json:"gamesPlayed"`
+	UniquePlayers int                    `json:"uniquePlayers"`
+	Players       int                    `json:"players"` // connected now
+	PeakPlayers   int                    `json:"peakPlayers"`
+	PeakAt        *time.Time             `json:"peakAt,omitempty"`
+	FinishTimes   map[string]FinishStats `json:"finishTimes"` // by maze size, "71x41"
+}
+
+func handleStats(w http.ResponseWriter, r *http.Request) {
+	mu.Lock()
+	connected := len(clients)
+	mu.Unlock()
+	statsMu.Lock()
+	s := ServerStats{
+		StartedAt:     serverStarted.UTC(),
+		Uptime:        int64(time.Since(serverStarted).Seconds()),
+		GamesPlayed:   gamesPlayed,
+		UniquePlayers: len(seenPlayers),
+		Players:       connected,
+		PeakPlayers:   peakPlayers,
+		FinishTimes:   map[string]FinishStats{},
+	}
+	if !peakAt.IsZero() {
+		at := peakAt.UTC()
+		s.PeakAt = &at
+	}
+	for size, times := range finishTimes {
+		s.FinishTimes[size] = finishStats(times)
+	}
+	statsMu.Unlock()
+	json.NewEncoder(w).Encode(s)
+}
+
+func finishStats(times []int64) FinishStats {
+	sorted := slices.Clone(times)
+	slices.Sort(sorted)
+	var sum int64
+	for _, t := range sorted {
+		sum += t
+	}
+	n := len(sorted)
+	median := float64(sorted[n/2])
+	if n%2 == 0 {
+		median = float64(sorted[n/2-1]+sorted[n/2]) / 2
+	}
+	return FinishStats{Finishes: n, Average: float64(sum) / float64(n), Median: median}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"flag"
+	"sort"
+)
+
+var flagTeams = flag.Int("teams", 0, "team mode: number of teams (2-4); players pick one or are put in the smallest (0 disables)")
+
+// teamDefs are the teams in the order they are used.
+var teamDefs = []struct{ Name, Color string }{
+	{"red", "#e74c3c"},
+	{"blue", "#3498db"},
+	{"green", "#2ecc71"},
+	{"yellow", "#f1c40f"},
+}
+
+// TeamStanding is one team's line in the team leaderboard.
+type TeamStanding struct {
+	Name      string `json:"name"`
+	Color     string `json:"color"`
+	Members   int    `json:"members"`
+	Finished  int    `json:"finished"`
+	TotalTime int64  `json:"totalTime"` // sum of the members' finish times
+	Coins     int    `json:"coins,omitempty"`
+	Home      bool   `json:"home"` // every member has finished
+	Rank      int    `json:"rank"`
+
+	lastRank int // finish rank of the member who completed the team
+}
+
+func teamCount() int {
+	if *flagTeams < 2 {
+		return 0
+	}
+	return min(*flagTeams, len(teamDefs))
+}
+
+func validTeam(name string) bool {
+	for _, t := range teamDefs[:teamCount()] {
+		if t.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// autoTeam returns the team with the fewest connected members. Caller
+// holds mu.
+func autoTeam() string {
+	n := teamCount()
+	if n == 0 {
+		return ""
+	}
+	size := make(map[string]int)
+	for _, p := range clients {
+		size[p.Team]++
+	}
+	best := teamDefs[0].Name
+	for _, t := range teamDefs[1:n] {
+		if size[t.Name] < size[best] {
+			best = t.Name
+		}
+	}
+	return best
+}
+
+// chooseTeam moves p to the team it asked for. Switching is only allowed
+// before the first move of a round, so nobody changes sides when their team
+// is losing. Caller holds mu.
+func chooseTeam(p *Player, want string) {
+	if teamCount() == 0 || want == p.Team || !validTeam(want) || p.moved > 0 {
+		return
+	}
+	p.Team = want
+}
+
+// teamStandings ranks the teams: complete teams first, in the order they
+// got home, then by members finished and total time (or coins in coin
+// mode). Caller holds mu.
+func teamStandings() []TeamStanding {
+	n := teamCount()
+	if n == 0 {
+		return nil
+	}
+	list := make([]TeamStanding, n)
+	idx := make(map[string]int, n)
+	for i, t := range teamDefs[:n] {
+		list[i] = TeamStanding{Name: t.Name, Color: t.Color}
+		idx[t.Name] = i
+	}
+	for _, p := range clients {
+		i, ok := idx[p.Team]
+		if !ok {
+			continue
+		}
+		t := &list[i]
+		t.Members++
+		t.Coins += p.Coins
+		if p.Finished {
+			t.Finished++
+			t.TotalTime += p.FinishTime
+			t.lastRank = max(t.lastRank, p.FinishRank)
+		}
+	}
+	for i := range list {
+		list[i].Home = list[i].Members > 0 && list[i].Finished == list[i].Members
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		switch {
+		case a.Home != b.Home:
+			return a.Home
+		case a.Home && !coinMode():
+			return a.lastRank < b.lastRank
+		case coinMode() && a.Coins != b.Coins:
+			return a.Coins > b.Coins
+		case a.Finished != b.Finished:
+			return a.Finished > b.Finished
+		}
+		return a.TotalTime < b.TotalTime
+	})
+	for i := range list {
+		list[i].Rank = i + 1
+	}
+	return list
+}
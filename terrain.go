@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"math/rand"
+	"time"
+)
+
+var (
+	flagIce      = flag.Int("ice", 0, "percent of straight corridor cells in generated mazes that are ice (players slide over them)")
+	flagMud      = flag.Int("mud", 0, "percent of floor cells in generated mazes that are mud (players are held there for -mud-delay)")
+	flagMudDelay = flag.Duration("mud-delay", 600*time.Millisecond, "how long a player stepping into mud has to wait before moving on")
+)
+
+// Cell values in the maze grid. One-way cells are floor that may only be
+// entered and left moving in their direction. Players keep sliding over ice
+// in the direction they entered it, and get stuck in mud for a moment.
+const (
+	cellFloor = 0
+	cellWall  = 1
+	cellEast  = 2
+	cellSouth = 3
+	cellWest  = 4
+	cellNorth = 5
+	cellIce   = 6
+	cellMud   = 7
+)
+
+// oneWayDirs maps one-way cell values to the step they allow.
+var oneWayDirs = map[int][2]int{
+	cellEast:  {1, 0},
+	cellSouth: {0, 1},
+	cellWest:  {-1, 0},
+	cellNorth: {0, -1},
+}
+
+// terrainRunes is how special cells look in the text format.
+var terrainRunes = map[int]rune{cellEast: '>', cellSouth: 'v', cellWest: '<', cellNorth: '^', cellIce: '~', cellMud: '%'}
+
+// canStep reports whether a player may walk from one cell to an adjacent
+// one: both must be open and neither may be a one-way cell pointing
+// elsewhere.
+func canStep(grid [][]int, from, to point) bool {
+	if !isOpen(grid, from.X, from.Y) || !isOpen(grid, to.X, to.Y) {
+		return false
+	}
+	step := [2]int{to.X - from.X, to.Y - from.Y}
+	for _, c := range [2]point{from, to} {
+		if d, ok := oneWayDirs[grid[c.Y][c.X]]; ok && d != step {
+			return false
+		}
+	}
+	return true
+}
+
+// step returns where a move from c in direction d ends: the adjacent cell,
+// or further along if that is ice.
+func step(grid [][]int, c point, d [2]int) (point, bool) {
+	n := point{c.X + d[0], c.Y + d[1]}
+	if !canStep(grid, c, n) {
+		return c, false
+	}
+	for grid[n.Y][n.X] == cellIce {
+		m := point{n.X + d[0], n.Y + d[1]}
+		if !canStep(grid, n, m) {
+			break
+		}
+		n = m
+	}
+	return n, true
+}
+
+// hasIce reports whether any cell of grid is ice.
+func hasIce(grid [][]int) bool {
+	for _, row := range grid {
+		for _, v := range row {
+			if v == cellIce {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// predecessors maps every cell to the cells a single move ends on it from.
+func predecessors(grid [][]int) map[point][]point {
+	preds := make(map[point][]point)
+	for y, row := range grid {
+		for x := range row {
+			c := point{x, y}
+			for _, d := range [4][2]int{{0, 1}, {0, -1}, {1, 0}, {-1, 0}} {
+				if n, ok := step(grid, c, d); ok {
+					preds[n] = append(preds[n], c)
+				}
+			}
+		}
+	}
+	return preds
+}
+
+// addTerrain sprinkles ice and mud over a freshly carved maze. Ice only
+// goes on straight corridor cells, where sliding through cannot skip a
+// turn and cut off part of the maze.
+func addTerrain(grid [][]int, rng *rand.Rand, icePct, mudPct int, start, goal point) {
+	if icePct <= 0 && mudPct <= 0 {
+		return
+	}
+	for y, row := range grid {
+		for x, v := range row {
+			c := point{x, y}
+			if v != cellFloor || c == start || c == goal {
+				continue
+			}
+			switch r := rng.Intn(100); {
+			case straightCorridor(grid, x, y) && r < icePct:
+				row[x] = cellIce
+			case r >= 100-mudPct:
+				row[x] = cellMud
+			}
+		}
+	}
+}
+
+// straightCorridor reports whether x,y has open cells on both sides along
+// one axis and closed ones along the other: a straight corridor cell, or a
+// wall segment separating two corridors.
+func straightCorridor(grid [][]int, x, y int) bool {
+	return isOpen(grid, x-1, y) && isOpen(grid, x+1, y) && !isOpen(grid, x, y-1) && !isOpen(grid, x, y+1) ||
+		isOpen(grid, x, y-1) && isOpen(grid, x, y+1) && !isOpen(grid, x-1, y) && !isOpen(grid, x+1, y)
+}
+
+// slide moves p from its cell one step in direction d and on over any ice,
+// booking checkpoints and goals it passes. With collisions on, other
+// players stop the slide. Caller holds mu.
+func slide(p *Player, d [2]int) {
+	for {
+		p.X, p.Y = p.X+d[0], p.Y+d[1]
+		passCheckpoint(p)
+		collectGoal(p)
+		if maze[p.Y][p.X] != cellIce {
+			break
+		}
+		n := point{p.X + d[0], p.Y + d[1]}
+		if !canStep(maze, point{p.X, p.Y}, n) || *flagCollisions && occupied(p, n.X, n.Y) {
+			break
+		}
+	}
+	if maze[p.Y][p.X] == cellMud {
+		p.stuckUntil = clock().Add(penaltyFor(p, *flagMudDelay))
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	flagLogoURL = flag.String("logo-url", "", "image shown above the heading of the web page")
+	flagFooter  = flag.String("footer", "", "text shown at the bottom of the web page's menu")
+	flagTheme   = flag.String("theme", "", "page colors as comma-separated name=color pairs, e.g. bg=#fff,text=#222,accent=#c0392b (names: "+strings.Join(themeColors, ", ")+")")
+)
+
+// themeColors are the CSS custom properties of style.css a theme may set.
+var themeColors = []string{"bg", "panel", "text", "heading", "accent"}
+
+var cssColor = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+)$`)
+
+// Branding is how the page presents itself, in the page and in /config.
+type Branding struct {
+	Title  string            `json:"title"`
+	Logo   string            `json:"logo,omitempty"`
+	Footer string            `json:"footer,omitempty"`
+	Theme  map[string]string `json:"theme,omitempty"` // CSS custom property (without --) to color
+}
+
+var branding Branding
+
+func parseTheme(s string) (map[string]string, error) {
+	theme := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, color, ok := strings.Cut(pair, "=")
+		name, color = strings.TrimSpace(name), strings.TrimSpace(color)
+		if !ok || !cssColor.MatchString(color) {
+			return nil, fmt.Errorf("%q is not name=color", pair)
+		}
+		if !slices.Contains(themeColors, name) {
+			return nil, fmt.Errorf("unknown theme color %q", name)
+		}
+		theme[name] = color
+	}
+	if len(theme) == 0 {
+		return nil, nil
+	}
+	return theme, nil
+}
+
+// loadBranding checks the branding flags once at startup.
+func loadBranding() {
+	theme, err := parseTheme(*flagTheme)
+	if err != nil {
+		log.Fatalf("Invalid -theme: %v", err)
+	}
+	branding = Branding{Title: *flagBrand, Logo: *flagLogoURL, Footer: *flagFooter, Theme: theme}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+)
+
+// Set at build time, e.g.
+//
+//	go build -ldflags "-X main.version=1.4.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%FT%TZ)"
+//
+// Without them the commit and date come from the VCS stamp Go embeds.
+var (
+	version   = "dev"
+	commit    = ""
+	buildDate = ""
+)
+
+// VersionInfo is what /version reports.
+type VersionInfo struct {
+	Version     string `json:"version"`
+	Commit      string `json:"commit,omitempty"`
+	Modified    bool   `json:"modified,omitempty"` // built from a tree with uncommitted changes
+	BuildDate   string `json:"buildDate,omitempty"`
+	Go          string `json:"go"`
+	Protocol    int    `json:"protocol"`
+	MinProtocol int    `json:"minProtocol"`
+}
+
+var versionInfo = sync.OnceValue(func() VersionInfo {
+	v := VersionInfo{Version: version, Commit: commit, BuildDate: buildDate, Go: runtime.Version(), Protocol: protocolVersion, MinProtocol: minProtocol}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return v
+	}
+	if v.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		v.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if v.Commit == "" {
+				v.Commit = s.Value
+			}
+		case "vcs.time":
+			if v.BuildDate == "" {
+				v.BuildDate = s.Value
+			}
+		case "vcs.modified":
+			v.Modified = s.Value == "true"
+		}
+	}
+	return v
+})
+
+// protocolHeader goes out with every WebSocket handshake, so clients can
+// tell a mismatch before the first message.
+func protocolHeader() http.Header {
+	return http.Header{
+		"X-Mazerunner-Protocol": {strconv.Itoa(protocolVersion)},
+		"X-Mazerunner-Version":  {versionInfo().Version},
+	}
+}
+
+func handleVersion(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(versionInfo())
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"flag"
+	"html/template"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	flagWebDir     = flag.String("web-dir", "", "directory whose files (index.html, style.css, app.js, ...) replace the built-in web page, for custom frontends without recompiling; index.html is an html/template getting a PageConfig")
+	flagBrand      = flag.String("brand", "Maze Runner", "name shown as the title and heading of the web page (see also -logo-url, -footer and -theme)")
+	flagGameServer = flag.String("game-server", "", "host:port of the game server the web page connects to, for a website running apart from it (defaults to the website's host)")
+)
+
+//go:embed web
+var embeddedWeb embed.FS
+
+// overlayFS serves files from dir where they exist and from base otherwise,
+// so a custom frontend only needs the files it changes.
+type overlayFS struct {
+	dir  fs.FS
+	base fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if o.dir != nil {
+		f, err := o.dir.Open(name)
+		if !errors.Is(err, fs.ErrNotExist) {
+			return f, err
+		}
+	}
+	return o.base.Open(name)
+}
+
+// webAssets is the frontend the website serves.
+func webAssets() fs.FS {
+	base, _ := fs.Sub(embeddedWeb, "web")
+	o := overlayFS{base: base}
+	if *flagWebDir != "" {
+		o.dir = os.DirFS(*flagWebDir)
+	}
+	return o
+}
+
+// serveWebAsset serves the file the request names, reporting false if there
+// is none (or it is a directory) so the caller can fall back to the page.
+func serveWebAsset(w http.ResponseWriter, r *http.Request, assets fs.FS) bool {
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+	if name == "" || name == "index.html" {
+		return false
+	}
+	st, err := fs.Stat(assets, name)
+	if err != nil || st.IsDir() {
+		return false
+	}
+	http.ServeFileFS(w, r, assets, name)
+	return true
+}
+
+// PageConfig is what the web page template is rendered with. The page's
+// script finds the same values in window.SERVER_CONFIG.
+type PageConfig struct {
+	Branding
+	GameServer string       `json:"gameServer,omitempty"` // host:port, empty for the website's host
+	GamePort   string       `json:"gamePort,omitempty"`
+	Features   PageFeatures `json:"features"`
+	Account    string       `json:"account,omitempty"` // name of the logged-in player
+}
+
+// PageFeatures tells the page which parts of the menu apply to this server.
+type PageFeatures struct {
+	Teams   bool     `json:"teams"`
+	Private bool     `json:"private"`
+	Logins  []string `json:"logins,omitempty"` // login providers, as in /auth/{provider}
+}
+
+func pageConfig(gamePort string) PageConfig {
+	return PageConfig{
+		Branding:   branding,
+		GameServer: *flagGameServer,
+		GamePort:   gamePort,
+		Features:   PageFeatures{Teams: teamCount() > 0, Private: privateGame(), Logins: loginProviders()},
+	}
+}
+
+// renderPage executes index.html. It is parsed on every request so edits in
+// -web-dir show up on reload.
+func renderPage(w http.ResponseWriter, assets fs.FS, cfg PageConfig) {
+	tmpl, err := template.ParseFS(assets, "index.html")
+	if err != nil {
+		log.Printf("Web page template: %v", err)
+		http.Error(w, "web page template broken", http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, cfg); err != nil {
+		log.Printf("Web page template: %v", err)
+		http.Error(w, "web page template broken", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
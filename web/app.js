@@ -0,0 +1,576 @@
+const canvas=document.getElementById('c'),ctx=canvas.getContext('2d');
+let maze=[],ws,myPlayer={x:1,y:1,name:"",color:"#4a9eff",finished:false};
+let gameStartTime=0,timerInterval=null,selColor="#4a9eff",gameEnded=false,joined=false;
+let pathLen=1,mazeCanvas=null,camX=0,camY=0,lastPlayers=[],serverBase='',markers=[],hintPath=[],hintUntil=0,hintMsg='',collisions=false,spawns=[],checkpoints=[],myCP=0,laps=0,myLap=0,goals=[],myGot=new Set(),portals=[],portalFx=null,mudDelay=0,penalty=1,stuckUntil=0,items=[],myFx={},coins=[],coinEnd=0,npcs=[],ghosts=[],teams=[],hallOfFame=[],safeRadius=0,nextCollapse=0,nextMaze=0,mazeRev=0,privateGame=false;
+let practiceRun=null; // {id, path} while playing a solo practice maze
+let GOALX=69,GOALY=39,MW=71,MH=41;
+// Defaults until the game server's /config says otherwise.
+let CELL=14,VIEWW=800,VIEWH=560;
+const PROTOCOL=2; // the game protocol this page speaks
+const CFG=window.SERVER_CONFIG||{}; // filled in by the server, see PageConfig
+
+// --- i18n ---
+// Translations come from the server's /i18n bundles; the page's own text is
+// English until they arrive.
+let lang='en',langs=['en'];
+const T={};
+function t(k){return (T[lang]&&T[lang][k])||(T.en&&T.en[k])||k}
+function applyLang(){
+    document.querySelectorAll('[data-i]').forEach(el=>{el.textContent=t(el.dataset.i)});
+    document.querySelectorAll('[data-pi]').forEach(el=>{el.placeholder=t(el.dataset.pi)});
+    document.getElementById('langBtn').textContent=nextLang().toUpperCase();
+}
+function nextLang(){return langs[(langs.indexOf(lang)+1)%langs.length]}
+async function loadLang(l){
+    if(!T[l])T[l]=await (await fetch('/i18n/'+l+'.json')).json();
+}
+async function toggleLang(){const l=nextLang();await loadLang(l);lang=l;applyLang()}
+fetch('/i18n').then(r=>r.json()).then(async list=>{langs=list.length?list:langs;await loadLang('en');applyLang()}).catch(()=>{});
+
+// Game servers on the local network, one click fills in the address.
+fetch('/discover').then(r=>r.ok?r.json():[]).then(list=>{
+    const lan=document.getElementById('lan');
+    if(!list.length)return;
+    const l=document.createElement('p');l.className='hint';l.dataset.i='lanServers';l.textContent=t('lanServers');lan.appendChild(l);
+    list.forEach(s=>{const b=document.createElement('button');b.textContent=s.name;b.title=s.address;b.onclick=()=>{document.getElementById('sip').value=s.address};lan.appendChild(b)});
+}).catch(()=>{});
+// Public servers from the registry open their own website.
+// Only web pages are opened, whatever the registry lists.
+const webURL=u=>{try{return ['http:','https:'].includes(new URL(u).protocol)}catch{return false}};
+fetch('/servers').then(r=>r.ok?r.json():[]).then(list=>{
+    const pub=document.getElementById('pub');
+    list=list.filter(s=>webURL(s.url));
+    if(!list.length)return;
+    const l=document.createElement('p');l.className='hint';l.dataset.i='publicServers';l.textContent=t('publicServers');pub.appendChild(l);
+    list.forEach(s=>{const b=document.createElement('button');b.textContent=(s.private?'\u{1F512} ':'')+s.name+' ('+s.players+', '+s.width+'x'+s.height+')';b.title=s.url;b.onclick=()=>{location.href=s.url};pub.appendChild(b)});
+}).catch(()=>{});
+
+let colors=["#e74c3c","#e67e22","#f1c40f","#2ecc71","#1abc9c","#3498db","#4a9eff","#9b59b6","#e84393","#fd79a8","#00cec9","#6c5ce7","#a29bfe","#ffeaa7","#dfe6e9","#636e72"];
+
+function renderColors(){
+    const c=document.getElementById('co');c.innerHTML='';
+    colors.forEach(cl=>{const d=document.createElement('div');d.className='cs'+(cl===selColor?' sel':'');d.style.background=cl;d.onclick=()=>{selColor=cl;document.getElementById('cp').style.background=cl;document.getElementById('cc').value=cl;renderColors()};c.appendChild(d)})
+}
+document.getElementById('cc').addEventListener('input',e=>{selColor=e.target.value;document.getElementById('cp').style.background=e.target.value;renderColors()});
+renderColors();
+
+// loadConfig takes the sizes and colors the game server chose.
+async function loadConfig(base){
+    try{
+        const c=await (await fetch(base+'/config')).json();
+        CELL=c.cellSize||CELL;VIEWW=c.viewWidth||VIEWW;VIEWH=c.viewHeight||VIEWH;
+        if(c.palette&&c.palette.length)colors=c.palette;
+    }catch(e){} // servers from before /config
+}
+loadConfig(location.protocol+'//'+gameHost('')).then(renderColors);
+
+function startTimer(){
+    gameStartTime=Date.now();
+    // Coin rounds count down to the server's deadline instead.
+    timerInterval=setInterval(()=>{if(gameEnded)return;const s=coinEnd?Math.max(0,Math.ceil((coinEnd-Date.now())/1000)):Math.floor((Date.now()-gameStartTime)/1000);document.getElementById('tv').textContent=String(Math.floor(s/60)).padStart(2,'0')+':'+String(s%60).padStart(2,'0')},1000)
+}
+
+function move(dx,dy){
+    if(myPlayer.finished||gameEnded||Date.now()<stuckUntil)return;
+    let nx=myPlayer.x+dx,ny=myPlayer.y+dy;
+    if(collisions&&lastPlayers.some(p=>p.x===nx&&p.y===ny&&!(nx===GOALX&&ny===GOALY)&&!spawns.some(s=>s.x===nx&&s.y===ny)))return;
+    const now=Date.now(),fx=nx+dx,fy=ny+dy;
+    // Speed covers two open cells per step, wall-phase hops over one wall.
+    const fast=now<(myFx.speed||0)&&canStep(myPlayer.x,myPlayer.y,nx,ny)&&canStep(nx,ny,fx,fy);
+    const hop=now<(myFx.phase||0)&&maze[ny]&&maze[ny][nx]===1&&maze[fy]&&maze[fy][fx]!==undefined&&maze[fy][fx]!==1;
+    if(fast||hop){
+        myPlayer.x=nx;myPlayer.y=ny;
+        if(fast&&myCP<checkpoints.length&&checkpoints[myCP].x===nx&&checkpoints[myCP].y===ny)myCP++;
+        if(fast)goals.forEach((g,i)=>{if(g.x===nx&&g.y===ny)myGot.add(i)});
+        nx=fx;ny=fy;
+    }
+    if(fast||hop||canStep(myPlayer.x,myPlayer.y,nx,ny)){
+        // Only the step is reported; the server slides us over ice and
+        // through portals the same way we do here.
+        myPlayer.x=nx;myPlayer.y=ny;send();
+        items=items.filter(it=>it.x!==nx||it.y!==ny);
+        for(;;){
+            if(myCP<checkpoints.length&&checkpoints[myCP].x===nx&&checkpoints[myCP].y===ny)myCP++;
+            goals.forEach((g,i)=>{if(g.x===nx&&g.y===ny)myGot.add(i)});
+            if(maze[ny][nx]!==6||!canStep(nx,ny,nx+dx,ny+dy))break;
+            nx+=dx;ny+=dy;
+        }
+        myPlayer.x=nx;myPlayer.y=ny;
+        if(maze[ny][nx]===7)stuckUntil=Date.now()+mudDelay*penalty;
+        // In a lap race the server sends us back to the spawn instead.
+        const atGoal=goals.length?myGot.size>=goals.length:nx===GOALX&&ny===GOALY;
+        if(atGoal&&!coinEnd&&myCP>=checkpoints.length&&myLap+1>=laps)myPlayer.finished=true;
+        const tw=twin(nx,ny);if(tw){myPlayer.x=tw.x;myPlayer.y=tw.y}
+    }
+    // Bumping into a wall is reported too, the server counts it.
+    else if(ws&&ws.readyState===1)ws.send(JSON.stringify({...myPlayer,x:nx,y:ny}))
+}
+
+// One-way cells (2-5: east, south, west, north) are entered and left only in their direction.
+// Ice (6) slides us on, mud (7) holds us for a moment.
+const ONEWAY={2:[1,0],3:[0,1],4:[-1,0],5:[0,-1]};
+function canStep(x,y,nx,ny){
+    if(!maze[ny]||maze[ny][nx]===undefined||maze[ny][nx]===1)return false;
+    const dx=nx-x,dy=ny-y;
+    return [maze[y][x],maze[ny][nx]].every(v=>!ONEWAY[v]||(ONEWAY[v][0]===dx&&ONEWAY[v][1]===dy));
+}
+
+function buildMazeCanvas(){
+    mazeCanvas=document.createElement('canvas');
+    mazeCanvas.width=maze[0].length*CELL;
+    mazeCanvas.height=maze.length*CELL;
+    const mc=mazeCanvas.getContext('2d');
+    for(let y=0;y<maze.length;y++){
+        for(let x=0;x<maze[y].length;x++){
+            if(maze[y][x]!==1){
+                mc.fillStyle=(x+y)%2===0?'#1a1a1a':'#1c1c1c';
+                mc.fillRect(x*CELL,y*CELL,CELL,CELL);
+            }
+            if(maze[y][x]===6){mc.fillStyle='#1f3a4a';mc.fillRect(x*CELL,y*CELL,CELL,CELL)}
+            if(maze[y][x]===7){mc.fillStyle='#3a2a18';mc.fillRect(x*CELL,y*CELL,CELL,CELL)}
+            const ow=ONEWAY[maze[y][x]];
+            if(ow){
+                const cx=x*CELL+CELL/2,cy=y*CELL+CELL/2,r=CELL/3;
+                mc.fillStyle='#5a4a2a';mc.beginPath();
+                mc.moveTo(cx+ow[0]*r,cy+ow[1]*r);
+                mc.lineTo(cx-ow[0]*r-ow[1]*r,cy-ow[1]*r+ow[0]*r);
+                mc.lineTo(cx-ow[0]*r+ow[1]*r,cy-ow[1]*r-ow[0]*r);
+                mc.fill();
+            }
+        }
+    }
+    for(let y=0;y<maze.length;y++){
+        for(let x=0;x<maze[y].length;x++){
+            if(maze[y][x]===1){
+                const px=x*CELL,py=y*CELL;
+                mc.fillStyle='#2a2a2e';mc.fillRect(px,py,CELL,CELL);
+                mc.fillStyle='#353540';mc.fillRect(px,py,CELL,2);
+                mc.fillStyle='#30303a';mc.fillRect(px,py,2,CELL);
+                mc.fillStyle='#1a1a20';mc.fillRect(px,py+CELL-1,CELL,1);mc.fillRect(px+CELL-1,py,1,CELL);
+                if((x*7+y*13)%5===0){mc.fillStyle='rgba(255,255,255,0.03)';mc.fillRect(px+4,py+4,2,2)}
+            }
+        }
+    }
+    const gx=GOALX*CELL,gy=GOALY*CELL;
+    mc.fillStyle='#2a2200';mc.fillRect(gx-CELL,gy-CELL,CELL*3,CELL*3);
+    mc.fillStyle='#3a3200';mc.fillRect(gx,gy,CELL,CELL);
+}
+
+function applyInfo(info){
+    GOALX=info.goalX;GOALY=info.goalY;MW=info.width;MH=info.height;mazeRev=info.revision||0;
+    myPlayer.x=info.startX;myPlayer.y=info.startY;markers=info.markers||[];
+    collisions=!!info.collisions;spawns=info.spawns||[];checkpoints=info.checkpoints||[];myCP=0;laps=info.laps||0;myLap=0;goals=info.goals||[];myGot=new Set();portals=info.portals||[];mudDelay=info.mudDelay||0;penalty=1;stuckUntil=0;items=info.items||[];myFx={};
+    pathLen=(info.metrics&&info.metrics.pathLength)||1;privateGame=!!info.private;
+}
+
+// loadMaze fetches the grid bit-packed; servers without the format send the plain grid.
+async function loadMaze(base){
+    const m=await (await fetch(base+'/maze?format=bitset')).json();
+    if(m.markers)markers=m.markers;
+    if(Array.isArray(m))return m;
+    const bits=atob(m.walls),g=[];
+    for(let y=0;y<m.height;y++){const row=[];for(let x=0;x<m.width;x++){const i=y*m.width+x;row.push(bits.charCodeAt(i>>3)>>(i&7)&1)}g.push(row)}
+    (m.cells||[]).forEach(c=>{g[c.y][c.x]=c.cell});
+    return g;
+}
+
+// newRound loads the maze of a round started while we are connected.
+async function newRound(){
+    const info=await (await fetch(serverBase+'/info')).json();
+    const{x,y}=myPlayer;applyInfo(info);myPlayer.x=x;myPlayer.y=y;
+    maze=await loadMaze(serverBase);
+    buildMazeCanvas();hintPath=[];
+    if(gameEnded){
+        gameEnded=false;myPlayer.finished=false;
+        document.getElementById('go').style.display='none';canvas.style.display='block';
+        clearInterval(timerInterval);startTimer();requestAnimationFrame(gameLoop);
+    }
+}
+
+function restartRound(){if(ws&&ws.readyState===1)ws.send(JSON.stringify({type:'restart'}))}
+// The host of a private game asks for an invite link to pass on.
+function inviteFriend(){if(ws&&ws.readyState===1)ws.send(JSON.stringify({type:'invite'}))}
+
+// gameHost is the host:port of the game server: what the player typed, or
+// the one the website points at.
+function gameHost(sip){
+    // Auto-detect host logic
+    let host=sip;
+    if(!host) {
+        // Use the game server or port injected by the server if available, otherwise window.location.host
+        if(CFG.gameServer) {
+             host = CFG.gameServer;
+        } else if(CFG.gamePort) {
+             host = window.location.hostname + ":" + CFG.gamePort;
+        } else {
+             host = window.location.host;
+        }
+    }
+    // Fallback if port missing but needed? usually location.host includes port.
+    // If user enters IP without port, adding default 8080 isn't always right if game runs on different port.
+    // But for simplicty:
+    if(host && !host.includes(':') && !CFG.gamePort) host=host+':8080';
+    return host;
+}
+
+async function start(){
+    practiceRun=null;
+    myPlayer.name=document.getElementById('name').value||"Runner";
+    myPlayer.team=document.getElementById('team').value;
+    myPlayer.color=selColor;myPlayer.x=1;myPlayer.y=1;myPlayer.finished=false;myPlayer.seq=0;gameEnded=false;
+    const host=gameHost(document.getElementById('sip').value.trim());
+    const pr=location.protocol==='https:'?'https':'http';
+    const wpr=location.protocol==='https:'?'wss':'ws';
+    serverBase=pr+'://'+host;
+    try{
+        const infoRes=await fetch(pr+'://'+host+'/info');
+        const info=await infoRes.json();
+        const q=new URLSearchParams(location.search),pw=document.getElementById('pw').value,invite=q.get('invite');
+        if(q.get('token'))localStorage.setItem('mazeToken',q.get('token'));
+        const token=localStorage.getItem('mazeToken');
+        if(info.private&&!pw&&!invite){document.getElementById('pwf').style.display='block';alert(t('needPassword'));return}
+        if(info.login&&!token){alert(t('needLogin'));return}
+        applyInfo(info);
+        await loadConfig(serverBase);
+        maze=await loadMaze(serverBase);
+        canvas.width=VIEWW;canvas.height=VIEWH;
+        buildMazeCanvas();
+        joined=false;
+        const wq=new URLSearchParams();
+        if(pw)wq.set('password',pw);
+        // The profile lets the invite take this player back in after a reconnect.
+        else if(invite){wq.set('invite',invite);const prof=localStorage.getItem('mazeProfile');if(prof)wq.set('profile',prof)}
+        if(info.login)wq.set('token',token);
+        ws=new WebSocket(wpr+'://'+host+'/ws'+(wq.size?'?'+wq:''));
+        ws.onopen=()=>{
+            document.getElementById('ui').style.display='none';
+            canvas.style.display='block';
+            document.getElementById('lb').style.display='block';
+            document.getElementById('tm').style.display='block';
+            document.getElementById('pc').style.display='block';
+            ws.send(JSON.stringify({type:'hello',protocol:PROTOCOL,caps:['events','private','maze-rle','ping'],profile:localStorage.getItem('mazeProfile')||''}));
+            startTimer();requestAnimationFrame(gameLoop);
+        };
+        ws.onmessage=e=>{
+            const st=JSON.parse(e.data);
+            if(st.type==='welcome'){if(st.profile)localStorage.setItem('mazeProfile',st.profile);if(st.protocol!==PROTOCOL){hintMsg=t('protoMismatch');hintUntil=Date.now()+8000}return}
+            if(st.type==='join'){myPlayer.x=st.x;myPlayer.y=st.y;myPlayer.finished=false;myCP=0;myLap=0;myGot.clear();send();if(joined)newRound();joined=true;return}
+            if(st.type==='restart'||st.type==='host'||(st.type==='invite'||st.type==='settings')&&st.error){hintMsg=st.error;hintUntil=Date.now()+6000;return}
+            if(st.type==='settings'){hintMsg=t('settingsNext');hintUntil=Date.now()+4000;return}
+            if(st.type==='invite'){if(navigator.clipboard)navigator.clipboard.writeText(st.url).catch(()=>{});prompt(t('inviteLink'),st.url);return}
+            if(st.type==='lap'){myPlayer.x=st.x;myPlayer.y=st.y;myCP=0;myLap=st.lap;myGot.clear();return}
+            if(st.type==='reconnect'){gameEnded=true;clearInterval(timerInterval);ws.onclose=null;ws.close();document.getElementById('go').style.display='none';setTimeout(start,500+Math.random()*1500);return}
+            if(st.type==='banned'){gameEnded=true;ws.onclose=null;alert(t('banned')+(st.reason?'\n'+st.reason:''));return}
+            if(st.type==='ping'){ws.send(JSON.stringify({type:'pong',t:st.t}));return}
+            if(st.type==='warning'){console.warn('server:',st.message);return}
+            if(st.type==='portal'){portalFx={from:st.from,to:st.to,until:Date.now()+700};return}
+            if(st.type==='maze-rle'){
+                // A revision skipped means a change went past us: fetch the whole maze.
+                if(st.revision!==mazeRev+1){mazeRev=st.revision;loadMaze(serverBase).then(m=>{maze=m;buildMazeCanvas()});return}
+                const w=maze[0].length;mazeRev=st.revision;
+                st.runs.forEach(([i,n,c])=>{for(let k=i;k<i+n;k++)maze[Math.floor(k/w)][k%w]=c});
+                buildMazeCanvas();return}
+            if(st.type==='maze-diff'){st.changes.forEach(c=>{maze[c.y][c.x]=c.cell});buildMazeCanvas();return}
+            if(st.type==='item'){
+                if(st.event==='spawn')items.push(st.item);
+                else if(st.event==='pickup')items=items.filter(it=>it.id!==st.item.id);
+                else if(st.name===myPlayer.name)myFx[st.item.kind]=Date.now()+st.duration;
+                else if(st.item.kind==='freeze')stuckUntil=Date.now()+st.duration*penalty;
+                return
+            }
+            if(st.type==='markers'){markers=st.markers||[];return}
+            // Trailing players are held in mud and freezes for less time.
+            if(st.type==='assist'){penalty=st.on?st.penalty:1;return}
+            if(st.type==='finish'){if(st.personalBest&&st.name===myPlayer.name){hintMsg=t('newPB');hintUntil=Date.now()+6000}return}
+            if(st.type==='position'){myPlayer.x=st.x;myPlayer.y=st.y;return}
+            if(st.type==='hint'){
+                if(st.error){hintMsg=st.retryIn?t('hintWait').replace('%d',st.retryIn):st.error}
+                else{hintPath=st.path;hintMsg=st.assist?t('assistHint'):st.remaining+' '+t('hintsLeft')}
+                hintUntil=Date.now()+6000;return
+            }
+            // Protocol 2 marks states; other messages we do not know are skipped.
+            if(st.type&&st.type!=='state')return;
+            // The minotaur and the ghost ride along in the player list, flagged as npc and ghost.
+            const all=st.players||[];
+            lastPlayers=all.filter(p=>!p.npc&&!p.ghost);npcs=all.filter(p=>p.npc);ghosts=all.filter(p=>p.ghost);
+            teams=st.teams||[];hallOfFame=st.hallOfFame||[];safeRadius=st.safeRadius||0;nextCollapse=st.nextCollapse||0;nextMaze=st.nextMaze||0;coins=st.coins||[];coinEnd=st.timeLeft?Date.now()+st.timeLeft*1000:0;
+            if(st.allFinished&&lastPlayers.length>0&&!gameEnded){gameEnded=true;clearInterval(timerInterval);showGameOver(lastPlayers)}
+        };
+        ws.onerror=()=>alert(t('connFail'));
+        ws.onclose=()=>{if(!gameEnded)console.log("Disconnected")};
+        window.onkeydown=onKey;
+    }catch(err){alert(t('error')+': '+err)}
+}
+
+function onKey(e){
+    if(myPlayer.finished||gameEnded)return;
+    let dx=0,dy=0;
+    if(e.key==="ArrowUp"||e.key==="w")dy=-1;
+    if(e.key==="ArrowDown"||e.key==="s")dy=1;
+    if(e.key==="ArrowLeft"||e.key==="a")dx=-1;
+    if(e.key==="ArrowRight"||e.key==="d")dx=1;
+    if(dx||dy){e.preventDefault();move(dx,dy)}
+    if(e.key==="h"&&ws&&ws.readyState===1)ws.send(JSON.stringify({type:'hint'}));
+}
+
+// practice plays a solo maze in the page, without joining the game. Only the
+// finished path goes to the server, which times it for the personal best.
+async function practice(){
+    myPlayer.name=document.getElementById('name').value||"Runner";
+    myPlayer.color=selColor;myPlayer.finished=false;gameEnded=false;
+    serverBase=(location.protocol==='https:'?'https':'http')+'://'+gameHost(document.getElementById('sip').value.trim());
+    try{
+        const res=await fetch(serverBase+'/practice');
+        if(!res.ok)throw new Error(await res.text());
+        const pm=await res.json();
+        await loadConfig(serverBase);
+        applyInfo(pm);maze=pm.maze;
+        ws=null;practiceRun={id:pm.id,path:[]};lastPlayers=[myPlayer];
+        hintPath=[];coins=[];coinEnd=0;npcs=[];ghosts=[];teams=[];hallOfFame=[];safeRadius=0;nextCollapse=0;nextMaze=0;
+        // The world record on this maze, if the server has one, runs alongside.
+        const gs=new EventSource(serverBase+'/practice/'+pm.id+'/ghost');
+        gs.addEventListener('ghost',e=>{ghosts=[JSON.parse(e.data)]});
+        gs.addEventListener('done',()=>{gs.close();ghosts=[]});
+        gs.onerror=()=>gs.close();
+        practiceRun.ghost=gs;
+        canvas.width=VIEWW;canvas.height=VIEWH;
+        buildMazeCanvas();
+        document.getElementById('ui').style.display='none';
+        canvas.style.display='block';
+        document.getElementById('tm').style.display='block';
+        window.onkeydown=onKey;
+        startTimer();requestAnimationFrame(gameLoop);
+    }catch(err){alert(t('error')+': '+err)}
+}
+
+async function finishPractice(){
+    const run=practiceRun;practiceRun=null;run.ghost.close();
+    gameEnded=true;clearInterval(timerInterval);
+    const me={...myPlayer,finished:true,finishRank:1};
+    let msg=t('practiceDone');
+    try{
+        const res=await fetch(serverBase+'/practice/'+run.id,{method:'POST',body:JSON.stringify({path:run.path,profile:localStorage.getItem('mazeProfile')||''})});
+        if(!res.ok)throw new Error(await res.text());
+        const r=await res.json();
+        me.finishTime=r.time;
+        if(r.personalBest)msg+=' '+t('newPB');
+    }catch(err){msg=t('practiceFailed')+': '+err.message}
+    showGameOver([me]);
+    document.querySelector('#go .gs').textContent=msg;
+}
+
+function gameLoop(){
+    if(practiceRun&&myPlayer.finished){finishPractice();return}
+    if(gameEnded)return;
+    draw(lastPlayers);
+    requestAnimationFrame(gameLoop);
+}
+
+function draw(players){
+    const targetCX=myPlayer.x*CELL-VIEWW/2+CELL/2;
+    const targetCY=myPlayer.y*CELL-VIEWH/2+CELL/2;
+    camX+=(targetCX-camX)*0.12;camY+=(targetCY-camY)*0.12;
+    const mw=maze[0].length*CELL,mh=maze.length*CELL;
+    camX=Math.max(0,Math.min(camX,mw-VIEWW));
+    camY=Math.max(0,Math.min(camY,mh-VIEWH));
+
+    ctx.fillStyle="#111";ctx.fillRect(0,0,VIEWW,VIEWH);
+    ctx.drawImage(mazeCanvas,-camX,-camY);
+
+    const gx=GOALX*CELL-camX,gy=GOALY*CELL-camY;
+    const tt=Date.now()/1000;
+    ctx.fillStyle='#888';ctx.fillRect(gx+2,gy-8,2,CELL+8);
+    const wave=Math.sin(tt*3)*2;
+    ctx.fillStyle='#d4aa00';ctx.beginPath();ctx.moveTo(gx+4,gy-8);ctx.lineTo(gx+14+wave,gy-4);ctx.lineTo(gx+4,gy);ctx.fill();
+
+    const ICONS={speed:'\u26A1',freeze:'\u2744',reveal:'\u{1F441}',phase:'\u{1F47B}'};
+    ctx.font='10px system-ui';ctx.textAlign='center';
+    ctx.fillStyle='#f1c40f';
+    coins.forEach(c=>{ctx.beginPath();ctx.ellipse(c.x*CELL-camX+CELL/2,c.y*CELL-camY+CELL/2,(CELL/4)*Math.abs(Math.cos(tt*3+c.x)),CELL/4,0,0,Math.PI*2);ctx.fill()});
+
+    items.forEach(it=>{
+        const ix=it.x*CELL-camX,iy=it.y*CELL-camY;
+        ctx.fillStyle='rgba(155,89,182,'+(0.35+0.15*Math.sin(tt*4))+')';
+        ctx.beginPath();ctx.arc(ix+CELL/2,iy+CELL/2,CELL/2-1,0,Math.PI*2);ctx.fill();
+        ctx.fillStyle='#fff';ctx.fillText(ICONS[it.kind]||'?',ix+CELL/2,iy+CELL-3);
+    });
+    ctx.textAlign='left';
+
+    portals.forEach((pr,i)=>{
+        const hue=(i*67)%360;
+        pr.forEach(c=>{
+            const px=c.x*CELL-camX,py=c.y*CELL-camY;
+            ctx.strokeStyle='hsl('+hue+',80%,60%)';ctx.lineWidth=2;
+            ctx.beginPath();ctx.ellipse(px+CELL/2,py+CELL/2,CELL/2-2,CELL/3,tt*2,0,Math.PI*2);ctx.stroke();
+        });
+    });
+    if(portalFx&&Date.now()<portalFx.until){
+        const a=(portalFx.until-Date.now())/700;
+        ctx.fillStyle='rgba(155,89,182,'+(a*0.6)+')';
+        [portalFx.from,portalFx.to].forEach(c=>{ctx.beginPath();ctx.arc(c.x*CELL-camX+CELL/2,c.y*CELL-camY+CELL/2,CELL*(1.5-a),0,Math.PI*2);ctx.fill()});
+    }
+
+    goals.forEach((g,i)=>{
+        if(i===0)return;
+        const ox=g.x*CELL-camX,oy=g.y*CELL-camY;
+        ctx.strokeStyle=myGot.has(i)?'#555':'#d4aa00';ctx.lineWidth=2;
+        ctx.beginPath();ctx.arc(ox+CELL/2,oy+CELL/2,CELL/2-2,0,Math.PI*2);ctx.stroke();
+    });
+
+    checkpoints.forEach((c,i)=>{
+        const cx=c.x*CELL-camX,cy=c.y*CELL-camY;
+        ctx.fillStyle=i<myCP?'rgba(46,204,113,0.35)':i===myCP?'rgba(241,196,15,0.6)':'rgba(241,196,15,0.25)';
+        ctx.fillRect(cx,cy,CELL,CELL);
+        ctx.fillStyle='#eee';ctx.font='bold 9px system-ui';ctx.textAlign='center';ctx.fillText(i+1,cx+CELL/2,cy+CELL-4);ctx.textAlign='left';
+    });
+
+    markers.forEach(m=>{
+        const mx=m.x*CELL-camX,my=m.y*CELL-camY;
+        if(mx<-CELL*4||mx>VIEWW+CELL*4||my<-CELL||my>VIEWH+CELL)return;
+        ctx.strokeStyle=m.color||'#4a9eff';ctx.lineWidth=2;ctx.strokeRect(mx+1,my+1,CELL-2,CELL-2);
+        ctx.font='10px system-ui';ctx.textAlign='center';
+        if(m.icon){ctx.fillStyle='#eee';ctx.fillText(m.icon,mx+CELL/2,my+CELL-3)}
+        if(m.label){ctx.fillStyle=m.color||'#4a9eff';ctx.fillText(m.label,mx+CELL/2,my-3)}
+        ctx.textAlign='left';
+    });
+
+    if(Date.now()<hintUntil){
+        ctx.fillStyle='rgba(74,158,255,0.6)';
+        hintPath.forEach((c,i)=>{ctx.beginPath();ctx.arc(c.x*CELL-camX+CELL/2,c.y*CELL-camY+CELL/2,CELL/4-i*0.3,0,Math.PI*2);ctx.fill()});
+    }
+
+    // Sudden death: the ring about to fall glows red for its last ten seconds.
+    if(nextCollapse&&nextCollapse-Date.now()<10000){
+        ctx.fillStyle='rgba(231,76,60,'+(0.25+0.15*Math.sin(tt*8))+')';
+        const x0=Math.max(0,Math.floor(camX/CELL)),y0=Math.max(0,Math.floor(camY/CELL));
+        for(let y=y0;y<maze.length&&y<=y0+VIEWH/CELL+1;y++)for(let x=x0;x<maze[y].length&&x<=x0+VIEWW/CELL+1;x++){
+            if(maze[y][x]!==1&&Math.max(Math.abs(x-GOALX),Math.abs(y-GOALY))===safeRadius)ctx.fillRect(x*CELL-camX,y*CELL-camY,CELL,CELL);
+        }
+    }
+
+    ghosts.forEach(g=>{
+        const gx=g.x*CELL-camX,gy=g.y*CELL-camY;
+        ctx.globalAlpha=0.35;ctx.fillStyle=g.color;
+        ctx.beginPath();ctx.arc(gx+CELL/2,gy+CELL/2,CELL/2-1,0,Math.PI*2);ctx.fill();
+        ctx.globalAlpha=1;
+    });
+
+    npcs.forEach(n=>{
+        const nx=n.x*CELL-camX,ny=n.y*CELL-camY;
+        ctx.fillStyle=n.color;ctx.fillRect(nx+1,ny+1,CELL-2,CELL-2);
+        ctx.fillStyle='#ddd';
+        ctx.beginPath();ctx.moveTo(nx+1,ny+2);ctx.lineTo(nx+4,ny-4);ctx.lineTo(nx+6,ny+2);ctx.fill();
+        ctx.beginPath();ctx.moveTo(nx+CELL-1,ny+2);ctx.lineTo(nx+CELL-4,ny-4);ctx.lineTo(nx+CELL-6,ny+2);ctx.fill();
+    });
+
+    const sorted=[...players].sort((a,b)=>{
+        if(coinEnd)return b.coins-a.coins;
+        if(a.finished&&!b.finished)return -1;if(!a.finished&&b.finished)return 1;
+        if(a.finished&&b.finished)return a.finishRank-b.finishRank;return dist(a)-dist(b)
+    });
+
+    let totalP=players.length,finP=players.filter(p=>p.finished).length;
+    document.getElementById('pc').textContent=totalP+' '+t('players')+' | '+finP+' '+t('atGoal')+(Date.now()<hintUntil&&hintMsg?' | '+hintMsg:'')+(nextCollapse?' | \u26A0 '+Math.max(0,Math.ceil((nextCollapse-Date.now())/1000))+'s':'')+(nextMaze?' | '+t('newMazeIn').replace('%d',Math.max(0,Math.ceil((nextMaze-Date.now())/1000))):'');
+
+    let lh='<h3>'+t('ranking')+'</h3>';
+    sorted.forEach(p=>{
+        const rc=p.finished?(p.finishRank===1?'g':p.finishRank===2?'s':p.finishRank===3?'br':''):'';
+        lh+='<div class="le"><div class="rk '+rc+'">'+(p.finished?p.finishRank:'·')+'</div>';
+        lh+='<div class="ld" style="background:'+p.color+'"></div><span>'+p.name+(p.host?' <span title="'+t('host')+'">&#x1F451;</span>':'')+'</span>';
+        if(p.finished)lh+='<span class="fb">'+t('goal')+'</span>';
+        else if(p.eliminated)lh+='<span class="fb">&#x2620;</span>';
+        else if(checkpoints.length)lh+='<span class="fb">'+p.checkpoint+'/'+checkpoints.length+'</span>';
+        if(coinEnd||p.coins)lh+='<span class="fb">'+p.coins+'&#x1FA99;</span>';
+        if(goals.length&&!p.finished)lh+='<span class="fb">'+p.collected+'/'+goals.length+'</span>';
+        if(laps&&!p.finished)lh+='<span class="fb" title="'+(p.bestLap?(p.bestLap/1000).toFixed(1)+'s':'')+'">'+p.laps+'/'+laps+'</span>';
+        else if(p.distance>=0)lh+='<div class="pb"><div style="width:'+Math.round(100*Math.max(0,1-p.distance/Math.max(pathLen,p.distance)))+'%;background:'+p.color+'"></div></div>';
+        lh+='</div>';
+    });
+    if(teams.length){
+        lh+='<h3>'+t('teams')+'</h3>';
+        teams.forEach(tm=>{
+            lh+='<div class="le"><div class="rk">'+tm.rank+'</div><div class="ld" style="background:'+tm.color+'"></div><span>'+tm.name+'</span>';
+            lh+='<span class="fb">'+(coinEnd?tm.coins+'&#x1FA99;':tm.finished+'/'+tm.members)+'</span></div>';
+        });
+    }
+    document.getElementById('lb').innerHTML=lh;
+
+    sorted.forEach(p=>{
+        if(p.finished||p.eliminated)return;
+        const px=p.x*CELL-camX,py=p.y*CELL-camY;
+        if(px<-CELL||px>VIEWW+CELL||py<-CELL||py>VIEWH+CELL)return;
+        ctx.fillStyle='rgba(0,0,0,0.4)';ctx.beginPath();ctx.ellipse(px+CELL/2,py+CELL-1,CELL/2-1,3,0,0,Math.PI*2);ctx.fill();
+        ctx.fillStyle=p.color;ctx.beginPath();ctx.arc(px+CELL/2,py+CELL/2,CELL/2-1,0,Math.PI*2);ctx.fill();
+        const tm=p.team&&teams.find(x=>x.name===p.team);
+        if(tm){ctx.strokeStyle=tm.color;ctx.lineWidth=2;ctx.stroke()}
+        ctx.fillStyle='rgba(255,255,255,0.2)';ctx.beginPath();ctx.arc(px+CELL/2-1,py+CELL/2-2,CELL/4,0,Math.PI*2);ctx.fill();
+        ctx.font='bold 9px system-ui';
+        const tw=ctx.measureText(p.name).width;
+        ctx.fillStyle='rgba(0,0,0,0.6)';
+        const tagX=px+CELL/2-tw/2-3,tagY=py-12;
+        ctx.fillRect(tagX,tagY,tw+6,12);
+        ctx.fillStyle='#eee';ctx.fillText(p.name,tagX+3,tagY+9);
+    });
+}
+
+function twin(x,y){
+    for(const [a,b] of portals){if(a.x===x&&a.y===y)return b;if(b.x===x&&b.y===y)return a}
+    return null
+}
+
+function dist(p){return p.distance>=0?p.distance:1e6}
+
+function send(){
+    if(practiceRun){practiceRun.path.push({x:myPlayer.x,y:myPlayer.y});return}
+    // seq lets the server tell a late move from a fresh one; states echo it as ack.
+    if(ws&&ws.readyState===1){myPlayer.seq=(myPlayer.seq||0)+1;ws.send(JSON.stringify(myPlayer))}
+}
+
+function showGameOver(players){
+    document.getElementById('go').style.display='flex';canvas.style.display='none';
+    const r=document.getElementById('frs');
+    // Runners who did not make it are ordered by how close they got.
+    const s=[...players].sort((a,b)=>(a.finished?a.finishRank:1e9+dist(a))-(b.finished?b.finishRank:1e9+dist(b)));
+    let h='';
+    s.forEach((p,i)=>{
+        const m=(i+1)+'.';
+        const ts=p.finishTime?Math.floor(p.finishTime/60)+':'+String(p.finishTime%60).padStart(2,'0'):'--';
+        const bumps='<div class="frb" title="'+t('wallHits')+'">'+(p.wallHits||0)+'&#x1F4A5;</div>'+(p.hintsUsed?'<div class="frb" title="'+t('hintsUsed')+'">'+p.hintsUsed+'?</div>':'')+(p.coins?'<div class="frb">'+p.coins+'&#x1FA99;</div>':'')+(p.assisted?'<div class="frb" title="'+t('assisted')+'">&#x1F91D;</div>':'');
+        const card=p.card?'<a class="frs" target="_blank" href="'+serverBase+p.card+'">'+t('share')+'</a>':'';
+        h+='<div class="fre"><div class="frn">'+m+'</div><div class="frc" style="background:'+p.color+'"></div><div class="frname">'+p.name+'</div><div class="frt">'+ts+'</div>'+bumps+card+'</div>';
+    });
+    teams.forEach(tm=>{
+        const ts=Math.floor(tm.totalTime/60)+':'+String(tm.totalTime%60).padStart(2,'0');
+        h+='<div class="fre"><div class="frn">'+tm.rank+'.</div><div class="frc" style="background:'+tm.color+'"></div><div class="frname">'+t('teams')+' '+tm.name+'</div><div class="frt">'+ts+'</div>'+(tm.coins?'<div class="frb">'+tm.coins+'&#x1FA99;</div>':'')+'</div>';
+    });
+    r.innerHTML=h;
+    // The all-time podium of this maze size, if the server keeps one. Names
+    // and colors were typed by players, so they only go in as text.
+    const hof=document.getElementById('hof');hof.textContent='';
+    if(hallOfFame.length){
+        const l=document.createElement('p');l.className='gs';l.dataset.i='hallOfFame';l.textContent=t('hallOfFame');hof.appendChild(l);
+        const fr=document.createElement('div');fr.className='fr';hof.appendChild(fr);
+        hallOfFame.forEach((e,i)=>{
+            const row=document.createElement('div');row.className='fre';fr.appendChild(row);
+            const cell=(cls,text)=>{const c=document.createElement('div');c.className=cls;c.textContent=text;row.appendChild(c);return c};
+            cell('frn',(i+1)+'.');
+            cell('frc','').style.background=e.color;
+            cell('frname',e.name);
+            cell('frt',Math.floor(e.time/60)+':'+String(e.time%60).padStart(2,'0'));
+        });
+    }
+    const hosting=players.some(p=>p.host&&p.name===myPlayer.name);
+    document.getElementById('nrb').style.display=hosting?'inline-block':'none';
+    document.getElementById('ivb').style.display=hosting&&privateGame?'inline-block':'none';
+    applyLang();
+}
+
+function backToMenu(){
+    if(ws)ws.close();clearInterval(timerInterval);
+    if(practiceRun)practiceRun.ghost.close();
+    practiceRun=null;
+    document.getElementById('go').style.display='none';canvas.style.display='none';
+    document.getElementById('lb').style.display='none';document.getElementById('tm').style.display='none';
+    document.getElementById('pc').style.display='none';document.getElementById('ui').style.display='block';
+    myPlayer={x:1,y:1,name:myPlayer.name,color:myPlayer.color,finished:false};gameEnded=false;
+}
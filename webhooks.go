@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+var flagWebhooks = flag.String("webhooks", "webhooks.json", "file holding the registered webhooks")
+
+// Webhook events.
+const (
+	hookRoundStart = "round-start"
+	hookFinish     = "finish"
+	hookRoundOver  = "round-over"
+	hookNewRecord  = "new-record"
+)
+
+var hookEvents = []string{hookRoundStart, hookFinish, hookRoundOver, hookNewRecord}
+
+// webhookAttempts and webhookBackoff control redelivery: a failed POST is
+// retried with the wait doubling each time.
+const (
+	webhookAttempts = 6
+	webhookBackoff  = 2 * time.Second
+)
+
+// Webhook is a URL that gets a JSON POST for each of its events (all of
+// them if Events is empty). With a secret the body is signed with
+// HMAC-SHA256 in the X-MazeRunner-Signature header.
+type Webhook struct {
+	Name    string    `json:"name"`
+	URL     string    `json:"url"`
+	Events  []string  `json:"events,omitempty"`
+	Secret  string    `json:"secret,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+func (h *Webhook) wants(event string) bool {
+	return len(h.Events) == 0 || slices.Contains(h.Events, event)
+}
+
+// webhookPayload is the body of every webhook POST.
+type webhookPayload struct {
+	Event string `json:"event"`
+	Time  int64  `json:"time"` // unix milliseconds
+	Data  any    `json:"data"`
+}
+
+var (
+	webhooksMu sync.Mutex
+	webhooks   []Webhook
+	hookClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+func loadWebhooks(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+	if err := json.Unmarshal(data, &webhooks); err != nil {
+		return err
+	}
+	log.Printf("Loaded %d webhook(s) from %s", len(webhooks), path)
+	return nil
+}
+
+// saveWebhooks writes the webhook list; the caller holds webhooksMu.
+func saveWebhooks() error {
+	data, err := json.MarshalIndent(webhooks, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*flagWebhooks, data, 0600)
+}
+
+// fireWebhooks delivers event to every webhook that wants it, and to
+// Discord, in the background.
+func fireWebhooks(event string, data any) {
+	notifyDiscord(event, data)
+	webhooksMu.Lock()
+	var targets []Webhook
+	for _, h := range webhooks {
+		if h.wants(event) {
+			targets = append(targets, h)
+		}
+	}
+	webhooksMu.Unlock()
+	if len(targets) == 0 {
+		return
+	}
+	body, err := json.Marshal(webhookPayload{Event: event, Time: time.Now().UnixMilli(), Data: data})
+	if err != nil {
+		log.Printf("Webhook payload for %s: %v", event, err)
+		return
+	}
+	for _, h := range targets {
+		go deliverWebhook(h, event, body)
+	}
+}
+
+// deliverWebhook POSTs body until the receiver answers 2xx or the attempts
+// run out.
+func deliverWebhook(h Webhook, event string, body []byte) {
+	wait := webhookBackoff
+	for attempt := 1; ; attempt++ {
+		err := postWebhook(h, event, body)
+		if err == nil {
+			return
+		}
+		if attempt == webhookAttempts {
+			log.Printf("Webhook %q gave up on %s after %d attempts: %v", h.Name, event, attempt, err)
+			return
+		}
+		time.Sleep(wait)
+		wait *= 2
+	}
+}
+
+func postWebhook(h Webhook, event string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-MazeRunner-Event", event)
+	if h.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(h.Secret))
+		mac.Write(body)
+		req.Header.Set("X-MazeRunner-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	resp, err := hookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %s", resp.Status)
+	}
+	return nil
+}
+
+// webhookRoundStart announces a new round.
+func webhookRoundStart() {
+	fireWebhooks(hookRoundStart, map[string]any{"seed": mazeSeed, "width": mazeWidth, "height": mazeHeight})
+}
+
+// handleWebhooks lists (GET), registers (POST {"name","url","events","secret"})
+// and removes (DELETE ?name=) webhooks. Secrets are never listed.
+func handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooksMu.Lock()
+	defer webhooksMu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		list := []Webhook{}
+		for _, h := range webhooks {
+			h.Secret = ""
+			list = append(list, h)
+		}
+		json.NewEncoder(w).Encode(list)
+	case http.MethodPost:
+		var h Webhook
+		if err := json.NewDecoder(r.Body).Decode(&h); err != nil || h.Name == "" || h.URL == "" {
+			http.Error(w, `want {"name": "...", "url": "https://...", "events": [...], "secret": "..."}`, http.StatusBadRequest)
+			return
+		}
+		if u, err := url.Parse(h.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			http.Error(w, "url must be an absolute http or https URL", http.StatusBadRequest)
+			return
+		}
+		for _, e := range h.Events {
+			if !slices.Contains(hookEvents, e) {
+				http.Error(w, fmt.Sprintf("unknown event %s, want one of %v", e, hookEvents), http.StatusBadRequest)
+				return
+			}
+		}
+		if slices.ContainsFunc(webhooks, func(o Webhook) bool { return o.Name == h.Name }) {
+			http.Error(w, "a webhook named "+h.Name+" already exists", http.StatusConflict)
+			return
+		}
+		h.Created = time.Now()
+		webhooks = append(webhooks, h)
+		if err := saveWebhooks(); err != nil {
+			log.Printf("Saving webhooks failed: %v", err)
+		}
+		log.Printf("Webhook %q registered for %s", h.Name, h.URL)
+		audit(r, "webhook-add", map[string]any{"name": h.Name, "url": h.URL, "events": h.Events})
+		h.Secret = ""
+		json.NewEncoder(w).Encode(h)
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		n := len(webhooks)
+		webhooks = slices.DeleteFunc(webhooks, func(h Webhook) bool { return h.Name == name })
+		if len(webhooks) == n {
+			http.Error(w, "no such webhook", http.StatusNotFound)
+			return
+		}
+		if err := saveWebhooks(); err != nil {
+			log.Printf("Saving webhooks failed: %v", err)
+		}
+		log.Printf("Webhook %q removed", name)
+		audit(r, "webhook-remove", map[string]any{"name": name})
+		json.NewEncoder(w).Encode(map[string]bool{"ok": true})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}